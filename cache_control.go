@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// CacheScope is the audience a cached response may be shared with
+type CacheScope string
+
+const (
+	// CacheScopePublic responses may be cached by shared caches
+	CacheScopePublic CacheScope = "PUBLIC"
+	// CacheScopePrivate responses may only be cached by the client
+	CacheScopePrivate CacheScope = "PRIVATE"
+)
+
+// CacheHint is the freshness of a field, Apollo style
+type CacheHint struct {
+	// MaxAge is the number of seconds the field may be cached
+	MaxAge int `json:"maxAge"`
+	// Scope defaults to CacheScopePublic
+	Scope CacheScope `json:"scope,omitempty"`
+}
+
+// CacheControlConfig declares the cache hints reported in the
+// extensions.cacheControl entry of responses, the Go counterpart of the
+// @cacheControl schema directive
+type CacheControlConfig struct {
+	// Hints maps "Type.field" to the hint of that field and "Type" to the
+	// hint of every field returning that type, field hints win
+	Hints map[string]CacheHint
+	// HTTPHeaders sets the Cache-Control header of responses to the policy
+	// all hints agree on: the lowest maxAge, private when any hint is
+	// private. Root fields and fields returning objects need a hint, or
+	// the response is not cacheable and no header is sent
+	HTTPHeaders bool
+}
+
+// SetCacheHint sets the hint of the field being resolved, replacing the
+// configured one, it has no effect unless Config.CacheControl is set
+func SetCacheHint(p graphql.ResolveParams, hint CacheHint) {
+	cc, ok := p.Context.Value(cacheControlKey{}).(*cacheControl)
+	if !ok || p.Info.Path == nil {
+		return
+	}
+	cc.set(p.Info.Path.AsArray(), hint)
+}
+
+// cacheHintPath is a hint applied to a response path
+type cacheHintPath struct {
+	Path   []interface{} `json:"path"`
+	MaxAge int           `json:"maxAge"`
+	Scope  CacheScope    `json:"scope,omitempty"`
+}
+
+// cacheControl collects the hints of one request
+type cacheControl struct {
+	mu    sync.Mutex
+	hints []cacheHintPath
+	// uncovered are the paths of fields that need a hint and have none
+	uncovered map[string]struct{}
+}
+
+type cacheControlKey struct{}
+
+func newCacheControl() *cacheControl {
+	return &cacheControl{uncovered: map[string]struct{}{}}
+}
+
+func (cc *cacheControl) add(path []interface{}, hint CacheHint) {
+	cc.mu.Lock()
+	cc.hints = append(cc.hints, cacheHintPath{Path: path, MaxAge: hint.MaxAge, Scope: hint.Scope})
+	cc.mu.Unlock()
+}
+
+func (cc *cacheControl) set(path []interface{}, hint CacheHint) {
+	key := fmt.Sprint(path)
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	delete(cc.uncovered, key)
+	for i := range cc.hints {
+		if fmt.Sprint(cc.hints[i].Path) == key {
+			cc.hints[i].MaxAge, cc.hints[i].Scope = hint.MaxAge, hint.Scope
+			return
+		}
+	}
+	cc.hints = append(cc.hints, cacheHintPath{Path: path, MaxAge: hint.MaxAge, Scope: hint.Scope})
+}
+
+func (cc *cacheControl) require(path []interface{}) {
+	cc.mu.Lock()
+	cc.uncovered[fmt.Sprint(path)] = struct{}{}
+	cc.mu.Unlock()
+}
+
+// header returns the Cache-Control value every hint allows, empty when the
+// response must not be cached
+func (cc *cacheControl) header() string {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if len(cc.uncovered) > 0 || len(cc.hints) == 0 {
+		return ""
+	}
+	maxAge, scope := cc.hints[0].MaxAge, CacheScopePublic
+	for _, hint := range cc.hints {
+		if hint.MaxAge < maxAge {
+			maxAge = hint.MaxAge
+		}
+		if hint.Scope == CacheScopePrivate {
+			scope = CacheScopePrivate
+		}
+	}
+	if maxAge <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("max-age=%d, %s", maxAge, strings.ToLower(string(scope)))
+}
+
+// cacheControlExtension records the configured hints of resolved fields
+type cacheControlExtension struct {
+	hints map[string]CacheHint
+}
+
+// newCacheControlExtension returns the graphql extension reporting cfg hints
+func newCacheControlExtension(cfg *CacheControlConfig) *cacheControlExtension {
+	return &cacheControlExtension{hints: cfg.Hints}
+}
+
+func (e *cacheControlExtension) Init(ctx context.Context, p *graphql.Params) context.Context {
+	if _, ok := ctx.Value(cacheControlKey{}).(*cacheControl); ok {
+		// the handler collects the hints to compute the HTTP header
+		return ctx
+	}
+	return context.WithValue(ctx, cacheControlKey{}, newCacheControl())
+}
+
+func (e *cacheControlExtension) Name() string {
+	return "cacheControl"
+}
+
+func (e *cacheControlExtension) ParseDidStart(ctx context.Context) (context.Context, graphql.ParseFinishFunc) {
+	return ctx, func(error) {}
+}
+
+func (e *cacheControlExtension) ValidationDidStart(ctx context.Context) (context.Context, graphql.ValidationFinishFunc) {
+	return ctx, func([]gqlerrors.FormattedError) {}
+}
+
+func (e *cacheControlExtension) ExecutionDidStart(ctx context.Context) (context.Context, graphql.ExecutionFinishFunc) {
+	return ctx, func(*graphql.Result) {}
+}
+
+func (e *cacheControlExtension) ResolveFieldDidStart(ctx context.Context, info *graphql.ResolveInfo) (context.Context, graphql.ResolveFieldFinishFunc) {
+	cc, ok := ctx.Value(cacheControlKey{}).(*cacheControl)
+	if ok && info.ParentType != nil {
+		hint, found := e.hints[info.ParentType.Name()+"."+info.FieldName]
+		if !found {
+			hint, found = e.hints[graphql.GetNamed(info.ReturnType).String()]
+		}
+		if found {
+			cc.add(info.Path.AsArray(), hint)
+		} else if needsCacheHint(info) {
+			cc.require(info.Path.AsArray())
+		}
+	}
+	return ctx, func(interface{}, error) {}
+}
+
+// needsCacheHint reports whether an unhinted field makes the response
+// uncacheable, scalar fields inherit the policy of their parent
+func needsCacheHint(info *graphql.ResolveInfo) bool {
+	for _, root := range []*graphql.Object{info.Schema.QueryType(), info.Schema.MutationType(), info.Schema.SubscriptionType()} {
+		if root != nil && root.Name() == info.ParentType.Name() {
+			return true
+		}
+	}
+	switch graphql.GetNamed(info.ReturnType).(type) {
+	case *graphql.Object, *graphql.Interface, *graphql.Union:
+		return true
+	}
+	return false
+}
+
+func (e *cacheControlExtension) HasResult() bool {
+	return true
+}
+
+func (e *cacheControlExtension) GetResult(ctx context.Context) interface{} {
+	cc, ok := ctx.Value(cacheControlKey{}).(*cacheControl)
+	if !ok {
+		return nil
+	}
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	hints := append([]cacheHintPath{}, cc.hints...)
+	return map[string]interface{}{
+		"version": 1,
+		"hints":   hints,
+	}
+}
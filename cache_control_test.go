@@ -0,0 +1,100 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_CacheControlExtension(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema: &testutil.StarWarsSchema,
+		CacheControl: &handler.CacheControlConfig{
+			Hints: map[string]handler.CacheHint{
+				"Query.hero": {MaxAge: 60},
+				"Character":  {MaxAge: 30, Scope: handler.CacheScopePrivate},
+			},
+		},
+	})
+	req, _ := http.NewRequest("GET", "/graphql?query={hero{name,friends{name}}}", nil)
+	result, _ := executeTest(t, h, req)
+
+	raw, _ := json.Marshal(result.Extensions["cacheControl"])
+	var cacheControl interface{}
+	_ = json.Unmarshal(raw, &cacheControl)
+	expected := map[string]interface{}{
+		"version": float64(1),
+		"hints": []interface{}{
+			map[string]interface{}{"path": []interface{}{"hero"}, "maxAge": float64(60)},
+			map[string]interface{}{"path": []interface{}{"hero", "friends"}, "maxAge": float64(30), "scope": "PRIVATE"},
+		},
+	}
+	if !reflect.DeepEqual(cacheControl, expected) {
+		t.Fatalf("wrong cache hints, diff: %v", testutil.Diff(expected, cacheControl))
+	}
+
+	// the caller's schema is not extended
+	plain := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema})
+	req, _ = http.NewRequest("GET", "/graphql?query={hero{name}}", nil)
+	result, _ = executeTest(t, plain, req)
+	if result.Extensions != nil {
+		t.Fatalf("unexpected extensions %v", result.Extensions)
+	}
+}
+
+func TestHandler_CacheControlHeader(t *testing.T) {
+	cfg := &handler.CacheControlConfig{
+		Hints: map[string]handler.CacheHint{
+			"Query.hero": {MaxAge: 60},
+			"Character":  {MaxAge: 30, Scope: handler.CacheScopePrivate},
+		},
+		HTTPHeaders: true,
+	}
+	h := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema, CacheControl: cfg})
+	cases := map[string]string{
+		// the lowest maxAge, private as one hint is
+		"{hero{name,friends{name}}}": "max-age=30, private",
+		"{hero{name}}":               "max-age=60, public",
+		// human has no hint
+		`{hero{name} human(id:"1000"){name}}`: "",
+	}
+	for query, expected := range cases {
+		req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape(query), nil)
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		if got := resp.Header().Get("Cache-Control"); got != expected {
+			t.Errorf("%s: expected Cache-Control %q, got %q", query, expected, got)
+		}
+	}
+}
+
+func TestSetCacheHint(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"now": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					handler.SetCacheHint(p, handler.CacheHint{MaxAge: 5})
+					return "now", nil
+				},
+			},
+		}}),
+	})
+	h := handler.New(&handler.Config{
+		Schema:       &schema,
+		CacheControl: &handler.CacheControlConfig{HTTPHeaders: true},
+	})
+	req, _ := http.NewRequest("GET", "/graphql?query={now}", nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if got := resp.Header().Get("Cache-Control"); got != "max-age=5, public" {
+		t.Fatalf("unexpected Cache-Control %q", got)
+	}
+}
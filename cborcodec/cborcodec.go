@@ -0,0 +1,47 @@
+// Package cborcodec encodes GraphQL requests and responses as CBOR
+// (application/cbor), see handler.Config.Codecs
+package cborcodec
+
+import (
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// ContentType is the media type of the codec
+const ContentType = "application/cbor"
+
+// Codec is the CBOR handler.Codec
+type Codec struct {
+	enc cbor.EncMode
+	dec cbor.DecMode
+}
+
+// New returns the CBOR codec, nested maps decode with string keys like
+// JSON objects do
+func New() *Codec {
+	enc, err := cbor.EncOptions{}.EncMode()
+	if err != nil {
+		panic(err)
+	}
+	dec, err := cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]interface{}(nil))}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	return &Codec{enc: enc, dec: dec}
+}
+
+// ContentType implements handler.Codec
+func (c *Codec) ContentType() string {
+	return ContentType
+}
+
+// Marshal implements handler.Codec
+func (c *Codec) Marshal(v interface{}) ([]byte, error) {
+	return c.enc.Marshal(v)
+}
+
+// Unmarshal implements handler.Codec
+func (c *Codec) Unmarshal(data []byte, v interface{}) error {
+	return c.dec.Unmarshal(data, v)
+}
@@ -0,0 +1,60 @@
+package cborcodec_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/cxuhua/handler/cborcodec"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestCodec(t *testing.T) {
+	h := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema, Codecs: []handler.Codec{cborcodec.New()}})
+	body, _ := cbor.Marshal(map[string]interface{}{
+		"query":     "query($id: String!) { human(id: $id) { name } }",
+		"variables": map[string]interface{}{"id": "1000"},
+	})
+	req, _ := http.NewRequest("POST", "/graphql", bytes.NewReader(body))
+	req.Header.Set("Content-Type", cborcodec.ContentType)
+	req.Header.Set("Accept", cborcodec.ContentType)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Header().Get("Content-Type") != cborcodec.ContentType {
+		t.Fatalf("unexpected content type %q", resp.Header().Get("Content-Type"))
+	}
+	var result struct {
+		Data struct {
+			Human struct {
+				Name string `cbor:"name"`
+			} `cbor:"human"`
+		} `cbor:"data"`
+		Errors []interface{} `cbor:"errors"`
+	}
+	if err := cbor.Unmarshal(resp.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Data.Human.Name != "Luke Skywalker" || len(result.Errors) != 0 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestCodec_Errors(t *testing.T) {
+	h := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema, Codecs: []handler.Codec{cborcodec.New()}})
+	req, _ := http.NewRequest("POST", "/graphql", bytes.NewReader([]byte{0xff}))
+	req.Header.Set("Content-Type", cborcodec.ContentType)
+	req.Header.Set("Accept", cborcodec.ContentType)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	var result map[string]interface{}
+	if err := cborcodec.New().Unmarshal(resp.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	errs, _ := result["errors"].([]interface{})
+	if resp.Code != http.StatusBadRequest || len(errs) != 1 {
+		t.Fatalf("unexpected response %d %v", resp.Code, result)
+	}
+}
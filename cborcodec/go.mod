@@ -0,0 +1,13 @@
+module github.com/cxuhua/handler/cborcodec
+
+go 1.18
+
+replace github.com/cxuhua/handler => ../
+
+require (
+	github.com/cxuhua/handler v0.0.0-00010101000000-000000000000
+	github.com/fxamacker/cbor/v2 v2.5.0
+	github.com/graphql-go/graphql v0.7.9
+)
+
+require github.com/x448/float16 v0.8.4 // indirect
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+)
+
+// The headers Apollo clients identify themselves with
+const (
+	ClientNameHeader    = "apollographql-client-name"
+	ClientVersionHeader = "apollographql-client-version"
+)
+
+// ClientInfo identifies the client application sending a request
+type ClientInfo struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+type clientInfoKey struct{}
+
+// ParseClientInfo returns the client r identifies through its headers
+func ParseClientInfo(r *http.Request) ClientInfo {
+	return ClientInfo{
+		Name:    r.Header.Get(ClientNameHeader),
+		Version: r.Header.Get(ClientVersionHeader),
+	}
+}
+
+// RequestClient returns the client of the request ctx belongs to, empty
+// when it did not identify itself
+func RequestClient(ctx context.Context) ClientInfo {
+	client, _ := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return client
+}
+
+// withClientInfo stores the client r identifies in ctx
+func withClientInfo(ctx context.Context, r *http.Request) context.Context {
+	client := ParseClientInfo(r)
+	if client == (ClientInfo{}) {
+		return ctx
+	}
+	return context.WithValue(ctx, clientInfoKey{}, client)
+}
@@ -0,0 +1,45 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_ClientInfo(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"client": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				client := handler.RequestClient(p.Context)
+				return client.Name + "@" + client.Version, nil
+			}},
+		}}),
+	})
+	var reported handler.ClientInfo
+	h := handler.New(&handler.Config{
+		Schema: &schema,
+		SlowQueryFn: func(ctx context.Context, info handler.SlowQueryInfo) {
+			reported = info.Client
+		},
+	})
+	req, _ := http.NewRequest("GET", "/graphql?query={client}", nil)
+	req.Header.Set("apollographql-client-name", "ios")
+	req.Header.Set("apollographql-client-version", "1.2.0")
+	result, _ := executeTest(t, h, req)
+	if !reflect.DeepEqual(result.Data, map[string]interface{}{"client": "ios@1.2.0"}) {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if reported != (handler.ClientInfo{Name: "ios", Version: "1.2.0"}) {
+		t.Fatalf("unexpected client %+v", reported)
+	}
+
+	req, _ = http.NewRequest("GET", "/graphql?query={client}", nil)
+	executeTest(t, h, req)
+	if reported != (handler.ClientInfo{}) {
+		t.Fatalf("unexpected client %+v", reported)
+	}
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// CoalesceConfig shares the execution of identical queries arriving while
+// one runs: same normalized document, variables, route parameters and
+// session. Mutations and subscriptions always execute
+type CoalesceConfig struct {
+	// SessionKey separates callers seeing different data, e.g. the
+	// authenticated user, nil shares the executions between all. It is
+	// required along with Config.ContextFn or Config.AuthFn
+	SessionKey func(ctx context.Context, r *http.Request) string
+}
+
+// coalescer runs one execution per key at a time
+type coalescer struct {
+	sessionKey func(ctx context.Context, r *http.Request) string
+
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// flight is an execution the requests with its key wait for
+type flight struct {
+	done   chan struct{}
+	result *graphql.Result
+	cc     *cacheControl
+	fw     *fieldWarnings
+	// ok is false when the execution panicked or its request went away,
+	// the waiting requests then execute on their own
+	ok bool
+}
+
+func newCoalescer(cfg *CoalesceConfig) *coalescer {
+	if cfg == nil {
+		return nil
+	}
+	return &coalescer{sessionKey: cfg.SessionKey, flights: map[string]*flight{}}
+}
+
+// do executes params with run unless an execution with the key of opts is
+// running, in which case its result is shared. The cache hints and field
+// warnings of the execution are returned along, replacing cc and fw
+func (c *coalescer) do(r *http.Request, opts *RequestOptions, scope string, params graphql.Params, cc *cacheControl, fw *fieldWarnings, run func(graphql.Params) *graphql.Result) (*graphql.Result, *cacheControl, *fieldWarnings) {
+	ctx := params.Context
+	key := queryKey(ctx, r, opts, scope, c.sessionKey)
+	if key == "" {
+		return run(params), cc, fw
+	}
+	c.mu.Lock()
+	if f, ok := c.flights[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			return &graphql.Result{Errors: gqlerrors.FormatErrors(ctx.Err())}, cc, fw
+		}
+		if !f.ok {
+			return run(params), cc, fw
+		}
+		return f.share(), f.cc, f.fw
+	}
+	f := &flight{done: make(chan struct{}), cc: cc, fw: fw}
+	c.flights[key] = f
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.flights, key)
+		c.mu.Unlock()
+		close(f.done)
+	}()
+	f.result = run(params)
+	f.ok = ctx.Err() == nil
+	return f.share(), cc, fw
+}
+
+// share copies the result of f, the requests sharing it format their errors
+// and extensions and post-process their data on their own
+func (f *flight) share() *graphql.Result {
+	result := *f.result
+	result.Data = copyData(f.result.Data)
+	result.Errors = append([]gqlerrors.FormattedError(nil), f.result.Errors...)
+	if f.result.Extensions != nil {
+		result.Extensions = make(map[string]interface{}, len(f.result.Extensions))
+		for k, v := range f.result.Extensions {
+			result.Extensions[k] = v
+		}
+	}
+	return &result
+}
+
+// copyData deep copies the objects and lists of resolved data, leaf values
+// are shared
+func copyData(data interface{}) interface{} {
+	switch data := data.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(data))
+		for k, v := range data {
+			copied[k] = copyData(v)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(data))
+		for i, v := range data {
+			copied[i] = copyData(v)
+		}
+		return copied
+	}
+	return data
+}
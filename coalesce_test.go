@@ -0,0 +1,169 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_Coalesce(t *testing.T) {
+	var calls int32
+	started, release := make(chan struct{}, 10), make(chan struct{})
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"count": &graphql.Field{
+				Type: graphql.Int,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					n := atomic.AddInt32(&calls, 1)
+					started <- struct{}{}
+					<-release
+					return n, nil
+				},
+			},
+		}}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := handler.New(&handler.Config{
+		Schema: &schema,
+		Coalesce: &handler.CoalesceConfig{
+			SessionKey: func(ctx context.Context, r *http.Request) string {
+				return r.Header.Get("Authorization")
+			},
+		},
+	})
+	do := func(query, session string) interface{} {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(query))
+		req.Header.Set("Content-Type", "application/graphql")
+		req.Header.Set("Authorization", session)
+		result, _ := executeTest(t, h, req)
+		return result.Data.(map[string]interface{})["count"]
+	}
+
+	var wg sync.WaitGroup
+	results := make([]interface{}, 4)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[0] = do("{count}", "a")
+	}()
+	<-started
+	// equivalent documents of the same session wait for the running one
+	for i := 1; i < len(results); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = do("query {\n  count\n}", "a")
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	for i, got := range results {
+		if got != float64(1) {
+			t.Fatalf("request %d got %v", i, got)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected one execution, got %d", calls)
+	}
+	// the finished execution is not reused, other sessions execute on their own
+	if got := do("{count}", "a"); got != float64(2) {
+		t.Fatalf("unexpected count %v", got)
+	}
+	if got := do("{count}", "b"); got != float64(3) {
+		t.Fatalf("unexpected count %v", got)
+	}
+}
+
+func TestHandler_Coalesce_ResultFn(t *testing.T) {
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"user": &graphql.Field{
+				Type: graphql.NewObject(graphql.ObjectConfig{Name: "User", Fields: graphql.Fields{
+					"name":  &graphql.Field{Type: graphql.String},
+					"email": &graphql.Field{Type: graphql.String},
+				}}),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					started <- struct{}{}
+					<-release
+					return map[string]interface{}{"name": "Luke", "email": "luke@tatooine"}, nil
+				},
+			},
+		}}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := handler.New(&handler.Config{
+		Schema: &schema,
+		// the callers share the execution, one redacts its copy
+		Coalesce: &handler.CoalesceConfig{
+			SessionKey: func(ctx context.Context, r *http.Request) string { return "staff" },
+		},
+		ResultFn: func(ctx context.Context, params *graphql.Params, result *graphql.Result) *graphql.Result {
+			if redact, _ := params.Context.Value(redactKey{}).(bool); redact {
+				user := result.Data.(map[string]interface{})["user"].(map[string]interface{})
+				user["email"] = "redacted"
+			}
+			return result
+		},
+		ContextFn: func(ctx context.Context, r *http.Request) (context.Context, error) {
+			return context.WithValue(ctx, redactKey{}, r.Header.Get("X-Redact") != ""), nil
+		},
+	})
+	do := func(redact bool) interface{} {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader("{user{name email}}"))
+		req.Header.Set("Content-Type", "application/graphql")
+		if redact {
+			req.Header.Set("X-Redact", "1")
+		}
+		result, _ := executeTest(t, h, req)
+		return result.Data.(map[string]interface{})["user"].(map[string]interface{})["email"]
+	}
+
+	var wg sync.WaitGroup
+	emails := make([]interface{}, 2)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		emails[0] = do(false)
+	}()
+	<-started
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		emails[1] = do(true)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if emails[0] != "luke@tatooine" || emails[1] != "redacted" {
+		t.Fatalf("expected the redaction of one caller only, got %v", emails)
+	}
+}
+
+type redactKey struct{}
+
+func TestHandler_Coalesce_SessionKey(t *testing.T) {
+	_, err := handler.NewWithError(&handler.Config{
+		Schema:   &testutil.StarWarsSchema,
+		Coalesce: &handler.CoalesceConfig{},
+		AuthFn: func(ctx context.Context, r *http.Request, opts *handler.RequestOptions) (context.Context, error) {
+			return ctx, nil
+		},
+	})
+	if _, ok := err.(*handler.ConfigError); !ok {
+		t.Fatalf("expected a *ConfigError, got %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+)
+
+// Codec encodes responses and decodes request bodies of a media type other
+// than JSON, requests select it through their Content-Type and Accept headers
+type Codec interface {
+	// ContentType is the media type of the codec, e.g. "application/msgpack"
+	ContentType() string
+	// Marshal encodes the value ResultValue returns for a result
+	Marshal(v interface{}) ([]byte, error)
+	// Unmarshal decodes a request body into a *map[string]interface{}
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// ResultValue returns result as the maps, slices and scalars its JSON form
+// decodes to, for codecs unaware of the graphql types
+func ResultValue(result *graphql.Result) map[string]interface{} {
+	out := map[string]interface{}{}
+	if result.Data != nil {
+		out["data"] = result.Data
+	}
+	if len(result.Errors) > 0 {
+		errs := make([]interface{}, len(result.Errors))
+		for i, err := range result.Errors {
+			locations := make([]interface{}, len(err.Locations))
+			for j, location := range err.Locations {
+				locations[j] = map[string]interface{}{"line": location.Line, "column": location.Column}
+			}
+			formatted := map[string]interface{}{"message": err.Message, "locations": locations}
+			if len(err.Path) > 0 {
+				formatted["path"] = err.Path
+			}
+			if len(err.Extensions) > 0 {
+				formatted["extensions"] = err.Extensions
+			}
+			errs[i] = formatted
+		}
+		out["errors"] = errs
+	}
+	if len(result.Extensions) > 0 {
+		out["extensions"] = result.Extensions
+	}
+	return out
+}
+
+// requestCodec returns the codec decoding bodies of contentType, nil for JSON
+func (h *Handler) requestCodec(contentType string) Codec {
+	for _, codec := range h.codecs {
+		if codec.ContentType() == contentType {
+			return codec
+		}
+	}
+	return nil
+}
+
+// DecodeRequestBody decodes a POST body of contentType with the Codec of
+// that media type, as JSON when no Codec has it. Adapters reading bodies
+// themselves decode them with it
+func (h *Handler) DecodeRequestBody(contentType string, body []byte) (*RequestOptions, error) {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mediaType
+	}
+	if codec := h.requestCodec(contentType); codec != nil {
+		return decodeRequest(codec, body)
+	}
+	return getFromJSON(body, false)
+}
+
+// responseCodec returns the codec r accepts its response in, nil for JSON
+func (h *Handler) responseCodec(r *http.Request) Codec {
+	if len(h.codecs) == 0 {
+		return nil
+	}
+	type accepted struct {
+		mediaType string
+		q         float64
+	}
+	var ranges []accepted
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, accepted{mediaType, q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+	for _, accepted := range ranges {
+		if accepted.mediaType == ContentTypeJSON || accepted.mediaType == "*/*" {
+			return nil
+		}
+		if codec := h.requestCodec(accepted.mediaType); codec != nil {
+			return codec
+		}
+	}
+	return nil
+}
+
+// decodeRequest decodes a request body with codec
+func decodeRequest(codec Codec, body []byte) (*RequestOptions, error) {
+	var fields map[string]interface{}
+	if err := codec.Unmarshal(body, &fields); err != nil {
+		return nil, fmt.Errorf("invalid %s body: %v", codec.ContentType(), err)
+	}
+	opts := &RequestOptions{}
+	opts.Query, _ = fields["query"].(string)
+	opts.OperationName, _ = fields["operationName"].(string)
+	var err error
+	if opts.Variables, err = objectField("variables", fields["variables"]); err != nil {
+		return nil, err
+	}
+	if opts.Extensions, err = objectField("extensions", fields["extensions"]); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// objectField returns a decoded map field, strings are decoded as JSON like
+// the variables of JSON requests
+func objectField(name string, value interface{}) (map[string]interface{}, error) {
+	switch value := value.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		return value, nil
+	case string:
+		return decodeObject(name, []byte(value), nil)
+	}
+	return nil, fmt.Errorf("invalid %s: %T is not an object", name, value)
+}
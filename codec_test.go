@@ -0,0 +1,57 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+// prefixCodec is JSON behind a marker, telling codec bodies apart
+type prefixCodec struct{}
+
+func (prefixCodec) ContentType() string { return "application/x-prefixed" }
+
+func (prefixCodec) Marshal(v interface{}) ([]byte, error) {
+	buff, err := json.Marshal(v)
+	return append([]byte("P:"), buff...), err
+}
+
+func (prefixCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(bytes.TrimPrefix(data, []byte("P:")), v)
+}
+
+func TestHandler_Codecs(t *testing.T) {
+	h := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema, Codecs: []handler.Codec{prefixCodec{}}})
+	body := `P:{"query": "query($id: String!) { human(id: $id) { name } }", "variables": {"id": "1000"}}`
+	req, _ := http.NewRequest("POST", "/graphql", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/x-prefixed")
+	req.Header.Set("Accept", "application/json;q=0.5, application/x-prefixed")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Header().Get("Content-Type") != "application/x-prefixed" || resp.Header().Get("Vary") != "Accept" {
+		t.Fatalf("unexpected headers %v", resp.Header())
+	}
+	var result map[string]interface{}
+	if err := (prefixCodec{}).Unmarshal(resp.Body.Bytes(), &result); err != nil {
+		t.Fatalf("unexpected body %s", resp.Body.String())
+	}
+	expected := map[string]interface{}{"data": map[string]interface{}{"human": map[string]interface{}{"name": "Luke Skywalker"}}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("unexpected result %v", result)
+	}
+
+	// JSON stays the default
+	req, _ = http.NewRequest("GET", "/graphql?query={hero{name}}", nil)
+	req.Header.Set("Accept", "*/*")
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Fatalf("unexpected content type %q", resp.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// The Config fields Playground, RootObjectFn, ResultCallbackFn and
+// FormatErrorFn mirror github.com/graphql-go/handler v0.2.x, projects using
+// it switch to this package by changing the import path only.
+
+// RootObjectFn generates the RootObject of a request, it is the upstream
+// counterpart of EntryFn
+type RootObjectFn func(ctx context.Context, r *http.Request, opts *RequestOptions) map[string]interface{}
+
+// ResultCallbackFn is called with the result of an operation once it is
+// written, responseBody is the serialized result
+type ResultCallbackFn func(ctx context.Context, params *graphql.Params, result *graphql.Result, responseBody []byte)
+
+// FormatErrorFn formats the errors of results, err is the error the
+// execution reported
+type FormatErrorFn func(err error) gqlerrors.FormattedError
+
+// entryFromRootObject adapts fn to an EntryFn
+func entryFromRootObject(fn RootObjectFn) EntryFn {
+	return func(ctx context.Context, r *http.Request, opts *RequestOptions) (map[string]interface{}, error) {
+		return fn(ctx, r, opts), nil
+	}
+}
+
+// formatErrors replaces the errors of result with their formatErrorFn form
+func (h *Handler) formatErrors(result *graphql.Result) {
+	if h.formatErrorFn == nil || len(result.Errors) == 0 {
+		return
+	}
+	formatted := make([]gqlerrors.FormattedError, len(result.Errors))
+	for i, err := range result.Errors {
+		original := err.OriginalError()
+		if original == nil {
+			original = err
+		}
+		formatted[i] = h.formatErrorFn(original)
+	}
+	result.Errors = formatted
+}
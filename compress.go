@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Compressor compresses the bodies of the responses to clients listing its
+// encoding in their Accept-Encoding header, see the zstdcompress module
+// for zstd with dictionaries per operation
+type Compressor interface {
+	// Encoding is the Content-Encoding of the compressed bodies, e.g. "zstd"
+	Encoding() string
+	// Compress compresses the response to the operation whose document
+	// hashes to operation, the sha256 hex digest OperationRegistry.Register
+	// returns, empty when the request has none. A nil body leaves the
+	// response uncompressed
+	Compress(operation string, body []byte) ([]byte, error)
+}
+
+// operationHashKey is the context key of the hash of the operation
+type operationHashKey struct{}
+
+// withOperationHash returns ctx carrying the hash of the document of opts
+func withOperationHash(ctx context.Context, opts *RequestOptions) context.Context {
+	if opts.Query == "" {
+		return ctx
+	}
+	sum := sha256.Sum256([]byte(opts.Query))
+	return context.WithValue(ctx, operationHashKey{}, hex.EncodeToString(sum[:]))
+}
+
+// compress returns the body written for buff, compressed when r accepts the
+// encoding of the Compressor
+func (h *Handler) compress(ctx context.Context, w http.ResponseWriter, r *http.Request, buff []byte) []byte {
+	w.Header().Add("Vary", "Accept-Encoding")
+	if !acceptsEncoding(r, h.compressor.Encoding()) {
+		return buff
+	}
+	operation, _ := ctx.Value(operationHashKey{}).(string)
+	compressed, err := h.compressor.Compress(operation, buff)
+	if err != nil {
+		h.logger.WarnContext(ctx, "compressing the response failed", requestAttrs(r, "error", err)...)
+		return buff
+	}
+	if compressed == nil {
+		return buff
+	}
+	w.Header().Set("Content-Encoding", h.compressor.Encoding())
+	return compressed
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header of r lists
+// encoding with a non-zero quality
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || !strings.EqualFold(coding, encoding) {
+			continue
+		}
+		if q, ok := params["q"]; ok {
+			if value, err := strconv.ParseFloat(q, 64); err != nil || value <= 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
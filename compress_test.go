@@ -0,0 +1,46 @@
+package handler_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+// markCompressor marks the bodies it compresses with the operation
+type markCompressor struct{}
+
+func (markCompressor) Encoding() string { return "x-mark" }
+
+func (markCompressor) Compress(operation string, body []byte) ([]byte, error) {
+	return append([]byte(operation+":"), body...), nil
+}
+
+func TestHandler_Compressor(t *testing.T) {
+	operations := handler.NewOperationRegistry(false)
+	hash := operations.MustRegister("Hero", "query Hero { hero { name } }")
+	h := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema, Operations: operations, Compressor: markCompressor{}})
+	do := func(acceptEncoding string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/graphql?"+url.Values{"operationName": {"Hero"}}.Encode(), nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+	resp := do("gzip, x-mark;q=0.5")
+	if resp.Header().Get("Content-Encoding") != "x-mark" || resp.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("unexpected headers %v", resp.Header())
+	}
+	if !bytes.HasPrefix(resp.Body.Bytes(), []byte(hash+":{")) {
+		t.Fatalf("expected the body compressed for %s, got %s", hash, resp.Body)
+	}
+	for _, acceptEncoding := range []string{"", "gzip", "x-mark;q=0"} {
+		if resp := do(acceptEncoding); resp.Header().Get("Content-Encoding") != "" || !bytes.HasPrefix(resp.Body.Bytes(), []byte("{")) {
+			t.Fatalf("unexpected response for %q: %v %s", acceptEncoding, resp.Header(), resp.Body)
+		}
+	}
+}
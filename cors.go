@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig lets browsers on other origins send operations, the handler
+// answers their preflight requests and tags its responses
+type CORSConfig struct {
+	// AllowedOrigins are the origins allowed, "*" allows any
+	AllowedOrigins []string
+	// AllowedHeaders are the request headers allowed, nil allows the
+	// headers preflights ask for
+	AllowedHeaders []string
+	// AllowCredentials lets requests carry cookies and authorization
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+}
+
+// allowOrigin reports whether origin may send requests
+func (c *CORSConfig) allowOrigin(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// setHeaders tags the response to r for its origin, it reports whether r
+// is a preflight the response answers
+func (c *CORSConfig) setHeaders(w http.ResponseWriter, r *http.Request) bool {
+	header := w.Header()
+	header.Add("Vary", "Origin")
+	origin := r.Header.Get("Origin")
+	if origin == "" || !c.allowOrigin(origin) {
+		return false
+	}
+	header.Set("Access-Control-Allow-Origin", origin)
+	if c.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+		return false
+	}
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	header.Set("Access-Control-Allow-Methods", allowedMethods)
+	if c.AllowedHeaders != nil {
+		header.Set("Access-Control-Allow-Headers", strings.Join(c.AllowedHeaders, ", "))
+	} else if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+		header.Set("Access-Control-Allow-Headers", requested)
+	}
+	if c.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+	}
+	return true
+}
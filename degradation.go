@@ -0,0 +1,234 @@
+package handler
+
+import (
+	"container/list"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+const (
+	// DefaultDegradationCacheSize bounds the values a Degrader keeps for
+	// the CacheOnly fields
+	DefaultDegradationCacheSize = 1000
+	// DefaultDegradationCacheTTL is how long a Degrader serves the kept
+	// value of a CacheOnly field
+	DefaultDegradationCacheTTL = 10 * time.Minute
+)
+
+// DegradationProfile designates the fields that stop calling their
+// resolvers while the profile is active
+type DegradationProfile struct {
+	// Fallbacks maps "Type.field" to the value the field resolves to
+	Fallbacks map[string]interface{}
+	// CacheOnly lists "Type.field"s resolving to the last value their
+	// resolver returned for the same arguments, or their fallback when none
+	CacheOnly []string
+	// Trigger activates the profile automatically while it returns true,
+	// it runs whenever a designated field resolves and must be cheap
+	Trigger func() bool
+}
+
+// Degrader switches degradation profiles on and off, activate them with
+// Activate or through its admin endpoint
+type Degrader struct {
+	Profiles map[string]*DegradationProfile
+	// CacheSize bounds the kept values of the CacheOnly fields, the least
+	// recently used go first. DefaultDegradationCacheSize when zero
+	CacheSize int
+	// CacheTTL is how long a kept value is served, DefaultDegradationCacheTTL
+	// when zero
+	CacheTTL time.Duration
+
+	mu     sync.RWMutex
+	active map[string]bool
+
+	// cached keeps the last values of the CacheOnly fields
+	cachedMu sync.Mutex
+	order    *list.List
+	cached   map[string]*list.Element
+}
+
+type degradedEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+// NewDegrader returns a Degrader of profiles, all inactive
+func NewDegrader(profiles map[string]*DegradationProfile) *Degrader {
+	return &Degrader{Profiles: profiles}
+}
+
+// Activate turns the named profile on, it reports false for unknown profiles
+func (d *Degrader) Activate(name string) bool {
+	if _, ok := d.Profiles[name]; !ok {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.active == nil {
+		d.active = map[string]bool{}
+	}
+	d.active[name] = true
+	return true
+}
+
+// Deactivate turns the named profile off, its trigger may still activate it
+func (d *Degrader) Deactivate(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.active, name)
+}
+
+// Active returns the names of the active profiles
+func (d *Degrader) Active() []string {
+	names := []string{}
+	for name, profile := range d.Profiles {
+		if d.isActive(name, profile) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (d *Degrader) isActive(name string, profile *DegradationProfile) bool {
+	d.mu.RLock()
+	active := d.active[name]
+	d.mu.RUnlock()
+	return active || (profile.Trigger != nil && profile.Trigger())
+}
+
+// degraded returns the value overriding the resolver of coordinate, ok
+// is false when no active profile designates it
+func (d *Degrader) degraded(coordinate string, args map[string]interface{}) (value interface{}, ok bool) {
+	for name, profile := range d.Profiles {
+		fallback, hasFallback := profile.Fallbacks[coordinate]
+		cacheOnly := false
+		for _, c := range profile.CacheOnly {
+			cacheOnly = cacheOnly || c == coordinate
+		}
+		if (!hasFallback && !cacheOnly) || !d.isActive(name, profile) {
+			continue
+		}
+		if cacheOnly {
+			if cached, found := d.load(cacheOnlyKey(coordinate, args)); found {
+				return cached, true
+			}
+		}
+		return fallback, true
+	}
+	return nil, false
+}
+
+// load returns the kept value of key unless it expired
+func (d *Degrader) load(key string) (interface{}, bool) {
+	d.cachedMu.Lock()
+	defer d.cachedMu.Unlock()
+	el, ok := d.cached[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*degradedEntry)
+	if time.Now().After(entry.expires) {
+		d.order.Remove(el)
+		delete(d.cached, key)
+		return nil, false
+	}
+	d.order.MoveToFront(el)
+	return entry.value, true
+}
+
+// store keeps value for key, evicting the least recently used values
+// beyond CacheSize
+func (d *Degrader) store(key string, value interface{}) {
+	size, ttl := d.CacheSize, d.CacheTTL
+	if size <= 0 {
+		size = DefaultDegradationCacheSize
+	}
+	if ttl <= 0 {
+		ttl = DefaultDegradationCacheTTL
+	}
+	d.cachedMu.Lock()
+	defer d.cachedMu.Unlock()
+	if d.cached == nil {
+		d.order, d.cached = list.New(), map[string]*list.Element{}
+	}
+	if el, ok := d.cached[key]; ok {
+		entry := el.Value.(*degradedEntry)
+		entry.value, entry.expires = value, time.Now().Add(ttl)
+		d.order.MoveToFront(el)
+		return
+	}
+	d.cached[key] = d.order.PushFront(&degradedEntry{key: key, value: value, expires: time.Now().Add(ttl)})
+	for d.order.Len() > size {
+		oldest := d.order.Back()
+		d.order.Remove(oldest)
+		delete(d.cached, oldest.Value.(*degradedEntry).key)
+	}
+}
+
+func cacheOnlyKey(coordinate string, args map[string]interface{}) string {
+	buff, _ := json.Marshal(args)
+	return coordinate + string(buff)
+}
+
+// designated reports whether any profile designates coordinate, and
+// whether its values have to be cached
+func (d *Degrader) designated(coordinate string) (designated, cacheOnly bool) {
+	for _, profile := range d.Profiles {
+		if _, ok := profile.Fallbacks[coordinate]; ok {
+			designated = true
+		}
+		for _, c := range profile.CacheOnly {
+			if c == coordinate {
+				designated, cacheOnly = true, true
+			}
+		}
+	}
+	return designated, cacheOnly
+}
+
+// wrap returns the resolver of the field at coordinate, resolve is
+// returned as is when no profile designates the field
+func (d *Degrader) wrap(coordinate string, resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	designated, cacheOnly := d.designated(coordinate)
+	if !designated {
+		return resolve
+	}
+	if resolve == nil {
+		resolve = graphql.DefaultResolveFn
+	}
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		if value, ok := d.degraded(coordinate, p.Args); ok {
+			return value, nil
+		}
+		value, err := resolve(p)
+		if cacheOnly && err == nil {
+			d.store(cacheOnlyKey(coordinate, p.Args), value)
+		}
+		return value, err
+	}
+}
+
+// ServeHTTP lists the active profiles, POST ?activate=name or
+// ?deactivate=name switches a profile
+func (d *Degrader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		if name := r.URL.Query().Get("activate"); name != "" && !d.Activate(name) {
+			http.Error(w, "unknown profile "+name, http.StatusNotFound)
+			return
+		}
+		if name := r.URL.Query().Get("deactivate"); name != "" {
+			d.Deactivate(name)
+		}
+	}
+	buff, _ := json.Marshal(map[string]interface{}{"active": d.Active()})
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, _ = w.Write(buff)
+}
@@ -0,0 +1,155 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+)
+
+func TestDegrader(t *testing.T) {
+	calls := 0
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"recommendations": &graphql.Field{
+				Type: graphql.NewList(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					calls++
+					return []interface{}{"fresh"}, nil
+				},
+			},
+			"price": &graphql.Field{
+				Type: graphql.Int,
+				Args: graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.Int}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					calls++
+					return p.Args["id"].(int) * 10, nil
+				},
+			},
+		}}),
+	})
+	degrader := handler.NewDegrader(map[string]*handler.DegradationProfile{
+		"no-recommendations": {Fallbacks: map[string]interface{}{"Query.recommendations": []interface{}{}}},
+		"cache-only":         {CacheOnly: []string{"Query.price"}},
+	})
+	h := handler.New(&handler.Config{Schema: &schema, Degrader: degrader})
+	do := func(query string) string {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(query))
+		req.Header.Set("Content-Type", "application/graphql")
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return strings.Join(strings.Fields(resp.Body.String()), "")
+	}
+
+	if got := do("{recommendations a: price(id: 1)}"); got != `{"data":{"a":10,"recommendations":["fresh"]}}` {
+		t.Fatalf("unexpected response %s", got)
+	}
+	// the admin endpoint switches profiles
+	admin := httptest.NewRecorder()
+	degrader.ServeHTTP(admin, httptest.NewRequest("POST", "/admin/degrade?activate=no-recommendations", nil))
+	if !degrader.Activate("cache-only") || degrader.Activate("unknown") {
+		t.Fatal("unexpected Activate result")
+	}
+	calls = 0
+	if got := do("{recommendations a: price(id: 1) b: price(id: 2)}"); got != `{"data":{"a":10,"b":null,"recommendations":[]}}` {
+		t.Fatalf("unexpected degraded response %s", got)
+	}
+	if calls != 0 {
+		t.Fatalf("degraded resolvers were called %d times", calls)
+	}
+	degrader.Deactivate("cache-only")
+	admin = httptest.NewRecorder()
+	degrader.ServeHTTP(admin, httptest.NewRequest("GET", "/admin/degrade", nil))
+	if got := admin.Body.String(); got != `{"active":["no-recommendations"]}` {
+		t.Fatalf("unexpected active profiles %s", got)
+	}
+}
+
+func TestDegrader_SetSchema(t *testing.T) {
+	wrappers := 0
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"count": &graphql.Field{
+				Type: graphql.Int,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					pc := make([]uintptr, 64)
+					frames := runtime.CallersFrames(pc[:runtime.Callers(1, pc)])
+					for {
+						frame, more := frames.Next()
+						if strings.Contains(frame.Function, "(*Degrader).wrap") {
+							wrappers++
+						}
+						if !more {
+							return wrappers, nil
+						}
+					}
+				},
+			},
+		}}),
+	})
+	degrader := handler.NewDegrader(map[string]*handler.DegradationProfile{
+		"cache-only": {CacheOnly: []string{"Query.count"}},
+	})
+	h := handler.New(&handler.Config{Schema: &schema, Degrader: degrader})
+	// preparing the same schema again does not wrap its resolvers twice
+	h.SetSchema(&schema)
+	h.SetSchema(&schema)
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader("{count}"))
+	req.Header.Set("Content-Type", "application/graphql")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if wrappers != 1 {
+		t.Fatalf("resolver wrapped %d times", wrappers)
+	}
+	// the caller's schema keeps its own resolvers
+	wrappers = 0
+	if result := graphql.Do(graphql.Params{Schema: schema, RequestString: "{count}"}); result.HasErrors() || wrappers != 0 {
+		t.Fatalf("expected the schema to be left alone, got %d wrappers %+v", wrappers, result)
+	}
+}
+
+func TestDegrader_CacheBounds(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"price": &graphql.Field{
+				Type: graphql.Int,
+				Args: graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.Int}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Args["id"].(int) * 10, nil
+				},
+			},
+		}}),
+	})
+	degrader := handler.NewDegrader(map[string]*handler.DegradationProfile{
+		"cache-only": {CacheOnly: []string{"Query.price"}},
+	})
+	degrader.CacheSize = 1
+	h := handler.New(&handler.Config{Schema: &schema, Degrader: degrader})
+	do := func(query string) string {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(query))
+		req.Header.Set("Content-Type", "application/graphql")
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp.Body.String()
+	}
+
+	do("{price(id: 1)}")
+	do("{price(id: 2)}")
+	degrader.Activate("cache-only")
+	// the least recently used value was evicted
+	if got := do("{a: price(id: 1) b: price(id: 2)}"); got != `{"data":{"a":null,"b":20}}` {
+		t.Fatalf("unexpected response %s", got)
+	}
+	degrader.CacheTTL = time.Nanosecond
+	degrader.Deactivate("cache-only")
+	do("{price(id: 2)}")
+	degrader.Activate("cache-only")
+	time.Sleep(time.Millisecond)
+	if got := do("{price(id: 2)}"); got != `{"data":{"price":null}}` {
+		t.Fatalf("expected the value to expire, got %s", got)
+	}
+}
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// DocumentCacheStats counts the lookups of the parsed document cache
+type DocumentCacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Len    int    `json:"len"`
+}
+
+// documentCache keeps parsed and validated documents, keyed by the schema
+// they were validated against and the hash of their source
+type documentCache struct {
+	hits, misses uint64
+
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type documentEntry struct {
+	key string
+	doc *ast.Document
+}
+
+func newDocumentCache(size int) *documentCache {
+	return &documentCache{size: size, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (c *documentCache) get(key string) (*ast.Document, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false
+	}
+	atomic.AddUint64(&c.hits, 1)
+	c.order.MoveToFront(el)
+	return el.Value.(*documentEntry).doc, true
+}
+
+// contains reports whether key is cached without counting a lookup
+func (c *documentCache) contains(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.entries[key]
+	return ok
+}
+
+func (c *documentCache) add(key string, doc *ast.Document) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+	c.entries[key] = c.order.PushFront(&documentEntry{key: key, doc: doc})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*documentEntry).key)
+	}
+}
+
+// DocumentCacheStats returns the counters of the parsed document cache,
+// zero when Config.DocumentCacheSize is not set
+func (h *Handler) DocumentCacheStats() DocumentCacheStats {
+	if h.documents == nil {
+		return DocumentCacheStats{}
+	}
+	h.documents.mu.Lock()
+	n := h.documents.order.Len()
+	h.documents.mu.Unlock()
+	return DocumentCacheStats{
+		Hits:   atomic.LoadUint64(&h.documents.hits),
+		Misses: atomic.LoadUint64(&h.documents.misses),
+		Len:    n,
+	}
+}
+
+// execute runs params like graphql.Do, with the document cache enabled
+// the document is only parsed and validated on a miss and registered
+// operations are never parsed. scope identifies the schema version.
+// Schema extensions then only see the execution. A configured Executor
+// replaces all of it
+func (h *Handler) execute(params graphql.Params, scope string) *graphql.Result {
+	if h.executor != nil {
+		return h.executor.Do(params.Context, params)
+	}
+	if h.operations == nil && h.documents == nil {
+		return graphql.Do(params)
+	}
+	sum := sha256.Sum256([]byte(params.RequestString))
+	hash := hex.EncodeToString(sum[:])
+	if op := h.operations.lookup(hash); op != nil {
+		if errs := op.validate(&params.Schema, scope); errs != nil {
+			return &graphql.Result{Errors: errs}
+		}
+		return h.executeDocument(params, op.doc)
+	}
+	if h.documents == nil {
+		return graphql.Do(params)
+	}
+	key := scope + "\x00" + hash
+	doc, ok := h.documents.get(key)
+	if !ok {
+		var err error
+		doc, err = parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+			Body: []byte(params.RequestString),
+			Name: "GraphQL request",
+		})})
+		if err != nil {
+			return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+		}
+		if validation := graphql.ValidateDocument(&params.Schema, doc, nil); !validation.IsValid {
+			return &graphql.Result{Errors: validation.Errors}
+		}
+		h.documents.add(key, doc)
+	}
+	return h.executeDocument(params, doc)
+}
+
+// executeDocument executes the parsed and validated doc with params
+func (h *Handler) executeDocument(params graphql.Params, doc *ast.Document) *graphql.Result {
+	ctx := params.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if h.cacheControl != nil {
+		// graphql.Do would have run the Init of the extension
+		if _, ok := ctx.Value(cacheControlKey{}).(*cacheControl); !ok {
+			ctx = context.WithValue(ctx, cacheControlKey{}, newCacheControl())
+		}
+	}
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        params.Schema,
+		Root:          params.RootObject,
+		AST:           doc,
+		OperationName: params.OperationName,
+		Args:          params.VariableValues,
+		Context:       ctx,
+	})
+}
@@ -0,0 +1,55 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_DocumentCache(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:            &testutil.StarWarsSchema,
+		DocumentCacheSize: 10,
+		CacheControl: &handler.CacheControlConfig{
+			Hints: map[string]handler.CacheHint{"Query.human": {MaxAge: 60}},
+		},
+	})
+	query := url.QueryEscape("query($id: String!) { human(id: $id) { name } }")
+	for id, name := range map[string]string{"1000": "Luke Skywalker", "1001": "Darth Vader"} {
+		req, _ := http.NewRequest("GET", "/graphql?query="+query+"&variables="+url.QueryEscape(`{"id":"`+id+`"}`), nil)
+		result, _ := executeTest(t, h, req)
+		if !reflect.DeepEqual(result.Data, map[string]interface{}{"human": map[string]interface{}{"name": name}}) {
+			t.Fatalf("unexpected data %v", result.Data)
+		}
+		// the extensions still run when executing from the cache
+		if result.Extensions["cacheControl"] == nil {
+			t.Fatalf("missing cache hints %v", result.Extensions)
+		}
+	}
+	if stats := h.DocumentCacheStats(); stats != (handler.DocumentCacheStats{Hits: 1, Misses: 1, Len: 1}) {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	// invalid documents are not kept
+	req, _ := http.NewRequest("GET", "/graphql?query={unknown}", nil)
+	for i := 0; i < 2; i++ {
+		if result, _ := executeTest(t, h, req); len(result.Errors) != 1 {
+			t.Fatalf("unexpected result %+v", result)
+		}
+	}
+	if stats := h.DocumentCacheStats(); stats.Len != 1 || stats.Misses != 3 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	// documents validated against a replaced schema are not reused
+	h.SetSchema(&testutil.StarWarsSchema)
+	req, _ = http.NewRequest("GET", "/graphql?query="+query+"&variables="+url.QueryEscape(`{"id":"1000"}`), nil)
+	executeTest(t, h, req)
+	if stats := h.DocumentCacheStats(); stats.Misses != 4 || stats.Len != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
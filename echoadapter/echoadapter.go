@@ -0,0 +1,103 @@
+// Package echoadapter serves a handler.Handler as an echo handler
+package echoadapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/cxuhua/handler"
+	"github.com/labstack/echo/v4"
+)
+
+type echoContextKey struct{}
+
+// New returns the echo handler serving the handler of cfg, it panics on
+// invalid configs like handler.New
+func New(cfg *handler.Config) echo.HandlerFunc {
+	return Handle(handler.New(cfg))
+}
+
+// Handle returns the echo handler serving h. Operations execute with a
+// context resolving string keys from the echo context first. Requests
+// failing with a 4xx or 5xx status are answered with their GraphQL error
+// body and return an *echo.HTTPError, so middleware logging errors sees
+// them while the committed response is left alone by the HTTPErrorHandler
+func Handle(h *handler.Handler) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		res := c.Response()
+		rec := &errorRecorder{ResponseWriter: res.Writer}
+		res.Writer = rec
+		defer func() { res.Writer = rec.ResponseWriter }()
+		ctx := &keysContext{Context: c.Request().Context(), c: c}
+		h.ContextHandler(ctx, res, c.Request())
+		if res.Status < http.StatusBadRequest {
+			return nil
+		}
+		return echo.NewHTTPError(res.Status, rec.message(res.Status))
+	}
+}
+
+// EchoContext returns the echo context of an operation served by Handle,
+// e.g. from resolvers through p.Context
+func EchoContext(ctx context.Context) (echo.Context, bool) {
+	c, ok := ctx.Value(echoContextKey{}).(echo.Context)
+	return c, ok
+}
+
+// keysContext resolves the values set with c.Set before the request context
+type keysContext struct {
+	context.Context
+	c echo.Context
+}
+
+func (ctx *keysContext) Value(key interface{}) interface{} {
+	if _, ok := key.(echoContextKey); ok {
+		return ctx.c
+	}
+	if name, ok := key.(string); ok {
+		if value := ctx.c.Get(name); value != nil {
+			return value
+		}
+	}
+	return ctx.Context.Value(key)
+}
+
+// errorRecorder keeps the body of error responses to report their message
+type errorRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *errorRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *errorRecorder) Write(p []byte) (int, error) {
+	if w.status >= http.StatusBadRequest {
+		w.body.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *errorRecorder) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// message returns the first GraphQL error of the recorded body
+func (w *errorRecorder) message(status int) string {
+	var result struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(w.body.Bytes(), &result); err != nil || len(result.Errors) == 0 {
+		return http.StatusText(status)
+	}
+	return result.Errors[0].Message
+}
@@ -0,0 +1,57 @@
+package echoadapter_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/cxuhua/handler/echoadapter"
+	"github.com/graphql-go/graphql"
+	"github.com/labstack/echo/v4"
+)
+
+func TestHandle(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"user": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if _, ok := echoadapter.EchoContext(p.Context); !ok {
+						t.Errorf("missing the echo context")
+					}
+					return p.Context.Value("user"), nil
+				},
+			},
+		}}),
+	})
+	var returned error
+	e := echo.New()
+	e.GET("/graphql", echoadapter.New(&handler.Config{Schema: &schema, Pretty: false}), func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("user", "luke")
+			returned = next(c)
+			return returned
+		}
+	})
+
+	req, _ := http.NewRequest("GET", "/graphql?query={user}", nil)
+	resp := httptest.NewRecorder()
+	e.ServeHTTP(resp, req)
+	if body := strings.TrimSpace(resp.Body.String()); body != `{"data":{"user":"luke"}}` || returned != nil {
+		t.Fatalf("unexpected response %s %v", body, returned)
+	}
+
+	req, _ = http.NewRequest("GET", "/graphql?query={user}&variables=[1]", nil)
+	resp = httptest.NewRecorder()
+	e.ServeHTTP(resp, req)
+	httpErr, ok := returned.(*echo.HTTPError)
+	if !ok || httpErr.Code != http.StatusBadRequest || !strings.Contains(httpErr.Message.(string), "invalid variables") {
+		t.Fatalf("unexpected error %v", returned)
+	}
+	// the GraphQL body is kept
+	if resp.Code != http.StatusBadRequest || !strings.Contains(resp.Body.String(), `"errors"`) {
+		t.Fatalf("unexpected response %d %s", resp.Code, resp.Body.String())
+	}
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// The codes Apollo clients recognize in extensions.code
+const (
+	CodeUnauthenticated     = "UNAUTHENTICATED"
+	CodeForbidden           = "FORBIDDEN"
+	CodeBadUserInput        = "BAD_USER_INPUT"
+	CodeInternalServerError = "INTERNAL_SERVER_ERROR"
+)
+
+// CodedError is implemented by errors classifying themselves, the handler
+// reports Code in extensions.code
+type CodedError interface {
+	error
+	Code() string
+}
+
+type codedError struct {
+	error
+	code string
+}
+
+func (e codedError) Code() string  { return e.code }
+func (e codedError) Unwrap() error { return e.error }
+
+// WithCode returns err classified as code
+func WithCode(err error, code string) error {
+	return codedError{err, code}
+}
+
+// errorCode returns the code of the first CodedError wrapped by err
+func errorCode(err error) string {
+	var coded CodedError
+	if errors.As(err, &coded) {
+		return coded.Code()
+	}
+	return ""
+}
+
+// withCode sets extensions.code of formatted from err, codes already set
+// by extended errors are kept
+func withCode(formatted gqlerrors.FormattedError, err error) gqlerrors.FormattedError {
+	code := errorCode(err)
+	if code == "" {
+		return formatted
+	}
+	if _, ok := formatted.Extensions["code"]; ok {
+		return formatted
+	}
+	extensions := map[string]interface{}{"code": code}
+	for k, v := range formatted.Extensions {
+		extensions[k] = v
+	}
+	formatted.Extensions = extensions
+	return formatted
+}
+
+// codeErrors reports the codes of the resolver errors of result
+func codeErrors(result *graphql.Result) {
+	for i, formatted := range result.Errors {
+		if located, ok := formatted.OriginalError().(*gqlerrors.Error); ok && located.OriginalError != nil {
+			result.Errors[i] = withCode(formatted, located.OriginalError)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+type quotaError struct{}
+
+func (quotaError) Error() string { return "quota exceeded" }
+func (quotaError) Code() string  { return "QUOTA_EXCEEDED" }
+
+func TestHandler_ErrorCodes(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"user": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return nil, handler.WithCode(errors.New("invalid id"), handler.CodeBadUserInput)
+			}},
+			"quota": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return nil, fmt.Errorf("search: %w", quotaError{})
+			}},
+			"plain": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return nil, errors.New("plain")
+			}},
+		}}),
+	})
+	h := handler.New(&handler.Config{Schema: &schema})
+	for field, code := range map[string]interface{}{
+		"user":  handler.CodeBadUserInput,
+		"quota": "QUOTA_EXCEEDED",
+		"plain": nil,
+	} {
+		req, _ := http.NewRequest("GET", "/graphql?query={"+field+"}", nil)
+		result, _ := executeTest(t, h, req)
+		if len(result.Errors) != 1 || result.Errors[0].Extensions["code"] != code {
+			t.Fatalf("%s: unexpected result %+v", field, result)
+		}
+	}
+
+	// errors of the handler carry their codes too
+	h = handler.New(&handler.Config{
+		Schema: &testutil.StarWarsSchema,
+		AuthFn: func(ctx context.Context, r *http.Request, opts *handler.RequestOptions) (context.Context, error) {
+			if r.Header.Get("Authorization") == "" {
+				return nil, errors.New("missing credentials")
+			}
+			return nil, fmt.Errorf("%w: read only", handler.ErrForbidden)
+		},
+	})
+	req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}", nil)
+	if result, _ := executeTest(t, h, req); result.Errors[0].Extensions["code"] != handler.CodeUnauthenticated {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	req.Header.Set("Authorization", "Bearer token")
+	if result, _ := executeTest(t, h, req); result.Errors[0].Extensions["code"] != handler.CodeForbidden {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
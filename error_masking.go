@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// DefaultMaskedErrorMessage replaces the messages of masked errors
+const DefaultMaskedErrorMessage = "internal server error"
+
+// SafeError is implemented by resolver errors whose message may reach the
+// clients of a handler masking errors
+type SafeError interface {
+	error
+	Safe() bool
+}
+
+type safeError struct{ error }
+
+func (e safeError) Safe() bool    { return true }
+func (e safeError) Unwrap() error { return e.error }
+
+// SafeErrorf returns an error passing Config.MaskErrors unchanged
+func SafeErrorf(format string, args ...interface{}) error {
+	return safeError{fmt.Errorf(format, args...)}
+}
+
+// MaskErrorFn is called with every error Config.MaskErrors hides and the
+// correlation ID the client receives instead, e.g. to log it. It returns
+// the message of the masked error, empty for DefaultMaskedErrorMessage
+type MaskErrorFn func(ctx context.Context, err error, id string) string
+
+// maskErrors replaces the resolver errors of result not marked safe by a
+// generic message and a correlation ID, the errors of the request itself,
+// like syntax and validation errors, are kept
+func (h *Handler) maskErrors(ctx context.Context, result *graphql.Result) {
+	if !h.maskErrorsEnabled {
+		return
+	}
+	for i, formatted := range result.Errors {
+		located, ok := formatted.OriginalError().(*gqlerrors.Error)
+		if !ok || located.OriginalError == nil {
+			continue
+		}
+		err := located.OriginalError
+		var safe SafeError
+		if errors.As(err, &safe) && safe.Safe() {
+			continue
+		}
+		buff := make([]byte, 8)
+		_, _ = rand.Read(buff)
+		id := hex.EncodeToString(buff)
+		message := ""
+		if h.maskErrorFn != nil {
+			message = h.maskErrorFn(ctx, err, id)
+		}
+		if message == "" {
+			message = DefaultMaskedErrorMessage
+		}
+		result.Errors[i] = gqlerrors.FormattedError{
+			Message:   message,
+			Locations: formatted.Locations,
+			Path:      formatted.Path,
+			Extensions: map[string]interface{}{
+				"code":          CodeInternalServerError,
+				"correlationId": id,
+			},
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_MaskErrors(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"internal": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return nil, errors.New(`pq: relation "users" does not exist`)
+			}},
+			"safe": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return nil, fmt.Errorf("lookup: %w", handler.SafeErrorf("user %d not found", 7))
+			}},
+		}}),
+	})
+	logged := map[string]string{}
+	h := handler.New(&handler.Config{
+		Schema:     &schema,
+		MaskErrors: true,
+		MaskErrorFn: func(ctx context.Context, err error, id string) string {
+			logged[id] = err.Error()
+			return ""
+		},
+	})
+	req, _ := http.NewRequest("GET", "/graphql?query={internal}", nil)
+	result, _ := executeTest(t, h, req)
+	if len(result.Errors) != 1 || result.Errors[0].Message != handler.DefaultMaskedErrorMessage {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	id, _ := result.Errors[0].Extensions["correlationId"].(string)
+	if logged[id] != `pq: relation "users" does not exist` || result.Errors[0].Path[0] != "internal" {
+		t.Fatalf("unexpected error %+v, logged %v", result.Errors[0], logged)
+	}
+
+	req, _ = http.NewRequest("GET", "/graphql?query={safe}", nil)
+	if result, _ = executeTest(t, h, req); result.Errors[0].Message != "lookup: user 7 not found" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	// the errors of the request itself are not masked
+	req, _ = http.NewRequest("GET", "/graphql?query={unknown}", nil)
+	if result, _ = executeTest(t, h, req); result.Errors[0].Message != `Cannot query field "unknown" on type "Query".` {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestHandler_MaskErrors_Incremental(t *testing.T) {
+	object := graphql.NewObject(graphql.ObjectConfig{Name: "A", Fields: graphql.Fields{
+		"id": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return "1", nil
+		}},
+		"secret": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return nil, errors.New("pq: password authentication failed for user admin")
+		}},
+	}})
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"a": &graphql.Field{Type: object, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return struct{}{}, nil
+			}},
+		}}),
+	})
+	h := handler.New(&handler.Config{Schema: &schema, MaskErrors: true, IncrementalDelivery: true})
+	for _, query := range []string{"{ a { id ... @defer { secret } } }", "{ a { id secret } ... @defer { a { secret } } }"} {
+		for _, accept := range []string{"application/json", "multipart/mixed"} {
+			req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape(query), nil)
+			req.Header.Set("Accept", accept)
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, req)
+			if body := resp.Body.String(); strings.Contains(body, "pq:") || !strings.Contains(body, handler.DefaultMaskedErrorMessage) {
+				t.Fatalf("%s answered with %s: %s", query, accept, body)
+			}
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/graphql-go/graphql"
+)
+
+// Executor runs the operations of the handler in place of graphql.Do, e.g.
+// to trace or cache executions or to serve them from another engine.
+// params.Context is ctx
+type Executor interface {
+	Do(ctx context.Context, params graphql.Params) *graphql.Result
+}
+
+// ExecutorFunc adapts a function to an Executor
+type ExecutorFunc func(ctx context.Context, params graphql.Params) *graphql.Result
+
+// Do implements Executor
+func (f ExecutorFunc) Do(ctx context.Context, params graphql.Params) *graphql.Result {
+	return f(ctx, params)
+}
+
+// DefaultExecutor executes operations with graphql.Do
+var DefaultExecutor Executor = ExecutorFunc(func(_ context.Context, params graphql.Params) *graphql.Result {
+	return graphql.Do(params)
+})
@@ -0,0 +1,45 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_Executor(t *testing.T) {
+	type traceKey struct{}
+	var operations []string
+	h := handler.New(&handler.Config{
+		Schema: &testutil.StarWarsSchema,
+		ContextFn: func(ctx context.Context, r *http.Request) (context.Context, error) {
+			return context.WithValue(ctx, traceKey{}, "trace-1"), nil
+		},
+		Executor: handler.ExecutorFunc(func(ctx context.Context, params graphql.Params) *graphql.Result {
+			if ctx.Value(traceKey{}) != "trace-1" {
+				t.Fatal("the executor does not see the request context")
+			}
+			operations = append(operations, params.OperationName)
+			return handler.DefaultExecutor.Do(ctx, params)
+		}),
+	})
+	req, _ := http.NewRequest("GET", "/graphql?query=query+Hero{hero{name}}&operationName=Hero", nil)
+	result, _ := executeTest(t, h, req)
+	if !reflect.DeepEqual(result.Data, map[string]interface{}{"hero": map[string]interface{}{"name": "R2-D2"}}) {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if !reflect.DeepEqual(operations, []string{"Hero"}) {
+		t.Fatalf("unexpected operations %q", operations)
+	}
+	if _, err := handler.NewWithError(&handler.Config{
+		Schema:            &testutil.StarWarsSchema,
+		Executor:          handler.DefaultExecutor,
+		DocumentCacheSize: 10,
+	}); err == nil {
+		t.Fatal("expected a config error")
+	}
+}
@@ -0,0 +1,340 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// ExplainHeader asks for the plan of an operation in place of its result,
+// honored with Config.Explain when its value is true
+const ExplainHeader = "X-GraphQL-Explain"
+
+// maxExplainFields bounds the field tree of an explanation, fragments
+// spread repeatedly can expand into exponentially many fields
+const maxExplainFields = 10000
+
+// Explain is the plan of an operation, reported in extensions.explain
+type Explain struct {
+	// Operation is "query", "mutation" or "subscription"
+	Operation string         `json:"operation"`
+	Name      string         `json:"name,omitempty"`
+	Fields    []ExplainField `json:"fields"`
+	// Truncated is set when the tree stopped at maxExplainFields fields
+	Truncated bool `json:"truncated,omitempty"`
+	// EstimatedCost counts every field once per item of the lists holding
+	// it. A list counts as many items as its first, last or limit
+	// argument, one without. Fields count as the Weight of their Policies
+	EstimatedCost int           `json:"estimatedCost"`
+	Limits        ExplainLimits `json:"limits"`
+	Cache         ExplainCache  `json:"cache"`
+}
+
+// ExplainField is a selected field, fragments are flattened into the
+// fields of their parents
+type ExplainField struct {
+	Name  string `json:"name"`
+	Alias string `json:"alias,omitempty"`
+	// On is the type condition of the fragment selecting the field
+	On   string `json:"on,omitempty"`
+	Type string `json:"type"`
+	// Cost is the estimated cost of the field and its selections
+	Cost   int            `json:"cost"`
+	Fields []ExplainField `json:"fields,omitempty"`
+}
+
+// ExplainLimits compares the operation with the query limits of the
+// Config, zero maxima are unlimited
+type ExplainLimits struct {
+	RootFields    int `json:"rootFields"`
+	MaxRootFields int `json:"maxRootFields,omitempty"`
+	Aliases       int `json:"aliases"`
+	MaxAliases    int `json:"maxAliases,omitempty"`
+	// MaxCost is the cost limit of the Policies
+	MaxCost int `json:"maxCost,omitempty"`
+}
+
+// ExplainCache tells which caches the execution would use, empty values
+// are disabled caches
+type ExplainCache struct {
+	// Response is "hit", "miss" or "skip" for the response or the
+	// introspection cache
+	Response string `json:"response,omitempty"`
+	// Document is "hit" or "miss" for the document cache, "registered"
+	// for registered operations
+	Document string `json:"document,omitempty"`
+	// Coalesce is set when identical running queries would share the
+	// execution
+	Coalesce bool `json:"coalesce,omitempty"`
+}
+
+// wantsExplain reports whether r asks for the plan of its operation
+func (h *Handler) wantsExplain(r *http.Request) bool {
+	if !h.explain {
+		return false
+	}
+	explain, _ := strconv.ParseBool(r.Header.Get(ExplainHeader))
+	return explain
+}
+
+// explainOperation validates the operation of opts against schema and
+// explains it, no resolver runs
+func (h *Handler) explainOperation(ctx context.Context, r *http.Request, opts *RequestOptions, schema *graphql.Schema, schemaName string, generation uint64) *graphql.Result {
+	doc, operation, root, errs := validOperation(schema, opts)
+	if errs != nil {
+		return &graphql.Result{Errors: errs}
+	}
+	explain := &Explain{Operation: operation.Operation}
+	if operation.Name != nil {
+		explain.Name = operation.Name.Value
+	}
+	policies := h.policies.current()
+	var weights *Policies
+	if policies != nil {
+		weights = policies.Policies
+	}
+	e := newExplainer(schema, doc, opts.Variables, weights)
+	explain.Fields = e.fields(root, operation.SelectionSet, "")
+	explain.Truncated = e.truncated
+	for _, field := range explain.Fields {
+		explain.EstimatedCost = saturatingAdd(explain.EstimatedCost, field.Cost)
+	}
+
+	c := &selectionCounter{fragments: e.fragments, counts: map[string]selectionCount{}}
+	count := c.count(operation.SelectionSet)
+	explain.Limits = ExplainLimits{
+		RootFields:    count.rootFields,
+		MaxRootFields: h.queryLimits.maxRootFields,
+		Aliases:       count.aliases,
+		MaxAliases:    h.queryLimits.maxAliases,
+	}
+	if policies != nil {
+		policy, _ := policies.operation(opts)
+		if explain.Limits.MaxCost = policy.MaxCost; policy.MaxCost == 0 {
+			explain.Limits.MaxCost = policies.MaxCost
+		}
+	}
+	explain.Cache = h.explainCache(ctx, r, opts, schemaName, generation)
+	return &graphql.Result{Extensions: map[string]interface{}{"explain": explain}}
+}
+
+// validOperation parses and validates the document of opts and returns the
+// operation it selects with its root type
+func validOperation(schema *graphql.Schema, opts *RequestOptions) (*ast.Document, *ast.OperationDefinition, *graphql.Object, []gqlerrors.FormattedError) {
+	parsed := parseRequest(opts)
+	if parsed.err != nil {
+		return nil, nil, nil, gqlerrors.FormatErrors(parsed.err)
+	}
+	doc, operation := parsed.doc, parsed.operation
+	if validation := graphql.ValidateDocument(schema, doc, nil); !validation.IsValid {
+		return nil, nil, nil, validation.Errors
+	}
+	if operation == nil {
+		return nil, nil, nil, gqlerrors.FormatErrors(fmt.Errorf("unknown operation named %q", opts.OperationName))
+	}
+	root := rootType(schema, operation)
+	if root == nil {
+		return nil, nil, nil, gqlerrors.FormatErrors(fmt.Errorf("schema has no %s type", operation.Operation))
+	}
+	return doc, operation, root, nil
+}
+
+// explainCache looks the operation of opts up in the caches the execution
+// would use, the document cache counters are left alone
+func (h *Handler) explainCache(ctx context.Context, r *http.Request, opts *RequestOptions, schemaName string, generation uint64) ExplainCache {
+	var decisions ExplainCache
+	cache := h.responseCache
+	if cache == nil && isIntrospection(opts) {
+		cache = h.introspectionCache
+	}
+	if cache != nil {
+		decisions.Response = "skip"
+		if key := cache.cacheKey(ctx, r, opts, h.cacheScope(r, schemaName, generation)); key != "" {
+			decisions.Response = "miss"
+			if _, ok := cache.Store.Get(ctx, key); ok {
+				decisions.Response = "hit"
+			}
+		}
+	}
+	sum := sha256.Sum256([]byte(opts.Query))
+	hash := hex.EncodeToString(sum[:])
+	scope := fmt.Sprintf("%s#%d", schemaName, generation)
+	if h.executor == nil {
+		if h.operations.lookup(hash) != nil {
+			decisions.Document = "registered"
+		} else if h.documents != nil {
+			decisions.Document = "miss"
+			if h.documents.contains(scope + "\x00" + hash) {
+				decisions.Document = "hit"
+			}
+		}
+	}
+	decisions.Coalesce = h.coalescer != nil && isQuery(opts)
+	return decisions
+}
+
+// explainer builds the field tree of an operation
+type explainer struct {
+	schema    *graphql.Schema
+	variables map[string]interface{}
+	fragments map[string]*ast.FragmentDefinition
+	// spreading are the fragments on the current path, cycles are
+	// rejected by the validation
+	spreading map[string]bool
+	// policies weigh the fields, nil weighs them all 1
+	policies  *Policies
+	cacheTTL  time.Duration
+	count     int
+	truncated bool
+}
+
+// newExplainer returns an explainer of the operations of doc
+func newExplainer(schema *graphql.Schema, doc *ast.Document, variables map[string]interface{}, policies *Policies) *explainer {
+	e := &explainer{schema: schema, variables: variables, policies: policies, fragments: map[string]*ast.FragmentDefinition{}, spreading: map[string]bool{}}
+	for _, def := range doc.Definitions {
+		if def, ok := def.(*ast.FragmentDefinition); ok && def.Name != nil {
+			e.fragments[def.Name.Value] = def
+		}
+	}
+	return e
+}
+
+// fields explains the fields of set selected on parent, on is the type
+// condition of the enclosing fragment
+func (e *explainer) fields(parent graphql.Type, set *ast.SelectionSet, on string) []ExplainField {
+	if set == nil {
+		return nil
+	}
+	var fields []ExplainField
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if e.count >= maxExplainFields {
+				e.truncated = true
+				return fields
+			}
+			e.count++
+			fields = append(fields, e.field(parent, selection, on))
+		case *ast.InlineFragment:
+			fields = append(fields, e.fragment(parent, selection.TypeCondition, selection.SelectionSet)...)
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment := e.fragments[name]
+			if fragment == nil || e.spreading[name] {
+				continue
+			}
+			e.spreading[name] = true
+			fields = append(fields, e.fragment(parent, fragment.TypeCondition, fragment.SelectionSet)...)
+			delete(e.spreading, name)
+		}
+	}
+	return fields
+}
+
+func (e *explainer) fragment(parent graphql.Type, condition *ast.Named, set *ast.SelectionSet) []ExplainField {
+	on := ""
+	if condition != nil && condition.Name != nil {
+		if t := e.schema.Type(condition.Name.Value); t != nil {
+			if parent == nil || t.Name() != parent.Name() {
+				on = t.Name()
+			}
+			parent = t
+		}
+	}
+	return e.fields(parent, set, on)
+}
+
+func (e *explainer) field(parent graphql.Type, selection *ast.Field, on string) ExplainField {
+	field := ExplainField{Name: selection.Name.Value, On: on, Cost: 1}
+	if selection.Alias != nil {
+		field.Alias = selection.Alias.Value
+	}
+	def := e.fieldDef(parent, field.Name)
+	if def == nil {
+		return field
+	}
+	field.Type = def.Type.String()
+	if e.policies != nil && parent != nil {
+		policy := e.policies.Fields[parent.Name()+"."+field.Name]
+		if policy.Weight > 0 {
+			field.Cost = policy.Weight
+		}
+		if ttl := time.Duration(policy.CacheTTL); ttl > 0 && (e.cacheTTL == 0 || ttl < e.cacheTTL) {
+			e.cacheTTL = ttl
+		}
+	}
+	named, _ := graphql.GetNamed(def.Type).(graphql.Type)
+	field.Fields = e.fields(named, selection.SelectionSet, "")
+	nested := 0
+	for _, child := range field.Fields {
+		nested = saturatingAdd(nested, child.Cost)
+	}
+	if _, ok := graphql.GetNullable(def.Type).(*graphql.List); ok {
+		nested = saturatingMul(nested, e.listSize(selection))
+	}
+	field.Cost = saturatingAdd(field.Cost, nested)
+	return field
+}
+
+func (e *explainer) fieldDef(parent graphql.Type, name string) *graphql.FieldDefinition {
+	switch name {
+	case "__typename":
+		return graphql.TypeNameMetaFieldDef
+	case "__schema":
+		return graphql.SchemaMetaFieldDef
+	case "__type":
+		return graphql.TypeMetaFieldDef
+	}
+	switch parent := parent.(type) {
+	case *graphql.Object:
+		return parent.Fields()[name]
+	case *graphql.Interface:
+		return parent.Fields()[name]
+	}
+	return nil
+}
+
+// listSize returns the first, last or limit argument of selection, one
+// when it has none
+func (e *explainer) listSize(selection *ast.Field) int {
+	for _, arg := range selection.Arguments {
+		switch arg.Name.Value {
+		case "first", "last", "limit":
+		default:
+			continue
+		}
+		var size int
+		switch value := arg.Value.(type) {
+		case *ast.IntValue:
+			size, _ = strconv.Atoi(value.Value)
+		case *ast.Variable:
+			switch v := e.variables[value.Name.Value].(type) {
+			case float64:
+				size = int(v)
+			case int:
+				size = v
+			}
+		}
+		if size > 0 {
+			return size
+		}
+	}
+	return 1
+}
+
+// saturatingMul is saturatingAdd for products of non-negative counts
+func saturatingMul(a, b int) int {
+	if a != 0 && b > math.MaxInt32/a {
+		return math.MaxInt32
+	}
+	return a * b
+}
@@ -0,0 +1,107 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func explainTest(t *testing.T, h *handler.Handler, query string) (handler.Explain, *httptest.ResponseRecorder) {
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(query))
+	req.Header.Set("Content-Type", "application/graphql")
+	req.Header.Set(handler.ExplainHeader, "true")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	var body struct {
+		Data       interface{}
+		Extensions struct{ Explain handler.Explain }
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Data != nil {
+		t.Fatalf("explained operation was executed: %v", body.Data)
+	}
+	return body.Extensions.Explain, resp
+}
+
+func TestHandler_Explain(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:        &testutil.StarWarsSchema,
+		Explain:       true,
+		MaxAliases:    3,
+		MaxRootFields: 5,
+	})
+	explain, resp := explainTest(t, h, `query Hero { hero { name friends { name } ...Droid } } fragment Droid on Droid { id: primaryFunction }`)
+	if resp.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("explanation is cacheable: %q", resp.Header().Get("Cache-Control"))
+	}
+	expected := handler.Explain{
+		Operation: "query",
+		Name:      "Hero",
+		Fields: []handler.ExplainField{{
+			Name: "hero",
+			Type: "Character",
+			Cost: 5,
+			Fields: []handler.ExplainField{
+				{Name: "name", Type: "String", Cost: 1},
+				{Name: "friends", Type: "[Character]", Cost: 2, Fields: []handler.ExplainField{
+					{Name: "name", Type: "String", Cost: 1},
+				}},
+				{Name: "primaryFunction", Alias: "id", On: "Droid", Type: "String", Cost: 1},
+			},
+		}},
+		EstimatedCost: 5,
+		Limits:        handler.ExplainLimits{RootFields: 1, MaxRootFields: 5, Aliases: 1, MaxAliases: 3},
+	}
+	if !reflect.DeepEqual(explain, expected) {
+		t.Fatalf("unexpected explanation %+v", explain)
+	}
+}
+
+func TestHandler_ExplainCache(t *testing.T) {
+	calls := 0
+	h := handler.New(&handler.Config{
+		Schema:            countingSchema(t, &calls),
+		Explain:           true,
+		ResponseCache:     &handler.ResponseCacheConfig{},
+		DocumentCacheSize: 10,
+	})
+	explain, _ := explainTest(t, h, "{count}")
+	if expected := (handler.ExplainCache{Response: "miss", Document: "miss"}); explain.Cache != expected {
+		t.Fatalf("unexpected cache decisions %+v", explain.Cache)
+	}
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader("{count}"))
+	req.Header.Set("Content-Type", "application/graphql")
+	executeTest(t, h, req)
+	explain, _ = explainTest(t, h, "{count}")
+	if expected := (handler.ExplainCache{Response: "hit", Document: "hit"}); explain.Cache != expected {
+		t.Fatalf("unexpected cache decisions %+v", explain.Cache)
+	}
+	if explain, _ = explainTest(t, h, "mutation {count}"); explain.Cache.Response != "skip" {
+		t.Fatalf("unexpected cache decisions %+v", explain.Cache)
+	}
+	if calls != 1 {
+		t.Fatalf("explanations executed %d resolvers", calls-1)
+	}
+	if stats := h.DocumentCacheStats(); stats.Hits != 0 || stats.Misses != 1 {
+		t.Fatalf("explanations were counted: %+v", stats)
+	}
+}
+
+func TestHandler_ExplainDisabled(t *testing.T) {
+	h := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema})
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader("{hero {name}}"))
+	req.Header.Set("Content-Type", "application/graphql")
+	req.Header.Set(handler.ExplainHeader, "true")
+	result, _ := executeTest(t, h, req)
+	if result.Data == nil || result.Extensions["explain"] != nil {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
@@ -0,0 +1,97 @@
+// Package fasthttpadapter serves a handler.Handler from fasthttp servers.
+// JSON, application/graphql and Codec bodies are decoded in place from the
+// fasthttp request, other requests are parsed like net/http ones
+package fasthttpadapter
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/cxuhua/handler"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+// New returns the fasthttp request handler serving h
+func New(h *handler.Handler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		Serve(h, ctx)
+	}
+}
+
+// Serve serves the request of ctx with h, the RequestCtx is the context
+// operations execute with
+func Serve(h *handler.Handler, ctx *fasthttp.RequestCtx) {
+	r := &http.Request{}
+	if err := fasthttpadaptor.ConvertRequest(ctx, r, true); err != nil {
+		ctx.Error(err.Error(), fasthttp.StatusInternalServerError)
+		return
+	}
+	w := &responseWriter{ctx: ctx, header: http.Header{}}
+	h.ContextHandlerWithParser(ctx, w, r.WithContext(ctx), func(r *http.Request) (*handler.RequestOptions, error) {
+		if opts, ok, err := ParseRequestOptions(h, ctx); ok {
+			return opts, err
+		}
+		return h.ParseRequestOptions(r)
+	})
+}
+
+// ParseRequestOptions decodes the options of application/graphql POST
+// bodies, and of the others with the Codecs of h by their Content-Type,
+// without copying them. ok is false for the requests it leaves to
+// h.ParseRequestOptions
+func ParseRequestOptions(h *handler.Handler, ctx *fasthttp.RequestCtx) (opts *handler.RequestOptions, ok bool, err error) {
+	if !ctx.IsPost() || ctx.QueryArgs().Has("query") {
+		return nil, false, nil
+	}
+	contentType := ctx.Request.Header.ContentType()
+	if i := bytes.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	contentType = bytes.TrimSpace(contentType)
+	body := ctx.PostBody()
+	switch string(contentType) {
+	case handler.ContentTypeGraphQL:
+		return &handler.RequestOptions{Query: string(body)}, true, nil
+	case handler.ContentTypeFormURLEncoded, handler.ContentTypeMultipartFormData:
+		return nil, false, nil
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return &handler.RequestOptions{}, true, nil
+	}
+	opts, err = h.DecodeRequestBody(string(contentType), body)
+	return opts, true, err
+}
+
+// responseWriter is the http.ResponseWriter of a RequestCtx
+type responseWriter struct {
+	ctx         *fasthttp.RequestCtx
+	header      http.Header
+	wroteHeader bool
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	for name, values := range w.header {
+		for i, value := range values {
+			if i == 0 {
+				w.ctx.Response.Header.Set(name, value)
+			} else {
+				w.ctx.Response.Header.Add(name, value)
+			}
+		}
+	}
+	w.ctx.SetStatusCode(status)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.ctx.Write(p)
+}
@@ -0,0 +1,95 @@
+package fasthttpadapter_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/cxuhua/handler/fasthttpadapter"
+	"github.com/graphql-go/graphql/testutil"
+	"github.com/valyala/fasthttp"
+)
+
+// prefixCodec is JSON behind a marker, telling codec bodies apart
+type prefixCodec struct{}
+
+func (prefixCodec) ContentType() string { return "application/x-prefixed" }
+
+func (prefixCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (prefixCodec) Unmarshal(data []byte, v interface{}) error {
+	if !bytes.HasPrefix(data, []byte("P:")) {
+		return errors.New("missing prefix")
+	}
+	return json.Unmarshal(data[2:], v)
+}
+
+func serve(t *testing.T, h *handler.Handler, req *fasthttp.Request) (*fasthttp.RequestCtx, map[string]interface{}) {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Init(req, nil, nil)
+	fasthttpadapter.New(h)(ctx)
+	var result map[string]interface{}
+	if err := json.Unmarshal(ctx.Response.Body(), &result); err != nil {
+		t.Fatalf("unexpected body %s", ctx.Response.Body())
+	}
+	return ctx, result
+}
+
+func TestServe(t *testing.T) {
+	h := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema, Pretty: false})
+	expected := map[string]interface{}{"data": map[string]interface{}{"hero": map[string]interface{}{"name": "R2-D2"}}}
+
+	req := &fasthttp.Request{}
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.SetRequestURI("/graphql")
+	req.Header.SetContentType("application/json")
+	req.SetBodyString(`{"query": "query($id: String!) { hero(episode: EMPIRE) { name } human(id: $id) { name } }", "variables": "{\"id\": \"1000\"}"}`)
+	ctx, result := serve(t, h, req)
+	if ctx.Response.StatusCode() != fasthttp.StatusOK || string(ctx.Response.Header.ContentType()) != "application/json; charset=utf-8" {
+		t.Fatalf("unexpected response %d %s", ctx.Response.StatusCode(), ctx.Response.Header.ContentType())
+	}
+	data := result["data"].(map[string]interface{})
+	if data["human"].(map[string]interface{})["name"] != "Luke Skywalker" {
+		t.Fatalf("unexpected result %v", result)
+	}
+
+	req = &fasthttp.Request{}
+	req.SetRequestURI("/graphql?query={hero{name}}")
+	if _, result := serve(t, h, req); !reflect.DeepEqual(result, expected) {
+		t.Fatalf("unexpected result %v", result)
+	}
+
+	req = &fasthttp.Request{}
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.SetRequestURI("/graphql")
+	req.Header.SetContentType("application/json")
+	req.SetBodyString(`{"query": 1}`)
+	if ctx, _ := serve(t, h, req); ctx.Response.StatusCode() != fasthttp.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", ctx.Response.StatusCode())
+	}
+}
+
+func TestServe_Codecs(t *testing.T) {
+	h := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema, Codecs: []handler.Codec{prefixCodec{}}})
+	req := &fasthttp.Request{}
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.SetRequestURI("/graphql")
+	req.Header.SetContentType("application/x-prefixed; charset=utf-8")
+	req.SetBodyString(`P:{"query": "query($id: String!) { human(id: $id) { name } }", "variables": {"id": "1000"}}`)
+	ctx, result := serve(t, h, req)
+	expected := map[string]interface{}{"data": map[string]interface{}{"human": map[string]interface{}{"name": "Luke Skywalker"}}}
+	if ctx.Response.StatusCode() != fasthttp.StatusOK || !reflect.DeepEqual(result, expected) {
+		t.Fatalf("unexpected response %d %v", ctx.Response.StatusCode(), result)
+	}
+
+	// the codec rejects JSON bodies sent with its content type
+	req.SetBodyString(`{"query": "{ hero { name } }"}`)
+	if ctx, _ := serve(t, h, req); ctx.Response.StatusCode() != fasthttp.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", ctx.Response.StatusCode())
+	}
+}
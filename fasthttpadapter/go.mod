@@ -0,0 +1,17 @@
+module github.com/cxuhua/handler/fasthttpadapter
+
+go 1.18
+
+replace github.com/cxuhua/handler => ../
+
+require (
+	github.com/cxuhua/handler v0.0.0-00010101000000-000000000000
+	github.com/graphql-go/graphql v0.7.9
+	github.com/valyala/fasthttp v1.51.0
+)
+
+require (
+	github.com/andybalholm/brotli v1.0.5 // indirect
+	github.com/klauspost/compress v1.17.0 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+)
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+// FieldTimeout bounds the resolver of a field
+type FieldTimeout struct {
+	Timeout time.Duration
+	// Fallback is the value of the field when its resolver times out, nil
+	// resolves the field to null with an error, otherwise the response
+	// carries a warning in extensions.warnings
+	Fallback interface{}
+}
+
+// fieldWarnings collects the warnings of one request
+type fieldWarnings struct {
+	mu       sync.Mutex
+	warnings []map[string]interface{}
+}
+
+type fieldWarningsKey struct{}
+
+func (fw *fieldWarnings) add(message string, path []interface{}) {
+	fw.mu.Lock()
+	fw.warnings = append(fw.warnings, map[string]interface{}{"message": message, "path": path})
+	fw.mu.Unlock()
+}
+
+// list returns the collected warnings, nil when there are none
+func (fw *fieldWarnings) list() []map[string]interface{} {
+	if fw == nil {
+		return nil
+	}
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if len(fw.warnings) == 0 {
+		return nil
+	}
+	return append([]map[string]interface{}{}, fw.warnings...)
+}
+
+type resolveOutcome struct {
+	value interface{}
+	err   error
+}
+
+// fieldTimeoutResolver returns the resolver of the field at coordinate,
+// bounded when timeouts configure it
+func fieldTimeoutResolver(coordinate string, resolve graphql.FieldResolveFn, timeouts map[string]FieldTimeout) graphql.FieldResolveFn {
+	cfg, ok := timeouts[coordinate]
+	if !ok || cfg.Timeout <= 0 {
+		return resolve
+	}
+	if resolve == nil {
+		resolve = graphql.DefaultResolveFn
+	}
+	return timeoutResolver(resolve, cfg)
+}
+
+func timeoutResolver(resolve graphql.FieldResolveFn, cfg FieldTimeout) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		parent := p.Context
+		if parent == nil {
+			parent = context.Background()
+		}
+		ctx, cancel := context.WithTimeout(parent, cfg.Timeout)
+		defer cancel()
+		p.Context = ctx
+		done := make(chan resolveOutcome, 1)
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					done <- resolveOutcome{err: fmt.Errorf("%v", r)}
+				}
+			}()
+			value, err := resolve(p)
+			done <- resolveOutcome{value: value, err: err}
+		}()
+		select {
+		case outcome := <-done:
+			return outcome.value, outcome.err
+		case <-ctx.Done():
+		}
+		message := fmt.Sprintf("%s.%s timed out after %v", p.Info.ParentType.Name(), p.Info.FieldName, cfg.Timeout)
+		if cfg.Fallback == nil {
+			return nil, fmt.Errorf("%s", message)
+		}
+		if fw, ok := parent.Value(fieldWarningsKey{}).(*fieldWarnings); ok && p.Info.Path != nil {
+			fw.add(message, p.Info.Path.AsArray())
+		}
+		return cfg.Fallback, nil
+	}
+}
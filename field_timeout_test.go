@@ -0,0 +1,55 @@
+package handler_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_FieldTimeouts(t *testing.T) {
+	slow := func(p graphql.ResolveParams) (interface{}, error) {
+		select {
+		case <-p.Context.Done():
+			return nil, p.Context.Err()
+		case <-time.After(time.Second):
+			return "late", nil
+		}
+	}
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"fast":     &graphql.Field{Type: graphql.String, Resolve: func(graphql.ResolveParams) (interface{}, error) { return "fast", nil }},
+			"slow":     &graphql.Field{Type: graphql.String, Resolve: slow},
+			"fallback": &graphql.Field{Type: graphql.String, Resolve: slow},
+		}}),
+	})
+	h := handler.New(&handler.Config{
+		Schema: &schema,
+		FieldTimeouts: map[string]handler.FieldTimeout{
+			"Query.slow":     {Timeout: 10 * time.Millisecond},
+			"Query.fallback": {Timeout: 10 * time.Millisecond, Fallback: "default"},
+			"Query.fast":     {Timeout: time.Second},
+		},
+	})
+	started := time.Now()
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader("{fast slow fallback}"))
+	req.Header.Set("Content-Type", "application/graphql")
+	result, _ := executeTest(t, h, req)
+	if elapsed := time.Since(started); elapsed > 500*time.Millisecond {
+		t.Fatalf("slow fields held the response for %v", elapsed)
+	}
+	data := result.Data.(map[string]interface{})
+	if data["fast"] != "fast" || data["slow"] != nil || data["fallback"] != "default" {
+		t.Fatalf("unexpected data %v", data)
+	}
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, "Query.slow timed out") {
+		t.Fatalf("unexpected errors %v", result.Errors)
+	}
+	warnings, _ := result.Extensions["warnings"].([]interface{})
+	if len(warnings) != 1 {
+		t.Fatalf("unexpected warnings %v", result.Extensions)
+	}
+}
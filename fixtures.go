@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/printer"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// SanitizedValue replaces the values of sanitized variables in fixtures
+const SanitizedValue = "[sanitized]"
+
+// Fixtures is an Executor recording operations and their results to Dir,
+// or serving them back from it, e.g. for deterministic integration tests
+// and offline demos. Operations are matched by their normalized document,
+// operation name and variables
+type Fixtures struct {
+	// Dir holds one JSON file per operation
+	Dir string
+	// Record executes the operations and writes them to Dir, otherwise they
+	// are served from Dir without executing
+	Record bool
+	// Executor executes the recorded operations, DefaultExecutor when nil
+	Executor Executor
+	// Sanitize names variables, at any depth of their input objects, that
+	// are recorded as SanitizedValue and ignored when matching
+	Sanitize []string
+}
+
+// fixtureName matches the GraphQL names operations are named with
+var fixtureName = regexp.MustCompile(`^[_A-Za-z][_0-9A-Za-z]*$`)
+
+// fixture is the file of a recorded operation
+type fixture struct {
+	OperationName string                 `json:"operationName,omitempty"`
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	Result        *graphql.Result        `json:"result"`
+}
+
+// Do implements Executor
+func (f *Fixtures) Do(ctx context.Context, params graphql.Params) *graphql.Result {
+	recorded := fixture{
+		OperationName: params.OperationName,
+		Query:         params.RequestString,
+		Variables:     f.sanitize(params.VariableValues).(map[string]interface{}),
+	}
+	path, err := f.path(recorded)
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	if !f.Record {
+		return f.replay(path, recorded.OperationName)
+	}
+	executor := f.Executor
+	if executor == nil {
+		executor = DefaultExecutor
+	}
+	recorded.Result = executor.Do(ctx, params)
+	if _, operation := parseOperation(&RequestOptions{Query: params.RequestString, OperationName: params.OperationName}); operation == nil {
+		// unknown operations and syntax errors are not worth a fixture
+		return recorded.Result
+	}
+	if err := writeFixture(path, recorded); err != nil {
+		// fixtures that silently go missing fail the tests replaying them
+		// much later
+		recorded.Result.Errors = append(recorded.Result.Errors, gqlerrors.NewFormattedError("recording fixture: "+err.Error()))
+	}
+	return recorded.Result
+}
+
+func (f *Fixtures) replay(path, operationName string) *graphql.Result {
+	buff, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &graphql.Result{Errors: []gqlerrors.FormattedError{{
+			Message:    fmt.Sprintf("no fixture recorded for operation %q with these variables", operationName),
+			Extensions: map[string]interface{}{"code": "FIXTURE_NOT_FOUND", "fixture": filepath.Base(path)},
+		}}}
+	}
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	var recorded fixture
+	if err := json.Unmarshal(buff, &recorded); err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(fmt.Errorf("fixture %s: %v", filepath.Base(path), err))}
+	}
+	if recorded.Result == nil {
+		return &graphql.Result{}
+	}
+	return recorded.Result
+}
+
+// path names the file of recorded after its operation and the hash of
+// its normalized document and sanitized variables
+func (f *Fixtures) path(recorded fixture) (string, error) {
+	// maps are marshaled with sorted keys, equal variables give equal names
+	variables, err := json.Marshal(recorded.Variables)
+	if err != nil {
+		return "", err
+	}
+	query := recorded.Query
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(query),
+		Name: "GraphQL request",
+	})})
+	if err == nil {
+		query = fmt.Sprint(printer.Print(doc))
+	}
+	sum := sha256.New()
+	fmt.Fprintf(sum, "%s\x00%s\x00%s", query, recorded.OperationName, variables)
+	// operation names come from clients, only GraphQL names make it into
+	// the file name
+	name := recorded.OperationName
+	if !fixtureName.MatchString(name) {
+		name = "anonymous"
+	}
+	return filepath.Join(f.Dir, name+"-"+hex.EncodeToString(sum.Sum(nil))[:16]+".json"), nil
+}
+
+// sanitize copies value replacing the values of the Sanitize keys
+func (f *Fixtures) sanitize(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if value == nil {
+			return map[string]interface{}(nil)
+		}
+		sanitized := make(map[string]interface{}, len(value))
+	keys:
+		for k, v := range value {
+			for _, name := range f.Sanitize {
+				if k == name {
+					sanitized[k] = SanitizedValue
+					continue keys
+				}
+			}
+			sanitized[k] = f.sanitize(v)
+		}
+		return sanitized
+	case []interface{}:
+		sanitized := make([]interface{}, len(value))
+		for i, v := range value {
+			sanitized[i] = f.sanitize(v)
+		}
+		return sanitized
+	}
+	return value
+}
+
+// writeFixture replaces the file at path, readers never see a partial
+// fixture
+func writeFixture(path string, recorded fixture) error {
+	buff, err := json.MarshalIndent(recorded, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".fixture-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(append(buff, '\n'))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,108 @@
+package handler_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+)
+
+func loginSchema(t *testing.T, resolve graphql.FieldResolveFn) *graphql.Schema {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"login": &graphql.Field{
+				Type: graphql.String,
+				Args: graphql.FieldConfigArgument{
+					"user":     &graphql.ArgumentConfig{Type: graphql.String},
+					"password": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: resolve,
+			},
+		}}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &schema
+}
+
+func TestFixtures(t *testing.T) {
+	dir := t.TempDir()
+	do := func(h *handler.Handler, query, variables string) *graphql.Result {
+		req, _ := http.NewRequest("GET", "/graphql?"+url.Values{
+			"query":         {query},
+			"variables":     {variables},
+			"operationName": {"Login"},
+		}.Encode(), nil)
+		result, _ := executeTest(t, h, req)
+		return result
+	}
+	query := "query Login($user: String, $password: String) { login(user: $user, password: $password) }"
+
+	recorder := handler.New(&handler.Config{
+		Schema: loginSchema(t, func(p graphql.ResolveParams) (interface{}, error) {
+			return "token-" + p.Args["user"].(string), nil
+		}),
+		Executor: &handler.Fixtures{Dir: dir, Record: true, Sanitize: []string{"password"}},
+	})
+	do(recorder, query, `{"user": "ada", "password": "secret"}`)
+	do(recorder, query, `{"user": "grace", "password": "secret"}`)
+	files, _ := filepath.Glob(filepath.Join(dir, "Login-*.json"))
+	if len(files) != 2 {
+		t.Fatalf("expected 2 fixtures, got %q", files)
+	}
+	for _, file := range files {
+		buff, _ := ioutil.ReadFile(file)
+		if strings.Contains(string(buff), "secret") || !strings.Contains(string(buff), handler.SanitizedValue) {
+			t.Fatalf("password was recorded: %s", buff)
+		}
+	}
+
+	replayer := handler.New(&handler.Config{
+		Schema: loginSchema(t, func(p graphql.ResolveParams) (interface{}, error) {
+			t.Fatal("replayed operation was executed")
+			return nil, nil
+		}),
+		Executor: &handler.Fixtures{Dir: dir, Sanitize: []string{"password"}},
+	})
+	// the sanitized variables and the layout of the document do not matter
+	result := do(replayer, strings.Replace(query, " {", "\n{\n", 1), `{"user": "grace", "password": "other"}`)
+	if !reflect.DeepEqual(result.Data, map[string]interface{}{"login": "token-grace"}) {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	result = do(replayer, query, `{"user": "linus"}`)
+	if len(result.Errors) != 1 || result.Errors[0].Extensions["code"] != "FIXTURE_NOT_FOUND" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestFixtures_OperationNames(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "dir")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	h := handler.New(&handler.Config{
+		Schema:   loginSchema(t, func(p graphql.ResolveParams) (interface{}, error) { return "token", nil }),
+		Executor: &handler.Fixtures{Dir: dir, Record: true},
+	})
+	for _, name := range []string{"../escaped", "Q"} {
+		req, _ := http.NewRequest("GET", "/graphql?"+url.Values{
+			"query":         {"query Q { login }"},
+			"operationName": {name},
+		}.Encode(), nil)
+		executeTest(t, h, req)
+	}
+	outside, _ := filepath.Glob(filepath.Join(root, "*.json"))
+	inside, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(outside) != 0 || len(inside) != 1 || !strings.HasPrefix(filepath.Base(inside[0]), "Q-") {
+		t.Fatalf("unexpected fixtures %q outside, %q inside", outside, inside)
+	}
+}
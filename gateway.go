@@ -0,0 +1,415 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/visitor"
+)
+
+// Downstream is a service a gateway schema serves the root fields of
+type Downstream struct {
+	// Name tells the downstream apart in errors
+	Name string
+	// Schema describes the downstream, its types are copied into the
+	// gateway schema and its own resolvers only run through Execute
+	Schema *graphql.Schema
+	// Timeout bounds each root field sent to the downstream
+	Timeout time.Duration
+	// Execute sends a document selecting one root field to the downstream,
+	// nil executes it against Schema
+	Execute func(ctx context.Context, doc *ast.Document, variables map[string]interface{}) *graphql.Result
+}
+
+func (d *Downstream) execute(ctx context.Context, doc *ast.Document, variables map[string]interface{}) *graphql.Result {
+	if d.Execute != nil {
+		return d.Execute(ctx, doc, variables)
+	}
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:  *d.Schema,
+		AST:     doc,
+		Args:    variables,
+		Context: ctx,
+	})
+}
+
+// DownstreamError carries the errors a downstream returned for a root field
+type DownstreamError struct {
+	Downstream string
+	Errors     []gqlerrors.FormattedError
+}
+
+func (e *DownstreamError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Message
+	}
+	return fmt.Sprintf("downstream %s: %s", e.Downstream, strings.Join(messages, "; "))
+}
+
+// Extensions reports the downstream and its errors in the GraphQL error
+func (e *DownstreamError) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"code":       "DOWNSTREAM_ERROR",
+		"downstream": e.Downstream,
+		"errors":     e.Errors,
+	}
+}
+
+// gatewayTypeKey is the alias the delegated documents select __typename
+// under, the gateway resolves abstract types from it
+const gatewayTypeKey = "_gatewayType"
+
+// gateway copies the types of the downstreams, resolving their fields from
+// the responses of the delegated root fields
+type gateway struct {
+	types  map[string]graphql.Type
+	owners map[string]string
+	err    error
+}
+
+// NewGatewaySchema merges the query and mutation root fields of downstreams
+// into one schema. Each root field is delegated to its downstream, types
+// and root fields defined by several downstreams are reported as errors
+func NewGatewaySchema(downstreams ...*Downstream) (*graphql.Schema, error) {
+	g := &gateway{types: map[string]graphql.Type{}, owners: map[string]string{}}
+	query, mutation := graphql.Fields{}, graphql.Fields{}
+	owners := map[string]string{}
+	for _, d := range downstreams {
+		if d.Schema == nil {
+			return nil, fmt.Errorf("downstream %s has no schema", d.Name)
+		}
+		// copy the types only reachable as implementations of interfaces too
+		roots := map[graphql.Type]bool{d.Schema.QueryType(): true}
+		if d.Schema.MutationType() != nil {
+			roots[d.Schema.MutationType()] = true
+		}
+		if d.Schema.SubscriptionType() != nil {
+			roots[d.Schema.SubscriptionType()] = true
+		}
+		typeMap := d.Schema.TypeMap()
+		names := make([]string, 0, len(typeMap))
+		for name, t := range typeMap {
+			if !strings.HasPrefix(name, "__") && !roots[t] {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			g.copyType(d, typeMap[name])
+		}
+		for _, root := range []struct {
+			object *graphql.Object
+			fields graphql.Fields
+			name   string
+		}{
+			{d.Schema.QueryType(), query, "Query"},
+			{d.Schema.MutationType(), mutation, "Mutation"},
+		} {
+			if root.object == nil {
+				continue
+			}
+			for _, name := range sortedFieldNames(root.object.Fields()) {
+				coordinate := root.name + "." + name
+				if owner, ok := owners[coordinate]; ok {
+					return nil, fmt.Errorf("%s is defined by downstreams %s and %s", coordinate, owner, d.Name)
+				}
+				owners[coordinate] = d.Name
+				field := g.field(d, root.object.Fields()[name])
+				field.Resolve = delegate(d)
+				if d.Timeout > 0 {
+					field.Resolve = timeoutResolver(field.Resolve, FieldTimeout{Timeout: d.Timeout})
+				}
+				root.fields[name] = field
+			}
+		}
+	}
+	if g.err != nil {
+		return nil, g.err
+	}
+	names := make([]string, 0, len(g.types))
+	for name := range g.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	config := graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: query}),
+	}
+	for _, name := range names {
+		config.Types = append(config.Types, g.types[name])
+	}
+	if len(mutation) > 0 {
+		config.Mutation = graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: mutation})
+	}
+	schema, err := graphql.NewSchema(config)
+	if err != nil {
+		return nil, err
+	}
+	if g.err != nil {
+		return nil, g.err
+	}
+	return &schema, nil
+}
+
+func sortedFieldNames(fields graphql.FieldDefinitionMap) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// field copies the definition of a downstream field
+func (g *gateway) field(d *Downstream, def *graphql.FieldDefinition) *graphql.Field {
+	args := graphql.FieldConfigArgument{}
+	for _, arg := range def.Args {
+		args[arg.Name()] = &graphql.ArgumentConfig{
+			Type:         g.copyType(d, arg.Type).(graphql.Input),
+			DefaultValue: arg.DefaultValue,
+			Description:  arg.Description(),
+		}
+	}
+	return &graphql.Field{
+		Type:              g.copyType(d, def.Type).(graphql.Output),
+		Args:              args,
+		Description:       def.Description,
+		DeprecationReason: def.DeprecationReason,
+		Resolve:           resolveResponseKey,
+	}
+}
+
+// copyType returns the gateway type of the downstream type t
+func (g *gateway) copyType(d *Downstream, t graphql.Type) graphql.Type {
+	switch t := t.(type) {
+	case *graphql.NonNull:
+		return graphql.NewNonNull(g.copyType(d, t.OfType))
+	case *graphql.List:
+		return graphql.NewList(g.copyType(d, t.OfType))
+	}
+	name := t.Name()
+	if builtinTypes[name] {
+		return t
+	}
+	if copied, ok := g.types[name]; ok {
+		if owner := g.owners[name]; owner != d.Name && g.err == nil {
+			g.err = fmt.Errorf("type %s is defined by downstreams %s and %s", name, owner, d.Name)
+		}
+		return copied
+	}
+	g.owners[name] = d.Name
+	switch t := t.(type) {
+	case *graphql.Scalar:
+		// the downstream serialized the values already
+		g.types[name] = graphql.NewScalar(graphql.ScalarConfig{
+			Name:         name,
+			Description:  t.Description(),
+			Serialize:    func(value interface{}) interface{} { return value },
+			ParseValue:   t.ParseValue,
+			ParseLiteral: t.ParseLiteral,
+		})
+	case *graphql.Enum:
+		// the values are the names the downstream serializes them to
+		values := graphql.EnumValueConfigMap{}
+		for _, value := range t.Values() {
+			values[value.Name] = &graphql.EnumValueConfig{
+				Value:             value.Name,
+				Description:       value.Description,
+				DeprecationReason: value.DeprecationReason,
+			}
+		}
+		g.types[name] = graphql.NewEnum(graphql.EnumConfig{Name: name, Description: t.Description(), Values: values})
+	case *graphql.InputObject:
+		g.types[name] = graphql.NewInputObject(graphql.InputObjectConfig{
+			Name:        name,
+			Description: t.Description(),
+			Fields: graphql.InputObjectConfigFieldMapThunk(func() graphql.InputObjectConfigFieldMap {
+				fields := graphql.InputObjectConfigFieldMap{}
+				for fieldName, field := range t.Fields() {
+					fields[fieldName] = &graphql.InputObjectFieldConfig{
+						Type:         g.copyType(d, field.Type).(graphql.Input),
+						DefaultValue: field.DefaultValue,
+						Description:  field.Description(),
+					}
+				}
+				return fields
+			}),
+		})
+	case *graphql.Object:
+		g.types[name] = graphql.NewObject(graphql.ObjectConfig{
+			Name:        name,
+			Description: t.Description(),
+			Fields:      g.fieldsThunk(d, t.Fields),
+			Interfaces: graphql.InterfacesThunk(func() []*graphql.Interface {
+				interfaces := make([]*graphql.Interface, len(t.Interfaces()))
+				for i, iface := range t.Interfaces() {
+					interfaces[i] = g.copyType(d, iface).(*graphql.Interface)
+				}
+				return interfaces
+			}),
+		})
+	case *graphql.Interface:
+		g.types[name] = graphql.NewInterface(graphql.InterfaceConfig{
+			Name:        name,
+			Description: t.Description(),
+			Fields:      g.fieldsThunk(d, t.Fields),
+			ResolveType: g.resolveType,
+		})
+	case *graphql.Union:
+		possible := make([]*graphql.Object, len(t.Types()))
+		for i, object := range t.Types() {
+			possible[i] = g.copyType(d, object).(*graphql.Object)
+		}
+		g.types[name] = graphql.NewUnion(graphql.UnionConfig{
+			Name:        name,
+			Description: t.Description(),
+			Types:       possible,
+			ResolveType: g.resolveType,
+		})
+	}
+	return g.types[name]
+}
+
+func (g *gateway) fieldsThunk(d *Downstream, defs func() graphql.FieldDefinitionMap) graphql.FieldsThunk {
+	return func() graphql.Fields {
+		fields := graphql.Fields{}
+		for name, def := range defs() {
+			fields[name] = g.field(d, def)
+		}
+		return fields
+	}
+}
+
+func (g *gateway) resolveType(p graphql.ResolveTypeParams) *graphql.Object {
+	value, _ := p.Value.(map[string]interface{})
+	name, _ := value[gatewayTypeKey].(string)
+	object, _ := g.types[name].(*graphql.Object)
+	return object
+}
+
+// resolveResponseKey resolves a field from the response of its downstream
+func resolveResponseKey(p graphql.ResolveParams) (interface{}, error) {
+	value, ok := p.Source.(map[string]interface{})
+	if !ok || len(p.Info.FieldASTs) == 0 {
+		return nil, nil
+	}
+	return value[responseKey(p.Info.FieldASTs[0])], nil
+}
+
+// delegate resolves a root field by sending it to d
+func delegate(d *Downstream) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		ctx := p.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		result := d.execute(ctx, delegatedDocument(p), p.Info.VariableValues)
+		var value interface{}
+		if data, ok := result.Data.(map[string]interface{}); ok {
+			value = data[responseKey(p.Info.FieldASTs[0])]
+		}
+		if len(result.Errors) > 0 {
+			return nil, &DownstreamError{Downstream: d.Name, Errors: result.Errors}
+		}
+		return value, nil
+	}
+}
+
+// delegatedDocument returns the operation selecting the root field p
+// resolves, with the fragments and variables it uses
+func delegatedDocument(p graphql.ResolveParams) *ast.Document {
+	selections := make([]ast.Selection, len(p.Info.FieldASTs))
+	for i, field := range p.Info.FieldASTs {
+		selections[i] = withTypename(field)
+	}
+	operation := &ast.OperationDefinition{
+		Kind:         "OperationDefinition",
+		Operation:    ast.OperationTypeQuery,
+		SelectionSet: &ast.SelectionSet{Kind: "SelectionSet", Selections: selections},
+	}
+	original, _ := p.Info.Operation.(*ast.OperationDefinition)
+	if original != nil {
+		operation.Operation = original.Operation
+	}
+	doc := &ast.Document{Kind: "Document", Definitions: []ast.Node{operation}}
+	variables := map[string]bool{}
+	collect := func(node ast.Node) []string {
+		var spreads []string
+		visitor.Visit(node, &visitor.VisitorOptions{
+			Enter: func(vp visitor.VisitFuncParams) (string, interface{}) {
+				switch node := vp.Node.(type) {
+				case *ast.Variable:
+					variables[node.Name.Value] = true
+				case *ast.FragmentSpread:
+					spreads = append(spreads, node.Name.Value)
+				}
+				return visitor.ActionNoChange, nil
+			},
+		}, nil)
+		return spreads
+	}
+	pending := collect(operation)
+	included := map[string]bool{}
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		fragment, ok := p.Info.Fragments[name].(*ast.FragmentDefinition)
+		if !ok || included[name] {
+			continue
+		}
+		included[name] = true
+		copied := *fragment
+		copied.SelectionSet = withTypenames(fragment.SelectionSet)
+		doc.Definitions = append(doc.Definitions, &copied)
+		pending = append(pending, collect(&copied)...)
+	}
+	if original != nil {
+		for _, def := range original.VariableDefinitions {
+			if variables[def.Variable.Name.Value] {
+				operation.VariableDefinitions = append(operation.VariableDefinitions, def)
+			}
+		}
+	}
+	return doc
+}
+
+// withTypename copies field, selecting __typename in every selection set
+// below it
+func withTypename(field *ast.Field) *ast.Field {
+	copied := *field
+	copied.SelectionSet = withTypenames(field.SelectionSet)
+	return &copied
+}
+
+func withTypenames(set *ast.SelectionSet) *ast.SelectionSet {
+	if set == nil {
+		return nil
+	}
+	selections := make([]ast.Selection, 0, len(set.Selections)+1)
+	selections = append(selections, &ast.Field{
+		Kind:  "Field",
+		Alias: &ast.Name{Kind: "Name", Value: gatewayTypeKey},
+		Name:  &ast.Name{Kind: "Name", Value: "__typename"},
+	})
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			selections = append(selections, withTypename(selection))
+		case *ast.InlineFragment:
+			copied := *selection
+			copied.SelectionSet = withTypenames(selection.SelectionSet)
+			selections = append(selections, &copied)
+		default:
+			selections = append(selections, selection)
+		}
+	}
+	copied := *set
+	copied.Selections = selections
+	return &copied
+}
@@ -0,0 +1,135 @@
+package handler_test
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func accountsSchema(t *testing.T) *graphql.Schema {
+	name := "Han"
+	user := graphql.NewObject(graphql.ObjectConfig{Name: "User", Fields: graphql.Fields{
+		"name": &graphql.Field{
+			Type: graphql.String,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return *p.Source.(*string), nil
+			},
+		},
+	}})
+	me := func(p graphql.ResolveParams) (interface{}, error) { return &name, nil }
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"me": &graphql.Field{Type: user, Resolve: me},
+			"slow": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				time.Sleep(200 * time.Millisecond)
+				return "late", nil
+			}},
+			"broken": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return nil, errors.New("accounts are down")
+			}},
+		}}),
+		Mutation: graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: graphql.Fields{
+			"rename": &graphql.Field{
+				Type: user,
+				Args: graphql.FieldConfigArgument{"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					name = p.Args["name"].(string)
+					return &name, nil
+				},
+			},
+		}}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &schema
+}
+
+func TestNewGatewaySchema(t *testing.T) {
+	schema, err := handler.NewGatewaySchema(
+		&handler.Downstream{Name: "starwars", Schema: &testutil.StarWarsSchema},
+		&handler.Downstream{Name: "accounts", Schema: accountsSchema(t), Timeout: 50 * time.Millisecond},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := handler.New(&handler.Config{Schema: schema})
+	for _, test := range []struct {
+		query, variables string
+		data             map[string]interface{}
+	}{
+		{
+			"query($ep: Episode) { hero(episode: $ep) { name ... on Human { homePlanet } } me { n: name } }",
+			`{"ep":"EMPIRE"}`,
+			map[string]interface{}{
+				"hero": map[string]interface{}{"name": "Luke Skywalker", "homePlanet": "Tatooine"},
+				"me":   map[string]interface{}{"n": "Han"},
+			},
+		},
+		{
+			"{ ...Root } fragment Root on Query { r2: hero { ...Named friends { ...Named } } } fragment Named on Character { name }",
+			"",
+			map[string]interface{}{"r2": map[string]interface{}{"name": "R2-D2", "friends": []interface{}{
+				map[string]interface{}{"name": "Luke Skywalker"},
+				map[string]interface{}{"name": "Han Solo"},
+				map[string]interface{}{"name": "Leia Organa"},
+			}}},
+		},
+		{
+			`mutation { rename(name: "Solo") { name } }`,
+			"",
+			map[string]interface{}{"rename": map[string]interface{}{"name": "Solo"}},
+		},
+	} {
+		req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape(test.query)+"&variables="+url.QueryEscape(test.variables), nil)
+		if strings.HasPrefix(test.query, "mutation") {
+			req, _ = http.NewRequest("POST", "/graphql", strings.NewReader(test.query))
+			req.Header.Set("Content-Type", "application/graphql")
+		}
+		result, _ := executeTest(t, h, req)
+		if result.HasErrors() || !reflect.DeepEqual(result.Data, test.data) {
+			t.Fatalf("%s: unexpected result %+v", test.query, result)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape("{ broken slow hero { name } }"), nil)
+	result, _ := executeTest(t, h, req)
+	if !reflect.DeepEqual(result.Data, map[string]interface{}{"broken": nil, "slow": nil, "hero": map[string]interface{}{"name": "R2-D2"}}) {
+		t.Fatalf("unexpected data %+v", result.Data)
+	}
+	if len(result.Errors) != 2 {
+		t.Fatalf("unexpected errors %+v", result.Errors)
+	}
+	for _, err := range result.Errors {
+		switch err.Path[0] {
+		case "broken":
+			if err.Message != "downstream accounts: accounts are down" || err.Extensions["downstream"] != "accounts" {
+				t.Fatalf("unexpected error %+v", err)
+			}
+		case "slow":
+			if !strings.Contains(err.Message, "timed out after 50ms") {
+				t.Fatalf("unexpected error %+v", err)
+			}
+		default:
+			t.Fatalf("unexpected error %+v", err)
+		}
+	}
+}
+
+func TestNewGatewaySchema_Conflicts(t *testing.T) {
+	_, err := handler.NewGatewaySchema(
+		&handler.Downstream{Name: "a", Schema: &testutil.StarWarsSchema},
+		&handler.Downstream{Name: "b", Schema: &testutil.StarWarsSchema},
+	)
+	if err == nil || err.Error() != "Query.droid is defined by downstreams a and b" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
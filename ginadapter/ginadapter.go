@@ -0,0 +1,59 @@
+// Package ginadapter serves a handler.Handler as a gin handler
+package ginadapter
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/cxuhua/handler"
+	"github.com/gin-gonic/gin"
+)
+
+type ginContextKey struct{}
+
+// New returns the gin handler serving the handler of cfg, it panics on
+// invalid configs like handler.New
+func New(cfg *handler.Config) gin.HandlerFunc {
+	return Handle(handler.New(cfg))
+}
+
+// Handle returns the gin handler serving h. Operations execute with a
+// context resolving string keys from the gin context first, requests
+// already aborted are skipped and failed requests abort the chain
+func Handle(h *handler.Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.IsAborted() {
+			return
+		}
+		ctx := &keysContext{Context: c.Request.Context(), c: c}
+		h.ContextHandler(ctx, c.Writer, c.Request)
+		if c.Writer.Status() >= http.StatusBadRequest {
+			c.Abort()
+		}
+	}
+}
+
+// GinContext returns the gin context of an operation served by Handle,
+// e.g. from resolvers through p.Context
+func GinContext(ctx context.Context) (*gin.Context, bool) {
+	c, ok := ctx.Value(ginContextKey{}).(*gin.Context)
+	return c, ok
+}
+
+// keysContext resolves the values set with c.Set before the request context
+type keysContext struct {
+	context.Context
+	c *gin.Context
+}
+
+func (ctx *keysContext) Value(key interface{}) interface{} {
+	if _, ok := key.(ginContextKey); ok {
+		return ctx.c
+	}
+	if name, ok := key.(string); ok {
+		if value, exists := ctx.c.Get(name); exists {
+			return value
+		}
+	}
+	return ctx.Context.Value(key)
+}
@@ -0,0 +1,64 @@
+package ginadapter_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/cxuhua/handler/ginadapter"
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"user": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if c, ok := ginadapter.GinContext(p.Context); !ok || c.GetHeader("X-Test") != "1" {
+						t.Errorf("missing the gin context")
+					}
+					return p.Context.Value("user"), nil
+				},
+			},
+		}}),
+	})
+	after := false
+	r := gin.New()
+	r.GET("/graphql", func(c *gin.Context) {
+		if c.Query("deny") != "" {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Set("user", "luke")
+	}, ginadapter.New(&handler.Config{Schema: &schema, Pretty: false}), func(c *gin.Context) {
+		after = true
+	})
+
+	req, _ := http.NewRequest("GET", "/graphql?query={user}", nil)
+	req.Header.Set("X-Test", "1")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	if body := strings.TrimSpace(resp.Body.String()); body != `{"data":{"user":"luke"}}` || !after {
+		t.Fatalf("unexpected response %s", body)
+	}
+
+	after = false
+	req, _ = http.NewRequest("GET", "/graphql?query={user}&variables=[1]", nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	if resp.Code != http.StatusBadRequest || after {
+		t.Fatalf("the chain continued after %d", resp.Code)
+	}
+
+	req, _ = http.NewRequest("GET", "/graphql?deny=1&query={user}", nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	if resp.Code != http.StatusForbidden || resp.Body.Len() != 0 {
+		t.Fatalf("unexpected response %d %s", resp.Code, resp.Body.String())
+	}
+}
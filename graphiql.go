@@ -4,93 +4,238 @@ import (
 	"encoding/json"
 	"html/template"
 	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultAssetsPath is the URL prefix embedded IDE assets are served under
+const DefaultAssetsPath = "/__graphql/assets/"
 
-	"github.com/graphql-go/graphql"
+const (
+	// DefaultAssetBaseURL is the npm CDN IDE assets are loaded from when none are embedded
+	DefaultAssetBaseURL = "//cdn.jsdelivr.net/npm"
+	// DefaultPlaygroundVersion is the graphql-playground-react release the page is pinned to
+	DefaultPlaygroundVersion = "1.7.26"
 )
 
-// renderGraphiQL renders the GraphiQL GUI
-func renderGraphiQL(w http.ResponseWriter, h *Handler, params graphql.Params) {
-	t := template.New("GraphiQL")
-	t, err := t.Parse(graphiqlTemplate)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+// IDE selects the in-browser IDE served to HTML requests
+type IDE int
+
+const (
+	// IDEAuto serves the playground when Config.GraphiQL or Config.Playground
+	// is set and nothing otherwise
+	IDEAuto IDE = iota
+	// IDENone disables the IDE
+	IDENone
+	// IDEPlayground serves graphql-playground
+	IDEPlayground
+	// IDEGraphiQL serves GraphiQL 2 with the explorer plugin
+	IDEGraphiQL
+	// IDEApolloSandbox serves the embedded Apollo Sandbox
+	IDEApolloSandbox
+	// IDEAltair serves the Altair GraphQL client
+	IDEAltair
+)
+
+// assetBase returns the npm root URL IDE assets are loaded from, the
+// embedded Assets mirror the CDN layout below it
+func (h *Handler) assetBase() string {
+	if h.assets == nil {
+		return h.assetBaseURL
 	}
-	// Create variables string
-	_, err = json.MarshalIndent(params.VariableValues, "", "  ")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	return strings.TrimSuffix(h.assetsPath, "/")
+}
+
+// assetsURL returns the base URL of the selected IDE package
+func (h *Handler) assetsURL() string {
+	switch h.ide {
+	case IDEGraphiQL:
+		return h.assetBase() + "/graphiql@" + graphiQLVersion
+	case IDEAltair:
+		return h.assetBase() + "/altair-static@" + altairVersion
+	default:
+		return h.assetBase() + "/graphql-playground-react@" + h.playgroundVersion
+	}
+}
+
+// ideTemplate returns the built-in page template of ide
+func ideTemplate(ide IDE) *template.Template {
+	switch ide {
+	case IDEGraphiQL:
+		return graphiQLPage
+	case IDEApolloSandbox:
+		return sandboxPage
+	case IDEAltair:
+		return altairPage
+	default:
+		return defaultGraphiQLTemplate
+	}
+}
+
+// ideOptions returns the initialization options of the selected IDE
+func (h *Handler) ideOptions(r *http.Request) interface{} {
+	endpoint := h.ideEndpoint(r)
+	subscription := h.subscriptionURL(r)
+	switch h.ide {
+	case IDEGraphiQL:
+		return graphiQLOptions(endpoint, subscription, h.exampleTabs)
+	case IDEApolloSandbox:
+		return sandboxOptions(h.sandboxSettings, r, endpoint, subscription, h.exampleTabs)
+	case IDEAltair:
+		return altairOptions(endpoint, subscription, h.exampleTabs)
+	default:
+		return playgroundOptions(h.playgroundSettings, endpoint, subscription, h.exampleTabs)
+	}
+}
+
+// subscriptionURL returns Config.Subscription, resolving a path against the
+// host of r as a WebSocket URL since IDEs open subscriptions over ws(s)
+func (h *Handler) subscriptionURL(r *http.Request) string {
+	if !strings.HasPrefix(h.subscription, "/") || r.Host == "" {
+		return h.subscription
+	}
+	scheme := "ws"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "wss"
+	}
+	return scheme + "://" + r.Host + h.subscription
+}
+
+// ideEndpoint returns the API path the IDE sends operations to
+func (h *Handler) ideEndpoint(r *http.Request) string {
+	if h.endpoint != "" {
+		return h.endpoint
+	}
+	return r.URL.Path
+}
+
+// isIDERequest reports whether r asks for the IDE page rather than the API
+func (h *Handler) isIDERequest(r *http.Request) bool {
+	if h.ide == IDENone {
+		return false
 	}
-	// Create result string
-	if params.RequestString != "" {
-		_, err := json.MarshalIndent(graphql.Do(params), "", "  ")
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+	if h.idePath != "" {
+		return r.URL.Path == h.idePath && (r.Method == http.MethodGet || r.Method == http.MethodHead)
+	}
+	acceptHeader := r.Header.Get("Accept")
+	_, raw := r.URL.Query()["raw"]
+	return !raw && !strings.Contains(acceptHeader, "application/json") && strings.Contains(acceptHeader, "text/html")
+}
+
+// serveAssets serves embedded IDE assets, it reports whether r was an asset request
+func (h *Handler) serveAssets(w http.ResponseWriter, r *http.Request) bool {
+	if h.assets == nil || !strings.HasPrefix(r.URL.Path, h.assetsPath) {
+		return false
+	}
+	http.StripPrefix(strings.TrimSuffix(h.assetsPath, "/"), http.FileServer(h.assets)).ServeHTTP(w, r)
+	return true
+}
+
+// GraphiQLData is the data IDE page templates are executed with
+type GraphiQLData struct {
+	// Title is the page title from Config.Title
+	Title string
+	// Endpoint is the URL path of the GraphQL API the page was requested on
+	Endpoint string
+	// SubscriptionEndpoint is the subscription URL from Config.Subscription
+	SubscriptionEndpoint string
+	// Assets is the base URL of the IDE package its scripts and styles are loaded from
+	Assets string
+	// AssetBaseURL is the npm root URL IDE dependencies are loaded from
+	AssetBaseURL string
+	// Settings is the JSON encoded IDE initialization options
+	Settings template.JS
+}
+
+// PlaygroundSettings configures the options graphql-playground is initialized with
+type PlaygroundSettings struct {
+	// Theme is the editor theme, "dark" or "light"
+	Theme string
+	// RequestCredentials is the fetch credentials mode, "omit", "include" or "same-origin"
+	RequestCredentials string
+	// PollingInterval enables schema polling at the given interval
+	PollingInterval time.Duration
+	// Headers are sent with every request from the playground
+	Headers map[string]string
+	// Tabs are opened when the playground loads
+	Tabs []PlaygroundTab
+}
+
+// PlaygroundTab is a playground tab opened on page load
+type PlaygroundTab struct {
+	Name string `json:"name,omitempty"`
+	// Endpoint defaults to the endpoint the page was requested on
+	Endpoint  string            `json:"endpoint"`
+	Query     string            `json:"query"`
+	Variables string            `json:"variables,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+}
+
+// playgroundOptions builds the GraphQLPlayground.init options, examples
+// open after the Tabs of s
+func playgroundOptions(s *PlaygroundSettings, endpoint, subscription string, examples []ExampleTab) map[string]interface{} {
+	opts := map[string]interface{}{
+		"endpoint":             endpoint,
+		"setTitle":             false,
+		"subscriptionEndpoint": subscription,
+	}
+	if s == nil {
+		s = &PlaygroundSettings{}
+	}
+	settings := map[string]interface{}{}
+	if s.Theme != "" {
+		settings["editor.theme"] = s.Theme
+	}
+	if s.RequestCredentials != "" {
+		settings["request.credentials"] = s.RequestCredentials
+	}
+	if s.PollingInterval > 0 {
+		settings["schema.polling.enable"] = true
+		settings["schema.polling.interval"] = s.PollingInterval.Milliseconds()
+	}
+	if len(settings) > 0 {
+		opts["settings"] = settings
+	}
+	if len(s.Headers) > 0 {
+		opts["headers"] = s.Headers
+	}
+	if len(s.Tabs) > 0 || len(examples) > 0 {
+		tabs := append(append([]PlaygroundTab{}, s.Tabs...), playgroundTabs(examples)...)
+		for i := range tabs {
+			if tabs[i].Endpoint == "" {
+				tabs[i].Endpoint = endpoint
+			}
 		}
+		opts["tabs"] = tabs
 	}
-	args := map[string]string{
-		"Title":        h.title,
-		"Subscription": h.subscription,
+	return opts
+}
+
+// executeGraphiQL executes t, using its "index" template when it defines one
+func executeGraphiQL(w http.ResponseWriter, t *template.Template, data *GraphiQLData) error {
+	if t.Lookup("index") != nil {
+		return t.ExecuteTemplate(w, "index", data)
 	}
-	err = t.ExecuteTemplate(w, "index", args)
+	return t.Execute(w, data)
+}
+
+// renderGraphiQL renders the GraphiQL GUI
+func renderGraphiQL(w http.ResponseWriter, r *http.Request, h *Handler) {
+	settings, err := json.Marshal(h.ideOptions(r))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data := &GraphiQLData{
+		Title:                h.title,
+		Endpoint:             h.ideEndpoint(r),
+		SubscriptionEndpoint: h.subscriptionURL(r),
+		Assets:               h.assetsURL(),
+		AssetBaseURL:         h.assetBase(),
+		Settings:             template.JS(settings),
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := executeGraphiQL(w, h.graphiqlTemplate, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
-	return
 }
-
-// tmpl is the page template to render GraphiQL
-const graphiqlTemplate = `
-{{ define "index" }}
-<!DOCTYPE html>
-<html>
-<head>
-  <meta http-equiv="Content-Type" content="text/html; charset=utf-8" />
-  <meta name="viewport" content="user-scalable=no, initial-scale=1.0, minimum-scale=1.0, maximum-scale=1.0, minimal-ui">
-  <title>{{.Title}}</title>
-  <link rel="stylesheet" href="//cdn.jsdelivr.net/npm/graphql-playground-react/build/static/css/index.css" />
-  <link rel="shortcut icon" href="/favicon.ico" />
-  <script src="//cdn.jsdelivr.net/npm/graphql-playground-react/build/static/js/middleware.js"></script>
-</head>
-<body>
-  <div id="root">
-    <style>
-      body {
-        background-color: rgb(23, 42, 58);
-        height: 90vh;
-      }
-      #root {
-        height: 100%;
-        width: 100%;
-        display: flex;
-        align-items: center;
-        justify-content: center;
-      }
-      .loading {
-        font-size: 32px;
-        font-weight: 200;
-        color: rgba(255, 255, 255, .6);
-        margin-left: 20px;
-      }
-      img {
-        width: 78px;
-        height: 78px;
-      }
-      .title {
-        font-weight: 400;
-      }
-    </style>
-    <img src='//cdn.jsdelivr.net/npm/graphql-playground-react/build/logo.png' alt=''>
-    <div class="loading"> Loading
-      <span class="title">{{.Title}}</span>
-    </div>
-  </div>
-  <script>window.addEventListener('load', function (event) {
-		GraphQLPlayground.init(document.getElementById('root'), {setTitle:false,subscriptionEndpoint:'{{.Subscription}}'})
-    })</script>
-</body>
-</html>
-{{ end }}
-`
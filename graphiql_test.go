@@ -1,10 +1,19 @@
+//go:build !noide
+// +build !noide
+
 package handler_test
 
 import (
+	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/cxuhua/handler"
 	"github.com/graphql-go/graphql/testutil"
@@ -88,3 +97,310 @@ func TestRenderGraphiQL(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderGraphiQL_EmbeddedAssets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	js := filepath.Join(dir, "graphql-playground-react@"+handler.DefaultPlaygroundVersion, "build", "static", "js")
+	if err := os.MkdirAll(js, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(js, "middleware.js"), []byte("// playground"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := handler.New(&handler.Config{
+		Schema:   &testutil.StarWarsSchema,
+		GraphiQL: true,
+		Assets:   http.Dir(dir),
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "/graphql", nil)
+	req.Header.Set("Accept", "text/html")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	asset := handler.DefaultAssetsPath + "graphql-playground-react@" + handler.DefaultPlaygroundVersion + "/build/static/js/middleware.js"
+	if body := rr.Body.String(); !strings.Contains(body, asset) || strings.Contains(body, "cdn.jsdelivr.net") {
+		t.Fatalf("expected page to reference embedded assets, got %s", body)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, asset, nil)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != "// playground" {
+		t.Fatalf("unexpected asset response %v: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestRenderGraphiQL_AssetBaseURL(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:            &testutil.StarWarsSchema,
+		GraphiQL:          true,
+		AssetBaseURL:      "https://mirror.internal/npm/",
+		PlaygroundVersion: "1.7.20",
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "/graphql", nil)
+	req.Header.Set("Accept", "text/html")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	expected := "https://mirror.internal/npm/graphql-playground-react@1.7.20/build/static/js/middleware.js"
+	if body := rr.Body.String(); !strings.Contains(body, expected) {
+		t.Fatalf("expected page to reference %s, got %s", expected, body)
+	}
+}
+
+func TestRenderGraphiQL_CustomTemplate(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:                 &testutil.StarWarsSchema,
+		GraphiQL:               true,
+		Title:                  "Branded",
+		Subscription:           "/subscriptions",
+		GraphiQLTemplateString: `<title>{{.Title}}</title><script>init({{.Settings}}, {{.Endpoint}})</script>`,
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/graphql", nil)
+	req.Header.Set("Accept", "text/html")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	expected := `<title>Branded</title><script>init({"endpoint":"/api/graphql","setTitle":false,"subscriptionEndpoint":"/subscriptions"}, "/api/graphql")</script>`
+	if body := rr.Body.String(); body != expected {
+		t.Fatalf("wrong body, expected %s, got %s", expected, body)
+	}
+}
+
+func TestRenderGraphiQL_PlaygroundSettings(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:                 &testutil.StarWarsSchema,
+		GraphiQL:               true,
+		GraphiQLTemplateString: `<script>{{.Settings}}</script>`,
+		PlaygroundSettings: &handler.PlaygroundSettings{
+			Theme:              "light",
+			RequestCredentials: "include",
+			PollingInterval:    2 * time.Second,
+			Headers:            map[string]string{"X-Client": "playground"},
+			Tabs: []handler.PlaygroundTab{
+				{Name: "Hero", Query: "{ hero { name } }"},
+			},
+		},
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "/graphql", nil)
+	req.Header.Set("Accept", "text/html")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	var opts map[string]interface{}
+	body := strings.TrimSuffix(strings.TrimPrefix(rr.Body.String(), "<script>"), "</script>")
+	if err := json.Unmarshal([]byte(body), &opts); err != nil {
+		t.Fatalf("settings are not JSON: %v: %s", err, rr.Body.String())
+	}
+	expected := map[string]interface{}{
+		"endpoint":             "/graphql",
+		"setTitle":             false,
+		"subscriptionEndpoint": "",
+		"settings": map[string]interface{}{
+			"editor.theme":            "light",
+			"request.credentials":     "include",
+			"schema.polling.enable":   true,
+			"schema.polling.interval": float64(2000),
+		},
+		"headers": map[string]interface{}{"X-Client": "playground"},
+		"tabs": []interface{}{
+			map[string]interface{}{"name": "Hero", "endpoint": "/graphql", "query": "{ hero { name } }"},
+		},
+	}
+	if !reflect.DeepEqual(opts, expected) {
+		t.Fatalf("wrong settings, diff: %v", testutil.Diff(expected, opts))
+	}
+}
+
+func TestRenderGraphiQL_ExampleTabs(t *testing.T) {
+	tabs := []handler.ExampleTab{
+		{Name: "Hero", Query: "{ hero { name } }"},
+		{Name: "Human", Query: "query($id: String!) { human(id: $id) { name } }", Variables: `{"id":"1000"}`, Headers: map[string]string{"X-Client": "docs"}},
+	}
+	cases := map[handler.IDE][]string{
+		handler.IDEPlayground: {
+			`"tabs":[{"name":"Pinned","endpoint":"/graphql","query":"{ __typename }"},{"name":"Hero","endpoint":"/graphql","query":"{ hero { name } }"},`,
+			`"variables":"{\"id\":\"1000\"}","headers":{"X-Client":"docs"}}]`,
+		},
+		handler.IDEGraphiQL: {
+			`"defaultTabs":[{"query":"# Hero\n{ hero { name } }"},{"headers":"{\"X-Client\":\"docs\"}",`,
+			"defaultTabs: settings.defaultTabs",
+		},
+		handler.IDEAltair: {
+			`"initialWindows":[{"initialName":"Hero","initialQuery":"{ hero { name } }"},{"initialHeaders":{"X-Client":"docs"},"initialName":"Human",`,
+		},
+		handler.IDEApolloSandbox: {
+			`"initialState":{"document":"{ hero { name } }","includeCookies":false}`,
+		},
+	}
+	for ide, expected := range cases {
+		h := handler.New(&handler.Config{
+			Schema:             &testutil.StarWarsSchema,
+			IDE:                ide,
+			ExampleTabs:        tabs,
+			PlaygroundSettings: &handler.PlaygroundSettings{Tabs: []handler.PlaygroundTab{{Name: "Pinned", Query: "{ __typename }"}}},
+		})
+		req, _ := http.NewRequest(http.MethodGet, "/graphql", nil)
+		req.Header.Set("Accept", "text/html")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		for _, expected := range expected {
+			if body := rr.Body.String(); !strings.Contains(body, expected) {
+				t.Fatalf("IDE %d: expected %s to contain %s", ide, body, expected)
+			}
+		}
+	}
+}
+
+func TestRenderGraphiQL_IDE(t *testing.T) {
+	cases := map[string]struct {
+		ide                  handler.IDE
+		graphiqlEnabled      bool
+		expectedContentType  string
+		expectedBodyContains string
+	}{
+		"GraphiQL flag keeps the playground": {
+			graphiqlEnabled:      true,
+			expectedContentType:  "text/html; charset=utf-8",
+			expectedBodyContains: "GraphQLPlayground.init",
+		},
+		"GraphiQL 2 with explorer": {
+			ide:                  handler.IDEGraphiQL,
+			expectedContentType:  "text/html; charset=utf-8",
+			expectedBodyContains: "graphiql@2.4.7/graphiql.min.js",
+		},
+		"Apollo Sandbox": {
+			ide:                  handler.IDEApolloSandbox,
+			expectedContentType:  "text/html; charset=utf-8",
+			expectedBodyContains: `"initialEndpoint":"http://example.com/graphql"`,
+		},
+		"Altair": {
+			ide:                  handler.IDEAltair,
+			expectedContentType:  "text/html; charset=utf-8",
+			expectedBodyContains: `AltairGraphQL.init({"endpointURL":"/graphql"})`,
+		},
+		"IDE disabled": {
+			ide:                 handler.IDENone,
+			graphiqlEnabled:     true,
+			expectedContentType: "application/json; charset=utf-8",
+		},
+	}
+	for tcID, tc := range cases {
+		t.Run(tcID, func(t *testing.T) {
+			h := handler.New(&handler.Config{
+				Schema:   &testutil.StarWarsSchema,
+				GraphiQL: tc.graphiqlEnabled,
+				IDE:      tc.ide,
+			})
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/graphql", nil)
+			req.Header.Set("Accept", "text/html")
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+			if contentType := rr.Header().Get("Content-Type"); contentType != tc.expectedContentType {
+				t.Fatalf("wrong content type, expected %s, got %s", tc.expectedContentType, contentType)
+			}
+			if body := rr.Body.String(); !strings.Contains(body, tc.expectedBodyContains) {
+				t.Fatalf("wrong body, expected %s to contain %s", body, tc.expectedBodyContains)
+			}
+		})
+	}
+}
+
+func TestRenderGraphiQL_SandboxSettings(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema: &testutil.StarWarsSchema,
+		IDE:    handler.IDEApolloSandbox,
+		SandboxSettings: &handler.SandboxSettings{
+			Endpoint: "https://api.example.com/graphql",
+			Document: "{ hero { name } }",
+		},
+	})
+	req, _ := http.NewRequest(http.MethodGet, "/graphql", nil)
+	req.Header.Set("Accept", "text/html")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	body := rr.Body.String()
+	for _, expected := range []string{`"initialEndpoint":"https://api.example.com/graphql"`, `"document":"{ hero { name } }"`} {
+		if !strings.Contains(body, expected) {
+			t.Fatalf("wrong body, expected %s to contain %s", body, expected)
+		}
+	}
+}
+
+func TestRenderGraphiQL_IDEPath(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:   &testutil.StarWarsSchema,
+		GraphiQL: true,
+		IDEPath:  "/playground",
+		Endpoint: "/graphql",
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "/playground", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if contentType := rr.Header().Get("Content-Type"); contentType != "text/html; charset=utf-8" {
+		t.Fatalf("wrong content type on IDE path, got %s", contentType)
+	}
+	if body := rr.Body.String(); !strings.Contains(body, `"endpoint":"/graphql"`) {
+		t.Fatalf("expected IDE to target the API endpoint, got %s", body)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "/graphql?query={hero{name}}", nil)
+	req.Header.Set("Accept", "text/html")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if contentType := rr.Header().Get("Content-Type"); contentType != "application/json; charset=utf-8" {
+		t.Fatalf("API endpoint must not sniff Accept, got %s", contentType)
+	}
+}
+
+func TestRenderGraphiQL_Subscription(t *testing.T) {
+	for ide, expected := range map[handler.IDE]string{
+		handler.IDEPlayground:    `"subscriptionEndpoint":"wss://example.com/subscriptions"`,
+		handler.IDEGraphiQL:      `"subscriptionUrl":"wss://example.com/subscriptions"`,
+		handler.IDEAltair:        `"subscriptionsEndpoint":"wss://example.com/subscriptions"`,
+		handler.IDEApolloSandbox: `"initialSubscriptionEndpoint":"wss://example.com/subscriptions"`,
+	} {
+		h := handler.New(&handler.Config{
+			Schema:       &testutil.StarWarsSchema,
+			IDE:          ide,
+			Subscription: "/subscriptions",
+		})
+		req, _ := http.NewRequest(http.MethodGet, "https://example.com/graphql", nil)
+		req.Header.Set("Accept", "text/html")
+		req.Header.Set("X-Forwarded-Proto", "https")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if body := rr.Body.String(); !strings.Contains(body, expected) {
+			t.Fatalf("IDE %v: expected %s to contain %s", ide, body, expected)
+		}
+	}
+}
+
+func TestRenderGraphiQL_NewHandlerDefault(t *testing.T) {
+	h := handler.NewHandler(&testutil.StarWarsSchema)
+	req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}", nil)
+	req.Header.Set("Accept", "text/html")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if ct := resp.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Fatalf("expected the IDE, got %q", ct)
+	}
+}
+
+func TestRenderGraphiQL_PlaygroundFlag(t *testing.T) {
+	h := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema, Playground: true})
+	req, _ := http.NewRequest("GET", "/graphql", nil)
+	req.Header.Set("Accept", "text/html")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if !strings.Contains(resp.Body.String(), "graphql-playground-react") {
+		t.Fatalf("expected the playground, got %s", resp.Body.String())
+	}
+}
@@ -1,12 +1,24 @@
 package handler
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
 	"io/ioutil"
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/graphql-go/graphql/gqlerrors"
 
@@ -26,76 +38,182 @@ const (
 	ContentTypeMultipartFormData = "multipart/form-data"
 )
 
-type ResultCallbackFn func(ctx context.Context, params *graphql.Params, result *graphql.Result, responseBody []byte)
-
 type Handler struct {
-	Schema       *graphql.Schema
-	pretty       bool
-	graphiql     bool
-	subscription string
-	title        string
-	entryFn      EntryFn
-	exitFn       ExitFn
-	finishFn     FinishFn
+	// Schema is the schema the handler was created with, see SetSchema
+	Schema              *graphql.Schema
+	resultMarshaler     ResultMarshaler
+	prettyParam         bool
+	postOnly            bool
+	cors                *CORSConfig
+	logger              Logger
+	logSlowQueries      bool
+	ide                 IDE
+	subscription        string
+	title               string
+	entryFn             EntryFn
+	contextFn           ContextFn
+	authFn              AuthFn
+	authorizeFn         AuthorizeOperationFn
+	resultFn            ResultFn
+	paramsFn            ParamsFn
+	variablesFn         VariablesFn
+	resultCallbackFn    ResultCallbackFn
+	formatErrorFn       FormatErrorFn
+	exitFn              ExitFn
+	finishFn            FinishFn
+	headersFn           HeadersFn
+	assets              http.FileSystem
+	assetsPath          string
+	assetBaseURL        string
+	playgroundVersion   string
+	graphiqlTemplate    *template.Template
+	playgroundSettings  *PlaygroundSettings
+	sandboxSettings     *SandboxSettings
+	exampleTabs         []ExampleTab
+	idePath             string
+	endpoint            string
+	incremental         bool
+	responseCache       *ResponseCacheConfig
+	introspectionCache  *ResponseCacheConfig
+	maskErrorsEnabled   bool
+	maskErrorFn         MaskErrorFn
+	cacheControlHeaders bool
+	etags               bool
+	timeout             time.Duration
+	shapes              *ShapeSampler
+	cacheControl        *CacheControlConfig
+	schemaReportFn      func(report *SchemaReport)
+	schemaSelectorFn    SchemaSelectorFn
+	slo                 *SLOTracker
+	degrader            *Degrader
+	sdlPath             string
+	healthPath          string
+	readyPath           string
+	readyQuery          string
+	fieldTimeouts       map[string]FieldTimeout
+	watchdog            *Watchdog
+	rateLimiter         RateLimiter
+	policies            *PolicyFile
+	warmRestart         *WarmRestartConfig
+	compressor          Compressor
+	codecs              []Codec
+	slowQueryThreshold  time.Duration
+	slowQueryFn         SlowQueryFn
+	documents           *documentCache
+	executor            Executor
+	pubSub              PubSub
+	coalescer           *coalescer
+	explain             bool
+	poolRequestOptions  bool
+	operations          *OperationRegistry
+	operationFilter     operationFilter
+	queryLimits         queryLimits
+	uploadPolicy        uploadPolicy
+	uploadStore         UploadStore
+	resumableUploads    *ResumableUploads
+	uploadProcessors    map[string]UploadProcessorFn
+	onUploadCleanup     UploadCleanupFn
+	uploadProgressFn    UploadProgressFn
+	uploadStallTimeout  time.Duration
+	cleanupUploadsFirst bool
+	// schema holds the schemaVersion requests execute against
+	schema atomic.Value
+	swapMu sync.Mutex
+	drain  drain
+}
+
+// limitRequest reports whether limiter rejected r, the rejection is written
+// to w
+func (h *Handler) limitRequest(ctx context.Context, w http.ResponseWriter, r *http.Request, limiter RateLimiter, opts *RequestOptions) bool {
+	info, err := limiter.Allow(ctx, r, opts)
+	rejected := errors.Is(err, ErrRateLimited)
+	writeRateLimitHeaders(w, info, rejected)
+	if err == nil {
+		return false
+	}
+	status := http.StatusInternalServerError
+	if rejected {
+		status = http.StatusTooManyRequests
+	} else {
+		h.logger.ErrorContext(ctx, "rate limiter failed", requestAttrs(r, "error", err)...)
+	}
+	h.writeResult(ctx, w, r, status, &graphql.Result{
+		Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(err)},
+	})
+	return true
 }
 
 type RequestOptions struct {
 	Query         string                             `json:"query" url:"query" schema:"query"`
 	Variables     map[string]interface{}             `json:"variables" url:"variables" schema:"variables"`
 	OperationName string                             `json:"operationName" url:"operationName" schema:"operationName"`
+	Extensions    map[string]interface{}             `json:"extensions" url:"extensions" schema:"extensions"`
 	File          map[string][]*multipart.FileHeader `json:"-"`
+
+	// pooled is set on the options of the request options pool,
+	// pooledVariables is the map the pool reuses
+	pooled          bool
+	pooledVariables map[string]interface{}
+	// refs are the files an UploadStore saved while the body was parsed
+	refs map[string][]UploadRef
+	// parsed is the document of Query, see parseRequest
+	parsed *parsedRequest
 }
 
-func getFromMultipartForm(form *multipart.Form) *RequestOptions {
+func getFromMultipartForm(form *multipart.Form) (*RequestOptions, error) {
 	values := url.Values(form.Value)
 	query := values.Get("query")
-	if query != "" {
+	// registered operations are referenced without a query
+	if query != "" || values.Get("operationName") != "" || values.Get("extensions") != "" {
 		// get variables map
-		variables := make(map[string]interface{}, len(values))
-		variablesStr := values.Get("variables")
-		_ = json.Unmarshal([]byte(variablesStr), &variables)
+		variables, err := decodeVariables([]byte(values.Get("variables")))
+		if err != nil {
+			return nil, err
+		}
+		if variables == nil {
+			variables = make(map[string]interface{})
+		}
+		extensions, err := decodeExtensions([]byte(values.Get("extensions")))
+		if err != nil {
+			return nil, err
+		}
 		return &RequestOptions{
 			Query:         query,
 			Variables:     variables,
 			OperationName: values.Get("operationName"),
+			Extensions:    extensions,
 			File:          form.File,
-		}
+		}, nil
 	}
 	operations := values.Get("operations")
 	if operations != "" {
 		//map files
-		maps := values.Get("map")
-		mapv := url.Values{}
-		//["0"]  = ["variables.filedname"]
+		paths, err := multipartPaths(values.Get("map"))
+		if err != nil {
+			return nil, err
+		}
+		// files are keyed by their path below the variables, e.g. "file" or
+		// "input.files.0"
 		files := map[string][]*multipart.FileHeader{}
-		_ = json.Unmarshal([]byte(maps), &mapv)
-		for k, v := range mapv {
+		for k, v := range paths {
 			fi, has := form.File[k]
 			if !has {
 				continue
 			}
-			if len(v) == 0 {
-				continue
-			}
-			ps := strings.Split(v[0], ".")
-			if len(ps) != 2 {
-				continue
+			for _, path := range v {
+				files[path] = append(files[path], fi...)
 			}
-			files[ps[1]] = fi
 		}
-		opts := make(map[string]interface{})
-		_ = json.Unmarshal([]byte(operations), &opts)
-		operationName := ""
-		if str := opts["operationName"]; str != nil {
-			operationName = str.(string)
+		var opts jsonRequestOptions
+		if err := json.Unmarshal([]byte(operations), &opts); err != nil {
+			return nil, fmt.Errorf("invalid multipart operations field: %v", err)
 		}
-		query := ""
-		if str := opts["query"]; str != nil {
-			query = str.(string)
+		variables, err := decodeVariables(opts.Variables)
+		if err != nil {
+			return nil, err
 		}
-		variables := make(map[string]interface{})
-		if str := opts["variables"]; str != nil {
-			variables = opts["variables"].(map[string]interface{})
+		if variables == nil {
+			variables = make(map[string]interface{})
 		}
 		for k := range variables {
 			_, has := files[k]
@@ -103,136 +221,689 @@ func getFromMultipartForm(form *multipart.Form) *RequestOptions {
 				variables[k] = k
 			}
 		}
+		extensions, err := decodeExtensions(opts.Extensions)
+		if err != nil {
+			return nil, err
+		}
 		return &RequestOptions{
-			Query:         query,
+			Query:         opts.Query,
 			Variables:     variables,
-			OperationName: operationName,
+			OperationName: opts.OperationName,
+			Extensions:    extensions,
 			File:          files,
+		}, nil
+	}
+	return nil, nil
+}
+
+// multipartPaths decodes the "map" field of multipart operations, the paths
+// below the variables of every file field
+func multipartPaths(maps string) (map[string][]string, error) {
+	//["0"]  = ["variables.filedname"]
+	mapv := url.Values{}
+	if maps != "" {
+		if err := json.Unmarshal([]byte(maps), &mapv); err != nil {
+			return nil, fmt.Errorf("invalid multipart map field: %v", err)
 		}
 	}
-	return nil
+	paths := make(map[string][]string, len(mapv))
+	for k, v := range mapv {
+		for _, path := range v {
+			if ps := strings.SplitN(path, ".", 2); len(ps) == 2 {
+				paths[k] = append(paths[k], ps[1])
+			}
+		}
+	}
+	return paths, nil
 }
 
-func getFromForm(values url.Values) *RequestOptions {
-	query := values.Get("query")
-	if query != "" {
-		// get variables map
-		variables := make(map[string]interface{}, len(values))
-		variablesStr := values.Get("variables")
-		_ = json.Unmarshal([]byte(variablesStr), &variables)
-		return &RequestOptions{
-			Query:         query,
-			Variables:     variables,
-			OperationName: values.Get("operationName"),
+func getFromForm(values url.Values, pool bool) (*RequestOptions, error) {
+	if values.Get("query") != "" {
+		return formParams(values).options(pool)
+	}
+	return nil, nil
+}
+
+// jsonRequestOptions is the wire form of a JSON request body, variables may
+// be sent either as an object or as a JSON encoded string
+type jsonRequestOptions struct {
+	Query         string          `json:"query"`
+	Variables     json.RawMessage `json:"variables"`
+	OperationName string          `json:"operationName"`
+	Extensions    json.RawMessage `json:"extensions"`
+}
+
+// decodeObject decodes the named field sent as a JSON object or as a string
+// containing a JSON object into dst, a new map when dst is nil. Empty input
+// and null yield a nil map
+func decodeObject(name string, data []byte, dst map[string]interface{}) (map[string]interface{}, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return nil, nil
+	}
+	if data[0] == '"' {
+		var str string
+		if err := json.Unmarshal(data, &str); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", name, err)
 		}
+		return decodeObject(name, []byte(str), dst)
 	}
-	return nil
+	obj := dst
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", name, err)
+	}
+	return obj, nil
+}
+
+func decodeVariables(data []byte) (map[string]interface{}, error) {
+	return decodeObject("variables", data, nil)
+}
+
+func decodeExtensions(data []byte) (map[string]interface{}, error) {
+	return decodeObject("extensions", data, nil)
+}
+
+func getFromJSON(body []byte, pool bool) (*RequestOptions, error) {
+	var opts jsonRequestOptions
+	if err := json.Unmarshal(body, &opts); err != nil {
+		return nil, fmt.Errorf("invalid JSON body: %v", err)
+	}
+	reqOpts := newRequestOptions(pool)
+	variables, err := decodeObject("variables", opts.Variables, reqOpts.Variables)
+	if err != nil {
+		return nil, err
+	}
+	extensions, err := decodeExtensions(opts.Extensions)
+	if err != nil {
+		return nil, err
+	}
+	reqOpts.Query = opts.Query
+	reqOpts.setVariables(variables)
+	reqOpts.OperationName = opts.OperationName
+	reqOpts.Extensions = extensions
+	return reqOpts, nil
 }
 
-// RequestOptions Parses a http.Request into GraphQL request options struct
+// NewRequestOptions Parses a http.Request into GraphQL request options struct,
+// requests that cannot be parsed yield empty options
 func NewRequestOptions(r *http.Request) *RequestOptions {
-	if reqOpt := getFromForm(r.URL.Query()); reqOpt != nil {
-		return reqOpt
+	opts, err := ParseRequestOptions(r)
+	if err != nil {
+		return &RequestOptions{}
+	}
+	return opts
+}
+
+// ParseRequestOptions Parses a http.Request into GraphQL request options struct
+// and reports malformed bodies, variables and multipart forms as errors
+func ParseRequestOptions(r *http.Request) (*RequestOptions, error) {
+	return parseRequestOptions(r, uploadPolicy{})
+}
+
+// ParseJSONRequestOptions decodes the options of a JSON request body
+func ParseJSONRequestOptions(body []byte) (*RequestOptions, error) {
+	return getFromJSON(body, false)
+}
+
+// ParseRequestOptions parses r like the package level ParseRequestOptions,
+// enforcing the upload limits and rules of h
+func (h *Handler) ParseRequestOptions(r *http.Request) (*RequestOptions, error) {
+	return parseRequestOptions(r, h.uploadPolicy)
+}
+
+func parseRequestOptions(r *http.Request, limits uploadPolicy) (*RequestOptions, error) {
+	params := parseQueryParams(r.URL.RawQuery)
+	if params.query != "" {
+		return params.options(limits.pool)
 	}
 
 	if r.Method != http.MethodPost {
-		return &RequestOptions{}
+		// registered operations are referenced without a query
+		if params.operationName != "" || params.extensions != "" {
+			return params.options(limits.pool)
+		}
+		return &RequestOptions{}, nil
 	}
 
 	if r.Body == nil {
-		return &RequestOptions{}
+		return &RequestOptions{}, nil
 	}
 
 	contentTypeStr := r.Header.Get("Content-Type")
 	contentTypeTokens := strings.Split(contentTypeStr, ";")
-	contentType := contentTypeTokens[0]
+	contentType := strings.TrimSpace(contentTypeTokens[0])
 
 	switch contentType {
 	case ContentTypeGraphQL:
 		body, err := ioutil.ReadAll(r.Body)
 		if err != nil {
-			return &RequestOptions{}
+			return nil, fmt.Errorf("read request body: %v", err)
 		}
 		return &RequestOptions{
 			Query: string(body),
-		}
+		}, nil
 	case ContentTypeFormURLEncoded:
 		if err := r.ParseForm(); err != nil {
-			return &RequestOptions{}
+			return nil, fmt.Errorf("invalid form body: %v", err)
 		}
-		if reqOpt := getFromForm(r.PostForm); reqOpt != nil {
-			return reqOpt
+		reqOpt, err := getFromForm(r.PostForm, limits.pool)
+		if err != nil {
+			return nil, err
 		}
-		return &RequestOptions{}
+		if reqOpt != nil {
+			return reqOpt, nil
+		}
+		return &RequestOptions{}, nil
 	case ContentTypeMultipartFormData:
-		if err := r.ParseMultipartForm(MaxUploadMemorySize); err != nil {
-			return &RequestOptions{}
+		if limits.store != nil {
+			opts, err := readStoredMultipartForm(r, limits)
+			switch err.(type) {
+			case nil:
+			case *UploadLimitError, *uploadStoreError:
+				return nil, err
+			default:
+				return nil, fmt.Errorf("invalid multipart body: %v", err)
+			}
+			if opts == nil {
+				opts = &RequestOptions{}
+			}
+			return opts, nil
 		}
-		if reqOpt := getFromMultipartForm(r.MultipartForm); reqOpt != nil {
-			return reqOpt
+		if limits.enabled() {
+			if _, err := readLimitedMultipartForm(r, limits); err != nil {
+				if _, ok := err.(*UploadLimitError); ok {
+					return nil, err
+				}
+				return nil, fmt.Errorf("invalid multipart body: %v", err)
+			}
+		} else if err := r.ParseMultipartForm(MaxUploadMemorySize); err != nil {
+			return nil, fmt.Errorf("invalid multipart body: %v", err)
 		}
-		return &RequestOptions{}
-	case ContentTypeJSON:
-		//fallthrough
-		return &RequestOptions{}
+		reqOpt, err := getFromMultipartForm(r.MultipartForm)
+		if err != nil {
+			return nil, err
+		}
+		if reqOpt != nil {
+			return reqOpt, nil
+		}
+		return &RequestOptions{}, nil
 	default:
-		var opts RequestOptions
+		// application/json, codecs and unknown content types
 		body, err := ioutil.ReadAll(r.Body)
 		if err != nil {
-			return &opts
+			return nil, fmt.Errorf("read request body: %v", err)
+		}
+		if len(bytes.TrimSpace(body)) == 0 {
+			return &RequestOptions{}, nil
+		}
+		for _, codec := range limits.codecs {
+			if codec.ContentType() == contentType {
+				return decodeRequest(codec, body)
+			}
 		}
-		_ = json.Unmarshal(body, &opts)
-		return &opts
+		return getFromJSON(body, limits.pool)
 	}
 }
 
 // ContextHandler provides an entrypoint into executing graphQL queries with a
 // user-provided context.
 func (h *Handler) ContextHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-	var buff []byte
+	h.ContextHandlerWithParser(ctx, w, r, h.ParseRequestOptions)
+}
+
+// ContextHandlerWithParser is ContextHandler with parse replacing
+// ParseRequestOptions, e.g. for adapters of other HTTP servers parsing the
+// options from their own request types. parse is only called for operations
+func (h *Handler) ContextHandlerWithParser(ctx context.Context, w http.ResponseWriter, r *http.Request, parse func(r *http.Request) (*RequestOptions, error)) {
+	if h.serveResumableUpload(ctx, w, r) {
+		return
+	}
+	w, answered := h.checkMethod(ctx, w, r)
+	if answered {
+		return
+	}
+	if h.serveAssets(w, r) || h.serveSDL(w, r) || h.serveHealth(w, r) {
+		return
+	}
+	if h.idePath != "" && h.isIDERequest(r) {
+		renderGraphiQL(w, r, h)
+		return
+	}
+	if h.postOnly && h.checkPostOnly(ctx, w, r) {
+		return
+	}
+	if !h.drain.begin() {
+		h.rejectShutdown(ctx, w, r)
+		return
+	}
+	defer h.drain.end()
 	if h.exitFn != nil {
 		defer h.exitFn(ctx, w, r)
 	}
+	defer h.logPanic(ctx, r)
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+	if h.contextFn != nil {
+		fnCtx, err := h.contextFn(ctx, r)
+		if err != nil {
+			h.rejectRequest(ctx, w, r, err)
+			return
+		}
+		if fnCtx != nil {
+			ctx = fnCtx
+		}
+	}
+	ctx = withClientInfo(ctx, r)
+	if h.pubSub != nil {
+		ctx = context.WithValue(ctx, pubSubKey{}, h.pubSub)
+	}
+	// the files are removed once the response is written, net/http would
+	// only remove them after the handler returns
+	defer func() { h.cleanupUploads(ctx, r) }()
+	var progress *progressReader
+	if h.uploadProgressFn != nil || h.uploadStallTimeout > 0 {
+		if progress = h.trackUploadProgress(ctx, w, r); progress != nil {
+			defer progress.close()
+		}
+	}
 	// get query
-	opts := NewRequestOptions(r)
+	opts, err := parse(r)
+	if err != nil {
+		status := http.StatusBadRequest
+		switch e := err.(type) {
+		case *UploadLimitError:
+			status = e.status()
+		case *uploadStoreError:
+			status = http.StatusInternalServerError
+		}
+		if progress != nil && progress.error() != nil {
+			if err = progress.error(); isUploadStalled(err) {
+				status = http.StatusRequestTimeout
+			}
+		}
+		if status == http.StatusInternalServerError {
+			h.logger.ErrorContext(ctx, "saving uploads failed", requestAttrs(r, "error", err)...)
+		} else {
+			h.logger.WarnContext(ctx, "graphql request rejected", requestAttrs(r, "status", status, "error", err)...)
+		}
+		h.writeResult(ctx, w, r, status, &graphql.Result{
+			Errors: []gqlerrors.FormattedError{formatError(err)},
+		})
+		return
+	}
+	if h.poolRequestOptions {
+		defer releaseRequestOptions(opts)
+	}
+	if err := h.queryLimits.checkSource(opts.Query); err != nil {
+		h.writeResult(ctx, w, r, err.(*QueryLimitError).status(), &graphql.Result{
+			Errors: []gqlerrors.FormattedError{formatError(err)},
+		})
+		return
+	}
+	if opts.refs != nil {
+		ctx = context.WithValue(ctx, uploadsKey{}, opts.refs)
+	}
+	if h.uploadStore != nil && len(opts.File) > 0 {
+		if ctx, err = saveUploads(ctx, h.uploadStore, r.MultipartForm, opts); err != nil {
+			h.logger.ErrorContext(ctx, "saving uploads failed", requestAttrs(r, "error", err)...)
+			h.writeResult(ctx, w, r, http.StatusInternalServerError, &graphql.Result{
+				Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(err)},
+			})
+			return
+		}
+	}
+	if h.operations != nil {
+		if err := h.operations.resolve(opts); err != nil {
+			h.writeResult(ctx, w, r, http.StatusBadRequest, &graphql.Result{
+				Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(err)},
+			})
+			return
+		}
+	}
+	if h.compressor != nil {
+		ctx = withOperationHash(ctx, opts)
+	}
+	if status, err := h.checkOperation(opts); err != nil {
+		h.writeResult(ctx, w, r, status, &graphql.Result{
+			Errors: []gqlerrors.FormattedError{formatError(err)},
+		})
+		return
+	}
+	policies := h.policies.current()
+	limiter := h.rateLimiter
+	if tier := policies.rateLimiter(opts); tier != nil {
+		limiter = tier
+	}
+	if limiter != nil && h.limitRequest(ctx, w, r, limiter, opts) {
+		return
+	}
+	if h.authFn != nil {
+		authCtx, err := h.authFn(ctx, r, opts)
+		if err != nil {
+			h.rejectRequest(ctx, w, r, err)
+			return
+		}
+		if authCtx != nil {
+			ctx = authCtx
+		}
+	}
+	if h.authorizeFn != nil && h.authorizeOperation(ctx, w, r, opts) {
+		return
+	}
+	if h.variablesFn != nil {
+		if err := h.variablesFn(ctx, opts); err != nil {
+			h.writeResult(ctx, w, r, http.StatusBadRequest, &graphql.Result{
+				Errors: []gqlerrors.FormattedError{formatError(err)},
+			})
+			return
+		}
+	}
+	started := time.Now()
+	version := h.currentSchema()
+	schema, schemaName, err := h.selectSchema(ctx, r, version)
+	if err != nil {
+		h.writeResult(ctx, w, r, http.StatusNotFound, &graphql.Result{
+			Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(err)},
+		})
+		return
+	}
+	// a schema declaring its own Upload scalar gets the multipart keys
+	if servesUploads(schema) {
+		mapUploads(ctx, opts)
+		ids, err := h.mapResumableUploads(opts)
+		if err != nil {
+			h.writeResult(ctx, w, r, http.StatusBadRequest, &graphql.Result{
+				Errors: []gqlerrors.FormattedError{formatError(err)},
+			})
+			return
+		}
+		// the uploads are passed to this operation only
+		defer h.resumableUploads.release(ids)
+		if err := h.processUploads(ctx, opts); err != nil {
+			h.logger.WarnContext(ctx, "processing uploads failed", requestAttrs(r, "error", err)...)
+			h.writeResult(ctx, w, r, http.StatusUnprocessableEntity, &graphql.Result{
+				Errors: []gqlerrors.FormattedError{formatError(err)},
+			})
+			return
+		}
+	}
+	var routeParams map[string]interface{}
+	if h.paramsFn != nil {
+		if routeParams = h.paramsFn(r); routeParams != nil {
+			ctx = context.WithValue(ctx, routeParamsKey{}, routeParams)
+		}
+	}
+	if h.wantsExplain(r) && !(h.idePath == "" && h.isIDERequest(r)) {
+		w.Header().Set("Cache-Control", "no-store")
+		h.writeResult(ctx, w, r, http.StatusOK, h.explainOperation(ctx, r, opts, schema, schemaName, version.generation))
+		return
+	}
+	estimate, err := policies.estimate(schema, opts)
+	if err != nil {
+		h.writeResult(ctx, w, r, err.(*QueryLimitError).status(), &graphql.Result{
+			Errors: []gqlerrors.FormattedError{formatError(err)},
+		})
+		return
+	}
 	// execute graphql query
 	params := graphql.Params{
-		Schema:         *h.Schema,
+		Schema:         *schema,
 		RequestString:  opts.Query,
 		VariableValues: opts.Variables,
 		OperationName:  opts.OperationName,
 		Context:        ctx,
 	}
-	var err error
 	var result *graphql.Result
 	if h.entryFn != nil {
 		params.RootObject, err = h.entryFn(ctx, r, opts)
 	}
+	if len(routeParams) > 0 && err == nil {
+		params.RootObject = withRouteParams(params.RootObject, routeParams)
+	}
+	// plan is the incremental delivery in parts, nil when the result is
+	// delivered at once
+	var plan *incrementalPlan
+	if err == nil && h.incremental && usesIncrementalDirectives(opts.Query) &&
+		!(h.idePath == "" && h.isIDERequest(r)) {
+		if plan = planIncremental(opts, params); plan != nil && !plan.inParts(w, r) {
+			// delivered inline, the directives are gone from the document
+			params.RequestString = plan.strippedQuery()
+			plan = nil
+		}
+	}
+	var cacheKey string
+	responseCache := h.responseCache
+	var cc *cacheControl
+	// only GET queries are idempotent
+	etag := h.etags && r.Method == http.MethodGet && isQuery(opts)
+	if err == nil && h.responseCache != nil && !(h.idePath == "" && h.isIDERequest(r)) {
+		if cacheKey = h.responseCache.cacheKey(ctx, r, opts, h.cacheScope(r, schemaName, version.generation)); cacheKey != "" {
+			if plan != nil {
+				// the parts are kept apart from the inline response
+				cacheKey = incrementalCacheKey(cacheKey)
+				if buff, ok := h.responseCache.Store.Get(ctx, cacheKey); ok && h.replayParts(ctx, w, r, buff) {
+					return
+				}
+			} else if buff, ok := h.responseCache.Store.Get(ctx, cacheKey); ok {
+				h.writeQueryBody(ctx, w, r, buff, etag)
+				return
+			}
+		}
+	}
+	if plan != nil {
+		var ttl time.Duration
+		if cacheKey != "" {
+			ttl = estimate.cacheTTL(h.responseCache.TTL)
+		}
+		h.serveIncremental(ctx, w, r, opts, params, plan, started, cacheKey, ttl)
+		return
+	}
+	if err == nil && cacheKey == "" && h.introspectionCache != nil && !(h.idePath == "" && h.isIDERequest(r)) && isIntrospection(opts) {
+		if cacheKey = h.introspectionCache.cacheKey(ctx, r, opts, h.cacheScope(r, schemaName, version.generation)); cacheKey != "" {
+			if buff, ok := h.introspectionCache.Store.Get(ctx, cacheKey); ok {
+				h.writeQueryBody(ctx, w, r, buff, etag)
+				return
+			}
+			responseCache = h.introspectionCache
+		}
+	}
 	if err != nil {
 		result = &graphql.Result{
 			Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(err)},
 		}
 	} else {
-		result = graphql.Do(params)
+		if h.cacheControlHeaders {
+			cc = newCacheControl()
+			params.Context = context.WithValue(params.Context, cacheControlKey{}, cc)
+		}
+		var fw *fieldWarnings
+		if len(h.fieldTimeouts) > 0 {
+			fw = &fieldWarnings{}
+			params.Context = context.WithValue(params.Context, fieldWarningsKey{}, fw)
+		}
+		scope := fmt.Sprintf("%s#%d", schemaName, version.generation)
+		do := func(params graphql.Params) *graphql.Result {
+			return h.execute(params, scope)
+		}
+		run := do
+		if h.watchdog != nil && h.watchdog.Ceiling > 0 {
+			run = func(params graphql.Params) *graphql.Result {
+				return h.watchdog.execute(params.Context, params, opts, do)
+			}
+		}
+		if h.coalescer != nil {
+			result, cc, fw = h.coalescer.do(r, opts, scope, params, cc, fw, run)
+		} else {
+			result = run(params)
+		}
+		if warnings := fw.list(); warnings != nil {
+			if result.Extensions == nil {
+				result.Extensions = map[string]interface{}{}
+			}
+			result.Extensions["warnings"] = warnings
+		}
+	}
+	h.finishErrors(params.Context, result)
+	if h.resultFn != nil {
+		if processed := h.resultFn(params.Context, &params, result); processed != nil {
+			result = processed
+		}
+	}
+	if h.idePath == "" && h.isIDERequest(r) {
+		renderGraphiQL(w, r, h)
+		return
+	}
+	if h.slo != nil {
+		h.slo.observe(operationKey(opts), time.Since(started), result.HasErrors())
 	}
-	if h.graphiql {
-		acceptHeader := r.Header.Get("Accept")
-		_, raw := r.URL.Query()["raw"]
-		if !raw && !strings.Contains(acceptHeader, "application/json") && strings.Contains(acceptHeader, "text/html") {
-			renderGraphiQL(w, h, params)
+	h.reportSlowQuery(ctx, opts, time.Since(started), result.HasErrors())
+	if h.shapes != nil && err == nil && result.Data != nil {
+		h.shapes.observe(opts, result.Data)
+	}
+	if cc != nil && !result.HasErrors() {
+		if header := cc.header(); header != "" {
+			w.Header().Set("Cache-Control", header)
+		}
+	}
+	if h.headersFn != nil {
+		h.headersFn(params.Context, &params, result, w.Header())
+	}
+	buff := h.marshalResult(r, result)
+	h.writeQueryBody(ctx, w, r, buff, etag)
+	if h.resultCallbackFn != nil {
+		h.resultCallbackFn(ctx, &params, result, buff)
+	}
+	if cacheKey != "" && !result.HasErrors() {
+		ttl := responseCache.TTL
+		if responseCache == h.responseCache {
+			ttl = estimate.cacheTTL(ttl)
+		}
+		responseCache.Store.Set(ctx, cacheKey, buff, ttl)
+	}
+}
+
+// finishErrors codes, masks and formats the errors of an executed result
+func (h *Handler) finishErrors(ctx context.Context, result *graphql.Result) {
+	codeErrors(result)
+	h.maskErrors(ctx, result)
+	h.formatErrors(result)
+}
+
+// rejectRequest answers the ContextFn or AuthFn error err with 401
+// Unauthorized, or 403 Forbidden when it wraps ErrForbidden
+func (h *Handler) rejectRequest(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	if h.idePath == "" && h.isIDERequest(r) {
+		// the page is no secret, the operations it sends are authorized
+		renderGraphiQL(w, r, h)
+		return
+	}
+	status, code := http.StatusUnauthorized, CodeUnauthenticated
+	if errors.Is(err, ErrForbidden) {
+		status, code = http.StatusForbidden, CodeForbidden
+	}
+	if errorCode(err) == "" {
+		err = WithCode(err, code)
+	}
+	h.writeResult(ctx, w, r, status, &graphql.Result{
+		Errors: []gqlerrors.FormattedError{formatError(err)},
+	})
+}
+
+// cacheScope identifies the schema and the encoding of cached responses
+func (h *Handler) cacheScope(r *http.Request, schemaName string, generation uint64) string {
+	scope := fmt.Sprintf("%s#%d", schemaName, generation)
+	if codec := h.responseCodec(r); codec != nil {
+		scope += "#" + codec.ContentType()
+	} else if pretty, ok := h.prettyOverride(r); ok {
+		scope += "#pretty=" + strconv.FormatBool(pretty)
+	}
+	return scope
+}
+
+// formatError formats err, keeping the extensions of errors implementing
+// gqlerrors.ExtendedError and the code of CodedErrors
+func formatError(err error) gqlerrors.FormattedError {
+	formatted := gqlerrors.FormatError(err)
+	if extended, ok := err.(gqlerrors.ExtendedError); ok && formatted.Extensions == nil {
+		formatted.Extensions = extended.Extensions()
+	}
+	return withCode(formatted, err)
+}
+
+// writeResult serializes result as the response body
+func (h *Handler) writeResult(ctx context.Context, w http.ResponseWriter, r *http.Request, status int, result *graphql.Result) {
+	h.writeBody(ctx, w, r, status, h.marshalResult(r, result))
+}
+
+// marshalResult serializes result in the encoding r accepts
+func (h *Handler) marshalResult(r *http.Request, result *graphql.Result) []byte {
+	if codec := h.responseCodec(r); codec != nil {
+		buff, err := codec.Marshal(ResultValue(result))
+		if err == nil {
+			return buff
+		}
+		h.logger.ErrorContext(r.Context(), "marshaling result failed", requestAttrs(r, "error", err)...)
+		buff, _ = codec.Marshal(ResultValue(&graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(err)}}))
+		return buff
+	}
+	marshaler := h.resultMarshalerOf(r)
+	buff, err := marshaler.MarshalResult(result)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "marshaling result failed", requestAttrs(r, "error", err)...)
+		buff, _ = marshaler.MarshalResult(&graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(err)}})
+	}
+	return buff
+}
+
+// writeQueryBody writes the response of a query, with etag set it is tagged
+// and clients already holding the same body get 304 Not Modified
+func (h *Handler) writeQueryBody(ctx context.Context, w http.ResponseWriter, r *http.Request, buff []byte, etag bool) {
+	if etag {
+		sum := sha256.Sum256(buff)
+		tag := `"` + hex.EncodeToString(sum[:16]) + `"`
+		w.Header().Set("ETag", tag)
+		if etagMatches(r.Header.Get("If-None-Match"), tag) {
+			w.WriteHeader(http.StatusNotModified)
 			return
 		}
 	}
-	// use proper JSON Header
-	w.Header().Add("Content-Type", "application/json; charset=utf-8")
-	if h.pretty {
-		w.WriteHeader(http.StatusOK)
-		buff, _ = json.MarshalIndent(result, "", " ")
-		_, _ = w.Write(buff)
+	h.writeBody(ctx, w, r, http.StatusOK, buff)
+}
+
+// etagMatches reports whether an If-None-Match header lists tag
+func etagMatches(header, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeBody writes an already serialized response
+func (h *Handler) writeBody(ctx context.Context, w http.ResponseWriter, r *http.Request, status int, buff []byte) {
+	if len(h.codecs) > 0 {
+		w.Header().Add("Vary", "Accept")
+	}
+	if codec := h.responseCodec(r); codec != nil {
+		w.Header().Add("Content-Type", codec.ContentType())
 	} else {
-		w.WriteHeader(http.StatusOK)
-		buff, _ = json.Marshal(result)
-		_, _ = w.Write(buff)
+		w.Header().Add("Content-Type", h.resultMarshaler.ContentType())
+	}
+	body := buff
+	if h.compressor != nil {
+		body = h.compress(ctx, w, r, buff)
+	}
+	w.WriteHeader(status)
+	if _, err := w.Write(body); err != nil {
+		h.logger.DebugContext(ctx, "writing response failed", requestAttrs(r, "status", status, "error", err)...)
+	}
+	if h.cleanupUploadsFirst {
+		h.cleanupUploads(ctx, r)
 	}
 	if h.finishFn != nil {
 		h.finishFn(ctx, w, r, buff)
@@ -244,20 +915,296 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.ContextHandler(r.Context(), w, r)
 }
 
-// RootObjectFn allows a user to generate a RootObject per request
+// EntryFn allows a user to generate a RootObject per request, opts carries
+// the parsed query, variables and extensions of the request
 type EntryFn func(ctx context.Context, r *http.Request, opts *RequestOptions) (map[string]interface{}, error)
+
+// VariablesFn rewrites the variables of opts before the operation executes,
+// an error aborts the request with 400 Bad Request
+type VariablesFn func(ctx context.Context, opts *RequestOptions) error
+
+// ContextFn prepares the context of a request before it is parsed, e.g. with
+// credentials, tenants, loaders or deadlines. The context it returns replaces
+// ctx, an error aborts the request like an AuthFn error
+type ContextFn func(ctx context.Context, r *http.Request) (context.Context, error)
+
+// AuthFn authorizes a request before it executes, the context it returns
+// replaces ctx. An error aborts the request with 401 Unauthorized, or 403
+// Forbidden when it wraps ErrForbidden
+type AuthFn func(ctx context.Context, r *http.Request, opts *RequestOptions) (context.Context, error)
+
+// ErrForbidden is wrapped by ContextFn and AuthFn errors rejecting authenticated requests
+var ErrForbidden = errors.New("forbidden")
+
+// ResultFn post-processes the result of an operation before it is
+// serialized, e.g. to redact fields or rewrite errors
+type ResultFn func(ctx context.Context, params *graphql.Params, result *graphql.Result) *graphql.Result
+
+// ParamsFn returns the route parameters of r, e.g. chi.URLParam values
+type ParamsFn func(r *http.Request) map[string]interface{}
+
+type routeParamsKey struct{}
+
+// RouteParams returns the ParamsFn result of the request ctx belongs to
+func RouteParams(ctx context.Context) map[string]interface{} {
+	params, _ := ctx.Value(routeParamsKey{}).(map[string]interface{})
+	return params
+}
+
+// withRouteParams merges params into a copy of root, the EntryFn values
+// win over route parameters of the same name
+func withRouteParams(root, params map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(root)+len(params))
+	for k, v := range params {
+		merged[k] = v
+	}
+	for k, v := range root {
+		merged[k] = v
+	}
+	return merged
+}
+
+// SchemaSelectorFn returns the name of the schema r executes against
+type SchemaSelectorFn func(ctx context.Context, r *http.Request) string
 type ExitFn func(ctx context.Context, w http.ResponseWriter, r *http.Request)
 type FinishFn func(ctx context.Context, w http.ResponseWriter, r *http.Request, buf []byte)
 
+// HeadersFn sets the response headers of an executed operation, e.g.
+// cookies or deprecation warnings, before the status is written. Responses
+// served from the response cache do not call it
+type HeadersFn func(ctx context.Context, params *graphql.Params, result *graphql.Result, header http.Header)
+
 type Config struct {
-	Title        string
-	Schema       *graphql.Schema
-	Pretty       bool
-	GraphiQL     bool
-	EntryFn      EntryFn
-	ExitFn       ExitFn
+	Title    string
+	Schema   *graphql.Schema
+	Pretty   bool
+	GraphiQL bool
+	// Playground serves graphql-playground like GraphiQL does
+	Playground bool
+	// IDE selects the in-browser IDE, IDEAuto keeps following GraphiQL
+	IDE     IDE
+	EntryFn EntryFn
+	// ContextFn attaches values to the context of every request
+	ContextFn ContextFn
+	// AuthFn rejects requests before they execute
+	AuthFn AuthFn
+	// AuthorizeOperationFn rejects operations by their name, type and root
+	// fields before they execute
+	AuthorizeOperationFn AuthorizeOperationFn
+	// VariablesFn coerces, defaults or scrubs the variables of requests
+	VariablesFn VariablesFn
+	// ParamsFn exposes route parameters to resolvers, through the
+	// RootObject and RouteParams
+	ParamsFn ParamsFn
+	// ResultFn replaces the result of an operation before it is written,
+	// a nil return keeps it
+	ResultFn ResultFn
+	// RootObjectFn is used when EntryFn is nil
+	RootObjectFn     RootObjectFn
+	ResultCallbackFn ResultCallbackFn
+	FormatErrorFn    FormatErrorFn
+	ExitFn           ExitFn
+	// Subscription is the subscription endpoint IDEs connect to, a path is
+	// resolved to a ws:// or wss:// URL on the requested host
 	Subscription string
 	FinishFn     FinishFn
+	// HeadersFn sets response headers from the result of an operation
+	HeadersFn HeadersFn
+	// Assets serves the IDE bundle from the binary, e.g. http.FS of an
+	// embed.FS mirroring the CDN layout below AssetBaseURL
+	// (graphql-playground-react@1.7.26/build/static/js/middleware.js, ...),
+	// nil loads the assets from the CDN
+	Assets http.FileSystem
+	// AssetsPath is the URL prefix the handler serves Assets under,
+	// defaults to DefaultAssetsPath
+	AssetsPath string
+	// AssetBaseURL is the npm CDN (or internal mirror) IDE assets are
+	// loaded from when Assets is nil, defaults to DefaultAssetBaseURL
+	AssetBaseURL string
+	// PlaygroundVersion pins the graphql-playground-react version loaded
+	// from AssetBaseURL, defaults to DefaultPlaygroundVersion
+	PlaygroundVersion string
+	// GraphiQLTemplate replaces the built-in IDE page, it is executed with
+	// a *GraphiQLData, through its "index" template when defined
+	GraphiQLTemplate *template.Template
+	// GraphiQLTemplateString is parsed into GraphiQLTemplate when that is nil
+	GraphiQLTemplateString string
+	// PlaygroundSettings are serialized into the playground initialization options
+	PlaygroundSettings *PlaygroundSettings
+	// SandboxSettings configure the Apollo Sandbox page
+	SandboxSettings *SandboxSettings
+	// ExampleTabs are the operations the IDE opens with, the Apollo Sandbox
+	// only opens the first
+	ExampleTabs []ExampleTab
+	// IDEPath serves the IDE on GET requests to this path only, the API
+	// then never answers with HTML based on the Accept header
+	IDEPath string
+	// Endpoint is the API path the IDE sends operations to, defaults to the
+	// path the IDE page was requested on
+	Endpoint string
+	// CacheControl reports cache hints in the extensions of responses
+	CacheControl *CacheControlConfig
+	// IncrementalDelivery executes @defer and @stream, clients accepting
+	// multipart/mixed receive the deferred parts as they complete
+	IncrementalDelivery bool
+	// ResponseCache serves repeated queries from a CacheStore, mutations
+	// and subscriptions always execute
+	ResponseCache *ResponseCacheConfig
+	// ETags tags the responses of GET queries with a strong ETag and
+	// answers a matching If-None-Match with 304 Not Modified
+	ETags bool
+	// SchemaReportFn receives the AnalyzeSchema report of Schema when the
+	// handler is created, e.g. to log the risk hotspots at startup
+	SchemaReportFn func(report *SchemaReport)
+	// Timeout bounds the context operations are executed with, zero keeps
+	// the request context as is
+	Timeout time.Duration
+	// Schemas are additional schemas served by the handler, keyed by the
+	// names SchemaSelectorFn returns
+	Schemas map[string]*graphql.Schema
+	// SchemaSelectorFn picks the schema of a request from Schemas, e.g. by
+	// path for versioned APIs, an empty name selects Schema
+	SchemaSelectorFn SchemaSelectorFn
+	// SDLPath serves the SDL document of the schema on GET requests to
+	// this path, e.g. "/graphql/schema.graphql"
+	SDLPath string
+	// FieldTimeouts bound the resolvers of "Type.field"s, New wraps them
+	// in the schema types in place
+	FieldTimeouts map[string]FieldTimeout
+	// MaxFileSize bounds the size of every uploaded file in bytes
+	MaxFileSize int64
+	// MaxFiles bounds the number of files a request uploads
+	MaxFiles int
+	// MaxFileFields bounds the number of form fields a request uploads files in
+	MaxFileFields int
+	// UploadRule validates the uploaded files while the request is parsed
+	UploadRule *UploadRule
+	// UploadFieldRules override UploadRule for the files mapped to a
+	// variable, or sent in a form field, of the given name
+	UploadFieldRules map[string]*UploadRule
+	// UploadStore saves uploaded files while the request body is read, the
+	// resolvers then read their UploadRefs instead of RequestOptions.File
+	UploadStore UploadStore
+	// ResumableUploads serves the tus endpoint completed uploads are passed
+	// to Upload variables from, see ResumableUploads
+	ResumableUploads *ResumableUploads
+	// UploadProcessors process the uploaded files of a media type, e.g.
+	// "image/jpeg" or "image/*", before the operation executes
+	UploadProcessors map[string]UploadProcessorFn
+	// OnUploadCleanup is called once the temporary files of a multipart
+	// request are removed, after FinishFn unless CleanupUploadsBeforeFinish
+	OnUploadCleanup            UploadCleanupFn
+	CleanupUploadsBeforeFinish bool
+	// UploadProgressFn follows the upload of multipart request bodies
+	UploadProgressFn UploadProgressFn
+	// UploadStallTimeout aborts multipart uploads reading nothing for about
+	// the duration with an UploadStalledError, unless UploadProgressFn
+	// returns nil when called for the stall
+	UploadStallTimeout time.Duration
+	// Codecs encode the requests and responses of media types other than
+	// JSON, e.g. msgpackcodec.New()
+	Codecs []Codec
+	// ResultMarshaler encodes the responses no codec was negotiated for,
+	// Pretty and PrettyIndent are ignored when it is set
+	ResultMarshaler ResultMarshaler
+	// PrettyIndent is the indentation of pretty responses, defaults to a
+	// space
+	PrettyIndent string
+	// PrettyParam lets requests override Pretty with ?pretty=1 or ?pretty=0
+	PrettyParam bool
+	// PostOnly only executes operations sent in POST bodies, keeping them
+	// out of the query strings proxies log. GET requests only get the IDE
+	PostOnly bool
+	// CORS answers the preflights of cross-origin requests, nil leaves them
+	// to middlewares
+	CORS *CORSConfig
+	// RateLimiter admits requests before they execute, see IPRateLimiter
+	RateLimiter RateLimiter
+	// Policies tune the response cache TTLs, the cost limit and the rate
+	// limits of operations and fields from a file reloaded at runtime
+	Policies *PolicyFile
+	// WarmRestart hands the rate limits and the in-memory cached responses
+	// over to the next process, saved by Shutdown and restored by New
+	WarmRestart *WarmRestartConfig
+	// Compressor compresses the responses written at once for the clients
+	// accepting its encoding
+	Compressor Compressor
+	// Watchdog cancels executions exceeding its ceiling and reports them
+	Watchdog *Watchdog
+	// HealthPath answers liveness probes, e.g. "/healthz"
+	HealthPath string
+	// ReadyPath answers readiness probes, e.g. "/readyz", by executing
+	// ReadyQuery against the schema
+	ReadyPath string
+	// ReadyQuery defaults to DefaultReadyQuery
+	ReadyQuery string
+	// Degrader replaces the resolvers of the fields its active profiles
+	// designate, New wraps the resolvers of the schema types in place
+	Degrader *Degrader
+	// MaskErrors replaces the messages of resolver errors not implementing
+	// SafeError with DefaultMaskedErrorMessage and a correlation ID in
+	// extensions.correlationId, MaskErrorFn receives the original errors
+	MaskErrors  bool
+	MaskErrorFn MaskErrorFn
+	// CacheIntrospection serves the responses of operations reading only
+	// introspection fields from memory until the schema is replaced
+	CacheIntrospection bool
+	// DocumentCacheSize keeps that many parsed and validated documents to
+	// execute repeated operations from, see DocumentCacheStats. Schema
+	// extensions are then only notified of the execution
+	DocumentCacheSize int
+	// PubSub is handed to resolvers through RequestPubSub, e.g. a
+	// MemoryPubSub
+	PubSub PubSub
+	// Coalesce shares the execution of identical queries running at the
+	// same time, see CoalesceConfig
+	Coalesce *CoalesceConfig
+	// Explain answers the requests setting the ExplainHeader with the plan
+	// of their operation in extensions.explain, no resolver runs
+	Explain bool
+	// PoolRequestOptions reuses the RequestOptions, and their Variables,
+	// of finished requests. Callbacks must not keep them past the request
+	PoolRequestOptions bool
+	// Executor runs the operations in place of graphql.Do, see
+	// DefaultExecutor
+	Executor Executor
+	// AllowedOperations and DeniedOperations are operation names or
+	// path.Match patterns, denied names win and an empty allow list allows
+	// every named operation. A non-empty one blocks anonymous operations.
+	// Blocked operations get 403 Forbidden
+	AllowedOperations []string
+	DeniedOperations  []string
+	// RejectAnonymousOperations blocks the operations without a name
+	RejectAnonymousOperations bool
+	// ReadOnly blocks mutations with 403 Forbidden, e.g. on replicas or
+	// during maintenance, ReadOnlySubscriptions blocks subscriptions too
+	ReadOnly              bool
+	ReadOnlySubscriptions bool
+	// MaxQueryLength bounds the bytes of query strings, longer ones get 413
+	// Request Entity Too Large, and MaxTokens their lexical tokens. Both
+	// apply before the query is parsed
+	MaxQueryLength int
+	MaxTokens      int
+	// MaxAliases bounds the aliased fields of an operation and MaxRootFields
+	// the fields of its root selection set, fragments counting once per
+	// spread. Zero values are unlimited
+	MaxAliases    int
+	MaxRootFields int
+	// Operations are the operations registered at startup, they execute
+	// without being parsed or validated again
+	Operations *OperationRegistry
+	// Logger receives parse failures, panics, transport errors and the
+	// operations above SlowQueryThreshold, e.g. slog.Default()
+	Logger Logger
+	// SlowQueryThreshold is the duration above which operations are
+	// reported to SlowQueryFn
+	SlowQueryThreshold time.Duration
+	SlowQueryFn        SlowQueryFn
+	// SLO tracks the latency and error objectives of operations
+	SLO *SLOTracker
+	// ShapeSampler infers the JSON Schema of the data operations return,
+	// mount it on an admin route to read the schemas
+	ShapeSampler *ShapeSampler
 }
 
 func NewConfig() *Config {
@@ -268,22 +1215,386 @@ func NewConfig() *Config {
 	}
 }
 
+// ConfigError lists the problems NewWithError found in a Config
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return strings.Join(e.Problems, "; ")
+}
+
+// validate returns a *ConfigError when p cannot configure a handler
+func (p *Config) validate() error {
+	var problems []string
+	if p.Schema == nil && (len(p.Schemas) == 0 || p.SchemaSelectorFn == nil) {
+		problems = append(problems, "undefined GraphQL schema")
+	}
+	if len(p.Schemas) > 0 && p.SchemaSelectorFn == nil {
+		problems = append(problems, "Schemas are set without a SchemaSelectorFn")
+	}
+	names := make([]string, 0, len(p.Schemas))
+	for name := range p.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "" || p.Schemas[name] == nil {
+			problems = append(problems, fmt.Sprintf("undefined GraphQL schema %q", name))
+		}
+	}
+	if p.EntryFn != nil && p.RootObjectFn != nil {
+		problems = append(problems, "both EntryFn and RootObjectFn are set")
+	}
+	if p.Operations != nil && p.Schema != nil {
+		if err := p.Operations.Validate(p.Schema); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if p.IDE < IDEAuto || p.IDE > IDEAltair {
+		problems = append(problems, fmt.Sprintf("unknown IDE %d", p.IDE))
+	}
+	if p.IDE == IDENone && p.IDEPath != "" {
+		problems = append(problems, "IDEPath is set but the IDE is disabled")
+	}
+	if p.IDEPath != "" && !strings.HasPrefix(p.IDEPath, "/") {
+		problems = append(problems, fmt.Sprintf("IDEPath %q is not an absolute path", p.IDEPath))
+	}
+	for _, path := range []struct{ name, value string }{
+		{"SDLPath", p.SDLPath},
+		{"HealthPath", p.HealthPath},
+		{"ReadyPath", p.ReadyPath},
+	} {
+		if path.value != "" && !strings.HasPrefix(path.value, "/") {
+			problems = append(problems, fmt.Sprintf("%s %q is not an absolute path", path.name, path.value))
+		}
+	}
+	if p.AssetsPath != "" && !strings.HasPrefix(p.AssetsPath, "/") {
+		problems = append(problems, fmt.Sprintf("AssetsPath %q is not an absolute path", p.AssetsPath))
+	}
+	if p.GraphiQLTemplate != nil && p.GraphiQLTemplateString != "" {
+		problems = append(problems, "both GraphiQLTemplate and GraphiQLTemplateString are set")
+	}
+	if p.GraphiQLTemplate == nil && p.GraphiQLTemplateString != "" {
+		if _, err := template.New("GraphiQL").Parse(p.GraphiQLTemplateString); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid GraphiQLTemplateString: %v", err))
+		}
+	}
+	if p.MaxQueryLength < 0 || p.MaxTokens < 0 || p.MaxAliases < 0 || p.MaxRootFields < 0 {
+		problems = append(problems, "negative query limit")
+	}
+	if p.PoolRequestOptions && p.Watchdog != nil {
+		// abandoned executions keep reading the variables
+		problems = append(problems, "both PoolRequestOptions and Watchdog are set")
+	}
+	if p.Executor != nil && p.DocumentCacheSize > 0 {
+		problems = append(problems, "both Executor and DocumentCacheSize are set")
+	}
+	if p.MaskErrorFn != nil && !p.MaskErrors {
+		problems = append(problems, "MaskErrorFn is set without MaskErrors")
+	}
+	if p.ReadOnlySubscriptions && !p.ReadOnly {
+		problems = append(problems, "ReadOnlySubscriptions is set without ReadOnly")
+	}
+	for _, pattern := range append(append([]string{}, p.AllowedOperations...), p.DeniedOperations...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid operation pattern %q", pattern))
+		}
+	}
+	if p.MaxFileSize < 0 || p.MaxFiles < 0 || p.MaxFileFields < 0 {
+		problems = append(problems, "negative upload limit")
+	}
+	if p.ResumableUploads != nil {
+		if p.ResumableUploads.Dir == "" {
+			problems = append(problems, "ResumableUploads.Dir is not set")
+		}
+		if p.ResumableUploads.Path != "" && !strings.HasPrefix(p.ResumableUploads.Path, "/") {
+			problems = append(problems, fmt.Sprintf("ResumableUploads.Path %q is not an absolute path", p.ResumableUploads.Path))
+		}
+		if p.ResumableUploads.MaxSize <= 0 {
+			problems = append(problems, "ResumableUploads.MaxSize is not set")
+		}
+		if p.ResumableUploads.Expiry < 0 {
+			problems = append(problems, "negative resumable upload expiry")
+		}
+	}
+	if p.Policies != nil && p.Policies.Path == "" {
+		problems = append(problems, "Policies.Path is not set")
+	} else if p.Policies != nil {
+		schemas := make([]*graphql.Schema, 0, len(p.Schemas)+1)
+		if p.Schema != nil {
+			schemas = append(schemas, p.Schema)
+		}
+		for _, schema := range p.Schemas {
+			if schema != nil {
+				schemas = append(schemas, schema)
+			}
+		}
+		if err := p.Policies.bind(schemas, p.Operations); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	for mediaType, processor := range p.UploadProcessors {
+		if processor == nil {
+			problems = append(problems, fmt.Sprintf("nil upload processor for %q", mediaType))
+		}
+	}
+	if p.WarmRestart != nil && p.WarmRestart.Store == nil {
+		problems = append(problems, "WarmRestart.Store is not set")
+	}
+	if p.SlowQueryThreshold < 0 {
+		problems = append(problems, fmt.Sprintf("negative SlowQueryThreshold %v", p.SlowQueryThreshold))
+	}
+	if p.Timeout < 0 {
+		problems = append(problems, fmt.Sprintf("negative Timeout %v", p.Timeout))
+	}
+	if p.ResponseCache != nil && p.ResponseCache.TTL < 0 {
+		problems = append(problems, fmt.Sprintf("negative ResponseCache.TTL %v", p.ResponseCache.TTL))
+	}
+	if p.CacheControl != nil {
+		coordinates := make([]string, 0, len(p.CacheControl.Hints))
+		for coordinate := range p.CacheControl.Hints {
+			coordinates = append(coordinates, coordinate)
+		}
+		sort.Strings(coordinates)
+		for _, coordinate := range coordinates {
+			hint := p.CacheControl.Hints[coordinate]
+			if hint.MaxAge < 0 {
+				problems = append(problems, fmt.Sprintf("negative maxAge for %s", coordinate))
+			}
+			if hint.Scope != "" && hint.Scope != CacheScopePublic && hint.Scope != CacheScopePrivate {
+				problems = append(problems, fmt.Sprintf("unknown cache scope %q for %s", hint.Scope, coordinate))
+			}
+		}
+	}
+	coordinates := make([]string, 0, len(p.FieldTimeouts))
+	for coordinate := range p.FieldTimeouts {
+		coordinates = append(coordinates, coordinate)
+	}
+	sort.Strings(coordinates)
+	for _, coordinate := range coordinates {
+		if p.FieldTimeouts[coordinate].Timeout <= 0 {
+			problems = append(problems, fmt.Sprintf("non-positive timeout for %s", coordinate))
+		}
+	}
+	if p.Coalesce != nil && p.Coalesce.SessionKey == nil && (p.ContextFn != nil || p.AuthFn != nil) {
+		// per request authentication would be shared between the callers
+		problems = append(problems, "Coalesce is set without a SessionKey along with ContextFn or AuthFn")
+	}
+	if p.CORS != nil && p.CORS.AllowCredentials {
+		for _, origin := range p.CORS.AllowedOrigins {
+			if origin == "*" {
+				// browsers refuse credentials for any origin
+				problems = append(problems, "CORS.AllowCredentials is set with a wildcard origin")
+			}
+		}
+	}
+	if p.ShapeSampler != nil && p.ShapeSampler.Every < 0 {
+		problems = append(problems, fmt.Sprintf("negative ShapeSampler.Every %d", p.ShapeSampler.Every))
+	}
+	if p.SLO != nil && p.SLO.MaxDefaultOperations < 0 {
+		problems = append(problems, fmt.Sprintf("negative SLO.MaxDefaultOperations %d", p.SLO.MaxDefaultOperations))
+	}
+	if p.ShapeSampler != nil && p.ShapeSampler.MaxOperations < 0 {
+		problems = append(problems, fmt.Sprintf("negative ShapeSampler.MaxOperations %d", p.ShapeSampler.MaxOperations))
+	}
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
+
+// New returns the handler configured by p and panics when p is invalid,
+// see NewWithError
 func New(p *Config) *Handler {
+	h, err := NewWithError(p)
+	if err != nil {
+		panic(err.Error())
+	}
+	return h
+}
+
+// NewWithError returns the handler configured by p, or a *ConfigError
+// listing every problem of p
+func NewWithError(p *Config) (*Handler, error) {
 	if p == nil {
 		p = NewConfig()
 	}
-
-	if p.Schema == nil {
-		panic("undefined GraphQL schema")
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	assetsPath := p.AssetsPath
+	if assetsPath == "" {
+		assetsPath = DefaultAssetsPath
 	}
-	return &Handler{
-		exitFn:       p.ExitFn,
-		Schema:       p.Schema,
-		pretty:       p.Pretty,
-		graphiql:     p.GraphiQL,
-		entryFn:      p.EntryFn,
-		subscription: p.Subscription,
-		title:        p.Title,
-		finishFn:     p.FinishFn,
+	if !strings.HasSuffix(assetsPath, "/") {
+		assetsPath += "/"
+	}
+	assetBaseURL := p.AssetBaseURL
+	if assetBaseURL == "" {
+		assetBaseURL = DefaultAssetBaseURL
+	}
+	playgroundVersion := p.PlaygroundVersion
+	if playgroundVersion == "" {
+		playgroundVersion = DefaultPlaygroundVersion
+	}
+	ide := p.IDE
+	if ide == IDEAuto {
+		ide = IDENone
+		if p.GraphiQL || p.Playground {
+			ide = IDEPlayground
+		}
+	}
+	responseCache := p.ResponseCache
+	if responseCache != nil && responseCache.Store == nil {
+		// fill in the default on a copy, the caller's config is left untouched
+		withStore := *responseCache
+		withStore.Store = NewLRUCache(DefaultLRUCacheSize)
+		responseCache = &withStore
+	}
+	readyQuery := p.ReadyQuery
+	if readyQuery == "" {
+		readyQuery = DefaultReadyQuery
+	}
+	graphiqlTemplate := p.GraphiQLTemplate
+	if graphiqlTemplate == nil && p.GraphiQLTemplateString != "" {
+		// validated above
+		graphiqlTemplate, _ = template.New("GraphiQL").Parse(p.GraphiQLTemplateString)
+	}
+	if graphiqlTemplate == nil {
+		graphiqlTemplate = ideTemplate(ide)
+	}
+	if graphiqlTemplate == nil {
+		// built with the noide tag, there is no page to render
+		ide = IDENone
+	}
+	entryFn := p.EntryFn
+	if entryFn == nil && p.RootObjectFn != nil {
+		entryFn = entryFromRootObject(p.RootObjectFn)
+	}
+	var introspectionCache *ResponseCacheConfig
+	if p.CacheIntrospection {
+		introspectionCache = &ResponseCacheConfig{Store: NewLRUCache(introspectionCacheSize)}
+	}
+	var logger Logger = nopLogger{}
+	if p.Logger != nil {
+		logger = p.Logger
+	}
+	resultMarshaler := p.ResultMarshaler
+	if resultMarshaler == nil {
+		resultMarshaler = JSONResultMarshaler{Pretty: p.Pretty, Indent: p.PrettyIndent}
+	}
+	var documents *documentCache
+	if p.DocumentCacheSize > 0 {
+		documents = newDocumentCache(p.DocumentCacheSize)
+	}
+	h := &Handler{
+		exitFn:              p.ExitFn,
+		resultMarshaler:     resultMarshaler,
+		prettyParam:         p.PrettyParam,
+		postOnly:            p.PostOnly,
+		cors:                p.CORS,
+		logger:              logger,
+		logSlowQueries:      p.Logger != nil && p.SlowQueryThreshold > 0,
+		ide:                 ide,
+		entryFn:             entryFn,
+		contextFn:           p.ContextFn,
+		authFn:              p.AuthFn,
+		authorizeFn:         p.AuthorizeOperationFn,
+		resultFn:            p.ResultFn,
+		paramsFn:            p.ParamsFn,
+		variablesFn:         p.VariablesFn,
+		resultCallbackFn:    p.ResultCallbackFn,
+		formatErrorFn:       p.FormatErrorFn,
+		subscription:        p.Subscription,
+		title:               p.Title,
+		finishFn:            p.FinishFn,
+		headersFn:           p.HeadersFn,
+		assets:              p.Assets,
+		assetsPath:          assetsPath,
+		assetBaseURL:        strings.TrimSuffix(assetBaseURL, "/"),
+		playgroundVersion:   playgroundVersion,
+		graphiqlTemplate:    graphiqlTemplate,
+		playgroundSettings:  p.PlaygroundSettings,
+		sandboxSettings:     p.SandboxSettings,
+		exampleTabs:         p.ExampleTabs,
+		idePath:             p.IDEPath,
+		endpoint:            p.Endpoint,
+		incremental:         p.IncrementalDelivery,
+		responseCache:       responseCache,
+		introspectionCache:  introspectionCache,
+		maskErrorsEnabled:   p.MaskErrors,
+		maskErrorFn:         p.MaskErrorFn,
+		cacheControlHeaders: p.CacheControl != nil && p.CacheControl.HTTPHeaders,
+		etags:               p.ETags,
+		timeout:             p.Timeout,
+		shapes:              p.ShapeSampler,
+		cacheControl:        p.CacheControl,
+		schemaReportFn:      p.SchemaReportFn,
+		schemaSelectorFn:    p.SchemaSelectorFn,
+		slo:                 p.SLO,
+		degrader:            p.Degrader,
+		sdlPath:             p.SDLPath,
+		healthPath:          p.HealthPath,
+		readyPath:           p.ReadyPath,
+		readyQuery:          readyQuery,
+		fieldTimeouts:       p.FieldTimeouts,
+		watchdog:            p.Watchdog,
+		rateLimiter:         p.RateLimiter,
+		policies:            p.Policies,
+		warmRestart:         p.WarmRestart,
+		compressor:          p.Compressor,
+		codecs:              p.Codecs,
+		slowQueryThreshold:  p.SlowQueryThreshold,
+		slowQueryFn:         p.SlowQueryFn,
+		documents:           documents,
+		executor:            p.Executor,
+		pubSub:              p.PubSub,
+		coalescer:           newCoalescer(p.Coalesce),
+		explain:             p.Explain,
+		poolRequestOptions:  p.PoolRequestOptions,
+		operations:          p.Operations,
+		uploadStore:         p.UploadStore,
+		resumableUploads:    p.ResumableUploads,
+		uploadProcessors:    p.UploadProcessors,
+		onUploadCleanup:     p.OnUploadCleanup,
+		uploadProgressFn:    p.UploadProgressFn,
+		uploadStallTimeout:  p.UploadStallTimeout,
+		cleanupUploadsFirst: p.CleanupUploadsBeforeFinish,
+		uploadPolicy: uploadPolicy{
+			UploadLimits: UploadLimits{MaxFileSize: p.MaxFileSize, MaxFiles: p.MaxFiles, MaxFileFields: p.MaxFileFields},
+			rule:         p.UploadRule,
+			fieldRules:   p.UploadFieldRules,
+			codecs:       p.Codecs,
+			pool:         p.PoolRequestOptions,
+			store:        p.UploadStore,
+		},
+		operationFilter: operationFilter{
+			allowed:            p.AllowedOperations,
+			denied:             p.DeniedOperations,
+			rejectAnonymous:    p.RejectAnonymousOperations,
+			readOnly:           p.ReadOnly,
+			blockSubscriptions: p.ReadOnlySubscriptions,
+		},
+		queryLimits: queryLimits{
+			maxLength:     p.MaxQueryLength,
+			maxTokens:     p.MaxTokens,
+			maxAliases:    p.MaxAliases,
+			maxRootFields: p.MaxRootFields,
+		},
+	}
+	if p.Schema != nil {
+		h.Schema = h.prepareSchema(p.Schema)
+	}
+	named := make(map[string]*graphql.Schema, len(p.Schemas))
+	for name, schema := range p.Schemas {
+		named[name] = h.prepareSchema(schema)
+	}
+	h.schema.Store(schemaVersion{schema: h.Schema, named: named})
+	if h.warmRestart != nil {
+		if err := h.restoreState(context.Background()); err != nil {
+			h.logger.WarnContext(context.Background(), "restoring the warm restart state failed", "error", err)
+		}
 	}
+	return h, nil
 }
@@ -2,13 +2,17 @@ package handler_test
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/cxuhua/handler"
 
@@ -291,3 +295,235 @@ func TestHandler_BasicQuery_WithFormatErrorFn(t *testing.T) {
 		t.Fatalf("wrong result, graphql result diff: %v", testutil.Diff(expected, result))
 	}
 }
+
+func TestHandler_MalformedRequest_BadRequest(t *testing.T) {
+	body := strings.NewReader(`{"query": "{ hero { name } }", "variables": [1]}`)
+	req, _ := http.NewRequest("POST", "/graphql", body)
+	req.Header.Set("Content-Type", "application/json")
+
+	h := handler.New(&handler.Config{
+		Schema: &testutil.StarWarsSchema,
+	})
+	result, resp := executeTest(t, h, req)
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected server response %v", resp.Code)
+	}
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, "invalid variables") {
+		t.Fatalf("unexpected errors %v", result.Errors)
+	}
+}
+
+func TestNewWithError(t *testing.T) {
+	h, err := handler.NewWithError(&handler.Config{Schema: &testutil.StarWarsSchema})
+	if err != nil || h == nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	_, err = handler.NewWithError(&handler.Config{
+		IDE:                    handler.IDENone,
+		IDEPath:                "graphiql",
+		GraphiQLTemplateString: "{{.Title",
+		Timeout:                -time.Second,
+	})
+	configErr, ok := err.(*handler.ConfigError)
+	if !ok {
+		t.Fatalf("expected a *ConfigError, got %v", err)
+	}
+	expected := []string{
+		"undefined GraphQL schema",
+		"IDEPath is set but the IDE is disabled",
+		`IDEPath "graphiql" is not an absolute path`,
+	}
+	if len(configErr.Problems) != 5 || !reflect.DeepEqual(configErr.Problems[:3], expected) {
+		t.Fatalf("unexpected problems %q", configErr.Problems)
+	}
+}
+
+func TestHandler_AuthFn(t *testing.T) {
+	type userKey struct{}
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"user": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Context.Value(userKey{}), nil
+				},
+			},
+		}}),
+	})
+	h := handler.New(&handler.Config{
+		Schema: &schema,
+		AuthFn: func(ctx context.Context, r *http.Request, opts *handler.RequestOptions) (context.Context, error) {
+			switch r.Header.Get("Authorization") {
+			case "":
+				return nil, fmt.Errorf("missing credentials")
+			case "guest":
+				return nil, fmt.Errorf("guests may not query: %w", handler.ErrForbidden)
+			}
+			return context.WithValue(ctx, userKey{}, r.Header.Get("Authorization")), nil
+		},
+	})
+	for auth, status := range map[string]int{"": http.StatusUnauthorized, "guest": http.StatusForbidden, "luke": http.StatusOK} {
+		req, _ := http.NewRequest("GET", "/graphql?query={user}", nil)
+		req.Header.Set("Authorization", auth)
+		result, resp := executeTest(t, h, req)
+		if resp.Code != status {
+			t.Fatalf("%q: expected %d, got %d", auth, status, resp.Code)
+		}
+		if status != http.StatusOK {
+			if len(result.Errors) != 1 || result.Data != nil {
+				t.Fatalf("%q: unexpected result %+v", auth, result)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(result.Data, map[string]interface{}{"user": "luke"}) {
+			t.Fatalf("unexpected data %v", result.Data)
+		}
+	}
+}
+
+func TestHandler_ContextFn(t *testing.T) {
+	type tenantKey struct{}
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"tenant": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Context.Value(tenantKey{}), nil
+				},
+			},
+		}}),
+	})
+	h := handler.New(&handler.Config{
+		Schema: &schema,
+		ContextFn: func(ctx context.Context, r *http.Request) (context.Context, error) {
+			tenant := r.Header.Get("X-Tenant")
+			if tenant == "" {
+				return nil, fmt.Errorf("unknown tenant: %w", handler.ErrForbidden)
+			}
+			return context.WithValue(ctx, tenantKey{}, tenant), nil
+		},
+		AuthFn: func(ctx context.Context, r *http.Request, opts *handler.RequestOptions) (context.Context, error) {
+			if ctx.Value(tenantKey{}) == nil {
+				t.Fatal("AuthFn does not see the ContextFn values")
+			}
+			return nil, nil
+		},
+	})
+	req, _ := http.NewRequest("GET", "/graphql?query={tenant}", nil)
+	result, resp := executeTest(t, h, req)
+	if resp.Code != http.StatusForbidden || len(result.Errors) != 1 || result.Errors[0].Extensions["code"] != handler.CodeForbidden {
+		t.Fatalf("unexpected response %d %+v", resp.Code, result)
+	}
+	req.Header.Set("X-Tenant", "acme")
+	result, resp = executeTest(t, h, req)
+	if resp.Code != http.StatusOK || !reflect.DeepEqual(result.Data, map[string]interface{}{"tenant": "acme"}) {
+		t.Fatalf("unexpected response %d %+v", resp.Code, result)
+	}
+}
+
+func TestHandler_HeadersFn(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema: &testutil.StarWarsSchema,
+		HeadersFn: func(ctx context.Context, params *graphql.Params, result *graphql.Result, header http.Header) {
+			if result.HasErrors() {
+				header.Set("Cache-Control", "no-store")
+				return
+			}
+			header.Set("Deprecation", params.OperationName)
+		},
+	})
+	req, _ := http.NewRequest("GET", "/graphql?query=query+Old{hero{name}}&operationName=Old", nil)
+	_, resp := executeTest(t, h, req)
+	if resp.Header().Get("Deprecation") != "Old" || resp.Header().Get("Cache-Control") != "" {
+		t.Fatalf("unexpected headers %v", resp.Header())
+	}
+	req, _ = http.NewRequest("GET", "/graphql?query={unknown}", nil)
+	_, resp = executeTest(t, h, req)
+	if resp.Header().Get("Cache-Control") != "no-store" || resp.Header().Get("Deprecation") != "" {
+		t.Fatalf("unexpected headers %v", resp.Header())
+	}
+}
+
+func TestHandler_ResultFn(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema: &testutil.StarWarsSchema,
+		ResultFn: func(ctx context.Context, params *graphql.Params, result *graphql.Result) *graphql.Result {
+			if params.OperationName == "keep" {
+				return nil
+			}
+			hero := result.Data.(map[string]interface{})["hero"].(map[string]interface{})
+			hero["name"] = "[redacted]"
+			result.Extensions = map[string]interface{}{"redacted": true}
+			return result
+		},
+	})
+	req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}", nil)
+	result, _ := executeTest(t, h, req)
+	expected := map[string]interface{}{"hero": map[string]interface{}{"name": "[redacted]"}}
+	if !reflect.DeepEqual(result.Data, expected) || result.Extensions["redacted"] != true {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	req, _ = http.NewRequest("GET", "/graphql?operationName=keep&query=query+keep{hero{name}}", nil)
+	result, _ = executeTest(t, h, req)
+	expected = map[string]interface{}{"hero": map[string]interface{}{"name": "R2-D2"}}
+	if !reflect.DeepEqual(result.Data, expected) {
+		t.Fatalf("unexpected data %v", result.Data)
+	}
+}
+
+func TestHandler_ParamsFn(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"tenant": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if handler.RouteParams(p.Context)["id"] != p.Info.RootValue.(map[string]interface{})["id"] {
+						t.Errorf("the context and the root object disagree")
+					}
+					return p.Info.RootValue.(map[string]interface{})["id"], nil
+				},
+			},
+		}}),
+	})
+	h := handler.New(&handler.Config{
+		Schema:        &schema,
+		ResponseCache: &handler.ResponseCacheConfig{},
+		ParamsFn: func(r *http.Request) map[string]interface{} {
+			return map[string]interface{}{"id": strings.Split(r.URL.Path, "/")[2]}
+		},
+	})
+	for _, id := range []string{"1", "2"} {
+		req, _ := http.NewRequest("GET", "/tenants/"+id+"/graphql?query={tenant}", nil)
+		result, _ := executeTest(t, h, req)
+		if !reflect.DeepEqual(result.Data, map[string]interface{}{"tenant": id}) {
+			t.Fatalf("unexpected data %v", result.Data)
+		}
+	}
+}
+
+func TestHandler_VariablesFn(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema: &testutil.StarWarsSchema,
+		VariablesFn: func(ctx context.Context, opts *handler.RequestOptions) error {
+			if _, ok := opts.Variables["debug"]; ok {
+				return errors.New("variable debug is not allowed")
+			}
+			if id, ok := opts.Variables["id"].(float64); ok {
+				opts.Variables["id"] = strconv.Itoa(int(id))
+			}
+			return nil
+		},
+	})
+	query := url.QueryEscape("query($id: String!) { human(id: $id) { name } }")
+	req, _ := http.NewRequest("GET", "/graphql?query="+query+"&variables="+url.QueryEscape(`{"id":1000}`), nil)
+	result, _ := executeTest(t, h, req)
+	if !reflect.DeepEqual(result.Data, map[string]interface{}{"human": map[string]interface{}{"name": "Luke Skywalker"}}) {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	req, _ = http.NewRequest("GET", "/graphql?query="+query+"&variables="+url.QueryEscape(`{"id":"1000","debug":true}`), nil)
+	result, resp := executeTest(t, h, req)
+	if resp.Code != http.StatusBadRequest || len(result.Errors) != 1 || result.Errors[0].Message != "variable debug is not allowed" {
+		t.Fatalf("unexpected response %d %+v", resp.Code, result)
+	}
+}
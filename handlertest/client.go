@@ -0,0 +1,311 @@
+// Package handlertest sends GraphQL operations to a handler from tests,
+// in process or through an httptest.Server
+package handlertest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// DefaultEndpoint is the path operations are sent to
+const DefaultEndpoint = "/graphql"
+
+// inProcessURL is the origin of the requests of in-process clients
+const inProcessURL = "http://handlertest.local"
+
+// Client sends operations to a handler, its fields are read by every
+// operation
+type Client struct {
+	// Endpoint is the path operations are sent to, DefaultEndpoint by default
+	Endpoint string
+	// Header is sent with every operation
+	Header http.Header
+
+	origin string
+	client *http.Client
+	server *httptest.Server
+}
+
+// New returns a Client calling h in process, the requests never leave
+// ServeHTTP
+func New(h http.Handler) *Client {
+	return &Client{
+		Endpoint: DefaultEndpoint,
+		Header:   http.Header{},
+		origin:   inProcessURL,
+		client:   &http.Client{Transport: handlerTransport{h}},
+	}
+}
+
+// NewServer returns a Client sending operations to h served by an
+// httptest.Server, stopped by Close
+func NewServer(h http.Handler) *Client {
+	server := httptest.NewServer(h)
+	return &Client{
+		Endpoint: DefaultEndpoint,
+		Header:   http.Header{},
+		origin:   server.URL,
+		client:   server.Client(),
+		server:   server,
+	}
+}
+
+// URL returns the URL operations are sent to
+func (c *Client) URL() string {
+	return c.origin + c.Endpoint
+}
+
+// Close stops the server of clients returned by NewServer
+func (c *Client) Close() {
+	if c.server != nil {
+		c.server.Close()
+	}
+}
+
+// Do sends the operation query with variables, the error reports requests
+// that failed or responses that are no GraphQL results. Errors in the
+// results are returned by Response.Decode
+func (c *Client) Do(query string, variables map[string]interface{}, opts ...Option) (*Response, error) {
+	o := &options{ctx: context.Background(), method: http.MethodPost, header: http.Header{}}
+	for _, opt := range opts {
+		opt(o)
+	}
+	req, err := o.request(c.URL(), query, variables)
+	if err != nil {
+		return nil, err
+	}
+	for _, header := range []http.Header{c.Header, o.header} {
+		for name, values := range header {
+			req.Header[name] = values
+		}
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	response := &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}
+	if len(bytes.TrimSpace(body)) == 0 {
+		// e.g. 304 Not Modified
+		return response, nil
+	}
+	if err := json.Unmarshal(body, response); err != nil {
+		return response, fmt.Errorf("decoding response with status %d: %v", resp.StatusCode, err)
+	}
+	return response, nil
+}
+
+// Response is the result of an operation
+type Response struct {
+	StatusCode int         `json:"-"`
+	Header     http.Header `json:"-"`
+	// Body is the response as received
+	Body []byte `json:"-"`
+
+	Data       json.RawMessage            `json:"data"`
+	Errors     []gqlerrors.FormattedError `json:"errors"`
+	Extensions map[string]interface{}     `json:"extensions"`
+}
+
+// Decode unmarshals the data of r into v, e.g. a struct mirroring the
+// selections of the operation. The errors of the result are returned as a
+// *ResponseError once the data, if any, is decoded
+func (r *Response) Decode(v interface{}) error {
+	if len(r.Data) > 0 && string(r.Data) != "null" {
+		if err := json.Unmarshal(r.Data, v); err != nil {
+			return err
+		}
+	} else if len(r.Errors) == 0 && r.StatusCode != http.StatusOK {
+		return &ResponseError{StatusCode: r.StatusCode}
+	}
+	if len(r.Errors) > 0 {
+		return &ResponseError{StatusCode: r.StatusCode, Errors: r.Errors}
+	}
+	return nil
+}
+
+// ResponseError is a response carrying GraphQL errors or no data
+type ResponseError struct {
+	StatusCode int
+	Errors     []gqlerrors.FormattedError
+}
+
+func (e *ResponseError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Message
+	}
+	if len(messages) == 0 {
+		return fmt.Sprintf("graphql: status %d without data", e.StatusCode)
+	}
+	return fmt.Sprintf("graphql: status %d: %s", e.StatusCode, strings.Join(messages, "; "))
+}
+
+// Option tunes the request of an operation
+type Option func(*options)
+
+type options struct {
+	ctx           context.Context
+	method        string
+	header        http.Header
+	operationName string
+	extensions    map[string]interface{}
+	files         []file
+}
+
+type file struct {
+	variable, filename string
+	content            []byte
+}
+
+// WithContext sends the request with ctx
+func WithContext(ctx context.Context) Option {
+	return func(o *options) { o.ctx = ctx }
+}
+
+// WithHeader adds a header to the request
+func WithHeader(name, value string) Option {
+	return func(o *options) { o.header.Add(name, value) }
+}
+
+// WithOperationName selects the operation of documents holding several
+func WithOperationName(name string) Option {
+	return func(o *options) { o.operationName = name }
+}
+
+// WithExtensions sends the extensions of the request, e.g. persisted query
+// hashes
+func WithExtensions(extensions map[string]interface{}) Option {
+	return func(o *options) { o.extensions = extensions }
+}
+
+// WithGET sends the operation in the query string of a GET request in
+// place of a JSON POST body
+func WithGET() Option {
+	return func(o *options) { o.method = http.MethodGet }
+}
+
+// WithFile uploads content as the file of variable in a multipart request
+// following the GraphQL multipart request spec
+func WithFile(variable, filename string, content []byte) Option {
+	return func(o *options) { o.files = append(o.files, file{variable, filename, content}) }
+}
+
+// request builds the request of an operation sent to endpoint
+func (o *options) request(endpoint, query string, variables map[string]interface{}) (*http.Request, error) {
+	if len(o.files) > 0 {
+		return o.multipartRequest(endpoint, query, variables)
+	}
+	if o.method == http.MethodGet {
+		values := url.Values{"query": {query}}
+		if o.operationName != "" {
+			values.Set("operationName", o.operationName)
+		}
+		for name, value := range map[string]map[string]interface{}{"variables": variables, "extensions": o.extensions} {
+			if value == nil {
+				continue
+			}
+			buff, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			values.Set(name, string(buff))
+		}
+		return http.NewRequestWithContext(o.ctx, http.MethodGet, endpoint+"?"+values.Encode(), nil)
+	}
+	body, err := o.operation(query, variables)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(o.ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// operation marshals the JSON body of an operation
+func (o *options) operation(query string, variables map[string]interface{}) ([]byte, error) {
+	return json.Marshal(struct {
+		Query         string                 `json:"query"`
+		Variables     map[string]interface{} `json:"variables,omitempty"`
+		OperationName string                 `json:"operationName,omitempty"`
+		Extensions    map[string]interface{} `json:"extensions,omitempty"`
+	}{query, variables, o.operationName, o.extensions})
+}
+
+func (o *options) multipartRequest(endpoint, query string, variables map[string]interface{}) (*http.Request, error) {
+	withFiles := make(map[string]interface{}, len(variables)+len(o.files))
+	for name, value := range variables {
+		withFiles[name] = value
+	}
+	mapping := map[string][]string{}
+	for i, f := range o.files {
+		withFiles[f.variable] = nil
+		mapping[strconv.Itoa(i)] = []string{"variables." + f.variable}
+	}
+	operations, err := o.operation(query, withFiles)
+	if err != nil {
+		return nil, err
+	}
+	mapped, err := json.Marshal(mapping)
+	if err != nil {
+		return nil, err
+	}
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	_ = mw.WriteField("operations", string(operations))
+	_ = mw.WriteField("map", string(mapped))
+	for i, f := range o.files {
+		fw, err := mw.CreateFormFile(strconv.Itoa(i), f.filename)
+		if err != nil {
+			return nil, err
+		}
+		_, _ = fw.Write(f.content)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(o.ctx, http.MethodPost, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req, nil
+}
+
+// handlerTransport serves the requests of in-process clients with ServeHTTP
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// ServeHTTP sees the request the way a server would have parsed it
+	served := req.Clone(req.Context())
+	served.RequestURI = req.URL.RequestURI()
+	served.RemoteAddr = "192.0.2.1:1234"
+	if served.Body == nil {
+		served.Body = http.NoBody
+	}
+	recorder := httptest.NewRecorder()
+	t.handler.ServeHTTP(recorder, served)
+	resp := recorder.Result()
+	resp.Request = req
+	return resp, nil
+}
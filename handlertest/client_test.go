@@ -0,0 +1,119 @@
+package handlertest_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/cxuhua/handler/handlertest"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+type hero struct {
+	Hero struct {
+		Name    string
+		Friends []struct{ Name string }
+	}
+}
+
+func TestClient(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema: &testutil.StarWarsSchema,
+		AuthFn: func(ctx context.Context, r *http.Request, opts *handler.RequestOptions) (context.Context, error) {
+			if r.Header.Get("Authorization") == "" {
+				return nil, errors.New("missing token")
+			}
+			return ctx, nil
+		},
+	})
+	for name, c := range map[string]*handlertest.Client{
+		"in process": handlertest.New(h),
+		"server":     handlertest.NewServer(h),
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer c.Close()
+			c.Header.Set("Authorization", "Bearer token")
+			query := "query Hero($episode: Episode) { hero(episode: $episode) { name friends { name } } }"
+			for _, opts := range [][]handlertest.Option{nil, {handlertest.WithGET()}} {
+				resp, err := c.Do(query, map[string]interface{}{"episode": "EMPIRE"}, opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var data hero
+				if err := resp.Decode(&data); err != nil {
+					t.Fatal(err)
+				}
+				if data.Hero.Name != "Luke Skywalker" || len(data.Hero.Friends) != 4 {
+					t.Fatalf("unexpected data %+v", data)
+				}
+			}
+
+			resp, err := c.Do("{ hero { missing } }", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var respErr *handlertest.ResponseError
+			if err := resp.Decode(&hero{}); !errors.As(err, &respErr) || len(respErr.Errors) != 1 {
+				t.Fatalf("expected a response error, got %v", err)
+			}
+
+			resp, err = c.Do("{ hero { name } }", nil, handlertest.WithHeader("Authorization", ""))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := resp.Decode(&hero{}); !errors.As(err, &respErr) || respErr.StatusCode != http.StatusUnauthorized {
+				t.Fatalf("expected 401 Unauthorized, got %v", err)
+			}
+		})
+	}
+}
+
+func TestClient_Upload(t *testing.T) {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"ok": &graphql.Field{Type: graphql.Boolean},
+		}}),
+		Mutation: graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: graphql.Fields{
+			"upload": &graphql.Field{
+				Type: graphql.String,
+				Args: graphql.FieldConfigArgument{
+					"file":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(handler.Upload)},
+					"folder": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					file := p.Args["file"].(*handler.UploadFile)
+					r, err := file.Open()
+					if err != nil {
+						return nil, err
+					}
+					defer r.Close()
+					content, err := ioutil.ReadAll(r)
+					return p.Args["folder"].(string) + "/" + file.Filename + ":" + string(content), err
+				},
+			},
+		}}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := handlertest.New(handler.New(&handler.Config{Schema: &schema}))
+	resp, err := c.Do(
+		"mutation($file: Upload!, $folder: String) { upload(file: $file, folder: $folder) }",
+		map[string]interface{}{"folder": "docs"},
+		handlertest.WithFile("file", "a.txt", []byte("content")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data struct{ Upload string }
+	if err := resp.Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Upload != "docs/a.txt:content" {
+		t.Fatalf("unexpected data %+v", data)
+	}
+}
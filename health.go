@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// DefaultReadyQuery is the query the readiness endpoint executes by default
+const DefaultReadyQuery = "{ __typename }"
+
+// serveHealth answers the liveness and readiness probes, liveness always
+// reports ok, readiness executes the ready query against the schema, with
+// the configured Executor, until Shutdown is called
+func (h *Handler) serveHealth(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	switch {
+	case h.healthPath != "" && r.URL.Path == h.healthPath:
+		writeHealth(w, http.StatusOK, nil)
+		return true
+	case h.readyPath != "" && r.URL.Path == h.readyPath:
+		if h.drain.shuttingDown() {
+			writeHealth(w, http.StatusServiceUnavailable, []gqlerrors.FormattedError{gqlerrors.FormatError(ErrShuttingDown)})
+			return true
+		}
+		version := h.currentSchema()
+		schema, schemaName, err := h.selectSchema(r.Context(), r, version)
+		if err != nil {
+			writeHealth(w, http.StatusServiceUnavailable, []gqlerrors.FormattedError{gqlerrors.FormatError(err)})
+			return true
+		}
+		// the probe fails when the executor operations go through does
+		result := h.execute(graphql.Params{
+			Schema:        *schema,
+			RequestString: h.readyQuery,
+			Context:       r.Context(),
+		}, fmt.Sprintf("%s#%d", schemaName, version.generation))
+		if result.HasErrors() {
+			writeHealth(w, http.StatusServiceUnavailable, result.Errors)
+			return true
+		}
+		writeHealth(w, http.StatusOK, nil)
+		return true
+	}
+	return false
+}
+
+func writeHealth(w http.ResponseWriter, status int, errors []gqlerrors.FormattedError) {
+	body := map[string]interface{}{"status": "ok"}
+	if status != http.StatusOK {
+		body = map[string]interface{}{"status": "unavailable", "errors": errors}
+	}
+	buff, _ := json.Marshal(body)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_, _ = w.Write(buff)
+}
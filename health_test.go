@@ -0,0 +1,57 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_HealthEndpoints(t *testing.T) {
+	probe := func(h *handler.Handler, path string) (int, string) {
+		req, _ := http.NewRequest("GET", path, nil)
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp.Code, resp.Body.String()
+	}
+	h := handler.NewHandler(&testutil.StarWarsSchema, handler.WithHealth("/healthz", "/readyz"))
+	if code, body := probe(h, "/healthz"); code != http.StatusOK || body != `{"status":"ok"}` {
+		t.Fatalf("unexpected liveness %d %s", code, body)
+	}
+	if code, body := probe(h, "/readyz"); code != http.StatusOK || body != `{"status":"ok"}` {
+		t.Fatalf("unexpected readiness %d %s", code, body)
+	}
+
+	h = handler.New(&handler.Config{
+		Schema:     &testutil.StarWarsSchema,
+		ReadyPath:  "/readyz",
+		ReadyQuery: "{ missing }",
+	})
+	code, body := probe(h, "/readyz")
+	if code != http.StatusServiceUnavailable || !strings.Contains(body, `"status":"unavailable"`) {
+		t.Fatalf("unexpected readiness %d %s", code, body)
+	}
+}
+
+func TestHandler_ReadyExecutor(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:    &testutil.StarWarsSchema,
+		ReadyPath: "/readyz",
+		Executor: handler.ExecutorFunc(func(ctx context.Context, params graphql.Params) *graphql.Result {
+			return &graphql.Result{Errors: gqlerrors.FormatErrors(errors.New("backend unavailable"))}
+		}),
+	})
+	req, _ := http.NewRequest("GET", "/readyz", nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Code != http.StatusServiceUnavailable || !strings.Contains(resp.Body.String(), "backend unavailable") {
+		t.Fatalf("unexpected readiness %d %s", resp.Code, resp.Body)
+	}
+}
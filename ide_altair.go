@@ -0,0 +1,18 @@
+package handler
+
+// altairVersion is the altair-static release the Altair page is pinned to
+const altairVersion = "5.2.4"
+
+// altairOptions builds the AltairGraphQL.init options
+func altairOptions(endpoint, subscription string, tabs []ExampleTab) map[string]interface{} {
+	opts := map[string]interface{}{
+		"endpointURL": endpoint,
+	}
+	if subscription != "" {
+		opts["subscriptionsEndpoint"] = subscription
+	}
+	if len(tabs) > 0 {
+		opts["initialWindows"] = altairWindows(tabs)
+	}
+	return opts
+}
@@ -0,0 +1,19 @@
+package handler
+
+// graphiQLVersion is the GraphiQL release the GraphiQL page is pinned to
+const graphiQLVersion = "2.4.7"
+
+// graphiQLOptions builds the GraphiQL.createFetcher options, the page
+// passes their defaultTabs on to GraphiQL
+func graphiQLOptions(endpoint, subscription string, tabs []ExampleTab) map[string]interface{} {
+	opts := map[string]interface{}{
+		"url": endpoint,
+	}
+	if subscription != "" {
+		opts["subscriptionUrl"] = subscription
+	}
+	if len(tabs) > 0 {
+		opts["defaultTabs"] = graphiQLTabs(tabs)
+	}
+	return opts
+}
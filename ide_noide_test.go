@@ -0,0 +1,25 @@
+//go:build noide
+// +build noide
+
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestNoIDE_ServesJSON(t *testing.T) {
+	h := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema, IDE: handler.IDEGraphiQL})
+	req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}", nil)
+	req.Header.Set("Accept", "text/html")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if ct := resp.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Fatalf("expected JSON without the IDE pages, got %q", ct)
+	}
+}
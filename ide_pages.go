@@ -0,0 +1,193 @@
+//go:build !noide
+// +build !noide
+
+package handler
+
+import "html/template"
+
+// defaultGraphiQLTemplate renders graphql-playground
+var defaultGraphiQLTemplate = template.Must(template.New("GraphiQL").Parse(graphiqlTemplate))
+
+// graphiQLPage renders GraphiQL
+var graphiQLPage = template.Must(template.New("GraphiQL").Parse(graphiQLTemplate))
+
+// sandboxPage renders the Apollo Sandbox
+var sandboxPage = template.Must(template.New("GraphiQL").Parse(sandboxTemplate))
+
+// altairPage renders Altair
+var altairPage = template.Must(template.New("GraphiQL").Parse(altairTemplate))
+
+// tmpl is the page template to render GraphiQL
+const graphiqlTemplate = `
+{{ define "index" }}
+<!DOCTYPE html>
+<html>
+<head>
+  <meta http-equiv="Content-Type" content="text/html; charset=utf-8" />
+  <meta name="viewport" content="user-scalable=no, initial-scale=1.0, minimum-scale=1.0, maximum-scale=1.0, minimal-ui">
+  <title>{{.Title}}</title>
+  <link rel="stylesheet" href="{{.Assets}}/build/static/css/index.css" />
+  <link rel="shortcut icon" href="/favicon.ico" />
+  <script src="{{.Assets}}/build/static/js/middleware.js"></script>
+</head>
+<body>
+  <div id="root">
+    <style>
+      body {
+        background-color: rgb(23, 42, 58);
+        height: 90vh;
+      }
+      #root {
+        height: 100%;
+        width: 100%;
+        display: flex;
+        align-items: center;
+        justify-content: center;
+      }
+      .loading {
+        font-size: 32px;
+        font-weight: 200;
+        color: rgba(255, 255, 255, .6);
+        margin-left: 20px;
+      }
+      img {
+        width: 78px;
+        height: 78px;
+      }
+      .title {
+        font-weight: 400;
+      }
+    </style>
+    <img src='{{.Assets}}/build/logo.png' alt=''>
+    <div class="loading"> Loading
+      <span class="title">{{.Title}}</span>
+    </div>
+  </div>
+  <script>window.addEventListener('load', function (event) {
+		GraphQLPlayground.init(document.getElementById('root'), {{.Settings}})
+    })</script>
+</body>
+</html>
+{{ end }}
+`
+
+// graphiQLTemplate is the page template to render GraphiQL 2 with the explorer plugin
+const graphiQLTemplate = `
+{{ define "index" }}
+<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta http-equiv="Content-Type" content="text/html; charset=utf-8" />
+  <meta name="viewport" content="width=device-width, initial-scale=1.0">
+  <title>{{.Title}}</title>
+  <style>
+    body {
+      height: 100%;
+      margin: 0;
+      width: 100%;
+      overflow: hidden;
+    }
+    #graphiql {
+      height: 100vh;
+    }
+  </style>
+  <link rel="stylesheet" href="{{.Assets}}/graphiql.min.css" />
+  <link rel="stylesheet" href="{{.AssetBaseURL}}/@graphiql/plugin-explorer@0.1.20/dist/style.css" />
+  <script src="{{.AssetBaseURL}}/react@18.2.0/umd/react.production.min.js"></script>
+  <script src="{{.AssetBaseURL}}/react-dom@18.2.0/umd/react-dom.production.min.js"></script>
+  <script src="{{.Assets}}/graphiql.min.js"></script>
+  <script src="{{.AssetBaseURL}}/@graphiql/plugin-explorer@0.1.20/dist/graphiql-plugin-explorer.umd.js"></script>
+</head>
+<body>
+  <div id="graphiql">Loading...</div>
+  <script>
+    var settings = {{.Settings}};
+    var fetcher = GraphiQL.createFetcher(settings);
+    var explorer = GraphiQLPluginExplorer.explorerPlugin();
+    ReactDOM.createRoot(document.getElementById('graphiql')).render(
+      React.createElement(GraphiQL, {
+        fetcher: fetcher,
+        defaultTabs: settings.defaultTabs,
+        defaultEditorToolsVisibility: true,
+        plugins: [explorer]
+      })
+    );
+  </script>
+</body>
+</html>
+{{ end }}
+`
+
+// sandboxTemplate is the page template to render the embedded Apollo Sandbox
+const sandboxTemplate = `
+{{ define "index" }}
+<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta http-equiv="Content-Type" content="text/html; charset=utf-8" />
+  <meta name="viewport" content="width=device-width, initial-scale=1.0">
+  <title>{{.Title}}</title>
+  <style>
+    body {
+      height: 100%;
+      margin: 0;
+      width: 100%;
+      overflow: hidden;
+    }
+    #embedded-sandbox {
+      height: 100vh;
+      width: 100%;
+    }
+  </style>
+</head>
+<body>
+  <div id="embedded-sandbox"></div>
+  <script src="` + sandboxScript + `"></script>
+  <script>
+    new window.EmbeddedSandbox({{.Settings}});
+  </script>
+</body>
+</html>
+{{ end }}
+`
+
+// altairTemplate is the page template to render Altair, its lazily loaded
+// chunks resolve against the base element
+const altairTemplate = `
+{{ define "index" }}
+<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>{{.Title}}</title>
+  <base href="{{.Assets}}/build/dist/">
+  <link rel="stylesheet" href="{{.Assets}}/build/dist/styles.css" />
+</head>
+<body>
+  <app-root>
+    <div class="loading-screen styled">
+      <div class="loading-screen-inner">
+        <div class="loading-screen-logo-container">
+          <img src="{{.Assets}}/build/dist/assets/img/logo_350.svg" alt="Altair">
+        </div>
+        <div class="loading-screen-loading-indicator">
+          <span class="loading-indicator-dot"></span>
+          <span class="loading-indicator-dot"></span>
+          <span class="loading-indicator-dot"></span>
+        </div>
+      </div>
+    </div>
+  </app-root>
+  <script type="text/javascript" src="{{.Assets}}/build/dist/runtime.js"></script>
+  <script type="text/javascript" src="{{.Assets}}/build/dist/polyfills.js"></script>
+  <script type="text/javascript" src="{{.Assets}}/build/dist/main.js"></script>
+  <script>
+    document.addEventListener('DOMContentLoaded', function () {
+      AltairGraphQL.init({{.Settings}});
+    });
+  </script>
+</body>
+</html>
+{{ end }}
+`
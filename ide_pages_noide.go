@@ -0,0 +1,15 @@
+//go:build noide
+// +build noide
+
+package handler
+
+import "html/template"
+
+// The noide build tag leaves the built-in IDE pages out of the binary,
+// IDEs are then only served from Config.GraphiQLTemplate.
+var (
+	defaultGraphiQLTemplate *template.Template
+	graphiQLPage            *template.Template
+	sandboxPage             *template.Template
+	altairPage              *template.Template
+)
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// sandboxScript is the Apollo embeddable sandbox bundle, it is only published on Apollo's CDN
+const sandboxScript = "https://embeddable-sandbox.cdn.apollographql.com/_latest/embeddable-sandbox.umd.production.min.js"
+
+// SandboxSettings configures the embedded Apollo Sandbox
+type SandboxSettings struct {
+	// Endpoint is the URL the sandbox sends operations to, defaults to the
+	// absolute URL of the API endpoint
+	Endpoint string
+	// Document is the operation the editor opens with
+	Document string
+	// Variables are the initial operation variables
+	Variables map[string]interface{}
+	// Headers are the initial request headers
+	Headers map[string]string
+	// IncludeCookies sends cookies with the sandbox requests
+	IncludeCookies bool
+}
+
+// absoluteURL resolves path against the scheme and host r was sent to,
+// honoring X-Forwarded-Proto
+func absoluteURL(r *http.Request, path string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host + path
+}
+
+// sandboxOptions builds the EmbeddedSandbox options, the sandbox opens
+// with a single operation: the Document or else the first of tabs
+func sandboxOptions(s *SandboxSettings, r *http.Request, endpoint, subscription string, tabs []ExampleTab) map[string]interface{} {
+	if s == nil {
+		s = &SandboxSettings{}
+	}
+	if s.Document == "" && len(tabs) > 0 {
+		tab := tabs[0]
+		copied := *s
+		copied.Document = tab.Query
+		if copied.Variables == nil && tab.Variables != "" {
+			_ = json.Unmarshal([]byte(tab.Variables), &copied.Variables)
+		}
+		if copied.Headers == nil {
+			copied.Headers = tab.Headers
+		}
+		s = &copied
+	}
+	if s.Endpoint != "" {
+		endpoint = s.Endpoint
+	} else if strings.HasPrefix(endpoint, "/") {
+		endpoint = absoluteURL(r, endpoint)
+	}
+	state := map[string]interface{}{
+		"includeCookies": s.IncludeCookies,
+	}
+	if s.Document != "" {
+		state["document"] = s.Document
+	}
+	if len(s.Variables) > 0 {
+		state["variables"] = s.Variables
+	}
+	if len(s.Headers) > 0 {
+		state["headers"] = s.Headers
+	}
+	opts := map[string]interface{}{
+		"target":          "#embedded-sandbox",
+		"initialEndpoint": endpoint,
+		"initialState":    state,
+	}
+	if subscription != "" {
+		opts["initialSubscriptionEndpoint"] = subscription
+	}
+	return opts
+}
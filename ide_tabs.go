@@ -0,0 +1,60 @@
+package handler
+
+import "encoding/json"
+
+// ExampleTab is an example operation the IDE opens with
+type ExampleTab struct {
+	Name  string
+	Query string
+	// Variables is the JSON encoded variables object
+	Variables string
+	Headers   map[string]string
+}
+
+// playgroundTabs returns tabs as playground tabs
+func playgroundTabs(tabs []ExampleTab) []PlaygroundTab {
+	playground := make([]PlaygroundTab, len(tabs))
+	for i, tab := range tabs {
+		playground[i] = PlaygroundTab{Name: tab.Name, Query: tab.Query, Variables: tab.Variables, Headers: tab.Headers}
+	}
+	return playground
+}
+
+// graphiQLTabs returns tabs as GraphiQL defaultTabs, which have no names:
+// the name is a comment heading the query
+func graphiQLTabs(tabs []ExampleTab) []map[string]string {
+	graphiql := make([]map[string]string, len(tabs))
+	for i, tab := range tabs {
+		query := tab.Query
+		if tab.Name != "" {
+			query = "# " + tab.Name + "\n" + query
+		}
+		graphiql[i] = map[string]string{"query": query}
+		if tab.Variables != "" {
+			graphiql[i]["variables"] = tab.Variables
+		}
+		if len(tab.Headers) > 0 {
+			headers, _ := json.Marshal(tab.Headers)
+			graphiql[i]["headers"] = string(headers)
+		}
+	}
+	return graphiql
+}
+
+// altairWindows returns tabs as Altair initialWindows
+func altairWindows(tabs []ExampleTab) []map[string]interface{} {
+	windows := make([]map[string]interface{}, len(tabs))
+	for i, tab := range tabs {
+		windows[i] = map[string]interface{}{"initialQuery": tab.Query}
+		if tab.Name != "" {
+			windows[i]["initialName"] = tab.Name
+		}
+		if tab.Variables != "" {
+			windows[i]["initialVariables"] = tab.Variables
+		}
+		if len(tab.Headers) > 0 {
+			windows[i]["initialHeaders"] = tab.Headers
+		}
+	}
+	return windows
+}
@@ -0,0 +1,686 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/printer"
+	"github.com/graphql-go/graphql/language/visitor"
+)
+
+// ContentTypeMultipartMixed is the content type of incrementally delivered responses
+const ContentTypeMultipartMixed = "multipart/mixed"
+
+// DeferDirective declares @defer, adding it to SchemaConfig.Directives makes
+// it visible to introspection, the handler accepts it either way when
+// Config.IncrementalDelivery is set
+var DeferDirective = graphql.NewDirective(graphql.DirectiveConfig{
+	Name:        "defer",
+	Description: "Delivers the fragment after the rest of the response.",
+	Locations:   []string{graphql.DirectiveLocationFragmentSpread, graphql.DirectiveLocationInlineFragment},
+	Args: graphql.FieldConfigArgument{
+		"if":    &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: true},
+		"label": &graphql.ArgumentConfig{Type: graphql.String},
+	},
+})
+
+// StreamDirective declares @stream, see DeferDirective
+var StreamDirective = graphql.NewDirective(graphql.DirectiveConfig{
+	Name:        "stream",
+	Description: "Delivers the list items after the first initialCount separately.",
+	Locations:   []string{graphql.DirectiveLocationField},
+	Args: graphql.FieldConfigArgument{
+		"if":           &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: true},
+		"label":        &graphql.ArgumentConfig{Type: graphql.String},
+		"initialCount": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+	},
+})
+
+// incrementalUnit is a deferred fragment or streamed field, chain holds the
+// selections leading to it from the operation root, itself included
+type incrementalUnit struct {
+	label        string
+	chain        []ast.Selection
+	stream       bool
+	initialCount int
+}
+
+// incrementalPlan splits the result of a document using @defer/@stream into
+// the initial payload and the units delivered afterwards. The document is
+// executed once, the units are cut from its result
+type incrementalPlan struct {
+	// stripped is the document with the directives removed and nothing deferred
+	stripped *ast.Document
+	// executed is stripped selecting the __typename of the objects fragments
+	// are spread on, telling the fragments that apply to them
+	executed  *ast.Document
+	operation *ast.OperationDefinition
+	fragments map[string]*ast.FragmentDefinition
+	schema    *graphql.Schema
+	variables map[string]interface{}
+	units     []*incrementalUnit
+}
+
+// usesIncrementalDirectives is a cheap test to skip planning for most queries
+func usesIncrementalDirectives(query string) bool {
+	return strings.Contains(query, "@defer") || strings.Contains(query, "@stream")
+}
+
+// findDirective returns the named directive if it is present and enabled
+func findDirective(directives []*ast.Directive, name string, variables map[string]interface{}) *ast.Directive {
+	for _, d := range directives {
+		if d.Name == nil || d.Name.Value != name {
+			continue
+		}
+		if arg := directiveArgument(d, "if", variables); arg != nil {
+			if enabled, ok := arg.(bool); ok && !enabled {
+				return nil
+			}
+		}
+		return d
+	}
+	return nil
+}
+
+// directiveArgument returns the value of an argument, resolving variables
+func directiveArgument(d *ast.Directive, name string, variables map[string]interface{}) interface{} {
+	for _, arg := range d.Arguments {
+		if arg.Name == nil || arg.Name.Value != name {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.Variable:
+			return variables[v.Name.Value]
+		case *ast.BooleanValue:
+			return v.Value
+		case *ast.StringValue:
+			return v.Value
+		case *ast.IntValue:
+			n, _ := strconv.Atoi(v.Value)
+			return n
+		}
+	}
+	return nil
+}
+
+// withoutIncrementalDirectives returns directives without @defer and @stream
+func withoutIncrementalDirectives(directives []*ast.Directive) []*ast.Directive {
+	out := make([]*ast.Directive, 0, len(directives))
+	for _, d := range directives {
+		if d.Name != nil && (d.Name.Value == "defer" || d.Name.Value == "stream") {
+			continue
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+// stripSelectionSet copies set without @defer/@stream, selecting the
+// __typename of the objects fragments are spread on when typenames is set
+func (p *incrementalPlan) stripSelectionSet(set *ast.SelectionSet, typenames bool) *ast.SelectionSet {
+	if set == nil {
+		return nil
+	}
+	selections := make([]ast.Selection, 0, len(set.Selections)+1)
+	spread := false
+	for _, sel := range set.Selections {
+		switch s := sel.(type) {
+		case *ast.Field:
+			selections = append(selections, ast.NewField(&ast.Field{
+				Loc:          s.Loc,
+				Alias:        s.Alias,
+				Name:         s.Name,
+				Arguments:    s.Arguments,
+				Directives:   withoutIncrementalDirectives(s.Directives),
+				SelectionSet: p.stripSelectionSet(s.SelectionSet, typenames),
+			}))
+		case *ast.InlineFragment:
+			spread = true
+			selections = append(selections, ast.NewInlineFragment(&ast.InlineFragment{
+				Loc:           s.Loc,
+				TypeCondition: s.TypeCondition,
+				Directives:    withoutIncrementalDirectives(s.Directives),
+				SelectionSet:  p.stripSelectionSet(s.SelectionSet, typenames),
+			}))
+		case *ast.FragmentSpread:
+			spread = true
+			selections = append(selections, ast.NewFragmentSpread(&ast.FragmentSpread{
+				Loc:        s.Loc,
+				Name:       s.Name,
+				Directives: withoutIncrementalDirectives(s.Directives),
+			}))
+		}
+	}
+	if spread && typenames {
+		selections = append(selections, ast.NewField(&ast.Field{Name: ast.NewName(&ast.Name{Value: "__typename"})}))
+	}
+	return ast.NewSelectionSet(&ast.SelectionSet{Loc: set.Loc, Selections: selections})
+}
+
+// document assembles the operation selecting set with the fragment
+// definitions and variable definitions it still uses, stripped alike, so
+// the document passes the validation of graphql.Do
+func (p *incrementalPlan) document(set *ast.SelectionSet, typenames bool) *ast.Document {
+	operation := ast.NewOperationDefinition(&ast.OperationDefinition{
+		Loc:          p.operation.Loc,
+		Operation:    p.operation.Operation,
+		Name:         p.operation.Name,
+		Directives:   p.operation.Directives,
+		SelectionSet: set,
+	})
+	doc := ast.NewDocument(&ast.Document{Definitions: []ast.Node{operation}})
+	variables := map[string]bool{}
+	collect := func(node ast.Node) []string {
+		var spreads []string
+		visitor.Visit(node, &visitor.VisitorOptions{
+			Enter: func(vp visitor.VisitFuncParams) (string, interface{}) {
+				switch node := vp.Node.(type) {
+				case *ast.Variable:
+					variables[node.Name.Value] = true
+				case *ast.FragmentSpread:
+					spreads = append(spreads, node.Name.Value)
+				}
+				return visitor.ActionNoChange, nil
+			},
+		}, nil)
+		return spreads
+	}
+	pending := collect(operation)
+	included := map[string]bool{}
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		def, ok := p.fragments[name]
+		if !ok || included[name] {
+			continue
+		}
+		included[name] = true
+		stripped := ast.NewFragmentDefinition(&ast.FragmentDefinition{
+			Loc:           def.Loc,
+			Name:          def.Name,
+			TypeCondition: def.TypeCondition,
+			Directives:    def.Directives,
+			SelectionSet:  p.stripSelectionSet(def.SelectionSet, typenames),
+		})
+		doc.Definitions = append(doc.Definitions, stripped)
+		pending = append(pending, collect(stripped)...)
+	}
+	for _, def := range p.operation.VariableDefinitions {
+		if variables[def.Variable.Name.Value] {
+			operation.VariableDefinitions = append(operation.VariableDefinitions, def)
+		}
+	}
+	return doc
+}
+
+// walk collects the deferred fragments and streamed fields below set
+func (p *incrementalPlan) walk(set *ast.SelectionSet, chain []ast.Selection, inDefer bool) {
+	if set == nil {
+		return
+	}
+	for _, sel := range set.Selections {
+		switch s := sel.(type) {
+		case *ast.Field:
+			next := append(append([]ast.Selection{}, chain...), s)
+			if d := findDirective(s.Directives, "stream", p.variables); d != nil && !inDefer {
+				label, _ := directiveArgument(d, "label", p.variables).(string)
+				count, _ := directiveArgument(d, "initialCount", p.variables).(int)
+				p.units = append(p.units, &incrementalUnit{label: label, chain: next, stream: true, initialCount: count})
+			}
+			p.walk(s.SelectionSet, next, inDefer)
+		case *ast.InlineFragment:
+			p.walkFragment(s, chain, inDefer)
+		case *ast.FragmentSpread:
+			def, ok := p.fragments[s.Name.Value]
+			if !ok {
+				continue
+			}
+			p.walkFragment(ast.NewInlineFragment(&ast.InlineFragment{
+				Loc:           s.Loc,
+				TypeCondition: def.TypeCondition,
+				Directives:    s.Directives,
+				SelectionSet:  def.SelectionSet,
+			}), chain, inDefer)
+		}
+	}
+}
+
+func (p *incrementalPlan) walkFragment(f *ast.InlineFragment, chain []ast.Selection, inDefer bool) {
+	next := append(append([]ast.Selection{}, chain...), f)
+	if d := findDirective(f.Directives, "defer", p.variables); d != nil && !inDefer {
+		label, _ := directiveArgument(d, "label", p.variables).(string)
+		p.units = append(p.units, &incrementalUnit{label: label, chain: next})
+		// nested deferred fragments are delivered with their parent
+		inDefer = true
+	}
+	p.walk(f.SelectionSet, next, inDefer)
+}
+
+// planIncremental plans the incremental delivery of the operation of opts,
+// the returned plan is nil when the document cannot be parsed
+func planIncremental(opts *RequestOptions, params graphql.Params) *incrementalPlan {
+	doc, operation := parseOperation(opts)
+	if operation == nil {
+		return nil
+	}
+	p := &incrementalPlan{
+		operation: operation,
+		fragments: map[string]*ast.FragmentDefinition{},
+		schema:    &params.Schema,
+		variables: params.VariableValues,
+	}
+	for _, def := range doc.Definitions {
+		if def, ok := def.(*ast.FragmentDefinition); ok {
+			p.fragments[def.Name.Value] = def
+		}
+	}
+	p.stripped = p.document(p.stripSelectionSet(p.operation.SelectionSet, false), false)
+	p.executed = p.document(p.stripSelectionSet(p.operation.SelectionSet, true), true)
+	if p.operation.Operation != ast.OperationTypeSubscription {
+		// the events of subscriptions are delivered whole
+		p.walk(p.operation.SelectionSet, nil, false)
+	}
+	return p
+}
+
+// strippedQuery prints the stripped document, executing it delivers the
+// complete result at once
+func (p *incrementalPlan) strippedQuery() string {
+	return fmt.Sprint(printer.Print(p.stripped))
+}
+
+// incrementalEntry is one entry of the incremental list of a subsequent payload
+type incrementalEntry struct {
+	Data   interface{}                `json:"data,omitempty"`
+	Items  []interface{}              `json:"items,omitempty"`
+	Path   []interface{}              `json:"path"`
+	Label  string                     `json:"label,omitempty"`
+	Errors []gqlerrors.FormattedError `json:"errors,omitempty"`
+}
+
+// responseKey returns the key f is reported under
+func responseKey(f *ast.Field) string {
+	if f.Alias != nil && f.Alias.Value != "" {
+		return f.Alias.Value
+	}
+	return f.Name.Value
+}
+
+// applies reports whether a fragment on condition applies to obj, enabled
+// deferred fragments only when deferred is set. The __typename the executed
+// document selects tells the type of obj, objects without one match
+func (p *incrementalPlan) applies(condition *ast.Named, directives []*ast.Directive, obj map[string]interface{}, deferred bool) bool {
+	if !deferred && findDirective(directives, "defer", p.variables) != nil {
+		return false
+	}
+	typename, ok := obj["__typename"].(string)
+	if condition == nil || condition.Name == nil || !ok || condition.Name.Value == typename {
+		return true
+	}
+	abstract, ok := p.schema.Type(condition.Name.Value).(graphql.Abstract)
+	object, isObject := p.schema.Type(typename).(*graphql.Object)
+	return ok && isObject && p.schema.IsPossibleType(abstract, object)
+}
+
+// project returns the part of value the selections of set select, see
+// applies for deferred
+func (p *incrementalPlan) project(value interface{}, set *ast.SelectionSet, deferred bool) interface{} {
+	if set == nil {
+		return value
+	}
+	switch v := value.(type) {
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = p.project(item, set, deferred)
+		}
+		return out
+	case map[string]interface{}:
+		out := map[string]interface{}{}
+		p.projectInto(out, v, set, deferred)
+		return out
+	}
+	return value
+}
+
+// projectInto adds the fields of obj selected by set to out
+func (p *incrementalPlan) projectInto(out, obj map[string]interface{}, set *ast.SelectionSet, deferred bool) {
+	for _, sel := range set.Selections {
+		switch s := sel.(type) {
+		case *ast.Field:
+			key := responseKey(s)
+			if value, ok := obj[key]; ok {
+				out[key] = mergeValues(out[key], p.project(value, s.SelectionSet, deferred))
+			}
+		case *ast.InlineFragment:
+			if p.applies(s.TypeCondition, s.Directives, obj, deferred) {
+				p.projectInto(out, obj, s.SelectionSet, deferred)
+			}
+		case *ast.FragmentSpread:
+			if def, ok := p.fragments[s.Name.Value]; ok && p.applies(def.TypeCondition, s.Directives, obj, deferred) {
+				p.projectInto(out, obj, def.SelectionSet, deferred)
+			}
+		}
+	}
+}
+
+// mergeValues merges the projections of a field selected more than once
+func mergeValues(a, b interface{}) interface{} {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		if b, ok := b.(map[string]interface{}); ok {
+			for key, value := range b {
+				a[key] = mergeValues(a[key], value)
+			}
+			return a
+		}
+	case []interface{}:
+		if b, ok := b.([]interface{}); ok && len(a) == len(b) {
+			for i := range a {
+				a[i] = mergeValues(a[i], b[i])
+			}
+			return a
+		}
+	}
+	return b
+}
+
+// locate calls fn with every object reached from data by the selections of
+// chain, fanning out over lists, and the response path of that object
+func (p *incrementalPlan) locate(data interface{}, chain []ast.Selection, path []interface{}, fn func(obj map[string]interface{}, path []interface{})) {
+	obj, ok := data.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if len(chain) == 0 {
+		fn(obj, path)
+		return
+	}
+	f, ok := chain[0].(*ast.Field)
+	if !ok {
+		if fragment := chain[0].(*ast.InlineFragment); p.applies(fragment.TypeCondition, nil, obj, true) {
+			p.locate(obj, chain[1:], path, fn)
+		}
+		return
+	}
+	key := responseKey(f)
+	switch v := obj[key].(type) {
+	case []interface{}:
+		for i, item := range v {
+			p.locate(item, chain[1:], append(append([]interface{}{}, path...), key, i), fn)
+		}
+	default:
+		p.locate(v, chain[1:], append(append([]interface{}{}, path...), key), fn)
+	}
+}
+
+// lookup returns the value at path in data, nil when there is none
+func lookup(data interface{}, path []interface{}) interface{} {
+	for _, segment := range path {
+		switch segment := segment.(type) {
+		case string:
+			obj, _ := data.(map[string]interface{})
+			data = obj[segment]
+		case int:
+			list, _ := data.([]interface{})
+			if segment >= len(list) {
+				return nil
+			}
+			data = list[segment]
+		}
+	}
+	return data
+}
+
+// splitStream truncates the lists streamed by u in the initial data and
+// returns the entries delivering the remaining items, the lists are located
+// through the executed data
+func (p *incrementalPlan) splitStream(data, initial interface{}, u *incrementalUnit) []incrementalEntry {
+	key := responseKey(u.chain[len(u.chain)-1].(*ast.Field))
+	count := u.initialCount
+	if count < 0 {
+		count = 0
+	}
+	var entries []incrementalEntry
+	p.locate(data, u.chain[:len(u.chain)-1], []interface{}{}, func(_ map[string]interface{}, path []interface{}) {
+		obj, _ := lookup(initial, path).(map[string]interface{})
+		list, ok := obj[key].([]interface{})
+		if !ok || len(list) <= count {
+			return
+		}
+		obj[key] = list[:count]
+		entries = append(entries, incrementalEntry{
+			Items: list[count:],
+			Path:  append(append([]interface{}{}, path...), key, count),
+			Label: u.label,
+		})
+	})
+	return entries
+}
+
+// deferredEntries cuts the deferred fragment of u from the executed data
+func (p *incrementalPlan) deferredEntries(data interface{}, u *incrementalUnit) []incrementalEntry {
+	fragment := u.chain[len(u.chain)-1].(*ast.InlineFragment)
+	var entries []incrementalEntry
+	p.locate(data, u.chain[:len(u.chain)-1], []interface{}{}, func(obj map[string]interface{}, path []interface{}) {
+		if !p.applies(fragment.TypeCondition, nil, obj, true) {
+			return
+		}
+		fields := map[string]interface{}{}
+		p.projectInto(fields, obj, fragment.SelectionSet, true)
+		if len(fields) > 0 {
+			entries = append(entries, incrementalEntry{Data: fields, Path: path, Label: u.label})
+		}
+	})
+	return entries
+}
+
+// payloads splits the result of the executed document into the initial
+// payload and the subsequent ones. An error goes with the payload
+// delivering the field at its path
+func (p *incrementalPlan) payloads(result *graphql.Result) []map[string]interface{} {
+	initial := p.project(result.Data, p.operation.SelectionSet, false)
+	var patches [][]incrementalEntry
+	var streamed []incrementalEntry
+	for _, u := range p.units {
+		if u.stream {
+			streamed = append(streamed, p.splitStream(result.Data, initial, u)...)
+		}
+	}
+	if len(streamed) > 0 {
+		patches = append(patches, streamed)
+	}
+	for _, u := range p.units {
+		if u.stream {
+			continue
+		}
+		if entries := p.deferredEntries(result.Data, u); len(entries) > 0 {
+			patches = append(patches, entries)
+		}
+	}
+	var errs []gqlerrors.FormattedError
+	for _, err := range result.Errors {
+		if entry := errorEntry(patches, initial, err.Path); entry != nil {
+			entry.Errors = append(entry.Errors, err)
+		} else {
+			errs = append(errs, err)
+		}
+	}
+	first := map[string]interface{}{"data": initial, "hasNext": true}
+	if len(errs) > 0 {
+		first["errors"] = errs
+	}
+	payloads := []map[string]interface{}{first}
+	for _, entries := range patches {
+		payloads = append(payloads, map[string]interface{}{"incremental": entries, "hasNext": true})
+	}
+	return append(payloads, map[string]interface{}{"hasNext": false})
+}
+
+// errorEntry returns the entry delivering the field at path, nil when the
+// initial data holds it
+func errorEntry(patches [][]incrementalEntry, initial interface{}, path []interface{}) *incrementalEntry {
+	for i := range patches {
+		for j := range patches[i] {
+			entry := &patches[i][j]
+			n := len(entry.Path)
+			if entry.Items != nil {
+				// the items start at the last index of the path
+				n--
+			}
+			if len(path) <= n || !hasPathPrefix(path, entry.Path[:n]) {
+				continue
+			}
+			if entry.Items != nil {
+				if index, ok := path[n].(int); ok && index >= entry.Path[n].(int) {
+					return entry
+				}
+				continue
+			}
+			key, _ := path[n].(string)
+			if _, ok := entry.Data.(map[string]interface{})[key]; !ok {
+				continue
+			}
+			if obj, _ := lookup(initial, path[:n]).(map[string]interface{}); obj != nil {
+				if _, ok := obj[key]; ok {
+					continue
+				}
+			}
+			return entry
+		}
+	}
+	return nil
+}
+
+func hasPathPrefix(path, prefix []interface{}) bool {
+	for i := range prefix {
+		if path[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// executeIncremental executes the planned doc, with the configured Executor
+// when there is one
+func (h *Handler) executeIncremental(params graphql.Params, doc *ast.Document) *graphql.Result {
+	if h.executor != nil {
+		params.RequestString = fmt.Sprint(printer.Print(doc))
+		return h.executor.Do(params.Context, params)
+	}
+	return h.executeDocument(params, doc)
+}
+
+// acceptsMultipartMixed reports whether the client accepts incremental responses
+func acceptsMultipartMixed(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), ContentTypeMultipartMixed)
+}
+
+// inParts reports whether the plan is delivered in parts to the client of r,
+// other clients get the result of plan.stripped at once
+func (p *incrementalPlan) inParts(w http.ResponseWriter, r *http.Request) bool {
+	_, canFlush := w.(http.Flusher)
+	return len(p.units) > 0 && acceptsMultipartMixed(r) && canFlush
+}
+
+// incrementalCacheKey returns the key the parts of the response cached
+// under key are kept under
+func incrementalCacheKey(key string) string {
+	return key + ":parts"
+}
+
+// serveIncremental delivers the deferred fragments and streamed items of
+// plan as subsequent multipart/mixed parts. The payloads are cached under
+// cacheKey for ttl unless it is empty or the result has errors
+func (h *Handler) serveIncremental(ctx context.Context, w http.ResponseWriter, r *http.Request, opts *RequestOptions, params graphql.Params, plan *incrementalPlan, started time.Time, cacheKey string, ttl time.Duration) {
+	var result *graphql.Result
+	if validation := graphql.ValidateDocument(&params.Schema, plan.executed, nil); !validation.IsValid {
+		result = &graphql.Result{Errors: validation.Errors}
+	} else {
+		// executed once, the parts are cut from the result
+		result = h.executeIncremental(params, plan.executed)
+	}
+	h.finishErrors(ctx, result)
+	if h.resultFn != nil {
+		if processed := h.resultFn(ctx, &params, result); processed != nil {
+			result = processed
+		}
+	}
+	if h.headersFn != nil {
+		h.headersFn(ctx, &params, result, w.Header())
+	}
+	failed := result.HasErrors()
+	observe := func(buff []byte) {
+		if h.slo != nil {
+			h.slo.observe(operationKey(opts), time.Since(started), failed)
+		}
+		h.reportSlowQuery(ctx, opts, time.Since(started), failed)
+		if h.resultCallbackFn != nil {
+			h.resultCallbackFn(ctx, &params, result, buff)
+		}
+	}
+	if result.Data == nil {
+		buff := h.marshalResult(r, result)
+		h.writeBody(ctx, w, r, http.StatusOK, buff)
+		observe(buff)
+		return
+	}
+	var payloads []json.RawMessage
+	for _, payload := range plan.payloads(result) {
+		buff, _ := json.Marshal(payload)
+		payloads = append(payloads, buff)
+	}
+	observe(h.writeParts(ctx, w, r, payloads))
+	if cacheKey != "" && !failed {
+		if buff, err := json.Marshal(payloads); err == nil {
+			h.responseCache.Store.Set(ctx, cacheKey, buff, ttl)
+		}
+	}
+}
+
+// replayParts writes the payloads serveIncremental cached, it returns false,
+// writing nothing, when buff does not hold them
+func (h *Handler) replayParts(ctx context.Context, w http.ResponseWriter, r *http.Request, buff []byte) bool {
+	var payloads []json.RawMessage
+	if err := json.Unmarshal(buff, &payloads); err != nil || len(payloads) == 0 {
+		return false
+	}
+	h.writeParts(ctx, w, r, payloads)
+	return true
+}
+
+// writeParts writes payloads as the parts of a multipart/mixed response,
+// flushing each, and returns the written body
+func (h *Handler) writeParts(ctx context.Context, w http.ResponseWriter, r *http.Request, payloads []json.RawMessage) []byte {
+	flusher := w.(http.Flusher)
+	w.Header().Set("Content-Type", ContentTypeMultipartMixed+`; boundary="-"; deferSpec=20220824`)
+	w.WriteHeader(http.StatusOK)
+	var body bytes.Buffer
+	write := func(b []byte) {
+		body.Write(b)
+		_, _ = w.Write(b)
+	}
+	for _, payload := range payloads {
+		write([]byte("\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n"))
+		write(payload)
+		flusher.Flush()
+	}
+	write([]byte("\r\n-----\r\n"))
+	flusher.Flush()
+	if h.cleanupUploadsFirst {
+		h.cleanupUploads(ctx, r)
+	}
+	if h.finishFn != nil {
+		h.finishFn(ctx, w, r, body.Bytes())
+	}
+	return body.Bytes()
+}
@@ -0,0 +1,168 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_IncrementalDelivery(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:              &testutil.StarWarsSchema,
+		IncrementalDelivery: true,
+	})
+	query := `{hero{id ... on Droid @defer(label: "droid") {primaryFunction} ... on Human @defer(label: "human") {homePlanet} friends @stream(initialCount: 1) {name}}}`
+
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":`+mustJSON(query)+`}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "multipart/mixed, application/json")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if ct := resp.Header().Get("Content-Type"); !strings.HasPrefix(ct, "multipart/mixed") {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	body := resp.Body.String()
+	if !strings.HasSuffix(body, "\r\n-----\r\n") {
+		t.Fatalf("response not terminated: %q", body)
+	}
+	var parts []interface{}
+	for _, part := range strings.Split(strings.TrimSuffix(body, "\r\n-----\r\n"), "\r\n---\r\n")[1:] {
+		var payload interface{}
+		if err := json.Unmarshal([]byte(part[strings.Index(part, "\r\n\r\n")+4:]), &payload); err != nil {
+			t.Fatalf("invalid part %q: %v", part, err)
+		}
+		parts = append(parts, payload)
+	}
+	expected := []interface{}{
+		map[string]interface{}{
+			"data": map[string]interface{}{"hero": map[string]interface{}{
+				"id":      "2001",
+				"friends": []interface{}{map[string]interface{}{"name": "Luke Skywalker"}},
+			}},
+			"hasNext": true,
+		},
+		map[string]interface{}{
+			"incremental": []interface{}{map[string]interface{}{
+				"items": []interface{}{
+					map[string]interface{}{"name": "Han Solo"},
+					map[string]interface{}{"name": "Leia Organa"},
+				},
+				"path": []interface{}{"hero", "friends", float64(1)},
+			}},
+			"hasNext": true,
+		},
+		map[string]interface{}{
+			"incremental": []interface{}{map[string]interface{}{
+				"data":  map[string]interface{}{"primaryFunction": "Astromech"},
+				"path":  []interface{}{"hero"},
+				"label": "droid",
+			}},
+			"hasNext": true,
+		},
+		map[string]interface{}{"hasNext": false},
+	}
+	if !reflect.DeepEqual(parts, expected) {
+		t.Fatalf("wrong parts, diff: %v", testutil.Diff(expected, parts))
+	}
+
+	// clients not accepting multipart/mixed get the complete result at once
+	req, _ = http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":`+mustJSON(query)+`}`))
+	req.Header.Set("Content-Type", "application/json")
+	result, _ := executeTest(t, h, req)
+	hero := result.Data.(map[string]interface{})["hero"].(map[string]interface{})
+	if hero["primaryFunction"] != "Astromech" || len(hero["friends"].([]interface{})) != 3 {
+		t.Fatalf("unexpected inline result %v", result.Data)
+	}
+}
+
+func TestHandler_IncrementalDelivery_Executor(t *testing.T) {
+	var executed, callbacks int
+	h := handler.New(&handler.Config{
+		Schema:              &testutil.StarWarsSchema,
+		IncrementalDelivery: true,
+		Executor: handler.ExecutorFunc(func(ctx context.Context, params graphql.Params) *graphql.Result {
+			executed++
+			return handler.DefaultExecutor.Do(ctx, params)
+		}),
+		ResultCallbackFn: func(ctx context.Context, params *graphql.Params, result *graphql.Result, responseBody []byte) {
+			callbacks++
+		},
+	})
+	query := `query($withHuman: Boolean = true) {hero{name} ... @defer(if: $withHuman) {human(id: "1000") {name}}}`
+
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":`+mustJSON(query)+`}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "multipart/mixed")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	// root fragments are delivered at the empty path
+	if body := resp.Body.String(); !strings.Contains(body, `"data":{"human":{"name":"Luke Skywalker"}},"path":[]`) {
+		t.Fatalf("unexpected body %q", body)
+	}
+	if executed != 1 || callbacks != 1 {
+		t.Fatalf("executed %d times with %d callbacks", executed, callbacks)
+	}
+
+	req, _ = http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":`+mustJSON(query)+`}`))
+	req.Header.Set("Content-Type", "application/json")
+	result, _ := executeTest(t, h, req)
+	if result.Data.(map[string]interface{})["human"] == nil || len(result.Errors) > 0 {
+		t.Fatalf("unexpected inline result %+v", result)
+	}
+	if executed != 2 || callbacks != 2 {
+		t.Fatalf("executed %d times with %d callbacks", executed, callbacks)
+	}
+}
+
+func TestHandler_IncrementalDelivery_ExecutedOnce(t *testing.T) {
+	resolved := 0
+	item := graphql.NewObject(graphql.ObjectConfig{Name: "Item", Fields: graphql.Fields{
+		"name": &graphql.Field{Type: graphql.String},
+		"price": &graphql.Field{Type: graphql.Int, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return nil, errors.New("no price")
+		}},
+	}})
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"items": &graphql.Field{Type: graphql.NewList(item), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				resolved++
+				return []interface{}{map[string]interface{}{"name": "a"}, map[string]interface{}{"name": "b"}}, nil
+			}},
+		}}),
+	})
+	h := handler.New(&handler.Config{Schema: &schema, IncrementalDelivery: true})
+	query := `{items {name ... on Item @defer {price}} more: items @stream {name}}`
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":`+mustJSON(query)+`}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "multipart/mixed")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	body := resp.Body.String()
+	if resolved != 2 {
+		t.Fatalf("items resolved %d times: %s", resolved, body)
+	}
+	// the errors of deferred fields go with their fragment
+	for _, part := range []string{
+		`{"data":{"items":[{"name":"a"},{"name":"b"}],"more":[]},"hasNext":true}`,
+		`{"hasNext":true,"incremental":[{"items":[{"name":"a"},{"name":"b"}],"path":["more",0]}]}`,
+		`{"data":{"price":null},"path":["items",1],"errors":[{"message":"no price","locations":[{"line":1,"column":34}],"path":["items",1,"price"]}]}`,
+	} {
+		if !strings.Contains(body, part) {
+			t.Fatalf("missing %s in %q", part, body)
+		}
+	}
+}
+
+func mustJSON(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// introspectionCacheSize is the number of introspection responses kept by
+// Config.CacheIntrospection
+const introspectionCacheSize = 64
+
+// isIntrospection reports whether opts selects a query reading nothing but
+// the introspection fields, its response only changes with the schema
+func isIntrospection(opts *RequestOptions) bool {
+	doc, operation := parseOperation(opts)
+	if operation == nil || operation.Operation != ast.OperationTypeQuery {
+		return false
+	}
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, def := range doc.Definitions {
+		if def, ok := def.(*ast.FragmentDefinition); ok && def.Name != nil {
+			fragments[def.Name.Value] = def
+		}
+	}
+	return onlyIntrospection(operation.SelectionSet, fragments, map[string]bool{})
+}
+
+func onlyIntrospection(set *ast.SelectionSet, fragments map[string]*ast.FragmentDefinition, visited map[string]bool) bool {
+	if set == nil || len(set.Selections) == 0 {
+		return false
+	}
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if !strings.HasPrefix(selection.Name.Value, "__") {
+				return false
+			}
+		case *ast.InlineFragment:
+			if !onlyIntrospection(selection.SelectionSet, fragments, visited) {
+				return false
+			}
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			if visited[name] {
+				continue
+			}
+			visited[name] = true
+			fragment, ok := fragments[name]
+			if !ok || !onlyIntrospection(fragment.SelectionSet, fragments, visited) {
+				return false
+			}
+		}
+	}
+	return true
+}
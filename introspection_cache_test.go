@@ -0,0 +1,53 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_CacheIntrospection(t *testing.T) {
+	executed := 0
+	h := handler.New(&handler.Config{
+		Schema:             &testutil.StarWarsSchema,
+		CacheIntrospection: true,
+		ResultFn: func(ctx context.Context, params *graphql.Params, result *graphql.Result) *graphql.Result {
+			executed++
+			return nil
+		},
+	})
+	run := func(query string) {
+		req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape(query), nil)
+		if result, _ := executeTest(t, h, req); result.HasErrors() {
+			t.Fatalf("%s: unexpected errors %v", query, result.Errors)
+		}
+	}
+	for _, test := range []struct {
+		query    string
+		executed int
+	}{
+		{testutil.IntrospectionQuery, 1},
+		{testutil.IntrospectionQuery, 1},
+		{"{ ...T } fragment T on Query { __type(name: \"Droid\") { name } }", 2},
+		{"{ ...T } fragment T on Query { __type(name: \"Droid\") { name } }", 2},
+		// operations reading other fields execute every time
+		{"{ __typename hero { name } }", 3},
+		{"{ __typename hero { name } }", 4},
+	} {
+		run(test.query)
+		if executed != test.executed {
+			t.Fatalf("%s: executed %d times, expected %d", test.query, executed, test.executed)
+		}
+	}
+	// replacing the schema invalidates the cached responses
+	h.SetSchema(&testutil.StarWarsSchema)
+	run(testutil.IntrospectionQuery)
+	if executed != 5 {
+		t.Fatalf("executed %d times after the schema swap", executed)
+	}
+}
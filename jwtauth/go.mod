@@ -0,0 +1,11 @@
+module github.com/cxuhua/handler/jwtauth
+
+go 1.18
+
+replace github.com/cxuhua/handler => ../
+
+require (
+	github.com/cxuhua/handler v0.0.0-00010101000000-000000000000
+	github.com/golang-jwt/jwt/v5 v5.2.1
+	github.com/graphql-go/graphql v0.7.9
+)
@@ -0,0 +1,130 @@
+// Package jwtauth authenticates handler requests by the JWT bearer tokens of
+// their Authorization header, see handler.Config.ContextFn
+package jwtauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cxuhua/handler"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrMissingToken rejects requests without a bearer token
+var ErrMissingToken = errors.New("missing bearer token")
+
+type claimsKey struct{}
+
+// ClaimsKey is the context key of the jwt.MapClaims of authenticated
+// requests, see Claims
+var ClaimsKey = claimsKey{}
+
+// Claims returns the claims of the token the request of ctx carried, nil
+// for unauthenticated requests
+func Claims(ctx context.Context) jwt.MapClaims {
+	claims, _ := ctx.Value(ClaimsKey).(jwt.MapClaims)
+	return claims
+}
+
+// Config selects the keys and claims tokens are validated against
+type Config struct {
+	// Keys verify tokens by their kid header, the "" key verifies tokens
+	// without one. Values are *rsa.PublicKey, *ecdsa.PublicKey,
+	// ed25519.PublicKey or []byte HMAC secrets
+	Keys map[string]interface{}
+	// JWKSURL is fetched for the keys Keys does not hold
+	JWKSURL string
+	// Client fetches JWKSURL, defaults to http.DefaultClient
+	Client *http.Client
+	// RefreshInterval is how long fetched keys are used, defaults to an hour.
+	// Unknown key IDs refetch the set at most once a minute
+	RefreshInterval time.Duration
+	// Methods are the accepted signing algorithms, e.g. "RS256", empty
+	// accepts any matching the type of the key
+	Methods []string
+	// Issuer and Audience are required to match the claims when set
+	Issuer   string
+	Audience string
+	// Leeway tolerates clock skew when validating exp, nbf and iat
+	Leeway time.Duration
+	// Optional lets requests without a token through unauthenticated,
+	// invalid tokens are still rejected
+	Optional bool
+}
+
+// Authenticator validates the bearer tokens of requests
+type Authenticator struct {
+	cfg    Config
+	parser *jwt.Parser
+	jwks   *keySet
+}
+
+// New returns the Authenticator of cfg
+func New(cfg Config) (*Authenticator, error) {
+	if len(cfg.Keys) == 0 && cfg.JWKSURL == "" {
+		return nil, errors.New("jwtauth: Keys or JWKSURL is required")
+	}
+	opts := []jwt.ParserOption{jwt.WithLeeway(cfg.Leeway)}
+	if len(cfg.Methods) > 0 {
+		opts = append(opts, jwt.WithValidMethods(cfg.Methods))
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	a := &Authenticator{cfg: cfg, parser: jwt.NewParser(opts...)}
+	if cfg.JWKSURL != "" {
+		a.jwks = newKeySet(cfg.JWKSURL, cfg.Client, cfg.RefreshInterval)
+	}
+	return a, nil
+}
+
+// Context is a handler.ContextFn storing the claims of the bearer token of r
+// under ClaimsKey. Missing and invalid tokens are answered with 401
+// Unauthorized
+func (a *Authenticator) Context(ctx context.Context, r *http.Request) (context.Context, error) {
+	token, ok := bearerToken(r)
+	if !ok {
+		if a.cfg.Optional {
+			return ctx, nil
+		}
+		return nil, ErrMissingToken
+	}
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return a.key(ctx, t)
+	}); err != nil {
+		return nil, fmt.Errorf("invalid bearer token: %w", err)
+	}
+	return context.WithValue(ctx, ClaimsKey, claims), nil
+}
+
+var _ handler.ContextFn = (*Authenticator)(nil).Context
+
+// key returns the key verifying t
+func (a *Authenticator) key(ctx context.Context, t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	if key, ok := a.cfg.Keys[kid]; ok {
+		return key, nil
+	}
+	if a.jwks != nil {
+		return a.jwks.key(ctx, kid)
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// bearerToken returns the token of the Authorization header of r
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return "", false
+	}
+	token := strings.TrimSpace(header[7:])
+	return token, token != ""
+}
@@ -0,0 +1,135 @@
+package jwtauth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cxuhua/handler"
+	"github.com/cxuhua/handler/jwtauth"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/graphql-go/graphql"
+)
+
+func subjectSchema(t *testing.T) *graphql.Schema {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"subject": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if claims := jwtauth.Claims(p.Context); claims != nil {
+						return claims["sub"], nil
+					}
+					return nil, nil
+				},
+			},
+		}}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &schema
+}
+
+func query(t *testing.T, h http.Handler, token string) (int, map[string]interface{}) {
+	req, _ := http.NewRequest("GET", "/graphql?query={subject}", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	var body map[string]interface{}
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatalf("unexpected body %s", resp.Body)
+	}
+	return resp.Code, body
+}
+
+func TestAuthenticator_JWKS(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	fetches := 0
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	defer jwks.Close()
+	auth, err := jwtauth.New(jwtauth.Config{JWKSURL: jwks.URL, Issuer: "https://issuer", Methods: []string{"RS256"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := handler.New(&handler.Config{Schema: subjectSchema(t), ContextFn: auth.Context})
+	sign := func(kid string, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	valid := sign("k1", jwt.MapClaims{"sub": "luke", "iss": "https://issuer", "exp": time.Now().Add(time.Hour).Unix()})
+	status, body := query(t, h, valid)
+	if status != http.StatusOK || body["data"].(map[string]interface{})["subject"] != "luke" {
+		t.Fatalf("unexpected response %d %v", status, body)
+	}
+	for name, token := range map[string]string{
+		"missing":      "",
+		"expired":      sign("k1", jwt.MapClaims{"sub": "luke", "iss": "https://issuer", "exp": time.Now().Add(-time.Hour).Unix()}),
+		"other issuer": sign("k1", jwt.MapClaims{"sub": "luke", "iss": "https://other"}),
+		"unknown key":  sign("k2", jwt.MapClaims{"sub": "luke", "iss": "https://issuer"}),
+		"garbage":      "not.a.token",
+	} {
+		status, body := query(t, h, token)
+		errs, _ := body["errors"].([]interface{})
+		if status != http.StatusUnauthorized || len(errs) != 1 || body["data"] != nil {
+			t.Fatalf("%s: unexpected response %d %v", name, status, body)
+		}
+		code := errs[0].(map[string]interface{})["extensions"].(map[string]interface{})["code"]
+		if code != handler.CodeUnauthenticated {
+			t.Fatalf("%s: unexpected code %v", name, code)
+		}
+	}
+	if fetches != 1 {
+		t.Fatalf("expected the key set to be fetched once, got %d", fetches)
+	}
+}
+
+func TestAuthenticator_Optional(t *testing.T) {
+	secret := []byte("secret")
+	auth, err := jwtauth.New(jwtauth.Config{Keys: map[string]interface{}{"": secret}, Optional: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := handler.New(&handler.Config{Schema: subjectSchema(t), ContextFn: auth.Context})
+	status, body := query(t, h, "")
+	if status != http.StatusOK || body["data"].(map[string]interface{})["subject"] != nil {
+		t.Fatalf("unexpected response %d %v", status, body)
+	}
+	signed, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "leia"}).SignedString(secret)
+	status, body = query(t, h, signed)
+	if status != http.StatusOK || body["data"].(map[string]interface{})["subject"] != "leia" {
+		t.Fatalf("unexpected response %d %v", status, body)
+	}
+	forged, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "vader"}).SignedString([]byte("guess"))
+	if status, body = query(t, h, forged); status != http.StatusUnauthorized {
+		t.Fatalf("unexpected response %d %v", status, body)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := jwtauth.New(jwtauth.Config{}); err == nil {
+		t.Fatal("expected an error without keys")
+	}
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+)
+
+// Logger receives the events the handler would otherwise drop, *slog.Logger
+// implements it. The events carry their details as key value pairs, using
+// the keys "error", "status", "operation", "duration", "failed", "method",
+// "path" and "panic"
+type Logger interface {
+	DebugContext(ctx context.Context, msg string, args ...interface{})
+	InfoContext(ctx context.Context, msg string, args ...interface{})
+	WarnContext(ctx context.Context, msg string, args ...interface{})
+	ErrorContext(ctx context.Context, msg string, args ...interface{})
+}
+
+// nopLogger drops the events of handlers without a Logger
+type nopLogger struct{}
+
+func (nopLogger) DebugContext(context.Context, string, ...interface{}) {}
+func (nopLogger) InfoContext(context.Context, string, ...interface{})  {}
+func (nopLogger) WarnContext(context.Context, string, ...interface{})  {}
+func (nopLogger) ErrorContext(context.Context, string, ...interface{}) {}
+
+// requestAttrs returns the key value pairs describing r
+func requestAttrs(r *http.Request, args ...interface{}) []interface{} {
+	return append([]interface{}{"method", r.Method, "path", r.URL.Path}, args...)
+}
+
+// logPanic reports a panic of the request r and panics again, the server
+// still answers it as without a Logger
+func (h *Handler) logPanic(ctx context.Context, r *http.Request) {
+	if v := recover(); v != nil {
+		h.logger.ErrorContext(ctx, "graphql request panicked", requestAttrs(r, "panic", v)...)
+		panic(v)
+	}
+}
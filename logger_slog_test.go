@@ -0,0 +1,33 @@
+//go:build go1.21
+// +build go1.21
+
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_SlogLogger(t *testing.T) {
+	buff := &bytes.Buffer{}
+	h := handler.New(&handler.Config{
+		Schema: &testutil.StarWarsSchema,
+		Logger: slog.New(slog.NewJSONHandler(buff, nil)),
+	})
+	req, _ := http.NewRequest("POST", "/graphql", bytes.NewBufferString("{"))
+	req.Header.Set("Content-Type", "application/json")
+	executeTest(t, h, req)
+	var event map[string]interface{}
+	if err := json.Unmarshal(buff.Bytes(), &event); err != nil {
+		t.Fatalf("unexpected log %q", buff)
+	}
+	if event["level"] != "WARN" || event["msg"] != "graphql request rejected" || event["status"] != float64(400) {
+		t.Fatalf("unexpected event %v", event)
+	}
+}
@@ -0,0 +1,85 @@
+package handler_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+// recordingLogger keeps the events it receives as "level msg key=value..."
+type recordingLogger struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *recordingLogger) record(level, msg string, args []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	event := level + " " + msg
+	for i := 0; i+1 < len(args); i += 2 {
+		event += fmt.Sprintf(" %v=%v", args[i], args[i+1])
+	}
+	l.events = append(l.events, event)
+}
+
+func (l *recordingLogger) DebugContext(_ context.Context, msg string, args ...interface{}) {
+	l.record("DEBUG", msg, args)
+}
+func (l *recordingLogger) InfoContext(_ context.Context, msg string, args ...interface{}) {
+	l.record("INFO", msg, args)
+}
+func (l *recordingLogger) WarnContext(_ context.Context, msg string, args ...interface{}) {
+	l.record("WARN", msg, args)
+}
+func (l *recordingLogger) ErrorContext(_ context.Context, msg string, args ...interface{}) {
+	l.record("ERROR", msg, args)
+}
+
+func TestHandler_Logger(t *testing.T) {
+	logger := &recordingLogger{}
+	h := handler.New(&handler.Config{
+		Schema:             &testutil.StarWarsSchema,
+		Logger:             logger,
+		SlowQueryThreshold: time.Nanosecond,
+		ResultFn: func(ctx context.Context, params *graphql.Params, result *graphql.Result) *graphql.Result {
+			if strings.Contains(params.RequestString, "Panic") {
+				panic("result hook failed")
+			}
+			return nil
+		},
+	})
+	req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}&variables=[1]", nil)
+	executeTest(t, h, req)
+	req, _ = http.NewRequest("GET", "/graphql?query=query+Hero{hero{name}}&operationName=Hero", nil)
+	executeTest(t, h, req)
+	func() {
+		defer func() {
+			if v := recover(); v != "result hook failed" {
+				t.Fatalf("unexpected panic %v", v)
+			}
+		}()
+		req, _ = http.NewRequest("GET", "/graphql?query=query+Panic{hero{name}}", nil)
+		executeTest(t, h, req)
+	}()
+
+	if len(logger.events) != 3 {
+		t.Fatalf("unexpected events %q", logger.events)
+	}
+	for i, prefix := range []string{
+		"WARN graphql request rejected method=GET path=/graphql status=400 error=invalid variables",
+		"WARN slow graphql operation operation=Hero duration=",
+		"ERROR graphql request panicked method=GET path=/graphql panic=result hook failed",
+	} {
+		if !strings.HasPrefix(logger.events[i], prefix) {
+			t.Fatalf("unexpected event %q, expected %q", logger.events[i], prefix)
+		}
+	}
+}
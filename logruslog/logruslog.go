@@ -0,0 +1,67 @@
+// Package logruslog reports the events of the handler to a logrus logger,
+// see handler.Config.Logger
+package logruslog
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cxuhua/handler"
+	"github.com/sirupsen/logrus"
+)
+
+// Logger is the handler.Logger writing to a logrus logger
+type Logger struct {
+	logger *logrus.Logger
+}
+
+var _ handler.Logger = Logger{}
+
+// New returns the handler.Logger writing to l
+func New(l *logrus.Logger) Logger {
+	return Logger{logger: l}
+}
+
+func (l Logger) log(ctx context.Context, level logrus.Level, msg string, args []interface{}) {
+	if !l.logger.IsLevelEnabled(level) {
+		return
+	}
+	fields := logrus.Fields{}
+	for i := 0; i < len(args); i += 2 {
+		key, ok := args[i].(string)
+		if !ok || i+1 == len(args) {
+			// like slog, a value without a key is reported under !BADKEY
+			fields["!BADKEY"] = args[i]
+			i--
+			continue
+		}
+		value := args[i+1]
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		} else if _, ok := value.(fmt.Stringer); ok {
+			value = fmt.Sprint(value)
+		}
+		fields[key] = value
+	}
+	l.logger.WithContext(ctx).WithFields(fields).Log(level, msg)
+}
+
+// DebugContext implements handler.Logger
+func (l Logger) DebugContext(ctx context.Context, msg string, args ...interface{}) {
+	l.log(ctx, logrus.DebugLevel, msg, args)
+}
+
+// InfoContext implements handler.Logger
+func (l Logger) InfoContext(ctx context.Context, msg string, args ...interface{}) {
+	l.log(ctx, logrus.InfoLevel, msg, args)
+}
+
+// WarnContext implements handler.Logger
+func (l Logger) WarnContext(ctx context.Context, msg string, args ...interface{}) {
+	l.log(ctx, logrus.WarnLevel, msg, args)
+}
+
+// ErrorContext implements handler.Logger
+func (l Logger) ErrorContext(ctx context.Context, msg string, args ...interface{}) {
+	l.log(ctx, logrus.ErrorLevel, msg, args)
+}
@@ -0,0 +1,30 @@
+package logruslog_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/cxuhua/handler/logruslog"
+	"github.com/graphql-go/graphql/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestLogger(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	h := handler.New(&handler.Config{
+		Schema: &testutil.StarWarsSchema,
+		Logger: logruslog.New(logger),
+	})
+	req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}&variables=[1]", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	entry := hook.LastEntry()
+	if len(hook.AllEntries()) != 1 || entry.Level != logrus.WarnLevel || entry.Message != "graphql request rejected" {
+		t.Fatalf("unexpected entries %+v", hook.AllEntries())
+	}
+	if entry.Data["status"] != 400 || entry.Data["error"] != "invalid variables: json: cannot unmarshal array into Go value of type map[string]interface {}" {
+		t.Fatalf("unexpected fields %v", entry.Data)
+	}
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// ErrPostOnly rejects the operations a POST-only handler receives outside
+// of a POST body
+var ErrPostOnly = errors.New("operations must be sent in the body of a POST request")
+
+// allowedMethods is the Allow header of OPTIONS and 405 responses
+const allowedMethods = "GET, POST, HEAD, OPTIONS"
+
+// checkMethod answers OPTIONS requests, CORS preflights included when
+// Config.CORS is set, and requests of unsupported methods. It returns the
+// writer serving the request and whether it was answered. HEAD requests
+// are served like GET with the body discarded
+func (h *Handler) checkMethod(ctx context.Context, w http.ResponseWriter, r *http.Request) (http.ResponseWriter, bool) {
+	if h.cors != nil && h.cors.setHeaders(w, r) {
+		w.WriteHeader(http.StatusNoContent)
+		return w, true
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodPost:
+		return w, false
+	case http.MethodHead:
+		return headResponseWriter{w}, false
+	case http.MethodOptions:
+		// without Config.CORS, middlewares in front of the handler answer
+		// preflights first
+		w.Header().Set("Allow", allowedMethods)
+		w.WriteHeader(http.StatusNoContent)
+		return w, true
+	}
+	w.Header().Set("Allow", allowedMethods)
+	h.writeResult(ctx, w, r, http.StatusMethodNotAllowed, &graphql.Result{
+		Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(fmt.Errorf("method %s is not allowed", r.Method))},
+	})
+	return w, true
+}
+
+// headResponseWriter discards the body of HEAD responses
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// checkPostOnly answers the requests a POST-only handler does not execute:
+// other methods get the IDE or 405 Method Not Allowed, POST requests with
+// operations in the query string 400 Bad Request
+func (h *Handler) checkPostOnly(ctx context.Context, w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		if h.isIDERequest(r) {
+			renderGraphiQL(w, r, h)
+			return true
+		}
+		w.Header().Set("Allow", "POST, OPTIONS")
+		h.writeResult(ctx, w, r, http.StatusMethodNotAllowed, &graphql.Result{
+			Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(ErrPostOnly)},
+		})
+		return true
+	}
+	if parseQueryParams(r.URL.RawQuery) != (queryParams{}) {
+		h.writeResult(ctx, w, r, http.StatusBadRequest, &graphql.Result{
+			Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(ErrPostOnly)},
+		})
+		return true
+	}
+	return false
+}
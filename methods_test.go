@@ -0,0 +1,97 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_Methods(t *testing.T) {
+	h := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema})
+
+	req, _ := http.NewRequest(http.MethodOptions, "/graphql", nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Code != http.StatusNoContent || resp.Header().Get("Allow") != "GET, POST, HEAD, OPTIONS" || resp.Body.Len() != 0 {
+		t.Fatalf("unexpected OPTIONS response %d %v %q", resp.Code, resp.Header(), resp.Body)
+	}
+
+	req, _ = http.NewRequest(http.MethodHead, "/graphql?query={hero{name}}", nil)
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK || resp.Header().Get("Content-Type") != "application/json; charset=utf-8" || resp.Body.Len() != 0 {
+		t.Fatalf("unexpected HEAD response %d %v %q", resp.Code, resp.Header(), resp.Body)
+	}
+
+	req, _ = http.NewRequest(http.MethodPut, "/graphql?query={hero{name}}", nil)
+	result, resp := executeTest(t, h, req)
+	if resp.Code != http.StatusMethodNotAllowed || resp.Header().Get("Allow") == "" || len(result.Errors) != 1 || result.Data != nil {
+		t.Fatalf("unexpected PUT response %d %+v", resp.Code, result)
+	}
+}
+
+func TestHandler_PostOnly(t *testing.T) {
+	h := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema, PostOnly: true, PrettyParam: true})
+
+	req, _ := http.NewRequest(http.MethodGet, "/graphql?query={hero{name}}", nil)
+	result, resp := executeTest(t, h, req)
+	if resp.Code != http.StatusMethodNotAllowed || resp.Header().Get("Allow") != "POST, OPTIONS" || len(result.Errors) != 1 {
+		t.Fatalf("unexpected GET response %d %+v", resp.Code, result)
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, "/graphql?variables={}", strings.NewReader(`{"query":"{hero{name}}"}`))
+	req.Header.Set("Content-Type", "application/json")
+	result, resp = executeTest(t, h, req)
+	if resp.Code != http.StatusBadRequest || len(result.Errors) != 1 || result.Data != nil {
+		t.Fatalf("unexpected POST response %d %+v", resp.Code, result)
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, "/graphql?pretty=0", strings.NewReader(`{"query":"{hero{name}}"}`))
+	req.Header.Set("Content-Type", "application/json")
+	result, resp = executeTest(t, h, req)
+	if resp.Code != http.StatusOK || result.HasErrors() {
+		t.Fatalf("unexpected POST response %d %+v", resp.Code, result)
+	}
+}
+
+func TestHandler_CORS(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema: &testutil.StarWarsSchema,
+		CORS: &handler.CORSConfig{
+			AllowedOrigins:   []string{"https://app.example.com"},
+			AllowCredentials: true,
+			MaxAge:           time.Hour,
+		},
+	})
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodOptions, "/graphql", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		req.Header.Set("Access-Control-Request-Headers", "content-type, authorization")
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+	resp := preflight("https://app.example.com")
+	header := resp.Header()
+	if resp.Code != http.StatusNoContent || header.Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		header.Get("Access-Control-Allow-Headers") != "content-type, authorization" ||
+		header.Get("Access-Control-Allow-Credentials") != "true" || header.Get("Access-Control-Max-Age") != "3600" {
+		t.Fatalf("unexpected preflight response %d %v", resp.Code, header)
+	}
+	if resp := preflight("https://evil.example.com"); resp.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("unexpected preflight response %d %v", resp.Code, resp.Header())
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/graphql?query={hero{name}}", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	result, resp := executeTest(t, h, req)
+	if resp.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" || result.HasErrors() {
+		t.Fatalf("unexpected response %d %v %+v", resp.Code, resp.Header(), result)
+	}
+}
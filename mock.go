@@ -0,0 +1,270 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// DefaultMockListLength is the number of items of mocked lists
+const DefaultMockListLength = 2
+
+// MockField is the field a MockFn generates the value of
+type MockField struct {
+	// Type is the name of the object type holding the field
+	Type string
+	Name string
+	Path []interface{}
+	// Seq counts the values generated for the operation from 1, the same
+	// operation always gets the same sequence
+	Seq int
+}
+
+// MockFn generates the value of a scalar field
+type MockFn func(ctx context.Context, field MockField) interface{}
+
+// MockExecutor is an Executor serving data derived from the types of the
+// schema in place of calling the resolvers, e.g. to develop clients before
+// the resolvers exist. Operations selecting only introspection fields are
+// executed
+type MockExecutor struct {
+	// Scalars generates the values of the scalars they are keyed by the
+	// name of. The built-in scalars default to placeholders derived from
+	// the field and the sequence, other scalars to strings
+	Scalars map[string]MockFn
+	// ListLength is the number of items of lists, DefaultMockListLength
+	// when zero
+	ListLength int
+}
+
+// Do implements Executor
+func (m *MockExecutor) Do(ctx context.Context, params graphql.Params) *graphql.Result {
+	if isIntrospection(&RequestOptions{Query: params.RequestString, OperationName: params.OperationName}) {
+		return graphql.Do(params)
+	}
+	doc, operation, root, errs := validOperation(&params.Schema, &RequestOptions{Query: params.RequestString, OperationName: params.OperationName})
+	if errs != nil {
+		return &graphql.Result{Errors: errs}
+	}
+	g := &mockGenerator{
+		ctx:       ctx,
+		mock:      m,
+		schema:    &params.Schema,
+		variables: params.VariableValues,
+		fragments: map[string]*ast.FragmentDefinition{},
+	}
+	for _, def := range doc.Definitions {
+		if def, ok := def.(*ast.FragmentDefinition); ok && def.Name != nil {
+			g.fragments[def.Name.Value] = def
+		}
+	}
+	data := g.object(root, []*ast.SelectionSet{operation.SelectionSet}, nil)
+	return &graphql.Result{Data: data, Errors: g.errors}
+}
+
+// mockGenerator completes the values of one operation
+type mockGenerator struct {
+	ctx       context.Context
+	mock      *MockExecutor
+	schema    *graphql.Schema
+	variables map[string]interface{}
+	fragments map[string]*ast.FragmentDefinition
+	seq       int
+	errors    []gqlerrors.FormattedError
+}
+
+// object completes the selections of sets on t, fields with the same
+// response key are merged like the executor does
+func (g *mockGenerator) object(t *graphql.Object, sets []*ast.SelectionSet, path []interface{}) map[string]interface{} {
+	var keys []string
+	fields := map[string][]*ast.Field{}
+	for _, set := range sets {
+		g.collect(t, set, fields, &keys, map[string]bool{})
+	}
+	data := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		selections := fields[key]
+		name := selections[0].Name.Value
+		fieldPath := append(append([]interface{}(nil), path...), key)
+		var def *graphql.FieldDefinition
+		switch name {
+		case "__typename":
+			data[key] = t.Name()
+			continue
+		case "__schema", "__type":
+			g.errors = append(g.errors, gqlerrors.FormattedError{
+				Message: fmt.Sprintf("%s is not mocked, select it in an operation of its own", name),
+				Path:    fieldPath,
+			})
+			data[key] = nil
+			continue
+		default:
+			def = t.Fields()[name]
+		}
+		if def == nil {
+			data[key] = nil
+			continue
+		}
+		var nested []*ast.SelectionSet
+		for _, selection := range selections {
+			if selection.SelectionSet != nil {
+				nested = append(nested, selection.SelectionSet)
+			}
+		}
+		data[key] = g.value(t, name, def.Type, nested, fieldPath, 0)
+	}
+	return data
+}
+
+// value completes a value of type typ for the field name of parent, index
+// picks the object type of abstract list items
+func (g *mockGenerator) value(parent *graphql.Object, name string, typ graphql.Type, sets []*ast.SelectionSet, path []interface{}, index int) interface{} {
+	switch typ := typ.(type) {
+	case *graphql.NonNull:
+		return g.value(parent, name, typ.OfType, sets, path, index)
+	case *graphql.List:
+		n := g.mock.ListLength
+		if n <= 0 {
+			n = DefaultMockListLength
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			items[i] = g.value(parent, name, typ.OfType, sets, append(append([]interface{}(nil), path...), i), i)
+		}
+		return items
+	case *graphql.Object:
+		return g.object(typ, sets, path)
+	case *graphql.Interface, *graphql.Union:
+		// the implementations of interfaces are listed in map order
+		possible := append([]*graphql.Object(nil), g.schema.PossibleTypes(typ.(graphql.Abstract))...)
+		if len(possible) == 0 {
+			return nil
+		}
+		sort.Slice(possible, func(i, j int) bool { return possible[i].Name() < possible[j].Name() })
+		return g.object(possible[index%len(possible)], sets, path)
+	case *graphql.Enum:
+		// the values are listed in map order
+		var names []string
+		for _, value := range typ.Values() {
+			names = append(names, value.Name)
+		}
+		if len(names) == 0 {
+			return nil
+		}
+		sort.Strings(names)
+		g.seq++
+		return names[(g.seq-1)%len(names)]
+	case *graphql.Scalar:
+		g.seq++
+		field := MockField{Type: parent.Name(), Name: name, Path: path, Seq: g.seq}
+		if fn, ok := g.mock.Scalars[typ.Name()]; ok {
+			return fn(g.ctx, field)
+		}
+		return mockScalar(typ, field)
+	}
+	return nil
+}
+
+// mockScalar is the placeholder of the built-in scalars
+func mockScalar(typ *graphql.Scalar, field MockField) interface{} {
+	switch typ {
+	case graphql.Int:
+		return field.Seq
+	case graphql.Float:
+		return float64(field.Seq) + 0.5
+	case graphql.Boolean:
+		return field.Seq%2 == 1
+	case graphql.ID:
+		return strconv.Itoa(field.Seq)
+	}
+	return fmt.Sprintf("%s %d", field.Name, field.Seq)
+}
+
+// collect gathers the fields of set applying to t by response key, keys
+// keeps their order
+func (g *mockGenerator) collect(t *graphql.Object, set *ast.SelectionSet, fields map[string][]*ast.Field, keys *[]string, visited map[string]bool) {
+	if set == nil {
+		return
+	}
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if !g.included(selection.Directives) {
+				continue
+			}
+			key := selection.Name.Value
+			if selection.Alias != nil {
+				key = selection.Alias.Value
+			}
+			if _, ok := fields[key]; !ok {
+				*keys = append(*keys, key)
+			}
+			fields[key] = append(fields[key], selection)
+		case *ast.InlineFragment:
+			if g.included(selection.Directives) && g.applies(t, selection.TypeCondition) {
+				g.collect(t, selection.SelectionSet, fields, keys, visited)
+			}
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment := g.fragments[name]
+			if fragment == nil || visited[name] || !g.included(selection.Directives) || !g.applies(t, fragment.TypeCondition) {
+				continue
+			}
+			visited[name] = true
+			g.collect(t, fragment.SelectionSet, fields, keys, visited)
+		}
+	}
+}
+
+// applies reports whether a fragment on condition applies to t
+func (g *mockGenerator) applies(t *graphql.Object, condition *ast.Named) bool {
+	if condition == nil || condition.Name == nil || condition.Name.Value == t.Name() {
+		return true
+	}
+	var abstract graphql.Abstract
+	switch t := g.schema.Type(condition.Name.Value).(type) {
+	case *graphql.Interface:
+		abstract = t
+	case *graphql.Union:
+		abstract = t
+	default:
+		return false
+	}
+	for _, possible := range g.schema.PossibleTypes(abstract) {
+		if possible == t {
+			return true
+		}
+	}
+	return false
+}
+
+// included evaluates the @skip and @include directives
+func (g *mockGenerator) included(directives []*ast.Directive) bool {
+	for _, directive := range directives {
+		name := directive.Name.Value
+		if name != "skip" && name != "include" {
+			continue
+		}
+		for _, arg := range directive.Arguments {
+			if arg.Name.Value != "if" {
+				continue
+			}
+			var value bool
+			switch v := arg.Value.(type) {
+			case *ast.BooleanValue:
+				value = v.Value
+			case *ast.Variable:
+				value, _ = g.variables[v.Name.Value].(bool)
+			}
+			if value == (name == "skip") {
+				return false
+			}
+		}
+	}
+	return true
+}
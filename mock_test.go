@@ -0,0 +1,94 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestMockExecutor(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema: &testutil.StarWarsSchema,
+		Executor: &handler.MockExecutor{
+			Scalars: map[string]handler.MockFn{
+				"String": func(ctx context.Context, field handler.MockField) interface{} {
+					if field.Name == "name" {
+						return []string{"Ada", "Grace", "Linus"}[field.Seq%3]
+					}
+					return field.Type + "." + field.Name
+				},
+			},
+		},
+	})
+	query := `query Hero($withFriends: Boolean!) {
+		hero { __typename id name friends @include(if: $withFriends) { name ... on Human { homePlanet } } }
+		hero { appearsIn }
+		droid(id: "2001") { primaryFunction }
+	}`
+	req, _ := http.NewRequest("GET", "/graphql?"+url.Values{
+		"query":     {query},
+		"variables": {`{"withFriends": true}`},
+	}.Encode(), nil)
+	result, _ := executeTest(t, h, req)
+	expected := map[string]interface{}{
+		"hero": map[string]interface{}{
+			"__typename": "Droid",
+			"id":         "Droid.id",
+			"name":       "Linus",
+			"friends": []interface{}{
+				// the items alternate between the implementations
+				map[string]interface{}{"name": "Ada"},
+				map[string]interface{}{"name": "Grace", "homePlanet": "Human.homePlanet"},
+			},
+			"appearsIn": []interface{}{"NEWHOPE", "EMPIRE"},
+		},
+		"droid": map[string]interface{}{"primaryFunction": "Droid.primaryFunction"},
+	}
+	if !reflect.DeepEqual(result.Data, expected) || len(result.Errors) > 0 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	// introspection reports the actual schema
+	req, _ = http.NewRequest("POST", "/graphql", strings.NewReader(`{ __type(name: "Droid") { name } }`))
+	req.Header.Set("Content-Type", "application/graphql")
+	result, _ = executeTest(t, h, req)
+	if !reflect.DeepEqual(result.Data, map[string]interface{}{"__type": map[string]interface{}{"name": "Droid"}}) {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestMockExecutor_Defaults(t *testing.T) {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"count": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"ratio": &graphql.Field{Type: graphql.Float},
+			"ready": &graphql.Field{Type: graphql.Boolean},
+			"tags":  &graphql.Field{Type: graphql.NewList(graphql.String)},
+		}}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := handler.New(&handler.Config{
+		Schema:   &schema,
+		Executor: &handler.MockExecutor{ListLength: 3},
+	})
+	req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape("{count ratio ready tags}"), nil)
+	result, _ := executeTest(t, h, req)
+	expected := map[string]interface{}{
+		"count": float64(1),
+		"ratio": 2.5,
+		"ready": true,
+		"tags":  []interface{}{"tags 4", "tags 5", "tags 6"},
+	}
+	if !reflect.DeepEqual(result.Data, expected) {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
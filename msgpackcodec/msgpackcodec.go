@@ -0,0 +1,31 @@
+// Package msgpackcodec encodes GraphQL requests and responses as
+// MessagePack (application/msgpack), see handler.Config.Codecs
+package msgpackcodec
+
+import "github.com/vmihailenco/msgpack/v5"
+
+// ContentType is the media type of the codec
+const ContentType = "application/msgpack"
+
+// Codec is the MessagePack handler.Codec
+type Codec struct{}
+
+// New returns the MessagePack codec
+func New() Codec {
+	return Codec{}
+}
+
+// ContentType implements handler.Codec
+func (Codec) ContentType() string {
+	return ContentType
+}
+
+// Marshal implements handler.Codec
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+// Unmarshal implements handler.Codec
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
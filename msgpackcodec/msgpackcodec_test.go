@@ -0,0 +1,43 @@
+package msgpackcodec_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/cxuhua/handler/msgpackcodec"
+	"github.com/graphql-go/graphql/testutil"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestCodec(t *testing.T) {
+	h := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema, Codecs: []handler.Codec{msgpackcodec.New()}})
+	body, _ := msgpack.Marshal(map[string]interface{}{
+		"query":     "query($id: String!) { human(id: $id) { name } }",
+		"variables": map[string]interface{}{"id": "1000"},
+	})
+	req, _ := http.NewRequest("POST", "/graphql", bytes.NewReader(body))
+	req.Header.Set("Content-Type", msgpackcodec.ContentType)
+	req.Header.Set("Accept", msgpackcodec.ContentType)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Header().Get("Content-Type") != msgpackcodec.ContentType {
+		t.Fatalf("unexpected content type %q", resp.Header().Get("Content-Type"))
+	}
+	var result struct {
+		Data struct {
+			Human struct {
+				Name string `msgpack:"name"`
+			} `msgpack:"human"`
+		} `msgpack:"data"`
+		Errors []interface{} `msgpack:"errors"`
+	}
+	if err := msgpack.Unmarshal(resp.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Data.Human.Name != "Luke Skywalker" || len(result.Errors) != 0 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
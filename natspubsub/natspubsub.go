@@ -0,0 +1,179 @@
+// Package natspubsub fans the events of handler.PubSub out through NATS
+// subjects, so the subscribers of every replica receive them
+package natspubsub
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cxuhua/handler"
+	"github.com/nats-io/nats.go"
+)
+
+// DefaultSubjectPrefix prefixes the subjects topics are mapped to by default
+const DefaultSubjectPrefix = "graphql.events."
+
+// PubSub is a handler.PubSub publishing to NATS subjects
+type PubSub struct {
+	Conn *nats.Conn
+	// Subject maps topics to subjects, defaults to DefaultSubjectPrefix
+	// followed by the topic. Topics then have to be valid subject tokens
+	Subject func(topic string) string
+	// Buffer is the number of events a subscriber holds before newer events
+	// are dropped, defaults to handler.DefaultPubSubBuffer
+	Buffer int
+
+	mu     sync.Mutex
+	closed bool
+	subs   map[*subscriber]struct{}
+}
+
+var _ handler.PubSub = (*PubSub)(nil)
+
+// New returns a PubSub on conn
+func New(conn *nats.Conn) *PubSub {
+	return &PubSub{Conn: conn}
+}
+
+// Connect connects to url, reconnecting forever unless opts say otherwise,
+// and returns a PubSub on the connection. The subscriptions are restored
+// after reconnecting, events published meanwhile are lost
+func Connect(url string, opts ...nats.Option) (*PubSub, error) {
+	defaults := []nats.Option{
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(time.Second),
+		nats.RetryOnFailedConnect(true),
+	}
+	conn, err := nats.Connect(url, append(defaults, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	return New(conn), nil
+}
+
+func (ps *PubSub) subject(topic string) string {
+	if ps.Subject != nil {
+		return ps.Subject(topic)
+	}
+	return DefaultSubjectPrefix + topic
+}
+
+// Publish implements handler.PubSub, events published while reconnecting
+// are buffered by the connection
+func (ps *PubSub) Publish(_ context.Context, topic string, payload []byte) error {
+	if ps.isClosed() {
+		return handler.ErrPubSubClosed
+	}
+	return ps.Conn.Publish(ps.subject(topic), payload)
+}
+
+type subscriber struct {
+	mu     sync.Mutex
+	sub    *nats.Subscription
+	events chan []byte
+	// done stops watching the context of the subscription
+	done   chan struct{}
+	closed bool
+}
+
+func (s *subscriber) deliver(msg *nats.Msg) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.events <- msg.Data:
+	default:
+	}
+}
+
+func (s *subscriber) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	_ = s.sub.Unsubscribe()
+	close(s.events)
+	close(s.done)
+}
+
+// Subscribe implements handler.PubSub, it returns once the server
+// registered the subscription unless the connection is reconnecting
+func (ps *PubSub) Subscribe(ctx context.Context, topic string) (<-chan []byte, error) {
+	buffer := ps.Buffer
+	if buffer <= 0 {
+		buffer = handler.DefaultPubSubBuffer
+	}
+	s := &subscriber{events: make(chan []byte, buffer), done: make(chan struct{})}
+	ps.mu.Lock()
+	if ps.closed {
+		ps.mu.Unlock()
+		return nil, handler.ErrPubSubClosed
+	}
+	sub, err := ps.Conn.Subscribe(ps.subject(topic), s.deliver)
+	if err != nil {
+		ps.mu.Unlock()
+		return nil, err
+	}
+	s.sub = sub
+	if ps.subs == nil {
+		ps.subs = map[*subscriber]struct{}{}
+	}
+	ps.subs[s] = struct{}{}
+	ps.mu.Unlock()
+	if err := ps.flush(ctx); err != nil && !ps.Conn.IsReconnecting() {
+		ps.unsubscribe(s)
+		return nil, err
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			ps.unsubscribe(s)
+		case <-s.done:
+		}
+	}()
+	return s.events, nil
+}
+
+// flush waits for the server to process the subscriptions, the connection
+// timeout applies when ctx has no deadline
+func (ps *PubSub) flush(ctx context.Context) error {
+	if _, ok := ctx.Deadline(); ok {
+		return ps.Conn.FlushWithContext(ctx)
+	}
+	return ps.Conn.Flush()
+}
+
+func (ps *PubSub) unsubscribe(s *subscriber) {
+	ps.mu.Lock()
+	delete(ps.subs, s)
+	ps.mu.Unlock()
+	s.close()
+}
+
+func (ps *PubSub) isClosed() bool {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return ps.closed
+}
+
+// Close ends the subscriptions and drains the connection
+func (ps *PubSub) Close() error {
+	ps.mu.Lock()
+	if ps.closed {
+		ps.mu.Unlock()
+		return nil
+	}
+	ps.closed = true
+	subs := ps.subs
+	ps.subs = nil
+	ps.mu.Unlock()
+	for s := range subs {
+		s.close()
+	}
+	return ps.Conn.Drain()
+}
@@ -0,0 +1,97 @@
+package natspubsub_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cxuhua/handler"
+	"github.com/cxuhua/handler/natspubsub"
+	"github.com/nats-io/nats-server/v2/server"
+	natsserver "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+)
+
+func runServer(t *testing.T, port int) *server.Server {
+	opts := natsserver.DefaultTestOptions
+	opts.Port = port
+	s := natsserver.RunServer(&opts)
+	t.Cleanup(s.Shutdown)
+	return s
+}
+
+func receive(t *testing.T, events <-chan []byte, expected string) {
+	t.Helper()
+	select {
+	case event := <-events:
+		if string(event) != expected {
+			t.Fatalf("unexpected event %q", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected event %q", expected)
+	}
+}
+
+func TestPubSub(t *testing.T) {
+	s := runServer(t, -1)
+	a, err := natspubsub.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := natspubsub.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Subject = func(topic string) string { return "app." + topic }
+	a.Subject = b.Subject
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := b.Subscribe(ctx, "reviews")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Publish(context.Background(), "reviews", []byte("5 stars")); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, events, "5 stars")
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatal("unexpected event")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the subscription to end with its context")
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Publish(context.Background(), "reviews", nil); err != handler.ErrPubSubClosed {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestPubSub_Reconnect(t *testing.T) {
+	s := runServer(t, -1)
+	ps, err := natspubsub.Connect(s.ClientURL(), nats.ReconnectWait(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+	events, err := ps.Subscribe(context.Background(), "reviews")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := s.Addr().(*net.TCPAddr).Port
+	s.Shutdown()
+	s.WaitForShutdown()
+	runServer(t, port)
+	deadline := time.Now().Add(5 * time.Second)
+	for !ps.Conn.IsConnected() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := ps.Publish(context.Background(), "reviews", []byte("after reconnect")); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, events, "after reconnect")
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// OperationInfo describes the operation a request selects
+type OperationInfo struct {
+	// Name is empty for anonymous operations
+	Name string
+	// Type is "query", "mutation" or "subscription"
+	Type string
+	// Fields are the names of the root fields, including those of fragments
+	Fields []string
+}
+
+// AuthorizeOperationFn authorizes an operation before any of its resolvers
+// run, an error aborts the request with 403 Forbidden
+type AuthorizeOperationFn func(ctx context.Context, info OperationInfo) error
+
+// operationInfo returns the OperationInfo of the operation opts selects,
+// false when the document does not parse
+func operationInfo(opts *RequestOptions) (OperationInfo, bool) {
+	doc, operation := parseOperation(opts)
+	if operation == nil {
+		return OperationInfo{}, false
+	}
+	info := OperationInfo{Type: operation.Operation}
+	if operation.Name != nil {
+		info.Name = operation.Name.Value
+	}
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, def := range doc.Definitions {
+		if def, ok := def.(*ast.FragmentDefinition); ok && def.Name != nil {
+			fragments[def.Name.Value] = def
+		}
+	}
+	seen := map[string]bool{}
+	visited := map[string]bool{}
+	var collect func(set *ast.SelectionSet)
+	collect = func(set *ast.SelectionSet) {
+		if set == nil {
+			return
+		}
+		for _, selection := range set.Selections {
+			switch selection := selection.(type) {
+			case *ast.Field:
+				if name := selection.Name.Value; !seen[name] {
+					seen[name] = true
+					info.Fields = append(info.Fields, name)
+				}
+			case *ast.InlineFragment:
+				collect(selection.SelectionSet)
+			case *ast.FragmentSpread:
+				// cyclic spreads are left to the validation to report
+				if name := selection.Name.Value; !visited[name] && fragments[name] != nil {
+					visited[name] = true
+					collect(fragments[name].SelectionSet)
+				}
+			}
+		}
+	}
+	collect(operation.SelectionSet)
+	return info, true
+}
+
+// authorizeOperation answers the requests the AuthorizeOperationFn rejects,
+// it reports whether the request was answered
+func (h *Handler) authorizeOperation(ctx context.Context, w http.ResponseWriter, r *http.Request, opts *RequestOptions) bool {
+	info, ok := operationInfo(opts)
+	if !ok {
+		return false
+	}
+	err := h.authorizeFn(ctx, info)
+	if err == nil {
+		return false
+	}
+	if errorCode(err) == "" {
+		err = WithCode(err, CodeForbidden)
+	}
+	h.writeResult(ctx, w, r, http.StatusForbidden, &graphql.Result{
+		Errors: []gqlerrors.FormattedError{formatError(err)},
+	})
+	return true
+}
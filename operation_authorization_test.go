@@ -0,0 +1,58 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_AuthorizeOperationFn(t *testing.T) {
+	var infos []handler.OperationInfo
+	h := handler.New(&handler.Config{
+		Schema: &testutil.StarWarsSchema,
+		AuthorizeOperationFn: func(ctx context.Context, info handler.OperationInfo) error {
+			infos = append(infos, info)
+			for _, field := range info.Fields {
+				if field == "human" {
+					return errors.New("humans are classified")
+				}
+			}
+			return nil
+		},
+	})
+	for _, test := range []struct {
+		query string
+		code  int
+		info  handler.OperationInfo
+	}{
+		{
+			"query Heroes { hero { name } other: hero { id } }",
+			http.StatusOK,
+			handler.OperationInfo{Name: "Heroes", Type: "query", Fields: []string{"hero"}},
+		},
+		{
+			"{ ...Root } fragment Root on Query { hero { name } ... on Query { human(id: \"1000\") { name } } }",
+			http.StatusForbidden,
+			handler.OperationInfo{Type: "query", Fields: []string{"hero", "human"}},
+		},
+	} {
+		infos = nil
+		req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape(test.query), nil)
+		result, resp := executeTest(t, h, req)
+		if resp.Code != test.code || (test.code != http.StatusOK) != result.HasErrors() {
+			t.Fatalf("%s: unexpected response %d %+v", test.query, resp.Code, result)
+		}
+		if test.code != http.StatusOK && (result.Data != nil || result.Errors[0].Extensions["code"] != handler.CodeForbidden) {
+			t.Fatalf("%s: unexpected result %+v", test.query, result)
+		}
+		if len(infos) != 1 || !reflect.DeepEqual(infos[0], test.info) {
+			t.Fatalf("%s: unexpected info %+v", test.query, infos)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// ErrOperationNotAllowed is returned for operations the operation name
+// lists block, the handler answers it with 403 Forbidden
+var ErrOperationNotAllowed = errors.New("operation not allowed")
+
+// ErrReadOnly is returned for the mutations, and optionally subscriptions,
+// of a read-only handler, the handler answers it with 403 Forbidden
+var ErrReadOnly = errors.New("the server is read-only")
+
+// operationFilter holds the operation names, exact or path.Match patterns,
+// requests may execute
+type operationFilter struct {
+	allowed, denied []string
+	rejectAnonymous bool
+	// readOnly blocks mutations, and subscriptions with blockSubscriptions
+	readOnly, blockSubscriptions bool
+}
+
+func (f operationFilter) enabled() bool {
+	return len(f.allowed) > 0 || len(f.denied) > 0 || f.rejectAnonymous || f.readOnly
+}
+
+// check rejects operation when its type is read-only or its name is denied
+// or not allowed, anonymous operations are not allowed by any allow list
+func (f operationFilter) check(operation *ast.OperationDefinition) error {
+	if f.readOnly && (operation.Operation == ast.OperationTypeMutation ||
+		(f.blockSubscriptions && operation.Operation == ast.OperationTypeSubscription)) {
+		return fmt.Errorf("%w, %s operations are rejected", ErrReadOnly, operation.Operation)
+	}
+	if operation.Name == nil || operation.Name.Value == "" {
+		if f.rejectAnonymous || len(f.allowed) > 0 {
+			return fmt.Errorf("%w: anonymous operations are rejected", ErrOperationNotAllowed)
+		}
+		return nil
+	}
+	name := operation.Name.Value
+	if matchOperation(f.denied, name) || (len(f.allowed) > 0 && !matchOperation(f.allowed, name)) {
+		return fmt.Errorf("%w: %s", ErrOperationNotAllowed, name)
+	}
+	return nil
+}
+
+func matchOperation(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOperation returns the status and error rejecting the operation opts
+// selects, documents that do not parse are left to the execution to report
+func (h *Handler) checkOperation(opts *RequestOptions) (int, error) {
+	if !h.operationFilter.enabled() && !h.queryLimits.enabled() {
+		return 0, nil
+	}
+	doc, operation := parseOperation(opts)
+	if operation == nil {
+		return 0, nil
+	}
+	if err := h.operationFilter.check(operation); err != nil {
+		return http.StatusForbidden, err
+	}
+	if err := h.queryLimits.check(doc, operation); err != nil {
+		return err.(*QueryLimitError).status(), err
+	}
+	return 0, nil
+}
@@ -0,0 +1,73 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_OperationNames(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:                    &testutil.StarWarsSchema,
+		AllowedOperations:         []string{"Hero*", "Droid"},
+		DeniedOperations:          []string{"HeroInternal"},
+		RejectAnonymousOperations: true,
+	})
+	for _, test := range []struct {
+		query, operationName string
+		code                 int
+	}{
+		{"query HeroName { hero { name } }", "", http.StatusOK},
+		{"query Droid { hero { id } }", "", http.StatusOK},
+		{"query HeroInternal { hero { id } }", "", http.StatusForbidden},
+		{"query Human { human(id: \"1000\") { name } }", "", http.StatusForbidden},
+		{"{ hero { name } }", "", http.StatusForbidden},
+		{"query A { hero { id } } query HeroB { hero { id } }", "HeroB", http.StatusOK},
+		{"query A { hero { id } } query HeroB { hero { id } }", "A", http.StatusForbidden},
+	} {
+		req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape(test.query)+"&operationName="+test.operationName, nil)
+		result, resp := executeTest(t, h, req)
+		if resp.Code != test.code || (test.code != http.StatusOK) != result.HasErrors() {
+			t.Fatalf("%s: unexpected response %d %+v", test.query, resp.Code, result)
+		}
+	}
+	// allow lists block anonymous operations on their own
+	h = handler.New(&handler.Config{
+		Schema:            &testutil.StarWarsSchema,
+		AllowedOperations: []string{"Hero*"},
+	})
+	req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape("{ hero { name } }"), nil)
+	if result, resp := executeTest(t, h, req); resp.Code != http.StatusForbidden {
+		t.Fatalf("anonymous operation: unexpected response %d %+v", resp.Code, result)
+	}
+	if _, err := handler.NewWithError(&handler.Config{
+		Schema:            &testutil.StarWarsSchema,
+		AllowedOperations: []string{"Hero["},
+	}); err == nil {
+		t.Fatal("expected an invalid pattern error")
+	}
+}
+
+func TestHandler_ReadOnly(t *testing.T) {
+	for _, subscriptions := range []bool{false, true} {
+		h := handler.NewHandler(&testutil.StarWarsSchema, handler.WithReadOnly(subscriptions))
+		for query, blocked := range map[string]bool{
+			"{ hero { name } }":                false,
+			"mutation { hero { name } }":       true,
+			"subscription S { hero { name } }": subscriptions,
+		} {
+			req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape(query), nil)
+			result, resp := executeTest(t, h, req)
+			if (resp.Code == http.StatusForbidden) != blocked {
+				t.Fatalf("%s: unexpected response %d %+v", query, resp.Code, result)
+			}
+			if blocked && !strings.HasPrefix(result.Errors[0].Message, "the server is read-only") {
+				t.Fatalf("%s: unexpected error %v", query, result.Errors)
+			}
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// ErrUnknownOperation is returned for operations missing from the
+// OperationRegistry, the handler answers it with 400 Bad Request
+var ErrUnknownOperation = errors.New("unknown operation")
+
+// OperationRegistry keeps the operations parsed at startup. Requests
+// reference them by name, with an empty query, or by the sha256 hash of
+// their document in extensions.persistedQuery.sha256Hash
+type OperationRegistry struct {
+	// Strict rejects the documents that are not registered
+	Strict bool
+
+	mu     sync.RWMutex
+	byName map[string]*registeredOperation
+	byHash map[string]*registeredOperation
+}
+
+type registeredOperation struct {
+	name  string
+	query string
+	doc   *ast.Document
+	// validated holds the validation errors per schema version
+	validated sync.Map
+}
+
+// NewOperationRegistry returns an empty OperationRegistry
+func NewOperationRegistry(strict bool) *OperationRegistry {
+	return &OperationRegistry{
+		Strict: strict,
+		byName: map[string]*registeredOperation{},
+		byHash: map[string]*registeredOperation{},
+	}
+}
+
+// Register parses document and keeps it under name, it returns the hash
+// requests may reference it by
+func (reg *OperationRegistry) Register(name, document string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("operation without a name")
+	}
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(document),
+		Name: name,
+	})})
+	if err != nil {
+		return "", fmt.Errorf("operation %q: %v", name, err)
+	}
+	sum := sha256.Sum256([]byte(document))
+	hash := hex.EncodeToString(sum[:])
+	op := &registeredOperation{name: name, query: document, doc: doc}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if _, ok := reg.byName[name]; ok {
+		return "", fmt.Errorf("operation %q registered twice", name)
+	}
+	reg.byName[name] = op
+	reg.byHash[hash] = op
+	return hash, nil
+}
+
+// MustRegister is like Register but panics when document does not parse
+func (reg *OperationRegistry) MustRegister(name, document string) string {
+	hash, err := reg.Register(name, document)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+}
+
+// Validate validates the registered operations against schema, handlers
+// otherwise report invalid operations on their first request
+func (reg *OperationRegistry) Validate(schema *graphql.Schema) error {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	names := make([]string, 0, len(reg.byName))
+	for name := range reg.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if result := graphql.ValidateDocument(schema, reg.byName[name].doc, nil); !result.IsValid {
+			return fmt.Errorf("operation %q: %v", name, result.Errors[0].Message)
+		}
+	}
+	return nil
+}
+
+func (reg *OperationRegistry) lookup(hash string) *registeredOperation {
+	if reg == nil {
+		return nil
+	}
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return reg.byHash[hash]
+}
+
+// resolve fills the query of opts referencing a registered operation
+func (reg *OperationRegistry) resolve(opts *RequestOptions) error {
+	if opts.Query != "" {
+		sum := sha256.Sum256([]byte(opts.Query))
+		if reg.Strict && reg.lookup(hex.EncodeToString(sum[:])) == nil {
+			return ErrUnknownOperation
+		}
+		return nil
+	}
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	var op *registeredOperation
+	if hash := persistedQueryHash(opts); hash != "" {
+		if op = reg.byHash[hash]; op == nil {
+			return fmt.Errorf("%w %q", ErrUnknownOperation, hash)
+		}
+	} else if opts.OperationName != "" {
+		if op = reg.byName[opts.OperationName]; op == nil {
+			return fmt.Errorf("%w %q", ErrUnknownOperation, opts.OperationName)
+		}
+	} else {
+		return nil
+	}
+	opts.Query = op.query
+	if opts.OperationName == "" && definesOperation(op.doc, op.name) {
+		opts.OperationName = op.name
+	}
+	return nil
+}
+
+// validate returns the validation errors of op against the schema version scope identifies
+func (op *registeredOperation) validate(schema *graphql.Schema, scope string) []gqlerrors.FormattedError {
+	if errs, ok := op.validated.Load(scope); ok {
+		return errs.([]gqlerrors.FormattedError)
+	}
+	var errs []gqlerrors.FormattedError
+	if result := graphql.ValidateDocument(schema, op.doc, nil); !result.IsValid {
+		errs = result.Errors
+	}
+	op.validated.Store(scope, errs)
+	return errs
+}
+
+// persistedQueryHash returns the hash of extensions.persistedQuery
+func persistedQueryHash(opts *RequestOptions) string {
+	persisted, _ := opts.Extensions["persistedQuery"].(map[string]interface{})
+	hash, _ := persisted["sha256Hash"].(string)
+	return hash
+}
+
+// defines reports whether an operation named name is registered, under that
+// name or in the document of another
+func (reg *OperationRegistry) defines(name string) bool {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	if _, ok := reg.byName[name]; ok {
+		return true
+	}
+	for _, op := range reg.byName {
+		if definesOperation(op.doc, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func definesOperation(doc *ast.Document, name string) bool {
+	for _, def := range doc.Definitions {
+		if def, ok := def.(*ast.OperationDefinition); ok && def.Name != nil && def.Name.Value == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_Operations(t *testing.T) {
+	reg := handler.NewOperationRegistry(false)
+	hash := reg.MustRegister("HeroName", "query HeroName { hero { name } }")
+	if _, err := reg.Register("HeroName", "{ hero { id } }"); err == nil {
+		t.Fatal("expected an error registering HeroName twice")
+	}
+	h := handler.New(&handler.Config{
+		Schema:            &testutil.StarWarsSchema,
+		Operations:        reg,
+		DocumentCacheSize: 10,
+	})
+	expected := map[string]interface{}{"hero": map[string]interface{}{"name": "R2-D2"}}
+	for _, target := range []string{
+		"/graphql?operationName=HeroName",
+		"/graphql?extensions=" + url.QueryEscape(`{"persistedQuery":{"version":1,"sha256Hash":"`+hash+`"}}`),
+		"/graphql?query=" + url.QueryEscape("query HeroName { hero { name } }"),
+	} {
+		req, _ := http.NewRequest("GET", target, nil)
+		result, resp := executeTest(t, h, req)
+		if resp.Code != http.StatusOK || !reflect.DeepEqual(result.Data, expected) {
+			t.Fatalf("%s: unexpected response %d %+v", target, resp.Code, result)
+		}
+	}
+	// registered operations are not parsed again
+	if stats := h.DocumentCacheStats(); stats.Misses != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	req, _ := http.NewRequest("GET", "/graphql?operationName=Unknown", nil)
+	if result, resp := executeTest(t, h, req); resp.Code != http.StatusBadRequest || len(result.Errors) != 1 {
+		t.Fatalf("unexpected response %d %+v", resp.Code, result)
+	}
+	req, _ = http.NewRequest("GET", "/graphql?query="+url.QueryEscape("{ hero { id } }"), nil)
+	if result, resp := executeTest(t, h, req); resp.Code != http.StatusOK || result.HasErrors() {
+		t.Fatalf("unexpected response %d %+v", resp.Code, result)
+	}
+}
+
+func TestHandler_OperationsStrict(t *testing.T) {
+	reg := handler.NewOperationRegistry(true)
+	reg.MustRegister("HeroName", "query HeroName { hero { name } }")
+	h := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema, Operations: reg})
+	req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape("{ hero { id } }"), nil)
+	result, resp := executeTest(t, h, req)
+	if resp.Code != http.StatusBadRequest || len(result.Errors) != 1 || result.Errors[0].Message != "unknown operation" {
+		t.Fatalf("unexpected response %d %+v", resp.Code, result)
+	}
+	req, _ = http.NewRequest("GET", "/graphql?operationName=HeroName", nil)
+	if result, resp := executeTest(t, h, req); resp.Code != http.StatusOK || result.HasErrors() {
+		t.Fatalf("unexpected response %d %+v", resp.Code, result)
+	}
+}
+
+func TestHandler_OperationsInvalid(t *testing.T) {
+	reg := handler.NewOperationRegistry(false)
+	reg.MustRegister("Unknown", "query Unknown { unknown }")
+	_, err := handler.NewWithError(&handler.Config{Schema: &testutil.StarWarsSchema, Operations: reg})
+	if err == nil || !strings.Contains(err.Error(), `operation "Unknown"`) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := reg.Register("Broken", "query {"); err == nil {
+		t.Fatal("expected a parse error")
+	}
+}
@@ -0,0 +1,360 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+// Option configures a handler created with NewHandler
+type Option func(*Config)
+
+// NewHandler returns a handler for schema configured by opts, on top of the
+// NewConfig defaults
+func NewHandler(schema *graphql.Schema, opts ...Option) *Handler {
+	cfg := NewConfig()
+	cfg.Schema = schema
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return New(cfg)
+}
+
+// WithPretty sets whether responses are indented
+func WithPretty(pretty bool) Option {
+	return func(c *Config) { c.Pretty = pretty }
+}
+
+// WithPrettyIndent indents pretty responses with indent and, with param,
+// lets requests toggle Pretty with ?pretty=1 or ?pretty=0
+func WithPrettyIndent(indent string, param bool) Option {
+	return func(c *Config) {
+		c.PrettyIndent = indent
+		c.PrettyParam = param
+	}
+}
+
+// WithExampleTabs opens the IDE with tabs
+func WithExampleTabs(tabs ...ExampleTab) Option {
+	return func(c *Config) { c.ExampleTabs = tabs }
+}
+
+// WithTitle sets the IDE page title
+func WithTitle(title string) Option {
+	return func(c *Config) { c.Title = title }
+}
+
+// WithIDE selects the in-browser IDE, IDENone disables it
+func WithIDE(ide IDE) Option {
+	return func(c *Config) { c.IDE = ide }
+}
+
+// WithIDEPath serves the IDE on path only, see Config.IDEPath
+func WithIDEPath(path string) Option {
+	return func(c *Config) { c.IDEPath = path }
+}
+
+// WithEndpoint sets the API path the IDE sends operations to
+func WithEndpoint(endpoint string) Option {
+	return func(c *Config) { c.Endpoint = endpoint }
+}
+
+// WithSubscription sets the subscription endpoint IDEs connect to
+func WithSubscription(endpoint string) Option {
+	return func(c *Config) { c.Subscription = endpoint }
+}
+
+// WithAssets serves the IDE bundle from fs, see Config.Assets
+func WithAssets(fs http.FileSystem) Option {
+	return func(c *Config) { c.Assets = fs }
+}
+
+// WithEntryFn sets the function generating the root object of requests
+func WithEntryFn(fn EntryFn) Option {
+	return func(c *Config) { c.EntryFn = fn }
+}
+
+// WithParamsFn exposes the route parameters fn returns to resolvers
+func WithParamsFn(fn ParamsFn) Option {
+	return func(c *Config) { c.ParamsFn = fn }
+}
+
+// WithVariablesFn rewrites the variables of requests with fn before they execute
+func WithVariablesFn(fn VariablesFn) Option {
+	return func(c *Config) { c.VariablesFn = fn }
+}
+
+// WithResultFn sets the function post-processing results before they are written
+func WithResultFn(fn ResultFn) Option {
+	return func(c *Config) { c.ResultFn = fn }
+}
+
+// WithRootObjectFn sets the upstream compatible root object function
+func WithRootObjectFn(fn RootObjectFn) Option {
+	return func(c *Config) { c.RootObjectFn = fn }
+}
+
+// WithResultCallbackFn sets the function called with every written result
+func WithResultCallbackFn(fn ResultCallbackFn) Option {
+	return func(c *Config) { c.ResultCallbackFn = fn }
+}
+
+// WithFormatErrorFn sets the function formatting the errors of results
+func WithFormatErrorFn(fn FormatErrorFn) Option {
+	return func(c *Config) { c.FormatErrorFn = fn }
+}
+
+// WithExitFn sets the function called when a request completes
+func WithExitFn(fn ExitFn) Option {
+	return func(c *Config) { c.ExitFn = fn }
+}
+
+// WithFinishFn sets the function receiving the written response body
+func WithFinishFn(fn FinishFn) Option {
+	return func(c *Config) { c.FinishFn = fn }
+}
+
+// WithTimeout bounds the execution context of operations
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Config) { c.Timeout = timeout }
+}
+
+// WithCacheControl reports and applies cache hints, see CacheControlConfig
+func WithCacheControl(cfg *CacheControlConfig) Option {
+	return func(c *Config) { c.CacheControl = cfg }
+}
+
+// WithResponseCache serves repeated queries from a cache, see ResponseCacheConfig
+func WithResponseCache(cfg *ResponseCacheConfig) Option {
+	return func(c *Config) { c.ResponseCache = cfg }
+}
+
+// WithIncrementalDelivery enables @defer and @stream
+func WithIncrementalDelivery() Option {
+	return func(c *Config) { c.IncrementalDelivery = true }
+}
+
+// WithETags answers conditional GET queries, see Config.ETags
+func WithETags() Option {
+	return func(c *Config) { c.ETags = true }
+}
+
+// WithSchemaReport passes the AnalyzeSchema report of the schema to fn
+func WithSchemaReport(fn func(report *SchemaReport)) Option {
+	return func(c *Config) { c.SchemaReportFn = fn }
+}
+
+// WithShapeSampler infers response schemas with sampler, see ShapeSampler
+func WithShapeSampler(sampler *ShapeSampler) Option {
+	return func(c *Config) { c.ShapeSampler = sampler }
+}
+
+// WithMaskErrors hides the messages of resolver errors not marked safe,
+// fn may be nil
+func WithMaskErrors(fn MaskErrorFn) Option {
+	return func(c *Config) { c.MaskErrors, c.MaskErrorFn = true, fn }
+}
+
+// WithIntrospectionCache serves repeated introspection queries from memory
+func WithIntrospectionCache() Option {
+	return func(c *Config) { c.CacheIntrospection = true }
+}
+
+// WithDocumentCache keeps up to size parsed and validated documents
+func WithDocumentCache(size int) Option {
+	return func(c *Config) { c.DocumentCacheSize = size }
+}
+
+// WithResultMarshaler encodes the responses with m instead of json.Marshal
+func WithResultMarshaler(m ResultMarshaler) Option {
+	return func(c *Config) { c.ResultMarshaler = m }
+}
+
+// WithOperationNames sets the allowed and denied operation names or patterns
+func WithOperationNames(allowed, denied []string) Option {
+	return func(c *Config) { c.AllowedOperations, c.DeniedOperations = allowed, denied }
+}
+
+// WithReadOnly blocks mutations, and subscriptions when subscriptions is set
+func WithReadOnly(subscriptions bool) Option {
+	return func(c *Config) { c.ReadOnly, c.ReadOnlySubscriptions = true, subscriptions }
+}
+
+// WithQueryLimits bounds the aliases and root fields of operations
+func WithQueryLimits(maxAliases, maxRootFields int) Option {
+	return func(c *Config) { c.MaxAliases, c.MaxRootFields = maxAliases, maxRootFields }
+}
+
+// WithQuerySize bounds the bytes and the tokens of query strings
+func WithQuerySize(maxLength, maxTokens int) Option {
+	return func(c *Config) { c.MaxQueryLength, c.MaxTokens = maxLength, maxTokens }
+}
+
+// WithOperations executes the operations of reg without parsing them
+func WithOperations(reg *OperationRegistry) Option {
+	return func(c *Config) { c.Operations = reg }
+}
+
+// WithSlowQueries reports the operations taking longer than threshold to fn
+func WithSlowQueries(threshold time.Duration, fn SlowQueryFn) Option {
+	return func(c *Config) {
+		c.SlowQueryThreshold, c.SlowQueryFn = threshold, fn
+	}
+}
+
+// WithSLO tracks operation objectives with tracker, see SLOTracker
+func WithSLO(tracker *SLOTracker) Option {
+	return func(c *Config) { c.SLO = tracker }
+}
+
+// WithDegrader applies the degradation profiles of d, see Degrader
+func WithDegrader(d *Degrader) Option {
+	return func(c *Config) { c.Degrader = d }
+}
+
+// WithSDLPath serves the schema SDL on path, see Config.SDLPath
+func WithSDLPath(path string) Option {
+	return func(c *Config) { c.SDLPath = path }
+}
+
+// WithHealth answers liveness probes on healthPath and readiness probes on
+// readyPath, see Config.HealthPath and Config.ReadyPath
+func WithHealth(healthPath, readyPath string) Option {
+	return func(c *Config) { c.HealthPath, c.ReadyPath = healthPath, readyPath }
+}
+
+// WithWatchdog cancels and reports executions exceeding the ceiling of wd
+func WithWatchdog(wd *Watchdog) Option {
+	return func(c *Config) { c.Watchdog = wd }
+}
+
+// WithContextFn prepares the context of every request through fn
+func WithContextFn(fn ContextFn) Option {
+	return func(c *Config) { c.ContextFn = fn }
+}
+
+// WithAuthorizeOperationFn authorizes operations through fn before they
+// execute
+func WithAuthorizeOperationFn(fn AuthorizeOperationFn) Option {
+	return func(c *Config) { c.AuthorizeOperationFn = fn }
+}
+
+// WithHeadersFn sets the response headers of operations through fn
+func WithHeadersFn(fn HeadersFn) Option {
+	return func(c *Config) { c.HeadersFn = fn }
+}
+
+// WithRequestOptionsPool reuses the RequestOptions of finished requests
+func WithRequestOptionsPool() Option {
+	return func(c *Config) { c.PoolRequestOptions = true }
+}
+
+// WithPostOnly only executes the operations of POST bodies
+func WithPostOnly() Option {
+	return func(c *Config) { c.PostOnly = true }
+}
+
+// WithCORS answers the preflights of cross-origin requests, see CORSConfig
+func WithCORS(cfg *CORSConfig) Option {
+	return func(c *Config) { c.CORS = cfg }
+}
+
+// WithUploadCleanup calls fn once the temporary files of multipart requests
+// are removed, before FinishFn with beforeFinish
+func WithUploadCleanup(fn UploadCleanupFn, beforeFinish bool) Option {
+	return func(c *Config) {
+		c.OnUploadCleanup = fn
+		c.CleanupUploadsBeforeFinish = beforeFinish
+	}
+}
+
+// WithUploadProgress follows the upload of multipart request bodies with fn
+func WithUploadProgress(fn UploadProgressFn) Option {
+	return func(c *Config) { c.UploadProgressFn = fn }
+}
+
+// WithUploadStallTimeout aborts multipart uploads reading nothing for about
+// timeout
+func WithUploadStallTimeout(timeout time.Duration) Option {
+	return func(c *Config) { c.UploadStallTimeout = timeout }
+}
+
+// WithPubSub hands ps to resolvers through RequestPubSub
+func WithPubSub(ps PubSub) Option {
+	return func(c *Config) { c.PubSub = ps }
+}
+
+// WithCoalesce shares the execution of identical concurrent queries, see
+// CoalesceConfig
+func WithCoalesce(cfg *CoalesceConfig) Option {
+	return func(c *Config) { c.Coalesce = cfg }
+}
+
+// WithExplain answers the requests setting the ExplainHeader with the plan
+// of their operation
+func WithExplain() Option {
+	return func(c *Config) { c.Explain = true }
+}
+
+// WithExecutor runs the operations through executor
+func WithExecutor(executor Executor) Option {
+	return func(c *Config) { c.Executor = executor }
+}
+
+// WithAuthFn authorizes requests through fn before they execute
+func WithAuthFn(fn AuthFn) Option {
+	return func(c *Config) { c.AuthFn = fn }
+}
+
+// WithUploadLimits bounds the files of multipart requests
+func WithUploadLimits(l UploadLimits) Option {
+	return func(c *Config) {
+		c.MaxFileSize, c.MaxFiles, c.MaxFileFields = l.MaxFileSize, l.MaxFiles, l.MaxFileFields
+	}
+}
+
+// WithUploadStore saves uploaded files to store before operations execute
+func WithUploadStore(store UploadStore) Option {
+	return func(c *Config) { c.UploadStore = store }
+}
+
+// WithResumableUploads serves the tus endpoint of uploads, see
+// ResumableUploads
+func WithResumableUploads(uploads *ResumableUploads) Option {
+	return func(c *Config) { c.ResumableUploads = uploads }
+}
+
+// WithPolicies tunes the caching, the cost and the rate limits of
+// operations with the policies of f
+func WithPolicies(f *PolicyFile) Option {
+	return func(c *Config) { c.Policies = f }
+}
+
+// WithUploadProcessor processes the uploaded files of mediaType, e.g.
+// "image/*", with processor before operations execute
+func WithUploadProcessor(mediaType string, processor UploadProcessorFn) Option {
+	return func(c *Config) {
+		if c.UploadProcessors == nil {
+			c.UploadProcessors = map[string]UploadProcessorFn{}
+		}
+		c.UploadProcessors[mediaType] = processor
+	}
+}
+
+// WithRateLimiter admits requests through l before they execute
+func WithRateLimiter(l RateLimiter) Option {
+	return func(c *Config) { c.RateLimiter = l }
+}
+
+// WithWarmRestart hands the state of the handler over to the next process
+// through cfg.Store
+func WithWarmRestart(cfg *WarmRestartConfig) Option {
+	return func(c *Config) { c.WarmRestart = cfg }
+}
+
+// WithCompressor compresses the responses with compressor for the clients
+// accepting its encoding
+func WithCompressor(compressor Compressor) Option {
+	return func(c *Config) { c.Compressor = compressor }
+}
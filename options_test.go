@@ -0,0 +1,38 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+)
+
+func TestNewHandler_Options(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"hasDeadline": &graphql.Field{
+				Type: graphql.Boolean,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					_, ok := p.Context.Deadline()
+					return ok, nil
+				},
+			},
+		}}),
+	})
+	h := handler.NewHandler(&schema,
+		handler.WithPretty(false),
+		handler.WithIDE(handler.IDENone),
+		handler.WithTimeout(time.Second),
+	)
+	req, _ := http.NewRequest("GET", "/graphql?query={hasDeadline}", nil)
+	req.Header.Set("Accept", "text/html")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if body := strings.TrimSpace(resp.Body.String()); body != `{"data":{"hasDeadline":true}}` {
+		t.Fatalf("unexpected body %s", body)
+	}
+}
@@ -0,0 +1,9 @@
+module github.com/cxuhua/handler/playgroundassets
+
+go 1.18
+
+replace github.com/cxuhua/handler => ../
+
+require github.com/cxuhua/handler v0.0.0-00010101000000-000000000000
+
+require github.com/graphql-go/graphql v0.7.9 // indirect
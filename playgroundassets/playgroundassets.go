@@ -0,0 +1,91 @@
+// Package playgroundassets pins the graphql-playground-react release the
+// handler loads, for Config.Assets in air-gapped or CSP-restricted
+// deployments. The module ships no bundle: Fetch downloads it into a
+// directory, e.g. while building the image, and Dir serves it:
+//
+//	assets, err := playgroundassets.Dir("assets")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	h := handler.New(&handler.Config{
+//		Schema:   &schema,
+//		GraphiQL: true,
+//		Assets:   assets,
+//	})
+package playgroundassets
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/cxuhua/handler"
+)
+
+// Version is the pinned graphql-playground-react release
+const Version = handler.DefaultPlaygroundVersion
+
+// Files are the paths of the bundle the playground page loads, below
+// graphql-playground-react@Version
+var Files = []string{
+	"build/static/css/index.css",
+	"build/static/js/middleware.js",
+	"build/logo.png",
+}
+
+// pkg is the directory of the bundle, laid out as on the CDN
+const pkg = "graphql-playground-react@" + Version
+
+// Fetch downloads the bundle from the npm CDN at baseURL, e.g.
+// "https:"+handler.DefaultAssetBaseURL, into dir
+func Fetch(ctx context.Context, baseURL, dir string) error {
+	for _, file := range Files {
+		url := baseURL + "/" + path.Join(pkg, file)
+		if err := fetch(ctx, url, filepath.Join(dir, pkg, filepath.FromSlash(file))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fetch(ctx context.Context, url, name string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Dir returns the file system of the bundle Fetch downloaded into dir, an
+// error when a file of it is missing
+func Dir(dir string) (http.FileSystem, error) {
+	for _, file := range Files {
+		if _, err := os.Stat(filepath.Join(dir, pkg, filepath.FromSlash(file))); err != nil {
+			return nil, fmt.Errorf("playground bundle %s in %s is incomplete: %v", Version, dir, err)
+		}
+	}
+	return http.Dir(dir), nil
+}
@@ -0,0 +1,48 @@
+package playgroundassets_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cxuhua/handler/playgroundassets"
+)
+
+func TestFetch(t *testing.T) {
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	}))
+	defer cdn.Close()
+	dir := t.TempDir()
+	if _, err := playgroundassets.Dir(dir); err == nil {
+		t.Fatal("expected an empty directory to be rejected")
+	}
+	if err := playgroundassets.Fetch(context.Background(), cdn.URL, dir); err != nil {
+		t.Fatal(err)
+	}
+	fs, err := playgroundassets.Dir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := "/graphql-playground-react@" + playgroundassets.Version + "/" + playgroundassets.Files[0]
+	f, err := fs.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := ioutil.ReadAll(f)
+	f.Close()
+	if string(content) != name {
+		t.Fatalf("unexpected content %q", content)
+	}
+
+	if err := os.Remove(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := playgroundassets.Dir(dir); err == nil {
+		t.Fatal("expected an incomplete bundle to be rejected")
+	}
+}
@@ -0,0 +1,395 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// DefaultPolicyReloadInterval is how often a PolicyFile checks its file for
+// changes
+const DefaultPolicyReloadInterval = 10 * time.Second
+
+// Policies tune the response cache, the cost limit and the rate limits of
+// operations and fields, see PolicyFile
+type Policies struct {
+	// Operations are the policies of operations by name. Clients name
+	// their operations, unless they are registered Operations a client can
+	// pick the name of another operation
+	Operations map[string]OperationPolicy `json:"operations,omitempty"`
+	// Fields are the policies of "Type.field" coordinates
+	Fields map[string]FieldPolicy `json:"fields,omitempty"`
+	// Tiers are the rate limits operations are assigned to by name
+	Tiers map[string]RateTier `json:"tiers,omitempty"`
+	// MaxCost bounds the estimated cost of the operations without their
+	// own MaxCost, zero is unlimited
+	MaxCost int `json:"maxCost,omitempty"`
+}
+
+// OperationPolicy is the policy of an operation
+type OperationPolicy struct {
+	// CacheTTL replaces ResponseCacheConfig.TTL for the operation
+	CacheTTL Duration `json:"cacheTTL,omitempty"`
+	// MaxCost bounds the estimated cost of the operation
+	MaxCost int `json:"maxCost,omitempty"`
+	// Tier names the rate tier limiting the operation in place of the
+	// RateLimiter
+	Tier string `json:"tier,omitempty"`
+}
+
+// FieldPolicy is the policy of a field coordinate
+type FieldPolicy struct {
+	// Weight is the cost of the field, excluding its selections, in place
+	// of 1
+	Weight int `json:"weight,omitempty"`
+	// CacheTTL bounds the TTL of the cached responses selecting the field
+	CacheTTL Duration `json:"cacheTTL,omitempty"`
+}
+
+// RateTier is a token bucket limit per client, see IPRateLimiter
+type RateTier struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// Duration is a time.Duration encoded as a string, e.g. "30s"
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string, e.g. \"30s\": %v", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// PolicyFile loads the Policies of the handler from a JSON file, and
+// reloads them when the file changes. A file that fails to load leaves the
+// previous policies in effect. Once a handler uses it, a file naming fields
+// its schemas lack, or operations missing from its Operations, fails to load
+type PolicyFile struct {
+	// Path is the file of the policies
+	Path string
+	// Interval is how often Path is checked for changes,
+	// DefaultPolicyReloadInterval when zero
+	Interval time.Duration
+	// Decode parses the file, json.Unmarshal when nil. YAML files can be
+	// decoded with e.g. sigs.k8s.io/yaml.Unmarshal
+	Decode func(data []byte, policies *Policies) error
+	// KeyFn returns the client key of the rate tiers, defaults to the IP of
+	// r.RemoteAddr
+	KeyFn func(r *http.Request) string
+	// OnError is called with the errors of the reloads
+	OnError func(err error)
+
+	mu     sync.Mutex
+	loaded *policySet
+	// schemas and operations are what the loads check the policies
+	// against, set by the handler using the file
+	schemas    []*graphql.Schema
+	operations *OperationRegistry
+	checked    time.Time
+	modTime    time.Time
+	size       int64
+}
+
+// policySet is a loaded version of the Policies
+type policySet struct {
+	*Policies
+	tiers map[string]*IPRateLimiter
+}
+
+// NewPolicyFile loads the policies of path
+func NewPolicyFile(path string) (*PolicyFile, error) {
+	f := &PolicyFile{Path: path}
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Policies returns the policies in effect, nil before a first load
+func (f *PolicyFile) Policies() *Policies {
+	if set := f.current(); set != nil {
+		return set.Policies
+	}
+	return nil
+}
+
+// Reload loads the file whether it changed or not
+func (f *PolicyFile) Reload() error {
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.checked = time.Now()
+	return f.load(info)
+}
+
+// load replaces the policies with the content of the file info describes,
+// the buckets of the rate tiers that did not change are kept
+func (f *PolicyFile) load(info os.FileInfo) error {
+	data, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return err
+	}
+	policies := &Policies{}
+	if f.Decode != nil {
+		err = f.Decode(data, policies)
+	} else {
+		err = json.Unmarshal(data, policies)
+	}
+	if err != nil {
+		return fmt.Errorf("policies %s: %v", f.Path, err)
+	}
+	for name, op := range policies.Operations {
+		if _, ok := policies.Tiers[op.Tier]; op.Tier != "" && !ok {
+			return fmt.Errorf("policies %s: operation %s has an unknown tier %q", f.Path, name, op.Tier)
+		}
+	}
+	if err := f.diagnose(policies); err != nil {
+		return err
+	}
+	set := &policySet{Policies: policies, tiers: make(map[string]*IPRateLimiter, len(policies.Tiers))}
+	for name, tier := range policies.Tiers {
+		if f.loaded != nil {
+			if limiter := f.loaded.tiers[name]; limiter != nil && limiter.Rate == tier.Rate && limiter.Burst == tier.Burst {
+				set.tiers[name] = limiter
+				continue
+			}
+		}
+		set.tiers[name] = &IPRateLimiter{Rate: tier.Rate, Burst: tier.Burst, KeyFn: f.KeyFn}
+	}
+	f.loaded, f.modTime, f.size = set, info.ModTime(), info.Size()
+	return nil
+}
+
+// bind checks the loaded policies against the schemas and the registered
+// operations of a handler, later loads are checked alike
+func (f *PolicyFile) bind(schemas []*graphql.Schema, operations *OperationRegistry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.schemas, f.operations = schemas, operations
+	if f.loaded == nil {
+		return nil
+	}
+	return f.diagnose(f.loaded.Policies)
+}
+
+// diagnose reports the field coordinates of policies none of the schemas
+// has, and the operations the registry does not define
+func (f *PolicyFile) diagnose(policies *Policies) error {
+	var problems []string
+	if len(f.schemas) > 0 {
+		for _, coordinate := range sortedKeys(policies.Fields) {
+			if !hasCoordinate(f.schemas, coordinate) {
+				problems = append(problems, fmt.Sprintf("unknown field %s", coordinate))
+			}
+		}
+	}
+	if f.operations != nil {
+		for _, name := range sortedKeys(policies.Operations) {
+			if !f.operations.defines(name) {
+				problems = append(problems, fmt.Sprintf("unknown operation %s", name))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("policies %s: %s", f.Path, strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of the policies m maps in order
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]FieldPolicy:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]OperationPolicy:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// hasCoordinate reports whether one of schemas has the field at the
+// "Type.field" coordinate
+func hasCoordinate(schemas []*graphql.Schema, coordinate string) bool {
+	i := strings.LastIndex(coordinate, ".")
+	if i <= 0 {
+		return false
+	}
+	for _, schema := range schemas {
+		var fields graphql.FieldDefinitionMap
+		switch t := schema.Type(coordinate[:i]).(type) {
+		case *graphql.Object:
+			fields = t.Fields()
+		case *graphql.Interface:
+			fields = t.Fields()
+		}
+		if _, ok := fields[coordinate[i+1:]]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// limiters returns the limiters of the rate tiers in effect by name
+func (f *PolicyFile) limiters() map[string]*IPRateLimiter {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.loaded == nil {
+		return nil
+	}
+	return f.loaded.tiers
+}
+
+// current returns the policies in effect, the file is reloaded when it
+// changed since the last check
+func (f *PolicyFile) current() *policySet {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	interval := f.Interval
+	if interval <= 0 {
+		interval = DefaultPolicyReloadInterval
+	}
+	if f.loaded != nil && time.Since(f.checked) < interval {
+		defer f.mu.Unlock()
+		return f.loaded
+	}
+	f.checked = time.Now()
+	info, err := os.Stat(f.Path)
+	if err == nil && (f.loaded == nil || !info.ModTime().Equal(f.modTime) || info.Size() != f.size) {
+		err = f.load(info)
+	}
+	set := f.loaded
+	f.mu.Unlock()
+	if err != nil && f.OnError != nil {
+		f.OnError(err)
+	}
+	return set
+}
+
+// operation returns the policy of the operation of opts
+func (s *policySet) operation(opts *RequestOptions) (OperationPolicy, bool) {
+	if s == nil || len(s.Operations) == 0 {
+		return OperationPolicy{}, false
+	}
+	name := opts.OperationName
+	if name == "" {
+		if _, operation := parseOperation(opts); operation != nil && operation.Name != nil {
+			name = operation.Name.Value
+		}
+	}
+	policy, ok := s.Operations[name]
+	return policy, ok
+}
+
+// rateLimiter returns the limiter of the tier of the operation of opts,
+// nil when it has none
+func (s *policySet) rateLimiter(opts *RequestOptions) RateLimiter {
+	policy, ok := s.operation(opts)
+	if !ok || policy.Tier == "" {
+		return nil
+	}
+	return s.tiers[policy.Tier]
+}
+
+// policyEstimate is what the Policies decide for an operation
+type policyEstimate struct {
+	operationTTL, fieldTTL time.Duration
+}
+
+// cacheTTL returns the TTL of the cached responses of the operation, ttl
+// is the TTL of the response cache
+func (e policyEstimate) cacheTTL(ttl time.Duration) time.Duration {
+	if e.operationTTL > 0 {
+		ttl = e.operationTTL
+	}
+	if e.fieldTTL > 0 && (ttl == 0 || e.fieldTTL < ttl) {
+		ttl = e.fieldTTL
+	}
+	return ttl
+}
+
+// estimate weighs the fields of the operation of opts and checks its cost
+// against the MaxCost of the policies
+func (s *policySet) estimate(schema *graphql.Schema, opts *RequestOptions) (policyEstimate, error) {
+	var estimate policyEstimate
+	if s == nil {
+		return estimate, nil
+	}
+	policy, _ := s.operation(opts)
+	estimate.operationTTL = time.Duration(policy.CacheTTL)
+	maxCost := policy.MaxCost
+	if maxCost == 0 {
+		maxCost = s.MaxCost
+	}
+	if maxCost <= 0 && len(s.Fields) == 0 {
+		return estimate, nil
+	}
+	doc, operation := parseOperation(opts)
+	root := rootType(schema, operation)
+	if root == nil {
+		// the execution reports the document
+		return estimate, nil
+	}
+	e := newExplainer(schema, doc, opts.Variables, s.Policies)
+	cost := 0
+	for _, field := range e.fields(root, operation.SelectionSet, "") {
+		cost = saturatingAdd(cost, field.Cost)
+	}
+	estimate.fieldTTL = e.cacheTTL
+	// a truncated tree is a lower bound of the cost
+	if maxCost > 0 && (cost > maxCost || e.truncated) {
+		return estimate, &QueryLimitError{Limit: "maxCost", Max: maxCost, Value: cost}
+	}
+	return estimate, nil
+}
+
+// rootType returns the type operation selects its fields on, nil when
+// operation is or the schema has none
+func rootType(schema *graphql.Schema, operation *ast.OperationDefinition) *graphql.Object {
+	if operation == nil {
+		return nil
+	}
+	switch operation.Operation {
+	case ast.OperationTypeMutation:
+		return schema.MutationType()
+	case ast.OperationTypeSubscription:
+		return schema.SubscriptionType()
+	}
+	return schema.QueryType()
+}
@@ -0,0 +1,137 @@
+package handler_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+type ttlStore struct {
+	*handler.LRUCache
+	ttls map[string]time.Duration
+}
+
+func (s *ttlStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) {
+	s.ttls[key] = ttl
+	s.LRUCache.Set(ctx, key, value, ttl)
+}
+
+func TestHandler_Policies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.json")
+	write := func(content string) {
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{
+		"maxCost": 5,
+		"operations": {"Hero": {"tier": "strict", "cacheTTL": "1m"}},
+		"fields": {"Character.friends": {"weight": 10}, "Human.name": {"cacheTTL": "10s"}},
+		"tiers": {"strict": {"rate": 0.001, "burst": 1}}
+	}`)
+	policies, err := handler.NewPolicyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies.Interval = time.Nanosecond
+	store := &ttlStore{LRUCache: handler.NewLRUCache(10), ttls: map[string]time.Duration{}}
+	h := handler.New(&handler.Config{
+		Schema:        &testutil.StarWarsSchema,
+		ResponseCache: &handler.ResponseCacheConfig{Store: store, TTL: time.Hour},
+		Policies:      policies,
+	})
+	do := func(query string) int {
+		req, _ := http.NewRequest("GET", "/graphql?"+url.Values{"query": {query}}.Encode(), nil)
+		_, resp := executeTest(t, h, req)
+		return resp.Code
+	}
+	ttls := func() []time.Duration {
+		var ttls []time.Duration
+		for key, ttl := range store.ttls {
+			ttls = append(ttls, ttl)
+			delete(store.ttls, key)
+		}
+		return ttls
+	}
+
+	if status := do("{ hero { name friends { name } } }"); status != http.StatusBadRequest {
+		t.Fatalf("expected the weighed friends to exceed the cost, got %d", status)
+	}
+	if status := do(`{ human(id: "1000") { name } }`); status != http.StatusOK {
+		t.Fatalf("unexpected status %d", status)
+	}
+	if ttls := ttls(); len(ttls) != 1 || ttls[0] != 10*time.Second {
+		t.Fatalf("expected the TTL of Human.name, got %v", ttls)
+	}
+	if status := do("query Hero { hero { name } }"); status != http.StatusOK {
+		t.Fatalf("unexpected status %d", status)
+	}
+	if ttls := ttls(); len(ttls) != 1 || ttls[0] != time.Minute {
+		t.Fatalf("expected the TTL of Hero, got %v", ttls)
+	}
+	if status := do("query Hero { hero { id } }"); status != http.StatusTooManyRequests {
+		t.Fatalf("expected the strict tier to reject Hero, got %d", status)
+	}
+	if status := do("{ hero { id } }"); status != http.StatusOK {
+		t.Fatalf("unexpected status %d", status)
+	}
+
+	// the next request sees the new file, a broken one is ignored
+	write(`{"maxCost": 1}`)
+	if status := do("{ hero { name } }"); status != http.StatusBadRequest {
+		t.Fatalf("expected the reloaded cost limit, got %d", status)
+	}
+	write(`{"maxCost": "many"}`)
+	if status := do("{ hero { name } }"); status != http.StatusBadRequest {
+		t.Fatalf("expected the previous policies, got %d", status)
+	}
+}
+
+func TestPolicyFile_Diagnostics(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.json")
+	write := func(content string) {
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{"operations": {"Heroes": {"maxCost": 1}}, "fields": {"Human.nmae": {"weight": 2}, "Character.name": {"weight": 2}}}`)
+	policies, err := handler.NewPolicyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	operations := handler.NewOperationRegistry(false)
+	operations.MustRegister("Hero", "query Hero { hero { name } }")
+	_, err = handler.NewWithError(&handler.Config{Schema: &testutil.StarWarsSchema, Policies: policies, Operations: operations})
+	if err == nil || !strings.Contains(err.Error(), "unknown field Human.nmae, unknown operation Heroes") {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// reloads are checked against the schema of the handler
+	write(`{"maxCost": 1, "fields": {"Character.name": {"weight": 2}}}`)
+	if err := policies.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	var reloadErr error
+	policies.OnError = func(err error) { reloadErr = err }
+	policies.Interval = time.Nanosecond
+	h, err := handler.NewWithError(&handler.Config{Schema: &testutil.StarWarsSchema, Policies: policies})
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(`{"fields": {"Droid.nmae": {"weight": 2}}}`)
+	req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}", nil)
+	if _, resp := executeTest(t, h, req); resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected the previous cost limit, got %d", resp.Code)
+	}
+	if reloadErr == nil || !strings.Contains(reloadErr.Error(), "unknown field Droid.nmae") {
+		t.Fatalf("unexpected reload error %v", reloadErr)
+	}
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrPubSubClosed is returned by the PubSub methods once it is closed
+var ErrPubSubClosed = errors.New("pubsub closed")
+
+// PubSub fans events out to the subscribers of their topic, e.g. from the
+// mutation resolvers to the subscriptions of other requests or replicas
+type PubSub interface {
+	// Publish sends payload to the current subscribers of topic
+	Publish(ctx context.Context, topic string, payload []byte) error
+	// Subscribe returns the events of topic published from now on, the
+	// channel is closed once ctx is done or the PubSub is closed
+	Subscribe(ctx context.Context, topic string) (<-chan []byte, error)
+}
+
+type pubSubKey struct{}
+
+// RequestPubSub returns the Config.PubSub of the handler serving the
+// request ctx belongs to, nil when none is configured
+func RequestPubSub(ctx context.Context) PubSub {
+	ps, _ := ctx.Value(pubSubKey{}).(PubSub)
+	return ps
+}
+
+// DefaultPubSubBuffer is the number of events a MemoryPubSub subscriber
+// holds before newer events are dropped
+const DefaultPubSubBuffer = 16
+
+// MemoryPubSub is a PubSub within the process, for single replicas and
+// tests. Publish never blocks: events are dropped for the subscribers
+// whose buffer is full
+type MemoryPubSub struct {
+	buffer int
+
+	mu     sync.Mutex
+	closed bool
+	topics map[string]map[*memorySubscriber]struct{}
+}
+
+type memorySubscriber struct {
+	events chan []byte
+	// done stops watching the context of the subscription once the PubSub
+	// is closed
+	done chan struct{}
+}
+
+var _ PubSub = (*MemoryPubSub)(nil)
+
+// NewMemoryPubSub returns a MemoryPubSub whose subscribers buffer that
+// many events, DefaultPubSubBuffer when buffer is not positive
+func NewMemoryPubSub(buffer int) *MemoryPubSub {
+	if buffer <= 0 {
+		buffer = DefaultPubSubBuffer
+	}
+	return &MemoryPubSub{buffer: buffer, topics: map[string]map[*memorySubscriber]struct{}{}}
+}
+
+// Publish implements PubSub
+func (ps *MemoryPubSub) Publish(_ context.Context, topic string, payload []byte) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.closed {
+		return ErrPubSubClosed
+	}
+	for sub := range ps.topics[topic] {
+		select {
+		case sub.events <- payload:
+		default:
+		}
+	}
+	return nil
+}
+
+// Subscribe implements PubSub
+func (ps *MemoryPubSub) Subscribe(ctx context.Context, topic string) (<-chan []byte, error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.closed {
+		return nil, ErrPubSubClosed
+	}
+	sub := &memorySubscriber{events: make(chan []byte, ps.buffer), done: make(chan struct{})}
+	if ps.topics[topic] == nil {
+		ps.topics[topic] = map[*memorySubscriber]struct{}{}
+	}
+	ps.topics[topic][sub] = struct{}{}
+	go func() {
+		select {
+		case <-ctx.Done():
+			ps.unsubscribe(topic, sub)
+		case <-sub.done:
+		}
+	}()
+	return sub.events, nil
+}
+
+func (ps *MemoryPubSub) unsubscribe(topic string, sub *memorySubscriber) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if _, ok := ps.topics[topic][sub]; !ok {
+		return
+	}
+	delete(ps.topics[topic], sub)
+	if len(ps.topics[topic]) == 0 {
+		delete(ps.topics, topic)
+	}
+	close(sub.events)
+}
+
+// Close ends all subscriptions, later calls fail with ErrPubSubClosed
+func (ps *MemoryPubSub) Close() error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.closed {
+		return nil
+	}
+	ps.closed = true
+	for topic, subs := range ps.topics {
+		for sub := range subs {
+			close(sub.events)
+			close(sub.done)
+		}
+		delete(ps.topics, topic)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+)
+
+func TestMemoryPubSub(t *testing.T) {
+	ps := handler.NewMemoryPubSub(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := ps.Subscribe(ctx, "reviews")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := ps.Subscribe(context.Background(), "other")
+	_ = ps.Publish(context.Background(), "reviews", []byte("1"))
+	// the buffer is full, the event is dropped
+	_ = ps.Publish(context.Background(), "reviews", []byte("2"))
+	if event := <-events; string(event) != "1" {
+		t.Fatalf("unexpected event %q", event)
+	}
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatal("expected the dropped event not to be delivered")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the subscription to end with its context")
+	}
+	if err := ps.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-other; ok {
+		t.Fatal("expected Close to end the subscriptions")
+	}
+	if err := ps.Publish(context.Background(), "reviews", nil); err != handler.ErrPubSubClosed {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestHandler_PubSub(t *testing.T) {
+	ps := handler.NewMemoryPubSub(0)
+	events, _ := ps.Subscribe(context.Background(), "reviews")
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"ok": &graphql.Field{Type: graphql.Boolean},
+		}}),
+		Mutation: graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: graphql.Fields{
+			"review": &graphql.Field{
+				Type: graphql.Boolean,
+				Args: graphql.FieldConfigArgument{"stars": &graphql.ArgumentConfig{Type: graphql.Int}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					err := handler.RequestPubSub(p.Context).Publish(p.Context, "reviews", []byte("5 stars"))
+					return err == nil, err
+				},
+			},
+		}}),
+	})
+	h := handler.New(&handler.Config{Schema: &schema, PubSub: ps})
+	req, _ := http.NewRequest("GET", "/graphql?query=mutation{review(stars:5)}", nil)
+	result, _ := executeTest(t, h, req)
+	if result.HasErrors() {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if event := <-events; string(event) != "5 stars" {
+		t.Fatalf("unexpected event %q", event)
+	}
+}
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/lexer"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// QueryLimitError reports an operation exceeding one of the query limits
+// of the Config, the handler answers it with 400 Bad Request, or 413
+// Request Entity Too Large for the query length
+type QueryLimitError struct {
+	// Limit is "maxQueryLength", "maxTokens", "maxAliases", "maxRootFields"
+	// or the "maxCost" of the Policies
+	Limit string `json:"limit"`
+	Max   int    `json:"max"`
+	Value int    `json:"value"`
+}
+
+func (e *QueryLimitError) Error() string {
+	return fmt.Sprintf("query exceeds %s %d with %d", e.Limit, e.Max, e.Value)
+}
+
+func (e *QueryLimitError) status() int {
+	if e.Limit == "maxQueryLength" {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// Extensions reports the exceeded limit in the GraphQL error
+func (e *QueryLimitError) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"code":  "QUERY_LIMIT_EXCEEDED",
+		"limit": e.Limit,
+		"max":   e.Max,
+		"value": e.Value,
+	}
+}
+
+// queryLimits bound the size of query strings and the shape of parsed
+// documents, zero values are unlimited
+type queryLimits struct {
+	maxLength, maxTokens      int
+	maxAliases, maxRootFields int
+}
+
+func (l queryLimits) enabled() bool {
+	return l.maxAliases > 0 || l.maxRootFields > 0
+}
+
+// checkSource bounds the length and the tokens of query before it is parsed,
+// the lexer stops after the first token above the limit
+func (l queryLimits) checkSource(query string) error {
+	if l.maxLength > 0 && len(query) > l.maxLength {
+		return &QueryLimitError{Limit: "maxQueryLength", Max: l.maxLength, Value: len(query)}
+	}
+	if l.maxTokens <= 0 {
+		return nil
+	}
+	lex := lexer.Lex(source.NewSource(&source.Source{Body: []byte(query)}))
+	for tokens := 0; ; tokens++ {
+		token, err := lex(0)
+		if err != nil || token.Kind == lexer.EOF {
+			// the parser reports the syntax errors
+			return nil
+		}
+		if tokens == l.maxTokens {
+			// the lexer stopped counting, Value is a lower bound
+			return &QueryLimitError{Limit: "maxTokens", Max: l.maxTokens, Value: tokens + 1}
+		}
+	}
+}
+
+// check counts the aliases and root fields of operation, fragments count
+// once per spread
+func (l queryLimits) check(doc *ast.Document, operation *ast.OperationDefinition) error {
+	c := &selectionCounter{fragments: map[string]*ast.FragmentDefinition{}, counts: map[string]selectionCount{}}
+	for _, def := range doc.Definitions {
+		if def, ok := def.(*ast.FragmentDefinition); ok && def.Name != nil {
+			c.fragments[def.Name.Value] = def
+		}
+	}
+	count := c.count(operation.SelectionSet)
+	if l.maxRootFields > 0 && count.rootFields > l.maxRootFields {
+		return &QueryLimitError{Limit: "maxRootFields", Max: l.maxRootFields, Value: count.rootFields}
+	}
+	if l.maxAliases > 0 && count.aliases > l.maxAliases {
+		return &QueryLimitError{Limit: "maxAliases", Max: l.maxAliases, Value: count.aliases}
+	}
+	return nil
+}
+
+type selectionCount struct {
+	rootFields, aliases int
+}
+
+// selectionCounter memoizes the counts of fragments, spreads can repeat a
+// fragment exponentially often
+type selectionCounter struct {
+	fragments map[string]*ast.FragmentDefinition
+	counts    map[string]selectionCount
+}
+
+// count returns the fields of set, through its fragments, and the aliases
+// of set and of every nested selection set
+func (c *selectionCounter) count(set *ast.SelectionSet) selectionCount {
+	var total selectionCount
+	if set == nil {
+		return total
+	}
+	for _, selection := range set.Selections {
+		var nested selectionCount
+		switch selection := selection.(type) {
+		case *ast.Field:
+			total.rootFields++
+			if selection.Alias != nil {
+				total.aliases++
+			}
+			nested = c.count(selection.SelectionSet)
+			nested.rootFields = 0
+		case *ast.InlineFragment:
+			nested = c.count(selection.SelectionSet)
+		case *ast.FragmentSpread:
+			nested = c.spread(selection.Name.Value)
+		}
+		total.rootFields = saturatingAdd(total.rootFields, nested.rootFields)
+		total.aliases = saturatingAdd(total.aliases, nested.aliases)
+	}
+	return total
+}
+
+// saturatingAdd keeps the counts of deeply nested spreads from overflowing
+func saturatingAdd(a, b int) int {
+	if a > math.MaxInt32-b {
+		return math.MaxInt32
+	}
+	return a + b
+}
+
+func (c *selectionCounter) spread(name string) selectionCount {
+	if count, ok := c.counts[name]; ok {
+		return count
+	}
+	fragment, ok := c.fragments[name]
+	if !ok {
+		return selectionCount{}
+	}
+	// cycles are rejected by the validation, count them as empty meanwhile
+	c.counts[name] = selectionCount{}
+	count := c.count(fragment.SelectionSet)
+	c.counts[name] = count
+	return count
+}
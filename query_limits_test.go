@@ -0,0 +1,81 @@
+package handler_test
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_QueryLimits(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:        &testutil.StarWarsSchema,
+		MaxAliases:    3,
+		MaxRootFields: 2,
+	})
+	nested := "fragment F on Character { a: name b: name } fragment G on Character { ...F ...F }"
+	for _, test := range []struct {
+		query string
+		limit string
+	}{
+		{"{ hero { name } a: hero { id } }", ""},
+		{"{ hero { name } a: hero { id } b: hero { id } }", "maxRootFields"},
+		{"{ h: hero { id } ... on Query { hero { id } } ...Q } fragment Q on Query { a: hero { id } }", "maxRootFields"},
+		{"{ hero { a: name b: name c: name } }", ""},
+		{"{ hero { a: name b: name c: name d: name } }", "maxAliases"},
+		{"{ hero { ...G } } " + nested, "maxAliases"},
+	} {
+		req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape(test.query), nil)
+		result, resp := executeTest(t, h, req)
+		if test.limit == "" {
+			if resp.Code != http.StatusOK || result.HasErrors() {
+				t.Fatalf("%s: unexpected response %d %+v", test.query, resp.Code, result)
+			}
+			continue
+		}
+		if resp.Code != http.StatusBadRequest || len(result.Errors) != 1 || result.Errors[0].Extensions["limit"] != test.limit {
+			t.Fatalf("%s: unexpected response %d %+v", test.query, resp.Code, result)
+		}
+	}
+
+	// spreads repeating fragments exponentially often are counted without expanding them
+	query := "{ hero { ...F80 } } fragment F0 on Character { a: name }"
+	for i := 1; i <= 80; i++ {
+		query += fmt.Sprintf(" fragment F%d on Character { ...F%d ...F%d }", i, i-1, i-1)
+	}
+	req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape(query), nil)
+	if result, resp := executeTest(t, h, req); resp.Code != http.StatusBadRequest || result.Errors[0].Extensions["value"] != float64(math.MaxInt32) {
+		t.Fatalf("unexpected response %d %+v", resp.Code, result)
+	}
+}
+
+func TestHandler_QuerySize(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:         &testutil.StarWarsSchema,
+		MaxQueryLength: 64,
+		MaxTokens:      8,
+	})
+	for _, test := range []struct {
+		query string
+		code  int
+		limit string
+	}{
+		// { hero { name } } is 6 tokens, comments are not counted
+		{"{ hero { name } } # a comment", http.StatusOK, ""},
+		{"{ hero { name id } }", http.StatusOK, ""},
+		{"{ hero { name id } h: hero { id } }", http.StatusBadRequest, "maxTokens"},
+		{"{ hero { name } }" + strings.Repeat(" ", 64), http.StatusRequestEntityTooLarge, "maxQueryLength"},
+	} {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(test.query))
+		req.Header.Set("Content-Type", "application/graphql")
+		result, resp := executeTest(t, h, req)
+		if resp.Code != test.code || (test.limit != "" && result.Errors[0].Extensions["limit"] != test.limit) {
+			t.Fatalf("%q: unexpected response %d %+v", test.query, resp.Code, result)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"net/url"
+	"strings"
+)
+
+// queryParams are the request options of a query string or form
+type queryParams struct {
+	query, variables, operationName, extensions string
+}
+
+// parseQueryParams reads the request options of rawQuery like
+// url.ParseQuery and url.Values.Get, without building the values of the
+// other keys. Values without escapes are not copied
+func parseQueryParams(rawQuery string) queryParams {
+	var params queryParams
+	var seen [4]bool
+	for rawQuery != "" {
+		var pair string
+		if i := strings.IndexByte(rawQuery, '&'); i >= 0 {
+			pair, rawQuery = rawQuery[:i], rawQuery[i+1:]
+		} else {
+			pair, rawQuery = rawQuery, ""
+		}
+		// url.ParseQuery rejects semicolons, url.URL.Query drops their pairs
+		if pair == "" || strings.IndexByte(pair, ';') >= 0 {
+			continue
+		}
+		key, value := pair, ""
+		if i := strings.IndexByte(pair, '='); i >= 0 {
+			key, value = pair[:i], pair[i+1:]
+		}
+		key, ok := unescapeQuery(key)
+		if !ok {
+			continue
+		}
+		var field *string
+		var index int
+		switch key {
+		case "query":
+			field, index = &params.query, 0
+		case "variables":
+			field, index = &params.variables, 1
+		case "operationName":
+			field, index = &params.operationName, 2
+		case "extensions":
+			field, index = &params.extensions, 3
+		default:
+			continue
+		}
+		if seen[index] {
+			continue
+		}
+		if value, ok = unescapeQuery(value); ok {
+			*field, seen[index] = value, true
+		}
+	}
+	return params
+}
+
+func unescapeQuery(s string) (string, bool) {
+	if strings.IndexByte(s, '%') < 0 && strings.IndexByte(s, '+') < 0 {
+		return s, true
+	}
+	s, err := url.QueryUnescape(s)
+	return s, err == nil
+}
+
+func formParams(values url.Values) queryParams {
+	return queryParams{
+		query:         values.Get("query"),
+		variables:     values.Get("variables"),
+		operationName: values.Get("operationName"),
+		extensions:    values.Get("extensions"),
+	}
+}
+
+// options decodes the variables and extensions of p, the variables are
+// never nil. The options are taken from the pool when pool is set
+func (p queryParams) options(pool bool) (*RequestOptions, error) {
+	opts := newRequestOptions(pool)
+	variables, err := decodeObject("variables", []byte(p.variables), opts.Variables)
+	if err != nil {
+		return nil, err
+	}
+	if variables == nil {
+		if variables = opts.Variables; variables == nil {
+			variables = make(map[string]interface{})
+		}
+	}
+	extensions, err := decodeExtensions([]byte(p.extensions))
+	if err != nil {
+		return nil, err
+	}
+	opts.Query = p.query
+	opts.setVariables(variables)
+	opts.OperationName = p.operationName
+	opts.Extensions = extensions
+	return opts, nil
+}
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned by a RateLimiter rejecting a request, the
+// handler answers it with 429 Too Many Requests
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// RateLimitInfo is the state of the limit a request was counted against,
+// reported in the X-RateLimit-* headers
+type RateLimitInfo struct {
+	Limit     int
+	Remaining int
+	// Reset is when the limit is fully available again
+	Reset time.Time
+	// RetryAfter is how long a rejected client should wait
+	RetryAfter time.Duration
+}
+
+// RateLimiter decides whether a request may execute, errors other than
+// ErrRateLimited fail the request with 500 Internal Server Error
+type RateLimiter interface {
+	Allow(ctx context.Context, r *http.Request, opts *RequestOptions) (RateLimitInfo, error)
+}
+
+// IPRateLimiter is an in-memory token bucket RateLimiter keyed by client IP
+type IPRateLimiter struct {
+	// Rate is the sustained number of requests per second per client
+	Rate float64
+	// Burst is the number of requests a client may issue at once
+	Burst int
+	// KeyFn returns the client key of r, defaults to the IP of r.RemoteAddr
+	KeyFn func(r *http.Request) string
+
+	mu      sync.Mutex
+	buckets map[string]*ipBucket
+	calls   int
+}
+
+type ipBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewIPRateLimiter returns a limiter allowing rate requests per second per
+// client IP, in bursts of up to burst
+func NewIPRateLimiter(rate float64, burst int) *IPRateLimiter {
+	return &IPRateLimiter{Rate: rate, Burst: burst}
+}
+
+func (l *IPRateLimiter) key(r *http.Request) string {
+	if l.KeyFn != nil {
+		return l.KeyFn(r)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Allow implements RateLimiter
+func (l *IPRateLimiter) Allow(ctx context.Context, r *http.Request, opts *RequestOptions) (RateLimitInfo, error) {
+	burst := float64(l.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	now := time.Now()
+	key := l.key(r)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.buckets == nil {
+		l.buckets = map[string]*ipBucket{}
+	}
+	l.calls++
+	if l.calls%1024 == 0 {
+		// forget the clients whose buckets refilled
+		for k, b := range l.buckets {
+			if b.tokens+now.Sub(b.last).Seconds()*l.Rate >= burst {
+				delete(l.buckets, k)
+			}
+		}
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &ipBucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*l.Rate)
+	b.last = now
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	info := RateLimitInfo{Limit: int(burst), Remaining: int(b.tokens)}
+	if l.Rate > 0 {
+		info.Reset = now.Add(time.Duration((burst - b.tokens) / l.Rate * float64(time.Second)))
+		if !allowed {
+			info.RetryAfter = time.Duration((1 - b.tokens) / l.Rate * float64(time.Second))
+		}
+	}
+	if !allowed {
+		return info, ErrRateLimited
+	}
+	return info, nil
+}
+
+// bucketState is the bucket of a client handed over to the next process
+type bucketState struct {
+	Tokens float64   `json:"tokens"`
+	Last   time.Time `json:"last"`
+}
+
+// snapshot returns the buckets of the clients by their key
+func (l *IPRateLimiter) snapshot() map[string]bucketState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	buckets := make(map[string]bucketState, len(l.buckets))
+	for key, b := range l.buckets {
+		buckets[key] = bucketState{Tokens: b.tokens, Last: b.last}
+	}
+	return buckets
+}
+
+// restore sets the buckets of a snapshot, the clients seen since keep theirs
+func (l *IPRateLimiter) restore(buckets map[string]bucketState) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.buckets == nil {
+		l.buckets = make(map[string]*ipBucket, len(buckets))
+	}
+	for key, b := range buckets {
+		if _, ok := l.buckets[key]; !ok {
+			l.buckets[key] = &ipBucket{tokens: b.Tokens, last: b.Last}
+		}
+	}
+}
+
+// writeRateLimitHeaders reports info on w
+func writeRateLimitHeaders(w http.ResponseWriter, info RateLimitInfo, rejected bool) {
+	if info.Limit > 0 {
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(info.Limit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(info.Remaining))
+	}
+	if !info.Reset.IsZero() {
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(info.Reset.Unix(), 10))
+	}
+	if rejected {
+		retry := int(math.Ceil(info.RetryAfter.Seconds()))
+		if retry < 1 {
+			retry = 1
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(retry))
+	}
+}
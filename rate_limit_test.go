@@ -0,0 +1,39 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_RateLimiter(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:      &testutil.StarWarsSchema,
+		RateLimiter: handler.NewIPRateLimiter(1, 2),
+	})
+	do := func(addr string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}", nil)
+		req.RemoteAddr = addr
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+	if resp := do("10.0.0.1:1000"); resp.Code != http.StatusOK || resp.Header().Get("X-RateLimit-Remaining") != "1" {
+		t.Fatalf("unexpected response %d %v", resp.Code, resp.Header())
+	}
+	do("10.0.0.1:1001")
+	resp := do("10.0.0.1:1002")
+	if resp.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", resp.Code)
+	}
+	if resp.Header().Get("Retry-After") != "1" || resp.Header().Get("X-RateLimit-Limit") != "2" || resp.Header().Get("X-RateLimit-Reset") == "" {
+		t.Fatalf("unexpected headers %v", resp.Header())
+	}
+	// other clients have their own bucket
+	if resp := do("10.0.0.2:1000"); resp.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", resp.Code)
+	}
+}
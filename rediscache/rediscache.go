@@ -0,0 +1,46 @@
+// Package rediscache stores handler responses in Redis.
+package rediscache
+
+import (
+	"context"
+	"time"
+
+	"github.com/cxuhua/handler"
+	"github.com/redis/go-redis/v9"
+)
+
+// Store is a handler.CacheStore keeping responses in Redis
+type Store struct {
+	Client redis.UniversalClient
+	// Prefix namespaces the keys, defaults to "graphql:response:"
+	Prefix string
+}
+
+var _ handler.CacheStore = (*Store)(nil)
+
+// New returns a Store on client
+func New(client redis.UniversalClient) *Store {
+	return &Store{Client: client}
+}
+
+func (s *Store) key(key string) string {
+	if s.Prefix == "" {
+		return "graphql:response:" + key
+	}
+	return s.Prefix + key
+}
+
+// Get implements handler.CacheStore, Redis errors are reported as misses
+func (s *Store) Get(ctx context.Context, key string) ([]byte, bool) {
+	value, err := s.Client.Get(ctx, s.key(key)).Bytes()
+	if err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// Set implements handler.CacheStore, a zero ttl keeps the response until
+// Redis evicts it
+func (s *Store) Set(ctx context.Context, key string, value []byte, ttl time.Duration) {
+	s.Client.Set(ctx, s.key(key), value, ttl)
+}
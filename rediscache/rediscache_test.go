@@ -0,0 +1,73 @@
+package rediscache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/cxuhua/handler/rediscache"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	store := rediscache.New(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	ctx := context.Background()
+	if _, ok := store.Get(ctx, "hero"); ok {
+		t.Fatal("expected a miss before the response is set")
+	}
+	store.Set(ctx, "hero", []byte(`{"data":{}}`), 0)
+	if value, ok := store.Get(ctx, "hero"); !ok || string(value) != `{"data":{}}` {
+		t.Fatalf("unexpected value %q", value)
+	}
+	if value, err := server.Get("graphql:response:hero"); err != nil || value != `{"data":{}}` {
+		t.Fatalf("unexpected key in redis %q: %v", value, err)
+	}
+	if server.TTL("graphql:response:hero") != 0 {
+		t.Fatal("expected a zero ttl to keep the response")
+	}
+
+	store.Prefix = "other:"
+	store.Set(ctx, "hero", []byte("other"), 0)
+	if value, err := server.Get("other:hero"); err != nil || value != "other" {
+		t.Fatalf("unexpected prefixed key %q: %v", value, err)
+	}
+}
+
+func TestStore_TTL(t *testing.T) {
+	server := miniredis.RunT(t)
+	store := rediscache.New(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	ctx := context.Background()
+	store.Set(ctx, "hero", []byte("luke"), time.Minute)
+	if ttl := server.TTL("graphql:response:hero"); ttl != time.Minute {
+		t.Fatalf("unexpected ttl %v", ttl)
+	}
+	server.FastForward(30 * time.Second)
+	if _, ok := store.Get(ctx, "hero"); !ok {
+		t.Fatal("expected the response before its ttl")
+	}
+	server.FastForward(time.Minute)
+	if _, ok := store.Get(ctx, "hero"); ok {
+		t.Fatal("expected the response to expire")
+	}
+}
+
+func TestStore_Errors(t *testing.T) {
+	server := miniredis.RunT(t)
+	store := rediscache.New(redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1}))
+	ctx := context.Background()
+
+	// a key of another type is a miss
+	server.Lpush("graphql:response:hero", "luke")
+	if _, ok := store.Get(ctx, "hero"); ok {
+		t.Fatal("expected a wrong type to be a miss")
+	}
+
+	// an unreachable server is a miss and Set does not panic
+	server.Close()
+	store.Set(ctx, "droid", []byte("r2d2"), time.Minute)
+	if _, ok := store.Get(ctx, "droid"); ok {
+		t.Fatal("expected a miss without redis")
+	}
+}
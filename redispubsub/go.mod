@@ -0,0 +1,19 @@
+module github.com/cxuhua/handler/redispubsub
+
+go 1.18
+
+replace github.com/cxuhua/handler => ../
+
+require (
+	github.com/alicebob/miniredis/v2 v2.31.1
+	github.com/cxuhua/handler v0.0.0-00010101000000-000000000000
+	github.com/redis/go-redis/v9 v9.5.1
+)
+
+require (
+	github.com/alicebob/gopher-json v0.0.0-20200520072559-a9ecdc9d1d3a // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/graphql-go/graphql v0.7.9 // indirect
+	github.com/yuin/gopher-lua v1.1.0 // indirect
+)
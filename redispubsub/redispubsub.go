@@ -0,0 +1,75 @@
+// Package redispubsub fans the events of handler.PubSub out through Redis
+// pub/sub, so the subscribers of every replica receive them
+package redispubsub
+
+import (
+	"context"
+
+	"github.com/cxuhua/handler"
+	"github.com/redis/go-redis/v9"
+)
+
+// PubSub is a handler.PubSub publishing to Redis channels
+type PubSub struct {
+	Client redis.UniversalClient
+	// Prefix namespaces the channels, defaults to "graphql:events:"
+	Prefix string
+	// Buffer is the number of events a subscriber holds before newer events
+	// are dropped, defaults to handler.DefaultPubSubBuffer
+	Buffer int
+}
+
+var _ handler.PubSub = (*PubSub)(nil)
+
+// New returns a PubSub on client
+func New(client redis.UniversalClient) *PubSub {
+	return &PubSub{Client: client}
+}
+
+func (ps *PubSub) channel(topic string) string {
+	if ps.Prefix == "" {
+		return "graphql:events:" + topic
+	}
+	return ps.Prefix + topic
+}
+
+// Publish implements handler.PubSub
+func (ps *PubSub) Publish(ctx context.Context, topic string, payload []byte) error {
+	return ps.Client.Publish(ctx, ps.channel(topic), payload).Err()
+}
+
+// Subscribe implements handler.PubSub, it returns once Redis confirmed the
+// subscription. The go-redis client resubscribes after reconnecting, the
+// events published in between are lost
+func (ps *PubSub) Subscribe(ctx context.Context, topic string) (<-chan []byte, error) {
+	sub := ps.Client.Subscribe(ctx, ps.channel(topic))
+	if _, err := sub.Receive(ctx); err != nil {
+		_ = sub.Close()
+		return nil, err
+	}
+	buffer := ps.Buffer
+	if buffer <= 0 {
+		buffer = handler.DefaultPubSubBuffer
+	}
+	events := make(chan []byte, buffer)
+	messages := sub.Channel()
+	go func() {
+		defer close(events)
+		defer sub.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				select {
+				case events <- []byte(msg.Payload):
+				default:
+				}
+			}
+		}
+	}()
+	return events, nil
+}
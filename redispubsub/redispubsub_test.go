@@ -0,0 +1,46 @@
+package redispubsub_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/cxuhua/handler/redispubsub"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestPubSub(t *testing.T) {
+	server := miniredis.RunT(t)
+	// two replicas on their own connections
+	a := redispubsub.New(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	b := redispubsub.New(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := b.Subscribe(ctx, "reviews")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Publish(context.Background(), "reviews", []byte("5 stars")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-events:
+		if string(event) != "5 stars" {
+			t.Fatalf("unexpected event %q", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the event of the other replica")
+	}
+	if channels := server.PubSubChannels(""); len(channels) != 1 || channels[0] != "graphql:events:reviews" {
+		t.Fatalf("unexpected channels %q", channels)
+	}
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatal("unexpected event")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the subscription to end with its context")
+	}
+}
@@ -0,0 +1,532 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/printer"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// RemoteConfig describes an upstream GraphQL endpoint
+type RemoteConfig struct {
+	// Name tells the upstream apart in errors, defaults to Endpoint
+	Name     string
+	Endpoint string
+	// Client sends the requests, defaults to http.DefaultClient
+	Client *http.Client
+	// RequestFn prepares the requests sent upstream, e.g. to forward the
+	// credentials an AuthFn stored in ctx
+	RequestFn func(ctx context.Context, r *http.Request)
+	// Timeout bounds each root field sent upstream
+	Timeout time.Duration
+	// MaxResponseSize bounds the bytes read from a response of the
+	// upstream, DefaultMaxRemoteResponseSize when zero
+	MaxResponseSize int64
+}
+
+// DefaultMaxRemoteResponseSize is the default RemoteConfig.MaxResponseSize
+const DefaultMaxRemoteResponseSize = 32 << 20
+
+// remoteIntrospectionQuery selects what the schema of a remote endpoint is
+// rebuilt from
+const remoteIntrospectionQuery = `query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    types {
+      kind name description
+      fields(includeDeprecated: true) {
+        name description isDeprecated deprecationReason
+        args { name description type { ...TypeRef } defaultValue }
+        type { ...TypeRef }
+      }
+      inputFields { name description type { ...TypeRef } defaultValue }
+      interfaces { name }
+      enumValues(includeDeprecated: true) { name description isDeprecated deprecationReason }
+      possibleTypes { name }
+    }
+  }
+}
+fragment TypeRef on __Type {
+  kind name
+  ofType { kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name } } } } } }
+}`
+
+type introspectedTypeRef struct {
+	Kind   string               `json:"kind"`
+	Name   string               `json:"name"`
+	OfType *introspectedTypeRef `json:"ofType"`
+}
+
+type introspectedInputValue struct {
+	Name         string              `json:"name"`
+	Description  string              `json:"description"`
+	Type         introspectedTypeRef `json:"type"`
+	DefaultValue *string             `json:"defaultValue"`
+}
+
+type introspectedType struct {
+	Kind        string `json:"kind"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Fields      []struct {
+		Name              string                   `json:"name"`
+		Description       string                   `json:"description"`
+		DeprecationReason string                   `json:"deprecationReason"`
+		Args              []introspectedInputValue `json:"args"`
+		Type              introspectedTypeRef      `json:"type"`
+	} `json:"fields"`
+	InputFields []introspectedInputValue `json:"inputFields"`
+	Interfaces  []introspectedTypeRef    `json:"interfaces"`
+	EnumValues  []struct {
+		Name              string `json:"name"`
+		Description       string `json:"description"`
+		DeprecationReason string `json:"deprecationReason"`
+	} `json:"enumValues"`
+	PossibleTypes []introspectedTypeRef `json:"possibleTypes"`
+}
+
+type introspectedSchema struct {
+	Schema struct {
+		QueryType    *introspectedTypeRef `json:"queryType"`
+		MutationType *introspectedTypeRef `json:"mutationType"`
+		Types        []introspectedType   `json:"types"`
+	} `json:"__schema"`
+}
+
+// remoteResult is the wire form of the responses of the upstream
+type remoteResult struct {
+	Data   interface{}                `json:"data"`
+	Errors []gqlerrors.FormattedError `json:"errors"`
+}
+
+// post sends query to the endpoint of cfg
+func (cfg *RemoteConfig) post(ctx context.Context, query string, variables map[string]interface{}) (*remoteResult, error) {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return nil, err
+	}
+	r, err := http.NewRequest(http.MethodPost, cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	r = r.WithContext(ctx)
+	r.Header.Set("Content-Type", ContentTypeJSON)
+	r.Header.Set("Accept", ContentTypeJSON)
+	if cfg.RequestFn != nil {
+		cfg.RequestFn(ctx, r)
+	}
+	client := cfg.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	max := cfg.MaxResponseSize
+	if max <= 0 {
+		max = DefaultMaxRemoteResponseSize
+	}
+	buff, err := ioutil.ReadAll(io.LimitReader(resp.Body, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(buff)) > max {
+		return nil, fmt.Errorf("upstream response exceeds %d bytes", max)
+	}
+	var result remoteResult
+	if err := json.Unmarshal(buff, &result); err != nil {
+		return nil, fmt.Errorf("upstream answered %s: %v", resp.Status, err)
+	}
+	return &result, nil
+}
+
+// NewRemoteDownstream introspects the endpoint of cfg and returns the
+// downstream sending the root fields of its schema there
+func NewRemoteDownstream(ctx context.Context, cfg RemoteConfig) (*Downstream, error) {
+	if cfg.Name == "" {
+		cfg.Name = cfg.Endpoint
+	}
+	result, err := cfg.post(ctx, remoteIntrospectionQuery, nil)
+	if err != nil {
+		return nil, fmt.Errorf("introspect %s: %v", cfg.Name, err)
+	}
+	if len(result.Errors) > 0 {
+		return nil, fmt.Errorf("introspect %s: %s", cfg.Name, result.Errors[0].Message)
+	}
+	buff, _ := json.Marshal(result.Data)
+	var introspected introspectedSchema
+	if err := json.Unmarshal(buff, &introspected); err != nil {
+		return nil, fmt.Errorf("introspect %s: %v", cfg.Name, err)
+	}
+	schema, err := buildRemoteSchema(&introspected)
+	if err != nil {
+		return nil, fmt.Errorf("introspect %s: %v", cfg.Name, err)
+	}
+	return &Downstream{
+		Name:    cfg.Name,
+		Schema:  schema,
+		Timeout: cfg.Timeout,
+		Execute: func(ctx context.Context, doc *ast.Document, variables map[string]interface{}) *graphql.Result {
+			result, err := cfg.post(ctx, printer.Print(doc).(string), declaredVariables(doc, variables))
+			if err != nil {
+				return &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(err)}}
+			}
+			return &graphql.Result{Data: result.Data, Errors: result.Errors}
+		},
+	}, nil
+}
+
+// declaredVariables returns the variables the operation of doc declares,
+// upstreams may reject the others
+func declaredVariables(doc *ast.Document, variables map[string]interface{}) map[string]interface{} {
+	declared := map[string]interface{}{}
+	for _, def := range doc.Definitions {
+		if operation, ok := def.(*ast.OperationDefinition); ok {
+			for _, v := range operation.VariableDefinitions {
+				if value, ok := variables[v.Variable.Name.Value]; ok {
+					declared[v.Variable.Name.Value] = value
+				}
+			}
+		}
+	}
+	return declared
+}
+
+// NewRemoteSchema returns the schema proxying every operation to the
+// endpoint of cfg, the handler serving it applies its own hooks and limits
+func NewRemoteSchema(ctx context.Context, cfg RemoteConfig) (*graphql.Schema, error) {
+	d, err := NewRemoteDownstream(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewGatewaySchema(d)
+}
+
+// remoteSchema rebuilds the types of an introspected schema, their fields
+// have no resolvers
+type remoteSchema struct {
+	defs  map[string]*introspectedType
+	types map[string]graphql.Type
+	// err is the first type the thunks of the fields could not build
+	err error
+}
+
+func buildRemoteSchema(introspected *introspectedSchema) (*graphql.Schema, error) {
+	s := &remoteSchema{defs: map[string]*introspectedType{}, types: map[string]graphql.Type{}}
+	for i := range introspected.Schema.Types {
+		def := &introspected.Schema.Types[i]
+		s.defs[def.Name] = def
+	}
+	if introspected.Schema.QueryType == nil {
+		return nil, fmt.Errorf("schema without a query type")
+	}
+	config := graphql.SchemaConfig{}
+	var ok bool
+	if config.Query, ok = s.named(introspected.Schema.QueryType.Name).(*graphql.Object); !ok {
+		return nil, fmt.Errorf("query type %s is not an object", introspected.Schema.QueryType.Name)
+	}
+	if introspected.Schema.MutationType != nil {
+		if config.Mutation, ok = s.named(introspected.Schema.MutationType.Name).(*graphql.Object); !ok {
+			return nil, fmt.Errorf("mutation type %s is not an object", introspected.Schema.MutationType.Name)
+		}
+	}
+	for _, def := range introspected.Schema.Types {
+		if t := s.named(def.Name); t != nil && t != config.Query && t != config.Mutation {
+			config.Types = append(config.Types, t)
+		}
+	}
+	schema, err := graphql.NewSchema(config)
+	if s.err != nil {
+		return nil, s.err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+func (s *remoteSchema) ref(ref *introspectedTypeRef) graphql.Type {
+	if ref == nil {
+		return nil
+	}
+	switch ref.Kind {
+	case "NON_NULL", "LIST":
+		t := s.ref(ref.OfType)
+		if t == nil {
+			return nil
+		}
+		if ref.Kind == "LIST" {
+			return graphql.NewList(t)
+		}
+		return graphql.NewNonNull(t)
+	}
+	return s.named(ref.Name)
+}
+
+// input returns the input type of the value of coordinate, the error of s is
+// set when it is not one
+func (s *remoteSchema) input(coordinate string, ref *introspectedTypeRef) graphql.Input {
+	t := s.ref(ref)
+	if t == nil || !graphql.IsInputType(t) {
+		if s.err == nil {
+			s.err = fmt.Errorf("%s is not of an input type", coordinate)
+		}
+		return nil
+	}
+	return t
+}
+
+// output returns the output type of the field at coordinate, the error of s
+// is set when it is not one
+func (s *remoteSchema) output(coordinate string, ref *introspectedTypeRef) graphql.Output {
+	t := s.ref(ref)
+	if t == nil || !graphql.IsOutputType(t) {
+		if s.err == nil {
+			s.err = fmt.Errorf("%s is not of an output type", coordinate)
+		}
+		return nil
+	}
+	return t
+}
+
+// named returns the type called name, nil for the introspection types
+func (s *remoteSchema) named(name string) graphql.Type {
+	if t, ok := s.types[name]; ok {
+		return t
+	}
+	switch name {
+	case "String":
+		return graphql.String
+	case "Int":
+		return graphql.Int
+	case "Float":
+		return graphql.Float
+	case "Boolean":
+		return graphql.Boolean
+	case "ID":
+		return graphql.ID
+	}
+	def, ok := s.defs[name]
+	if !ok || strings.HasPrefix(name, "__") {
+		return nil
+	}
+	// the gateway copying the schema resolves abstract types on its own,
+	// proxied responses otherwise carry their __typename
+	resolveType := func(p graphql.ResolveTypeParams) *graphql.Object {
+		value, _ := p.Value.(map[string]interface{})
+		name, _ := value["__typename"].(string)
+		object, _ := s.types[name].(*graphql.Object)
+		return object
+	}
+	switch def.Kind {
+	case "SCALAR":
+		// the upstream coerces the values, they are only brought to the
+		// form they are sent in
+		s.types[name] = graphql.NewScalar(graphql.ScalarConfig{
+			Name:         name,
+			Description:  def.Description,
+			Serialize:    func(value interface{}) interface{} { return value },
+			ParseValue:   wireValue,
+			ParseLiteral: scalarLiteral,
+		})
+	case "ENUM":
+		values := graphql.EnumValueConfigMap{}
+		for _, value := range def.EnumValues {
+			values[value.Name] = &graphql.EnumValueConfig{
+				Value:             value.Name,
+				Description:       value.Description,
+				DeprecationReason: value.DeprecationReason,
+			}
+		}
+		s.types[name] = graphql.NewEnum(graphql.EnumConfig{Name: name, Description: def.Description, Values: values})
+	case "INPUT_OBJECT":
+		s.types[name] = graphql.NewInputObject(graphql.InputObjectConfig{
+			Name:        name,
+			Description: def.Description,
+			Fields: graphql.InputObjectConfigFieldMapThunk(func() graphql.InputObjectConfigFieldMap {
+				fields := graphql.InputObjectConfigFieldMap{}
+				for _, field := range def.InputFields {
+					t := s.input(name+"."+field.Name, &field.Type)
+					if t == nil {
+						continue
+					}
+					fields[field.Name] = &graphql.InputObjectFieldConfig{
+						Type:         t,
+						DefaultValue: defaultValue(field.DefaultValue),
+						Description:  field.Description,
+					}
+				}
+				return fields
+			}),
+		})
+	case "OBJECT":
+		s.types[name] = graphql.NewObject(graphql.ObjectConfig{
+			Name:        name,
+			Description: def.Description,
+			Fields:      s.fieldsThunk(def),
+			Interfaces: graphql.InterfacesThunk(func() []*graphql.Interface {
+				interfaces := make([]*graphql.Interface, len(def.Interfaces))
+				for i, iface := range def.Interfaces {
+					interfaces[i], _ = s.named(iface.Name).(*graphql.Interface)
+				}
+				return interfaces
+			}),
+		})
+	case "INTERFACE":
+		s.types[name] = graphql.NewInterface(graphql.InterfaceConfig{
+			Name:        name,
+			Description: def.Description,
+			Fields:      s.fieldsThunk(def),
+			ResolveType: resolveType,
+		})
+	case "UNION":
+		possible := make([]*graphql.Object, 0, len(def.PossibleTypes))
+		for _, ref := range def.PossibleTypes {
+			if object, ok := s.named(ref.Name).(*graphql.Object); ok {
+				possible = append(possible, object)
+			}
+		}
+		s.types[name] = graphql.NewUnion(graphql.UnionConfig{
+			Name:        name,
+			Description: def.Description,
+			Types:       possible,
+			ResolveType: resolveType,
+		})
+	}
+	return s.types[name]
+}
+
+func (s *remoteSchema) fieldsThunk(def *introspectedType) graphql.FieldsThunk {
+	return func() graphql.Fields {
+		fields := graphql.Fields{}
+		for _, field := range def.Fields {
+			coordinate := def.Name + "." + field.Name
+			t := s.output(coordinate, &field.Type)
+			if t == nil {
+				continue
+			}
+			args := graphql.FieldConfigArgument{}
+			for _, arg := range field.Args {
+				argType := s.input(coordinate+"("+arg.Name+":)", &arg.Type)
+				if argType == nil {
+					continue
+				}
+				args[arg.Name] = &graphql.ArgumentConfig{
+					Type:         argType,
+					DefaultValue: defaultValue(arg.DefaultValue),
+					Description:  arg.Description,
+				}
+			}
+			fields[field.Name] = &graphql.Field{
+				Type:              t,
+				Args:              args,
+				Description:       field.Description,
+				DeprecationReason: field.DeprecationReason,
+			}
+		}
+		return fields
+	}
+}
+
+// defaultValue returns the value of the GraphQL literal an introspection
+// reports as default, nil when there is none
+func defaultValue(literal *string) interface{} {
+	if literal == nil {
+		return nil
+	}
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte("{f(v:" + *literal + ")}"),
+	})})
+	if err != nil {
+		return nil
+	}
+	field := doc.Definitions[0].(*ast.OperationDefinition).SelectionSet.Selections[0].(*ast.Field)
+	return literalValue(field.Arguments[0].Value)
+}
+
+// wireValue returns the JSON form of a variable value, e.g. of the values a
+// VariablesFn set, nil when it has none
+func wireValue(value interface{}) interface{} {
+	switch value.(type) {
+	case nil, string, bool, float64, json.Number:
+		return value
+	}
+	buff, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	d := json.NewDecoder(bytes.NewReader(buff))
+	d.UseNumber()
+	var wire interface{}
+	if err := d.Decode(&wire); err != nil {
+		return nil
+	}
+	return wire
+}
+
+// scalarLiteral returns the value of a literal of a custom scalar, numbers
+// beyond the range of Go numbers are kept as they are written. The literal
+// itself is sent upstream
+func scalarLiteral(value ast.Value) interface{} {
+	switch value := value.(type) {
+	case *ast.IntValue:
+		if _, err := strconv.Atoi(value.Value); err != nil {
+			return json.Number(value.Value)
+		}
+	case *ast.FloatValue:
+		if _, err := strconv.ParseFloat(value.Value, 64); err != nil {
+			return json.Number(value.Value)
+		}
+	}
+	return literalValue(value)
+}
+
+// literalValue returns the Go value of a GraphQL literal, enum values
+// become their names
+func literalValue(value ast.Value) interface{} {
+	switch value := value.(type) {
+	case *ast.IntValue:
+		if n, err := strconv.Atoi(value.Value); err == nil {
+			return n
+		}
+	case *ast.FloatValue:
+		if f, err := strconv.ParseFloat(value.Value, 64); err == nil {
+			return f
+		}
+	case *ast.StringValue:
+		return value.Value
+	case *ast.BooleanValue:
+		return value.Value
+	case *ast.EnumValue:
+		return value.Value
+	case *ast.ListValue:
+		items := make([]interface{}, len(value.Values))
+		for i, item := range value.Values {
+			items[i] = literalValue(item)
+		}
+		return items
+	case *ast.ObjectValue:
+		fields := make(map[string]interface{}, len(value.Fields))
+		for _, field := range value.Fields {
+			fields[field.Name.Value] = literalValue(field.Value)
+		}
+		return fields
+	}
+	return nil
+}
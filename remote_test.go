@@ -0,0 +1,181 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestNewRemoteSchema(t *testing.T) {
+	upstream := httptest.NewServer(handler.New(&handler.Config{
+		Schema: &testutil.StarWarsSchema,
+		AuthFn: func(ctx context.Context, r *http.Request, opts *handler.RequestOptions) (context.Context, error) {
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				return nil, errors.New("missing token")
+			}
+			return ctx, nil
+		},
+	}))
+	defer upstream.Close()
+	cfg := handler.RemoteConfig{
+		Name:     "starwars",
+		Endpoint: upstream.URL,
+		RequestFn: func(ctx context.Context, r *http.Request) {
+			r.Header.Set("Authorization", "Bearer secret")
+		},
+	}
+	if _, err := handler.NewRemoteSchema(context.Background(), handler.RemoteConfig{Endpoint: upstream.URL}); err == nil ||
+		!strings.Contains(err.Error(), "missing token") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	schema, err := handler.NewRemoteSchema(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := handler.New(&handler.Config{Schema: schema, MaxAliases: 1})
+	query := "query($ep: Episode) { hero(episode: $ep) { name ... on Human { planet: homePlanet } appearsIn } }"
+	req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape(query)+"&variables="+url.QueryEscape(`{"ep":"EMPIRE"}`), nil)
+	result, _ := executeTest(t, h, req)
+	expected := map[string]interface{}{"hero": map[string]interface{}{
+		"name":      "Luke Skywalker",
+		"planet":    "Tatooine",
+		"appearsIn": []interface{}{"NEWHOPE", "EMPIRE", "JEDI"},
+	}}
+	if result.HasErrors() || !reflect.DeepEqual(result.Data, expected) {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	// the limits of the proxy apply before the upstream is reached
+	req, _ = http.NewRequest("GET", "/graphql?query="+url.QueryEscape("{ a: hero { name } b: hero { name } }"), nil)
+	if _, resp := executeTest(t, h, req); resp.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status %d", resp.Code)
+	}
+
+	// the errors of the upstream are reported with the root field
+	req, _ = http.NewRequest("GET", "/graphql?query="+url.QueryEscape(`{ droid(id: "2001") { name } }`), nil)
+	result, _ = executeTest(t, h, req)
+	if len(result.Errors) != 1 || result.Errors[0].Extensions["downstream"] != "starwars" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestNewRemoteSchema_CustomScalars(t *testing.T) {
+	long := graphql.NewScalar(graphql.ScalarConfig{
+		Name:      "Long",
+		Serialize: func(value interface{}) interface{} { return value },
+		ParseValue: func(value interface{}) interface{} {
+			if s, ok := value.(string); ok {
+				return s
+			}
+			return nil
+		},
+		ParseLiteral: func(value ast.Value) interface{} {
+			if n, ok := value.(*ast.IntValue); ok {
+				return n.Value
+			}
+			return nil
+		},
+	})
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"echo": &graphql.Field{
+				Type: long,
+				Args: graphql.FieldConfigArgument{"v": &graphql.ArgumentConfig{Type: long}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Args["v"], nil
+				},
+			},
+			"padding": &graphql.Field{
+				Type: graphql.String,
+				Args: graphql.FieldConfigArgument{"n": &graphql.ArgumentConfig{Type: graphql.Int}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return strings.Repeat("x", p.Args["n"].(int)), nil
+				},
+			},
+		}}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream := httptest.NewServer(handler.New(&handler.Config{Schema: &schema}))
+	defer upstream.Close()
+	remote, err := handler.NewRemoteSchema(context.Background(), handler.RemoteConfig{Endpoint: upstream.URL, MaxResponseSize: 500})
+	if err == nil || !strings.Contains(err.Error(), "exceeds 500 bytes") {
+		t.Fatalf("expected the introspection to exceed the limit, got %v", err)
+	}
+	remote, err = handler.NewRemoteSchema(context.Background(), handler.RemoteConfig{Endpoint: upstream.URL, MaxResponseSize: 1 << 14})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := handler.New(&handler.Config{Schema: remote})
+	do := func(query, variables string) *graphql.Result {
+		req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape(query)+"&variables="+url.QueryEscape(variables), nil)
+		result, _ := executeTest(t, h, req)
+		return result
+	}
+
+	// the upstream coerces the literals the int of Go cannot hold
+	result := do("{ echo(v: 12345678901234567890) }", "")
+	if result.HasErrors() || !reflect.DeepEqual(result.Data, map[string]interface{}{"echo": "12345678901234567890"}) {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	result = do("query($v: Long) { echo(v: $v) }", `{"v": "42"}`)
+	if result.HasErrors() || !reflect.DeepEqual(result.Data, map[string]interface{}{"echo": "42"}) {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	result = do("query($v: Long) { echo(v: $v) }", `{"v": 42}`)
+	if len(result.Errors) != 1 || result.Errors[0].Extensions["code"] != "DOWNSTREAM_ERROR" {
+		t.Fatalf("expected the upstream to reject the value, got %+v", result)
+	}
+	result = do("{ padding(n: 20000) }", "")
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, "upstream response exceeds 16384 bytes") {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestNewRemoteDownstream_AbstractTypes(t *testing.T) {
+	upstream := httptest.NewServer(handler.New(&handler.Config{Schema: &testutil.StarWarsSchema}))
+	defer upstream.Close()
+	d, err := handler.NewRemoteDownstream(context.Background(), handler.RemoteConfig{Endpoint: upstream.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the types of proxied responses are resolved from their __typename
+	result := graphql.Do(graphql.Params{
+		Schema:        *d.Schema,
+		RequestString: "{ hero { name ... on Human { homePlanet } } }",
+		RootObject: map[string]interface{}{"hero": map[string]interface{}{
+			"__typename": "Human", "name": "Luke Skywalker", "homePlanet": "Tatooine",
+		}},
+	})
+	expected := map[string]interface{}{"hero": map[string]interface{}{"name": "Luke Skywalker", "homePlanet": "Tatooine"}}
+	if result.HasErrors() || !reflect.DeepEqual(result.Data, expected) {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestNewRemoteSchema_InvalidTypes(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the argument of Query.user is an object
+		_, _ = w.Write([]byte(`{"data": {"__schema": {"queryType": {"name": "Query"}, "types": [
+			{"kind": "OBJECT", "name": "Query", "fields": [{"name": "user", "type": {"kind": "OBJECT", "name": "User"},
+				"args": [{"name": "filter", "type": {"kind": "OBJECT", "name": "User"}}]}]},
+			{"kind": "OBJECT", "name": "User", "fields": [{"name": "name", "args": [], "type": {"kind": "SCALAR", "name": "String"}}]}
+		]}}}`))
+	}))
+	defer upstream.Close()
+	_, err := handler.NewRemoteSchema(context.Background(), handler.RemoteConfig{Endpoint: upstream.URL})
+	if err == nil || !strings.Contains(err.Error(), "Query.user(filter:) is not of an input type") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
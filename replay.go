@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// ReplayConfig paces Handler.Replay
+type ReplayConfig struct {
+	// Rate is the number of operations executed per second, zero executes
+	// them back to back
+	Rate float64
+	// StopOnError ends the replay at the first operation with errors
+	StopOnError bool
+	// ProgressFn is called after every operation, result is its outcome
+	ProgressFn func(progress ReplayProgress, opts *RequestOptions, result *graphql.Result)
+}
+
+// ReplayProgress counts the operations of a replay
+type ReplayProgress struct {
+	Total   int
+	Done    int
+	Failed  int
+	Elapsed time.Duration
+}
+
+// ReadOperations reads newline delimited JSON operations, each line a
+// request body like {"query": ..., "variables": ..., "operationName": ...}
+func ReadOperations(r io.Reader) ([]*RequestOptions, error) {
+	var ops []*RequestOptions
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		opts, err := getFromJSON(scanner.Bytes(), false)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		ops = append(ops, opts)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+// Replay executes ops against the current default schema at cfg.Rate, e.g.
+// for data backfills reusing the resolvers. EntryFn sees a synthetic POST request
+// carrying ctx. Replay stops early when ctx is done, returning its error
+func (h *Handler) Replay(ctx context.Context, ops []*RequestOptions, cfg ReplayConfig) (ReplayProgress, error) {
+	progress := ReplayProgress{Total: len(ops)}
+	start := time.Now()
+	for i, opts := range ops {
+		if cfg.Rate > 0 {
+			next := start.Add(time.Duration(float64(i) / cfg.Rate * float64(time.Second)))
+			if wait := time.Until(next); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					progress.Elapsed = time.Since(start)
+					return progress, ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			progress.Elapsed = time.Since(start)
+			return progress, err
+		}
+		result := h.replayOne(ctx, opts)
+		progress.Done++
+		if result.HasErrors() {
+			progress.Failed++
+		}
+		progress.Elapsed = time.Since(start)
+		if cfg.ProgressFn != nil {
+			cfg.ProgressFn(progress, opts, result)
+		}
+		if cfg.StopOnError && result.HasErrors() {
+			return progress, fmt.Errorf("operation %d: %v", i, result.Errors[0].Message)
+		}
+	}
+	return progress, nil
+}
+
+// replayOne executes opts like ContextHandler does for a request
+func (h *Handler) replayOne(ctx context.Context, opts *RequestOptions) *graphql.Result {
+	version := h.currentSchema()
+	schema := version.schema
+	if schema == nil {
+		return &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError("no schema selected")}}
+	}
+	params := graphql.Params{
+		Schema:         *schema,
+		RequestString:  opts.Query,
+		VariableValues: opts.Variables,
+		OperationName:  opts.OperationName,
+		Context:        ctx,
+	}
+	if h.entryFn != nil {
+		body, _ := json.Marshal(opts)
+		r, err := http.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		if err == nil {
+			r = r.WithContext(ctx)
+			r.Header.Set("Content-Type", ContentTypeJSON)
+			params.RootObject, err = h.entryFn(ctx, r, opts)
+		}
+		if err != nil {
+			return &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(err)}}
+		}
+	}
+	return h.execute(params, fmt.Sprintf("#%d", version.generation))
+}
@@ -0,0 +1,50 @@
+package handler_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_Replay(t *testing.T) {
+	calls := 0
+	h := handler.New(&handler.Config{Schema: countingSchema(t, &calls)})
+	ops, err := handler.ReadOperations(strings.NewReader(`{"query":"mutation {count}"}
+
+{"query":"mutation Count {count}","operationName":"Count"}
+{"query":"{missing}"}
+`))
+	if err != nil || len(ops) != 3 {
+		t.Fatalf("unexpected operations %v, %v", ops, err)
+	}
+
+	var reported []handler.ReplayProgress
+	progress, err := h.Replay(context.Background(), ops, handler.ReplayConfig{
+		Rate: 100,
+		ProgressFn: func(p handler.ReplayProgress, _ *handler.RequestOptions, _ *graphql.Result) {
+			reported = append(reported, p)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 || progress.Done != 3 || progress.Failed != 1 || len(reported) != 3 {
+		t.Fatalf("unexpected progress %+v after %d calls", progress, calls)
+	}
+	if progress.Elapsed < 20*time.Millisecond {
+		t.Fatalf("replay was not paced, took %v", progress.Elapsed)
+	}
+
+	progress, err = h.Replay(context.Background(), ops[2:], handler.ReplayConfig{StopOnError: true})
+	if err == nil || progress.Failed != 1 {
+		t.Fatalf("expected the replay to stop, got %+v, %v", progress, err)
+	}
+
+	if _, err := handler.ReadOperations(strings.NewReader("{]")); err == nil || !strings.HasPrefix(err.Error(), "line 1:") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package handler
+
+import "sync"
+
+// maxPooledVariables bounds the variables maps kept for reuse, larger maps
+// are left to the garbage collector
+const maxPooledVariables = 64
+
+var requestOptionsPool = sync.Pool{
+	New: func() interface{} { return &RequestOptions{} },
+}
+
+// newRequestOptions returns empty options, taken from the pool when pool is
+// set
+func newRequestOptions(pool bool) *RequestOptions {
+	if pool {
+		return acquireRequestOptions()
+	}
+	return &RequestOptions{}
+}
+
+// acquireRequestOptions returns reset options, their Variables are an
+// empty map when a released request had variables
+func acquireRequestOptions() *RequestOptions {
+	opts := requestOptionsPool.Get().(*RequestOptions)
+	opts.pooled = true
+	return opts
+}
+
+// setVariables sets the Variables the options were parsed with, the pool
+// keeps them for reuse
+func (o *RequestOptions) setVariables(variables map[string]interface{}) {
+	o.Variables = variables
+	if o.pooled && variables != nil {
+		o.pooledVariables = variables
+	}
+}
+
+// releaseRequestOptions returns opts to the pool, they must not be used
+// afterwards. Options the pool did not allocate are left alone
+func releaseRequestOptions(opts *RequestOptions) {
+	if opts == nil || !opts.pooled {
+		return
+	}
+	opts.Reset()
+	requestOptionsPool.Put(opts)
+}
+
+// Reset clears o for reuse, the Variables map is emptied and kept when the
+// pool allocated it, any other map is dropped
+func (o *RequestOptions) Reset() {
+	variables := o.pooledVariables
+	if len(variables) > maxPooledVariables {
+		variables = nil
+	}
+	for k := range variables {
+		delete(variables, k)
+	}
+	*o = RequestOptions{Variables: variables, pooledVariables: variables}
+}
@@ -3,9 +3,14 @@ package handler
 import (
 	"bytes"
 	"fmt"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/graphql-go/graphql/testutil"
@@ -408,3 +413,241 @@ func TestRequestOptions_POST_UnsupportedContentType(t *testing.T) {
 		t.Fatalf("wrong result, graphql result diff: %v", testutil.Diff(expected, result))
 	}
 }
+
+func TestParseRequestOptions_Errors(t *testing.T) {
+	cases := map[string]struct {
+		method      string
+		url         string
+		contentType string
+		body        string
+	}{
+		"invalid JSON body": {
+			method:      http.MethodPost,
+			url:         "/graphql",
+			contentType: "application/json",
+			body:        `INVALIDJSON{}`,
+		},
+		"invalid JSON variables": {
+			method:      http.MethodPost,
+			url:         "/graphql",
+			contentType: "application/json",
+			body:        `{"query": "{ hero { name } }", "variables": "{ broken"}`,
+		},
+		"invalid query string variables": {
+			method: http.MethodGet,
+			url:    "/graphql?query=" + url.QueryEscape("{ hero { name } }") + "&variables=" + url.QueryEscape("[1,2]"),
+		},
+		"invalid multipart body": {
+			method:      http.MethodPost,
+			url:         "/graphql",
+			contentType: "multipart/form-data; boundary=xxx",
+			body:        `not multipart`,
+		},
+	}
+	for tcID, tc := range cases {
+		t.Run(tcID, func(t *testing.T) {
+			req, _ := http.NewRequest(tc.method, tc.url, bytes.NewBufferString(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			opts, err := ParseRequestOptions(req)
+			if err == nil {
+				t.Fatalf("expected error, got %+v", opts)
+			}
+		})
+	}
+}
+
+func TestParseRequestOptions_MultipartOperations(t *testing.T) {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	_ = mw.WriteField("operations", `{"query": "mutation($file: Upload!) { upload(file: $file) }", "variables": {"file": null}}`)
+	_ = mw.WriteField("map", `{"0": ["variables.file"]}`)
+	fw, _ := mw.CreateFormFile("0", "a.txt")
+	_, _ = fw.Write([]byte("content"))
+	_ = mw.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, "/graphql", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	opts, err := ParseRequestOptions(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.Variables["file"] != "file" {
+		t.Fatalf("expected file variable to reference upload, got %v", opts.Variables["file"])
+	}
+	if len(opts.File["file"]) != 1 {
+		t.Fatalf("expected one uploaded file, got %v", opts.File)
+	}
+}
+
+func TestRequestOptions_Extensions(t *testing.T) {
+	persisted := map[string]interface{}{
+		"persistedQuery": map[string]interface{}{
+			"version":    float64(1),
+			"sha256Hash": "abc",
+		},
+	}
+	extensions := `{"persistedQuery": {"version": 1, "sha256Hash": "abc"}}`
+	query := "query RebelsShipsQuery { rebels { name } }"
+
+	jsonReq, _ := http.NewRequest("POST", "/graphql", bytes.NewBufferString(fmt.Sprintf(`{"query": %q, "extensions": %s}`, query, extensions)))
+	jsonReq.Header.Add("Content-Type", "application/json")
+
+	getReq, _ := http.NewRequest("GET", fmt.Sprintf("/graphql?query=%s&extensions=%s", url.QueryEscape(query), url.QueryEscape(extensions)), nil)
+
+	form := url.Values{}
+	form.Add("query", query)
+	form.Add("extensions", extensions)
+	formReq, _ := http.NewRequest("POST", "/graphql", bytes.NewBufferString(form.Encode()))
+	formReq.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	for name, req := range map[string]*http.Request{"json": jsonReq, "get": getReq, "form": formReq} {
+		result, err := ParseRequestOptions(req)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if !reflect.DeepEqual(result.Extensions, persisted) {
+			t.Fatalf("%s: wrong extensions, diff: %v", name, testutil.Diff(persisted, result.Extensions))
+		}
+	}
+}
+
+func TestParseQueryParams(t *testing.T) {
+	for _, rawQuery := range []string{
+		"",
+		"query={hero{name}}",
+		"query=%7Bhero%7Bname%7D%7D&variables=%7B%22id%22%3A1%7D&operationName=Hero",
+		"query=a+b&query=second&op=1&operationName=",
+		"q%75ery=escaped&extensions={}",
+		"query=%zz&query=valid",
+		"query=a;b&query=c",
+		"&&variables&query",
+	} {
+		values, _ := url.ParseQuery(rawQuery)
+		if params := parseQueryParams(rawQuery); params != formParams(values) {
+			t.Fatalf("%q: expected %+v, got %+v", rawQuery, formParams(values), params)
+		}
+	}
+}
+
+func BenchmarkParseRequestOptions_GET(b *testing.B) {
+	req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape("query Hero { hero { name } }")+"&operationName=Hero", nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := parseRequestOptions(req, uploadPolicy{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestRequestOptions_Reset(t *testing.T) {
+	opts, err := getFromJSON([]byte(`{"query":"{hero{name}}","variables":{"id":"1000"},"operationName":"Hero"}`), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	variables := opts.Variables
+	opts.Reset()
+	if len(opts.Variables) != 0 || opts.Query != "" || opts.OperationName != "" {
+		t.Fatalf("unexpected options %+v", opts)
+	}
+	if reflect.ValueOf(opts.Variables).Pointer() != reflect.ValueOf(variables).Pointer() {
+		t.Fatal("expected the pooled variables map to be kept")
+	}
+
+	// a map the pool did not allocate is dropped, not cleared
+	own := map[string]interface{}{"id": "1001"}
+	opts.Variables = own
+	releaseRequestOptions(opts)
+	if !reflect.DeepEqual(own, map[string]interface{}{"id": "1001"}) {
+		t.Fatalf("expected the map to be left alone, got %v", own)
+	}
+	opts = &RequestOptions{Query: "{hero{name}}", Variables: own}
+	releaseRequestOptions(opts)
+	if opts.Query == "" || len(own) != 1 {
+		t.Fatal("expected options the pool did not allocate to be left alone")
+	}
+	opts.Reset()
+	if opts.Variables != nil || len(own) != 1 {
+		t.Fatalf("expected the variables to be dropped, got %v %v", opts.Variables, own)
+	}
+}
+
+func TestParseOperation_Once(t *testing.T) {
+	opts := &RequestOptions{Query: "query A { hero { name } } query B { hero { id } }"}
+	doc, operation := parseOperation(opts)
+	if again, _ := parseOperation(opts); again != doc || operation.Name.Value != "A" {
+		t.Fatal("expected the document to be parsed once")
+	}
+	opts.OperationName = "B"
+	if again, operation := parseOperation(opts); again == doc || operation.Name.Value != "B" {
+		t.Fatal("expected the document to be parsed again for another operation")
+	}
+
+	// the stages of a request share the parsed document
+	parsed := opts.parsed
+	h := New(&Config{
+		Schema:        &testutil.StarWarsSchema,
+		ResponseCache: &ResponseCacheConfig{},
+		MaxAliases:    5,
+	})
+	req, _ := http.NewRequest("GET", "/graphql", nil)
+	resp := httptest.NewRecorder()
+	h.ContextHandlerWithParser(req.Context(), resp, req, func(r *http.Request) (*RequestOptions, error) {
+		return opts, nil
+	})
+	if resp.Code != http.StatusOK || opts.parsed != parsed {
+		t.Fatalf("expected the request to reuse the document, got %d %s", resp.Code, resp.Body)
+	}
+}
+
+func TestParseRequestOptions_NotPooled(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{hero{name}}"}`))
+	req.Header.Set("Content-Type", "application/json")
+	opts, err := New(&Config{Schema: &testutil.StarWarsSchema}).ParseRequestOptions(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.pooled {
+		t.Fatal("expected the options not to be taken from the pool")
+	}
+}
+
+func TestHandler_PoolRequestOptions(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema, PoolRequestOptions: true})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		id := strconv.Itoa(1000 + i%4)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				body := `{"query":"query($id:String!){human(id:$id){id}}","variables":{"id":"` + id + `"}}`
+				req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(body))
+				req.Header.Set("Content-Type", "application/json")
+				resp := httptest.NewRecorder()
+				h.ServeHTTP(resp, req)
+				if expected := `{"data":{"human":{"id":"` + id + `"}}}`; resp.Body.String() != expected {
+					t.Errorf("expected %s, got %s", expected, resp.Body)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkHandler_PoolRequestOptions(b *testing.B) {
+	for _, pool := range []bool{false, true} {
+		b.Run("pool="+strconv.FormatBool(pool), func(b *testing.B) {
+			h := New(&Config{Schema: &testutil.StarWarsSchema, PoolRequestOptions: pool})
+			body := `{"query":"query($id:String!){human(id:$id){id}}","variables":{"id":"1000"}}`
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(body))
+				req.Header.Set("Content-Type", "application/json")
+				h.ServeHTTP(httptest.NewRecorder(), req)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/printer"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// CacheStore keeps serialized responses, a failing store should report a miss
+type CacheStore interface {
+	Get(ctx context.Context, key string) ([]byte, bool)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration)
+}
+
+// ResponseCacheConfig enables caching the responses of queries
+type ResponseCacheConfig struct {
+	// Store keeps the responses, defaults to an in-memory LRU of
+	// DefaultLRUCacheSize entries
+	Store CacheStore
+	// TTL is how long a response is served from the cache, zero keeps it
+	// until the store evicts it
+	TTL time.Duration
+	// SessionKey separates the cache entries of callers seeing different
+	// data, e.g. the authenticated user, nil shares one entry between all
+	SessionKey func(ctx context.Context, r *http.Request) string
+}
+
+// DefaultLRUCacheSize is the capacity of the default response cache store
+const DefaultLRUCacheSize = 1000
+
+// cacheKey returns the cache key of opts under the schema schema identifies, empty when the request must not be
+// cached: mutations, subscriptions and documents that do not parse
+func (c *ResponseCacheConfig) cacheKey(ctx context.Context, r *http.Request, opts *RequestOptions, schema string) string {
+	return queryKey(ctx, r, opts, schema, c.SessionKey)
+}
+
+// queryKey hashes the normalized query of opts with its variables, route
+// parameters and the session sessionKey returns, empty for operations other
+// than queries
+func queryKey(ctx context.Context, r *http.Request, opts *RequestOptions, schema string, sessionKey func(ctx context.Context, r *http.Request) string) string {
+	doc, operation := parseOperation(opts)
+	if operation == nil || operation.Operation != ast.OperationTypeQuery {
+		return ""
+	}
+	// maps are marshaled with sorted keys, equal variables give equal keys
+	variables, err := json.Marshal(opts.Variables)
+	if err != nil {
+		return ""
+	}
+	session := ""
+	if sessionKey != nil {
+		session = sessionKey(ctx, r)
+	}
+	routeParams, err := json.Marshal(RouteParams(ctx))
+	if err != nil {
+		return ""
+	}
+	sum := sha256.New()
+	// responses of other or replaced schemas are never served
+	fmt.Fprintf(sum, "%s\x00%v\x00%s\x00%s\x00%s\x00%s", schema, printer.Print(doc), opts.OperationName, variables, session, routeParams)
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// parsedRequest is the document of the Query of a RequestOptions and the
+// operation its OperationName selects
+type parsedRequest struct {
+	query, operationName string
+	doc                  *ast.Document
+	operation            *ast.OperationDefinition
+	err                  error
+}
+
+// parseRequest parses the document of opts once, the stages of the request
+// share it until its query or operation name is replaced
+func parseRequest(opts *RequestOptions) *parsedRequest {
+	if p := opts.parsed; p != nil && p.query == opts.Query && p.operationName == opts.OperationName {
+		return p
+	}
+	p := &parsedRequest{query: opts.Query, operationName: opts.OperationName}
+	p.doc, p.err = parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(opts.Query),
+		Name: "GraphQL request",
+	})})
+	if p.err == nil {
+		for _, def := range p.doc.Definitions {
+			if def, ok := def.(*ast.OperationDefinition); ok {
+				if opts.OperationName == "" || (def.Name != nil && def.Name.Value == opts.OperationName) {
+					p.operation = def
+					break
+				}
+			}
+		}
+	}
+	opts.parsed = p
+	return p
+}
+
+// parseOperation returns the parsed document of opts and the operation it
+// selects, nil when the document does not parse
+func parseOperation(opts *RequestOptions) (*ast.Document, *ast.OperationDefinition) {
+	p := parseRequest(opts)
+	if p.err != nil {
+		return nil, nil
+	}
+	return p.doc, p.operation
+}
+
+// isQuery reports whether opts selects a query operation
+func isQuery(opts *RequestOptions) bool {
+	_, operation := parseOperation(opts)
+	return operation != nil && operation.Operation == ast.OperationTypeQuery
+}
+
+// LRUCache is an in-memory CacheStore evicting the least recently used entry
+type LRUCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewLRUCache returns an LRUCache holding up to size responses
+func NewLRUCache(size int) *LRUCache {
+	if size <= 0 {
+		size = DefaultLRUCacheSize
+	}
+	return &LRUCache{
+		size:    size,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+// Get implements CacheStore
+func (c *LRUCache) Get(_ context.Context, key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*lruEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry.value, true
+}
+
+// Set implements CacheStore
+func (c *LRUCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) {
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value = &lruEntry{key: key, value: value, expires: expires}
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// Len returns the number of cached responses
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// lruState is an entry of an LRUCache handed over to the next process
+type lruState struct {
+	Key     string    `json:"key"`
+	Value   []byte    `json:"value"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// snapshot returns the entries that did not expire, most recently used first
+func (c *LRUCache) snapshot() []lruState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	entries := make([]lruState, 0, c.order.Len())
+	for el := c.order.Front(); el != nil; el = el.Next() {
+		entry := el.Value.(*lruEntry)
+		if entry.expires.IsZero() || now.Before(entry.expires) {
+			entries = append(entries, lruState{Key: entry.key, Value: entry.value, Expires: entry.expires})
+		}
+	}
+	return entries
+}
+
+// restore adds the entries of a snapshot that did not expire since, the
+// entries already cached are kept
+func (c *LRUCache) restore(entries []lruState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if _, ok := c.entries[entry.Key]; ok || (!entry.Expires.IsZero() && !now.Before(entry.Expires)) {
+			continue
+		}
+		c.entries[entry.Key] = c.order.PushFront(&lruEntry{key: entry.Key, value: entry.Value, expires: entry.Expires})
+		for c.order.Len() > c.size {
+			oldest := c.order.Back()
+			c.order.Remove(oldest)
+			delete(c.entries, oldest.Value.(*lruEntry).key)
+		}
+	}
+}
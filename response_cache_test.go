@@ -0,0 +1,165 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func countingSchema(t *testing.T, calls *int) *graphql.Schema {
+	count := &graphql.Field{
+		Type: graphql.Int,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			*calls++
+			return *calls, nil
+		},
+	}
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query:    graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{"count": count}}),
+		Mutation: graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: graphql.Fields{"count": count}}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &schema
+}
+
+func TestHandler_ResponseCache(t *testing.T) {
+	calls := 0
+	store := handler.NewLRUCache(10)
+	h := handler.New(&handler.Config{
+		Schema: countingSchema(t, &calls),
+		ResponseCache: &handler.ResponseCacheConfig{
+			Store: store,
+			SessionKey: func(ctx context.Context, r *http.Request) string {
+				return r.Header.Get("Authorization")
+			},
+		},
+	})
+	do := func(query, session string) interface{} {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(query))
+		req.Header.Set("Content-Type", "application/graphql")
+		req.Header.Set("Authorization", session)
+		result, _ := executeTest(t, h, req)
+		return result.Data.(map[string]interface{})["count"]
+	}
+
+	if got := do("{count}", "a"); got != float64(1) {
+		t.Fatalf("unexpected count %v", got)
+	}
+	// an equivalent document is served from the cache
+	if got := do("query {\n  count\n}", "a"); got != float64(1) || calls != 1 {
+		t.Fatalf("expected a cache hit, got %v after %d calls", got, calls)
+	}
+	// other sessions get their own entries
+	if got := do("{count}", "b"); got != float64(2) {
+		t.Fatalf("unexpected count %v", got)
+	}
+	// mutations bypass the cache
+	do("mutation {count}", "a")
+	if got := do("mutation {count}", "a"); got != float64(4) || store.Len() != 2 {
+		t.Fatalf("mutation was cached: %v, %d entries", got, store.Len())
+	}
+}
+
+func TestHandler_ResponseCache_Incremental(t *testing.T) {
+	store := handler.NewLRUCache(10)
+	executed := 0
+	h := handler.New(&handler.Config{
+		Schema:              &testutil.StarWarsSchema,
+		ResponseCache:       &handler.ResponseCacheConfig{Store: store},
+		IncrementalDelivery: true,
+		Executor: handler.ExecutorFunc(func(ctx context.Context, params graphql.Params) *graphql.Result {
+			executed++
+			return handler.DefaultExecutor.Do(ctx, params)
+		}),
+	})
+	do := func(query, accept string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":`+mustJSON(query)+`}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", accept)
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("unexpected response %d %s", resp.Code, resp.Body)
+		}
+		return resp
+	}
+	query := `{hero{id ... on Droid @defer {primaryFunction} friends @stream(initialCount: 1) {name}}}`
+
+	// the parts are stored and replayed in order
+	stored := do(query, "multipart/mixed")
+	replayed := do(query, "multipart/mixed")
+	if executed != 1 || store.Len() != 1 {
+		t.Fatalf("executed %d times with %d entries", executed, store.Len())
+	}
+	if replayed.Body.String() != stored.Body.String() || replayed.Header().Get("Content-Type") != stored.Header().Get("Content-Type") {
+		t.Fatalf("unexpected replay %q of %q", replayed.Body, stored.Body)
+	}
+	if parts := strings.Count(replayed.Body.String(), "\r\n---\r\n"); parts != 4 {
+		t.Fatalf("replayed %d parts", parts)
+	}
+
+	// the inline response and other plans have their own entries
+	inline := do(query, "application/json")
+	if do(query, "application/json").Body.String() != inline.Body.String() || !strings.Contains(inline.Body.String(), `"primaryFunction":"Astromech"`) {
+		t.Fatalf("unexpected inline response %s", inline.Body)
+	}
+	do(strings.Replace(query, "initialCount: 1", "initialCount: 2", 1), "multipart/mixed")
+	if executed != 3 || store.Len() != 3 {
+		t.Fatalf("executed %d times with %d entries", executed, store.Len())
+	}
+}
+
+func TestLRUCache_Evicts(t *testing.T) {
+	ctx := context.Background()
+	c := handler.NewLRUCache(2)
+	c.Set(ctx, "a", []byte("1"), 0)
+	c.Set(ctx, "b", []byte("2"), 0)
+	c.Get(ctx, "a")
+	c.Set(ctx, "c", []byte("3"), 0)
+	if _, ok := c.Get(ctx, "b"); ok {
+		t.Fatal("least recently used entry was kept")
+	}
+	if v, ok := c.Get(ctx, "a"); !ok || string(v) != "1" {
+		t.Fatalf("unexpected entry %q", v)
+	}
+	c.Set(ctx, "d", []byte("4"), -1)
+	if c.Len() != 2 {
+		t.Fatalf("unexpected size %d", c.Len())
+	}
+}
+
+func TestHandler_ETag(t *testing.T) {
+	h := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema, ETags: true})
+	req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}", nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	etag := resp.Header().Get("ETag")
+	if resp.Code != http.StatusOK || !strings.HasPrefix(etag, `"`) {
+		t.Fatalf("unexpected response %d with ETag %q", resp.Code, etag)
+	}
+
+	req.Header.Set("If-None-Match", `"other", `+etag)
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Code != http.StatusNotModified || resp.Body.Len() != 0 {
+		t.Fatalf("expected 304 without body, got %d %q", resp.Code, resp.Body.String())
+	}
+
+	// POST requests are not tagged
+	req, _ = http.NewRequest("POST", "/graphql", strings.NewReader("{hero{name}}"))
+	req.Header.Set("Content-Type", "application/graphql")
+	req.Header.Set("If-None-Match", etag)
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK || resp.Header().Get("ETag") != "" {
+		t.Fatalf("unexpected response %d with ETag %q", resp.Code, resp.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/graphql-go/graphql"
+)
+
+// ResultMarshaler encodes the results of the responses no Codec was
+// negotiated for
+type ResultMarshaler interface {
+	// ContentType is the Content-Type header of the encoded results
+	ContentType() string
+	MarshalResult(result *graphql.Result) ([]byte, error)
+}
+
+// JSONResultMarshaler is the ResultMarshaler the handler defaults to
+type JSONResultMarshaler struct {
+	// Pretty indents the results
+	Pretty bool
+	// Indent is the indentation of pretty results, defaults to a space
+	Indent string
+}
+
+// ContentType implements ResultMarshaler
+func (m JSONResultMarshaler) ContentType() string {
+	return "application/json; charset=utf-8"
+}
+
+// MarshalResult implements ResultMarshaler
+func (m JSONResultMarshaler) MarshalResult(result *graphql.Result) ([]byte, error) {
+	if m.Pretty {
+		indent := m.Indent
+		if indent == "" {
+			indent = " "
+		}
+		return json.MarshalIndent(result, "", indent)
+	}
+	return json.Marshal(result)
+}
+
+// prettyOverride returns the ?pretty=1 or ?pretty=0 toggle of r, false
+// when the handler does not honor it or r does not set it
+func (h *Handler) prettyOverride(r *http.Request) (pretty, ok bool) {
+	if !h.prettyParam {
+		return false, false
+	}
+	if _, ok := h.resultMarshaler.(JSONResultMarshaler); !ok {
+		return false, false
+	}
+	pretty, err := strconv.ParseBool(r.URL.Query().Get("pretty"))
+	return pretty, err == nil
+}
+
+// resultMarshalerOf returns the ResultMarshaler of the responses to r
+func (h *Handler) resultMarshalerOf(r *http.Request) ResultMarshaler {
+	if pretty, ok := h.prettyOverride(r); ok {
+		m := h.resultMarshaler.(JSONResultMarshaler)
+		m.Pretty = pretty
+		return m
+	}
+	return h.resultMarshaler
+}
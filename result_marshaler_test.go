@@ -0,0 +1,93 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+// ndjsonMarshaler writes the data and every error on lines of their own
+type ndjsonMarshaler struct{ fail bool }
+
+func (m ndjsonMarshaler) ContentType() string { return "application/x-ndjson" }
+
+func (m ndjsonMarshaler) MarshalResult(result *graphql.Result) ([]byte, error) {
+	if m.fail && result.Data != nil {
+		return nil, errors.New("cannot encode data")
+	}
+	buff := &bytes.Buffer{}
+	enc := json.NewEncoder(buff)
+	if result.Data != nil {
+		_ = enc.Encode(result.Data)
+	}
+	for _, err := range result.Errors {
+		_ = enc.Encode(err)
+	}
+	return buff.Bytes(), nil
+}
+
+func TestHandler_ResultMarshaler(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:          &testutil.StarWarsSchema,
+		ResultMarshaler: ndjsonMarshaler{},
+	})
+	req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}", nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if ct := resp.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	if body := resp.Body.String(); body != "{\"hero\":{\"name\":\"R2-D2\"}}\n" {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	// a failing marshaler reports its error through itself
+	h = handler.New(&handler.Config{
+		Schema:          &testutil.StarWarsSchema,
+		ResultMarshaler: ndjsonMarshaler{fail: true},
+	})
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if body := resp.Body.String(); body != "{\"message\":\"cannot encode data\",\"locations\":[]}\n" {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
+
+func TestJSONResultMarshaler(t *testing.T) {
+	result := &graphql.Result{Data: map[string]interface{}{"a": 1}}
+	buff, _ := handler.JSONResultMarshaler{Pretty: true}.MarshalResult(result)
+	if string(buff) != "{\n \"data\": {\n  \"a\": 1\n }\n}" {
+		t.Fatalf("unexpected body %q", buff)
+	}
+}
+
+func TestHandler_PrettyParam(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:        &testutil.StarWarsSchema,
+		PrettyIndent:  "\t",
+		PrettyParam:   true,
+		ResponseCache: &handler.ResponseCacheConfig{},
+	})
+	for query, expected := range map[string]string{
+		"":          `{"data":{"hero":{"name":"R2-D2"}}}`,
+		"&pretty=1": "{\n\t\"data\": {\n\t\t\"hero\": {\n\t\t\t\"name\": \"R2-D2\"\n\t\t}\n\t}\n}",
+		"&pretty=0": `{"data":{"hero":{"name":"R2-D2"}}}`,
+	} {
+		// the second round is served from the cache
+		for i := 0; i < 2; i++ {
+			req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}"+query, nil)
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, req)
+			if resp.Body.String() != expected {
+				t.Fatalf("%q: unexpected body %q", query, resp.Body)
+			}
+		}
+	}
+}
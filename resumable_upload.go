@@ -0,0 +1,505 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultResumableUploadPath is the URL prefix resumable uploads are served
+// under
+const DefaultResumableUploadPath = "/__graphql/uploads/"
+
+// tusVersion is the tus protocol version resumable uploads speak
+const tusVersion = "1.0.0"
+
+// ResumableUploads accepts files in chunks over the tus resumable upload
+// protocol, with the creation, expiration and termination extensions, for
+// clients on unreliable networks. A completed upload is passed to
+// operations by sending its id, the last segment of its Location, as the
+// value of an Upload variable. An upload is passed to one operation, it is
+// removed once the operation completes
+type ResumableUploads struct {
+	// Dir holds the partial and completed uploads
+	Dir string
+	// Path is the URL prefix of the endpoint, DefaultResumableUploadPath by
+	// default
+	Path string
+	// MaxSize bounds the length of an upload, it is required
+	MaxSize int64
+	// Expiry removes the uploads created longer ago, zero keeps them until
+	// they are terminated
+	Expiry time.Duration
+
+	mu        sync.Mutex
+	lastSweep time.Time
+	// patching are the uploads a PATCH request is appending to
+	patching map[string]bool
+	// claimed are the uploads passed to a running operation
+	claimed map[string]bool
+}
+
+// resumableUpload is the info file of an upload, its content is appended
+// to the data file next to it
+type resumableUpload struct {
+	Length      int64     `json:"length"`
+	Filename    string    `json:"filename,omitempty"`
+	ContentType string    `json:"contentType,omitempty"`
+	Created     time.Time `json:"created"`
+}
+
+// NewResumableUploads returns the endpoint keeping uploads of up to maxSize
+// bytes in dir
+func NewResumableUploads(dir string, maxSize int64) *ResumableUploads {
+	return &ResumableUploads{Dir: dir, MaxSize: maxSize}
+}
+
+// base returns Path without its trailing slash
+func (u *ResumableUploads) base() string {
+	if u.Path == "" {
+		return strings.TrimSuffix(DefaultResumableUploadPath, "/")
+	}
+	return strings.TrimSuffix(u.Path, "/")
+}
+
+// serves reports whether the endpoint serves the URL path p
+func (u *ResumableUploads) serves(p string) bool {
+	base := u.base()
+	return p == base || strings.HasPrefix(p, base+"/")
+}
+
+// files returns the info and data file of id, ok is false for ids that
+// were never handed out
+func (u *ResumableUploads) files(id string) (info, data string, ok bool) {
+	// ids come from clients, only ours make it into paths
+	if _, err := hex.DecodeString(id); err != nil || len(id) != 32 {
+		return "", "", false
+	}
+	data = filepath.Join(u.Dir, id)
+	return data + ".json", data, true
+}
+
+// load returns the upload id and the length of its content so far
+func (u *ResumableUploads) load(id string) (*resumableUpload, int64, error) {
+	info, data, ok := u.files(id)
+	if !ok {
+		return nil, 0, os.ErrNotExist
+	}
+	buff, err := ioutil.ReadFile(info)
+	if err != nil {
+		return nil, 0, err
+	}
+	var upload resumableUpload
+	if err := json.Unmarshal(buff, &upload); err != nil {
+		return nil, 0, err
+	}
+	if u.Expiry > 0 && time.Since(upload.Created) > u.Expiry {
+		return nil, 0, os.ErrNotExist
+	}
+	stat, err := os.Stat(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &upload, stat.Size(), nil
+}
+
+// File returns the completed upload id as the value of an Upload variable
+func (u *ResumableUploads) File(id string) (*UploadFile, error) {
+	upload, offset, err := u.load(id)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("unknown upload %q", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if offset < upload.Length {
+		return nil, fmt.Errorf("upload %q is incomplete, %d of %d bytes received", id, offset, upload.Length)
+	}
+	_, data, _ := u.files(id)
+	return &UploadFile{
+		Filename:    upload.Filename,
+		ContentType: upload.ContentType,
+		Size:        upload.Length,
+		Ref: &UploadRef{
+			Filename:    upload.Filename,
+			ContentType: upload.ContentType,
+			Size:        upload.Length,
+			Location:    data,
+		},
+		open: func() (io.ReadCloser, error) { return os.Open(data) },
+	}, nil
+}
+
+// ServeHTTP implements the tus protocol below Path
+func (u *ResumableUploads) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	header := w.Header()
+	header.Set("Tus-Resumable", tusVersion)
+	header.Set("Cache-Control", "no-store")
+	if r.Method == http.MethodOptions {
+		header.Set("Tus-Version", tusVersion)
+		header.Set("Tus-Extension", "creation,expiration,termination")
+		if u.MaxSize > 0 {
+			header.Set("Tus-Max-Size", strconv.FormatInt(u.MaxSize, 10))
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		header.Set("Tus-Version", tusVersion)
+		http.Error(w, "unsupported tus version", http.StatusPreconditionFailed)
+		return
+	}
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, u.base()), "/")
+	switch {
+	case id == "" && r.Method == http.MethodPost:
+		u.create(w, r)
+	case id != "" && !strings.Contains(id, "/") && r.Method == http.MethodHead:
+		u.head(w, id)
+	case id != "" && !strings.Contains(id, "/") && r.Method == http.MethodPatch:
+		u.patch(w, r, id)
+	case id != "" && !strings.Contains(id, "/") && r.Method == http.MethodDelete:
+		u.terminate(w, id)
+	default:
+		header.Set("Allow", "POST, HEAD, PATCH, DELETE, OPTIONS")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (u *ResumableUploads) create(w http.ResponseWriter, r *http.Request) {
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		http.Error(w, "invalid Upload-Length", http.StatusBadRequest)
+		return
+	}
+	if u.MaxSize <= 0 {
+		http.Error(w, "resumable uploads without a MaxSize", http.StatusInternalServerError)
+		return
+	}
+	if length > u.MaxSize {
+		http.Error(w, "upload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	u.sweep()
+	metadata := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+	upload := resumableUpload{
+		Length:      length,
+		Filename:    metadata["filename"],
+		ContentType: metadata["filetype"],
+		Created:     time.Now(),
+	}
+	name := make([]byte, 16)
+	if _, err := rand.Read(name); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	id := hex.EncodeToString(name)
+	info, data, _ := u.files(id)
+	buff, _ := json.Marshal(upload)
+	if err := ioutil.WriteFile(data, nil, 0600); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// the info file is written last, uploads without one do not exist
+	if err := ioutil.WriteFile(info, buff, 0600); err != nil {
+		_ = os.Remove(data)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", u.base()+"/"+id)
+	if u.Expiry > 0 {
+		w.Header().Set("Upload-Expires", upload.Created.Add(u.Expiry).UTC().Format(http.TimeFormat))
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (u *ResumableUploads) head(w http.ResponseWriter, id string) {
+	upload, offset, err := u.load(id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (u *ResumableUploads) patch(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		http.Error(w, "expected application/offset+octet-stream", http.StatusUnsupportedMediaType)
+		return
+	}
+	if !u.lock(id) {
+		http.Error(w, "upload is being appended to", http.StatusConflict)
+		return
+	}
+	defer u.unlock(id)
+	upload, offset, err := u.load(id)
+	if err != nil {
+		http.Error(w, "unknown upload", http.StatusNotFound)
+		return
+	}
+	if r.Header.Get("Upload-Offset") != strconv.FormatInt(offset, 10) {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+		http.Error(w, "Upload-Offset does not match", http.StatusConflict)
+		return
+	}
+	_, data, _ := u.files(id)
+	f, err := os.OpenFile(data, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// what arrived before the connection dropped is kept, the client
+	// resumes from there
+	n, err := io.Copy(f, io.LimitReader(r.Body, upload.Length-offset))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset+n, 10))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if u.Expiry > 0 {
+		w.Header().Set("Upload-Expires", upload.Created.Add(u.Expiry).UTC().Format(http.TimeFormat))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (u *ResumableUploads) terminate(w http.ResponseWriter, id string) {
+	if _, _, err := u.load(id); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	u.remove(id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (u *ResumableUploads) remove(id string) {
+	info, data, _ := u.files(id)
+	_ = os.Remove(info)
+	_ = os.Remove(data)
+}
+
+// claim returns the completed upload id for an operation, an upload is
+// claimed by one operation at a time
+func (u *ResumableUploads) claim(id string) (*UploadFile, error) {
+	u.mu.Lock()
+	if u.claimed[id] {
+		u.mu.Unlock()
+		return nil, fmt.Errorf("upload %q is used by another operation", id)
+	}
+	if u.claimed == nil {
+		u.claimed = map[string]bool{}
+	}
+	u.claimed[id] = true
+	u.mu.Unlock()
+	file, err := u.File(id)
+	if err != nil {
+		u.unclaim(id)
+	}
+	return file, err
+}
+
+func (u *ResumableUploads) unclaim(ids ...string) {
+	u.mu.Lock()
+	for _, id := range ids {
+		delete(u.claimed, id)
+	}
+	u.mu.Unlock()
+}
+
+// release removes the uploads an operation used
+func (u *ResumableUploads) release(ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+	for _, id := range ids {
+		u.remove(id)
+	}
+	u.unclaim(ids...)
+}
+
+func (u *ResumableUploads) lock(id string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.patching[id] {
+		return false
+	}
+	if u.patching == nil {
+		u.patching = map[string]bool{}
+	}
+	u.patching[id] = true
+	return true
+}
+
+func (u *ResumableUploads) unlock(id string) {
+	u.mu.Lock()
+	delete(u.patching, id)
+	u.mu.Unlock()
+}
+
+// sweep removes the expired uploads, at most once a minute
+func (u *ResumableUploads) sweep() {
+	if u.Expiry <= 0 {
+		return
+	}
+	u.mu.Lock()
+	if time.Since(u.lastSweep) < time.Minute {
+		u.mu.Unlock()
+		return
+	}
+	u.lastSweep = time.Now()
+	u.mu.Unlock()
+	infos, _ := filepath.Glob(filepath.Join(u.Dir, "*.json"))
+	for _, info := range infos {
+		id := strings.TrimSuffix(filepath.Base(info), ".json")
+		if _, _, err := u.load(id); os.IsNotExist(err) {
+			u.remove(id)
+		}
+	}
+}
+
+// parseUploadMetadata decodes the comma separated "key base64(value)"
+// pairs of an Upload-Metadata header
+func parseUploadMetadata(header string) map[string]string {
+	metadata := map[string]string{}
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		if len(fields) == 0 {
+			continue
+		}
+		value := ""
+		if len(fields) > 1 {
+			decoded, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				continue
+			}
+			value = string(decoded)
+		}
+		metadata[fields[0]] = value
+	}
+	return metadata
+}
+
+// serveResumableUpload serves the requests of the resumable upload
+// endpoint, after ContextFn, the RateLimiter and AuthFn accepted them
+func (h *Handler) serveResumableUpload(ctx context.Context, w http.ResponseWriter, r *http.Request) bool {
+	if h.resumableUploads == nil || !h.resumableUploads.serves(r.URL.Path) {
+		return false
+	}
+	if r.Method != http.MethodOptions {
+		if h.contextFn != nil {
+			fnCtx, err := h.contextFn(ctx, r)
+			if err != nil {
+				h.rejectRequest(ctx, w, r, err)
+				return true
+			}
+			if fnCtx != nil {
+				ctx = fnCtx
+			}
+		}
+		// the hooks see the endpoint requests without an operation
+		opts := &RequestOptions{}
+		if h.rateLimiter != nil && h.limitRequest(ctx, w, r, h.rateLimiter, opts) {
+			return true
+		}
+		if h.authFn != nil {
+			authCtx, err := h.authFn(ctx, r, opts)
+			if err != nil {
+				h.rejectRequest(ctx, w, r, err)
+				return true
+			}
+			if authCtx != nil {
+				ctx = authCtx
+			}
+		}
+	}
+	h.resumableUploads.ServeHTTP(w, r.WithContext(ctx))
+	return true
+}
+
+// mapResumableUploads replaces the ids of completed resumable uploads
+// passed to Upload variables by their *UploadFile, it returns the ids of
+// the claimed uploads
+func (h *Handler) mapResumableUploads(opts *RequestOptions) ([]string, error) {
+	if h.resumableUploads == nil || !hasStringValue(opts.Variables) {
+		return nil, nil
+	}
+	_, operation := parseOperation(opts)
+	if operation == nil {
+		return nil, nil
+	}
+	var claimed []string
+	claim := func(id string) (*UploadFile, error) {
+		for _, c := range claimed {
+			if c == id {
+				return h.resumableUploads.File(id)
+			}
+		}
+		file, err := h.resumableUploads.claim(id)
+		if err != nil {
+			h.resumableUploads.unclaim(claimed...)
+			return nil, err
+		}
+		claimed = append(claimed, id)
+		return file, nil
+	}
+	for _, def := range operation.VariableDefinitions {
+		if def.Variable == nil || def.Variable.Name == nil || !isUploadType(def.Type) {
+			continue
+		}
+		name := def.Variable.Name.Value
+		switch value := opts.Variables[name].(type) {
+		case string:
+			file, err := claim(value)
+			if err != nil {
+				return nil, err
+			}
+			opts.Variables[name] = file
+		case []interface{}:
+			files := make([]interface{}, len(value))
+			for i, item := range value {
+				files[i] = item
+				if id, ok := item.(string); ok {
+					file, err := claim(id)
+					if err != nil {
+						return nil, err
+					}
+					files[i] = file
+				}
+			}
+			opts.Variables[name] = files
+		}
+	}
+	return claimed, nil
+}
+
+// hasStringValue reports whether a variable is, or lists, a string
+func hasStringValue(variables map[string]interface{}) bool {
+	for _, value := range variables {
+		switch value := value.(type) {
+		case string:
+			return true
+		case []interface{}:
+			for _, item := range value {
+				if _, ok := item.(string); ok {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
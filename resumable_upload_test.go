@@ -0,0 +1,168 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+)
+
+func attachmentSchema(t *testing.T) *graphql.Schema {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"ok": &graphql.Field{Type: graphql.Boolean},
+		}}),
+		Mutation: graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: graphql.Fields{
+			"attach": &graphql.Field{
+				Type: graphql.String,
+				Args: graphql.FieldConfigArgument{
+					"file": &graphql.ArgumentConfig{Type: graphql.NewNonNull(handler.Upload)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					file := p.Args["file"].(*handler.UploadFile)
+					r, err := file.Open()
+					if err != nil {
+						return nil, err
+					}
+					defer r.Close()
+					content, err := ioutil.ReadAll(r)
+					return file.Filename + ":" + string(content), err
+				},
+			},
+		}}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &schema
+}
+
+func TestHandler_ResumableUploads(t *testing.T) {
+	dir := t.TempDir()
+	h := handler.New(&handler.Config{
+		Schema:           attachmentSchema(t),
+		ResumableUploads: &handler.ResumableUploads{Dir: dir, MaxSize: 100},
+	})
+	tus := func(method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Tus-Resumable", "1.0.0")
+		for name, value := range header {
+			req.Header.Set(name, value)
+		}
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+	attach := func(id string) *graphql.Result {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(
+			`{"query":"mutation($file: Upload!) { attach(file: $file) }","variables":{"file":"`+id+`"}}`))
+		req.Header.Set("Content-Type", "application/json")
+		result, _ := executeTest(t, h, req)
+		return result
+	}
+
+	resp := tus("POST", handler.DefaultResumableUploadPath, "", map[string]string{
+		"Upload-Length":   "11",
+		"Upload-Metadata": "filename " + base64.StdEncoding.EncodeToString([]byte("a.txt")),
+	})
+	location := resp.Header().Get("Location")
+	if resp.Code != http.StatusCreated || !strings.HasPrefix(location, handler.DefaultResumableUploadPath) {
+		t.Fatalf("unexpected creation %d %v", resp.Code, resp.Header())
+	}
+	id := location[strings.LastIndex(location, "/")+1:]
+
+	chunk := map[string]string{"Content-Type": "application/offset+octet-stream", "Upload-Offset": "0"}
+	if resp := tus("PATCH", location, "hello ", chunk); resp.Code != http.StatusNoContent || resp.Header().Get("Upload-Offset") != "6" {
+		t.Fatalf("unexpected PATCH response %d %v", resp.Code, resp.Header())
+	}
+	if result := attach(id); len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, "incomplete") {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	// the client lost track of the offset, it asks for it and resumes
+	if resp := tus("PATCH", location, "world", chunk); resp.Code != http.StatusConflict {
+		t.Fatalf("unexpected PATCH response %d %v", resp.Code, resp.Header())
+	}
+	resp = tus("HEAD", location, "", nil)
+	if resp.Code != http.StatusOK || resp.Header().Get("Upload-Offset") != "6" || resp.Header().Get("Upload-Length") != "11" {
+		t.Fatalf("unexpected HEAD response %d %v", resp.Code, resp.Header())
+	}
+	chunk["Upload-Offset"] = "6"
+	if resp := tus("PATCH", location, "world", chunk); resp.Code != http.StatusNoContent || resp.Header().Get("Upload-Offset") != "11" {
+		t.Fatalf("unexpected PATCH response %d %v", resp.Code, resp.Header())
+	}
+
+	result := attach(id)
+	if len(result.Errors) > 0 || result.Data.(map[string]interface{})["attach"] != "a.txt:hello world" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if result := attach("../" + id); len(result.Errors) != 1 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	// the upload was removed once the operation used it
+	if result := attach(id); len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, "unknown upload") {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) != 0 {
+		t.Fatalf("used upload left %q", files)
+	}
+
+	if resp := tus("POST", handler.DefaultResumableUploadPath, "", map[string]string{"Upload-Length": "101"}); resp.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("unexpected creation %d", resp.Code)
+	}
+	location = tus("POST", handler.DefaultResumableUploadPath, "", map[string]string{"Upload-Length": "1"}).Header().Get("Location")
+	if resp := tus("DELETE", location, "", nil); resp.Code != http.StatusNoContent {
+		t.Fatalf("unexpected DELETE response %d", resp.Code)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) != 0 {
+		t.Fatalf("terminated upload left %q", files)
+	}
+}
+
+func TestHandler_ResumableUploads_Hooks(t *testing.T) {
+	if _, err := handler.NewWithError(&handler.Config{
+		Schema:           attachmentSchema(t),
+		ResumableUploads: &handler.ResumableUploads{Dir: t.TempDir()},
+	}); err == nil || !strings.Contains(err.Error(), "ResumableUploads.MaxSize is not set") {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	h := handler.New(&handler.Config{
+		Schema:           attachmentSchema(t),
+		ResumableUploads: &handler.ResumableUploads{Dir: t.TempDir(), MaxSize: 100},
+		RateLimiter:      &handler.IPRateLimiter{Rate: 0.001, Burst: 2},
+		AuthFn: func(ctx context.Context, r *http.Request, opts *handler.RequestOptions) (context.Context, error) {
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				return nil, errors.New("missing token")
+			}
+			return ctx, nil
+		},
+	})
+	create := func(token string) int {
+		req, _ := http.NewRequest("POST", handler.DefaultResumableUploadPath, nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("Tus-Resumable", "1.0.0")
+		req.Header.Set("Upload-Length", "1")
+		req.Header.Set("Authorization", token)
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp.Code
+	}
+	if status := create(""); status != http.StatusUnauthorized {
+		t.Fatalf("expected AuthFn to reject the upload, got %d", status)
+	}
+	if status := create("Bearer secret"); status != http.StatusCreated {
+		t.Fatalf("unexpected status %d", status)
+	}
+	if status := create("Bearer secret"); status != http.StatusTooManyRequests {
+		t.Fatalf("expected the rate limiter to reject the upload, got %d", status)
+	}
+}
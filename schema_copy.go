@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"reflect"
+	"strings"
+	"unsafe"
+
+	"github.com/graphql-go/graphql"
+)
+
+// schemaCopy rebuilds the object, interface and union types of a schema
+// with the resolvers resolve returns, the types of the original schema are
+// left as they are. Scalars, enums and input objects are shared
+type schemaCopy struct {
+	types   map[string]graphql.Type
+	resolve func(coordinate string, resolve graphql.FieldResolveFn) graphql.FieldResolveFn
+}
+
+// copySchema returns a copy of schema whose field resolvers are replaced by
+// what resolve returns for their coordinates and resolvers
+func copySchema(schema *graphql.Schema, resolve func(coordinate string, resolve graphql.FieldResolveFn) graphql.FieldResolveFn) (*graphql.Schema, error) {
+	c := &schemaCopy{types: map[string]graphql.Type{}, resolve: resolve}
+	config := graphql.SchemaConfig{
+		Directives: schema.Directives(),
+		Extensions: schemaExtensions(schema),
+	}
+	if t := schema.QueryType(); t != nil {
+		config.Query = c.copyType(t).(*graphql.Object)
+	}
+	if t := schema.MutationType(); t != nil {
+		config.Mutation = c.copyType(t).(*graphql.Object)
+	}
+	if t := schema.SubscriptionType(); t != nil {
+		config.Subscription = c.copyType(t).(*graphql.Object)
+	}
+	for name, t := range schema.TypeMap() {
+		if !strings.HasPrefix(name, "__") {
+			config.Types = append(config.Types, c.copyType(t))
+		}
+	}
+	copied, err := graphql.NewSchema(config)
+	if err != nil {
+		return nil, err
+	}
+	return &copied, nil
+}
+
+// schemaExtensions returns the extensions added to schema, graphql-go does
+// not export them
+func schemaExtensions(schema *graphql.Schema) []graphql.Extension {
+	field := reflect.ValueOf(schema).Elem().FieldByName("extensions")
+	if !field.IsValid() {
+		return nil
+	}
+	extensions := *(*[]graphql.Extension)(unsafe.Pointer(field.UnsafeAddr()))
+	return append([]graphql.Extension(nil), extensions...)
+}
+
+func (c *schemaCopy) copyType(t graphql.Type) graphql.Type {
+	switch t := t.(type) {
+	case *graphql.NonNull:
+		return graphql.NewNonNull(c.copyType(t.OfType))
+	case *graphql.List:
+		return graphql.NewList(c.copyType(t.OfType))
+	}
+	name := t.Name()
+	if copied, ok := c.types[name]; ok {
+		return copied
+	}
+	switch t := t.(type) {
+	case *graphql.Object:
+		c.types[name] = graphql.NewObject(graphql.ObjectConfig{
+			Name:        name,
+			Description: t.Description(),
+			IsTypeOf:    t.IsTypeOf,
+			Fields:      c.fieldsThunk(name, t.Fields),
+			Interfaces: graphql.InterfacesThunk(func() []*graphql.Interface {
+				interfaces := make([]*graphql.Interface, len(t.Interfaces()))
+				for i, iface := range t.Interfaces() {
+					interfaces[i] = c.copyType(iface).(*graphql.Interface)
+				}
+				return interfaces
+			}),
+		})
+	case *graphql.Interface:
+		c.types[name] = graphql.NewInterface(graphql.InterfaceConfig{
+			Name:        name,
+			Description: t.Description(),
+			Fields:      c.fieldsThunk(name, t.Fields),
+			ResolveType: c.resolveType(t.ResolveType),
+		})
+	case *graphql.Union:
+		possible := make([]*graphql.Object, len(t.Types()))
+		for i, object := range t.Types() {
+			possible[i] = c.copyType(object).(*graphql.Object)
+		}
+		c.types[name] = graphql.NewUnion(graphql.UnionConfig{
+			Name:        name,
+			Description: t.Description(),
+			Types:       possible,
+			ResolveType: c.resolveType(t.ResolveType),
+		})
+	default:
+		c.types[name] = t
+	}
+	return c.types[name]
+}
+
+func (c *schemaCopy) fieldsThunk(typeName string, defs func() graphql.FieldDefinitionMap) graphql.FieldsThunk {
+	return func() graphql.Fields {
+		fields := graphql.Fields{}
+		for name, def := range defs() {
+			args := graphql.FieldConfigArgument{}
+			for _, arg := range def.Args {
+				args[arg.Name()] = &graphql.ArgumentConfig{
+					Type:         arg.Type,
+					DefaultValue: arg.DefaultValue,
+					Description:  arg.Description(),
+				}
+			}
+			fields[name] = &graphql.Field{
+				Type:              c.copyType(def.Type).(graphql.Output),
+				Args:              args,
+				Resolve:           c.resolve(typeName+"."+name, def.Resolve),
+				Description:       def.Description,
+				DeprecationReason: def.DeprecationReason,
+			}
+		}
+		return fields
+	}
+}
+
+// resolveType returns the types of the copy in place of those resolveType
+// returns
+func (c *schemaCopy) resolveType(resolveType graphql.ResolveTypeFn) graphql.ResolveTypeFn {
+	if resolveType == nil {
+		return nil
+	}
+	return func(p graphql.ResolveTypeParams) *graphql.Object {
+		object := resolveType(p)
+		if object == nil {
+			return nil
+		}
+		copied, _ := c.types[object.Name()].(*graphql.Object)
+		return copied
+	}
+}
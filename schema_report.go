@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+)
+
+// SchemaRisk is the kind of a SchemaFinding
+type SchemaRisk string
+
+const (
+	// SchemaRiskUnboundedList is a list field without an argument limiting its length
+	SchemaRiskUnboundedList SchemaRisk = "UNBOUNDED_LIST"
+	// SchemaRiskRecursion is a field closing a cycle of types, queries
+	// can nest it arbitrarily deep
+	SchemaRiskRecursion SchemaRisk = "RECURSION"
+)
+
+// SchemaFinding is one risk hotspot of a schema
+type SchemaFinding struct {
+	Risk SchemaRisk `json:"risk"`
+	// Coordinate is the "Type.field" the finding is about
+	Coordinate string `json:"coordinate"`
+	Message    string `json:"message"`
+}
+
+// SchemaReport lists the risk hotspots of a schema, see AnalyzeSchema
+type SchemaReport struct {
+	Findings []SchemaFinding `json:"findings"`
+}
+
+// limitArguments are the argument names taken to bound a list field
+var limitArguments = map[string]bool{
+	"first":    true,
+	"last":     true,
+	"limit":    true,
+	"take":     true,
+	"top":      true,
+	"pageSize": true,
+	"perPage":  true,
+}
+
+// schemaField is a field of an object or interface type
+type schemaField struct {
+	coordinate string
+	typ        graphql.Type
+	args       []*graphql.Argument
+}
+
+// compositeFields returns the fields of object and interface types, keyed by
+// type name, and the possible types of interfaces and unions
+func compositeFields(schema *graphql.Schema) (map[string][]schemaField, map[string][]string) {
+	fields := map[string][]schemaField{}
+	edges := map[string][]string{}
+	for name, t := range schema.TypeMap() {
+		if strings.HasPrefix(name, "__") {
+			continue
+		}
+		var defs graphql.FieldDefinitionMap
+		switch t := t.(type) {
+		case *graphql.Object:
+			defs = t.Fields()
+		case *graphql.Interface:
+			defs = t.Fields()
+			// fragments select the fields of the implementations
+			for _, possible := range schema.PossibleTypes(t) {
+				edges[name] = append(edges[name], possible.Name())
+			}
+		case *graphql.Union:
+			for _, possible := range t.Types() {
+				edges[name] = append(edges[name], possible.Name())
+			}
+			continue
+		default:
+			continue
+		}
+		for fieldName, def := range defs {
+			fields[name] = append(fields[name], schemaField{
+				coordinate: name + "." + fieldName,
+				typ:        def.Type,
+				args:       def.Args,
+			})
+		}
+		sort.Slice(fields[name], func(i, j int) bool {
+			return fields[name][i].coordinate < fields[name][j].coordinate
+		})
+	}
+	return fields, edges
+}
+
+// isListType reports whether t is a list, possibly non-null
+func isListType(t graphql.Type) bool {
+	if nonNull, ok := t.(*graphql.NonNull); ok {
+		t = nonNull.OfType
+	}
+	_, ok := t.(*graphql.List)
+	return ok
+}
+
+// namedType unwraps the lists and non-nulls around t
+func namedType(t graphql.Type) graphql.Type {
+	for {
+		switch wrapped := t.(type) {
+		case *graphql.List:
+			t = wrapped.OfType
+		case *graphql.NonNull:
+			t = wrapped.OfType
+		default:
+			return t
+		}
+	}
+}
+
+// isCompositeType reports whether t has fields or possible types
+func isCompositeType(t graphql.Type) bool {
+	switch t.(type) {
+	case *graphql.Object, *graphql.Interface, *graphql.Union:
+		return true
+	}
+	return false
+}
+
+// AnalyzeSchema reports the fields of schema that let clients request
+// unbounded amounts of work: lists without a limiting argument and fields
+// of recursive types
+func AnalyzeSchema(schema *graphql.Schema) *SchemaReport {
+	fields, edges := compositeFields(schema)
+	for name, defs := range fields {
+		for _, f := range defs {
+			if target := namedType(f.typ); isCompositeType(target) {
+				edges[name] = append(edges[name], target.Name())
+			}
+		}
+	}
+	reaches := func(from, to string) bool {
+		seen := map[string]bool{}
+		stack := []string{from}
+		for len(stack) > 0 {
+			name := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, next := range edges[name] {
+				if next == to {
+					return true
+				}
+				if !seen[next] {
+					seen[next] = true
+					stack = append(stack, next)
+				}
+			}
+		}
+		return false
+	}
+
+	report := &SchemaReport{Findings: []SchemaFinding{}}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, f := range fields[name] {
+			if isListType(f.typ) {
+				bounded := false
+				for _, arg := range f.args {
+					bounded = bounded || limitArguments[arg.Name()]
+				}
+				if !bounded {
+					report.Findings = append(report.Findings, SchemaFinding{
+						Risk:       SchemaRiskUnboundedList,
+						Coordinate: f.coordinate,
+						Message:    fmt.Sprintf("%s returns %s without a limiting argument", f.coordinate, f.typ),
+					})
+				}
+			}
+			target := namedType(f.typ)
+			if isCompositeType(target) && (target.Name() == name || reaches(target.Name(), name)) {
+				report.Findings = append(report.Findings, SchemaFinding{
+					Risk:       SchemaRiskRecursion,
+					Coordinate: f.coordinate,
+					Message:    fmt.Sprintf("%s leads from %s back to %s, selections can nest without bound", f.coordinate, name, name),
+				})
+			}
+		}
+	}
+	return report
+}
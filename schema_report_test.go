@@ -0,0 +1,39 @@
+package handler_test
+
+import (
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestAnalyzeSchema(t *testing.T) {
+	var report *handler.SchemaReport
+	handler.New(&handler.Config{
+		Schema:         &testutil.StarWarsSchema,
+		SchemaReportFn: func(r *handler.SchemaReport) { report = r },
+	})
+	if report == nil {
+		t.Fatal("SchemaReportFn was not called")
+	}
+	found := map[handler.SchemaRisk]map[string]bool{}
+	for _, f := range report.Findings {
+		if found[f.Risk] == nil {
+			found[f.Risk] = map[string]bool{}
+		}
+		found[f.Risk][f.Coordinate] = true
+	}
+	for _, coordinate := range []string{"Character.friends", "Human.friends", "Droid.friends", "Human.appearsIn"} {
+		if !found[handler.SchemaRiskUnboundedList][coordinate] {
+			t.Errorf("%s not reported as unbounded list", coordinate)
+		}
+	}
+	for _, coordinate := range []string{"Character.friends", "Droid.friends"} {
+		if !found[handler.SchemaRiskRecursion][coordinate] {
+			t.Errorf("%s not reported as recursive", coordinate)
+		}
+	}
+	if found[handler.SchemaRiskRecursion]["Query.hero"] {
+		t.Error("Query.hero reported as recursive")
+	}
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+)
+
+// schemaVersion is the schema requests execute against, generation tells
+// cached responses of replaced schemas apart
+type schemaVersion struct {
+	schema *graphql.Schema
+	// named are the schemas SchemaSelectorFn picks from
+	named      map[string]*graphql.Schema
+	generation uint64
+}
+
+// selectSchema returns the schema r executes against and its name, empty
+// for the default schema
+func (h *Handler) selectSchema(ctx context.Context, r *http.Request, version schemaVersion) (*graphql.Schema, string, error) {
+	name := ""
+	if h.schemaSelectorFn != nil {
+		name = h.schemaSelectorFn(ctx, r)
+	}
+	if name == "" {
+		if version.schema == nil {
+			return nil, "", fmt.Errorf("no schema selected")
+		}
+		return version.schema, "", nil
+	}
+	schema, ok := version.named[name]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown schema %q", name)
+	}
+	return schema, name, nil
+}
+
+// prepareSchema reports and extends schema as configured. The degraded and
+// bounded fields resolve through a copy of schema, the resolvers of the
+// caller's schema are left alone
+func (h *Handler) prepareSchema(schema *graphql.Schema) *graphql.Schema {
+	if h.schemaReportFn != nil {
+		h.schemaReportFn(AnalyzeSchema(schema))
+	}
+	if h.degrader != nil || len(h.fieldTimeouts) > 0 {
+		copied, err := copySchema(schema, func(coordinate string, resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+			if h.degrader != nil {
+				resolve = h.degrader.wrap(coordinate, resolve)
+			}
+			if len(h.fieldTimeouts) > 0 {
+				resolve = fieldTimeoutResolver(coordinate, resolve, h.fieldTimeouts)
+			}
+			return resolve
+		})
+		if err != nil {
+			h.logger.ErrorContext(context.Background(), "graphql schema not degraded or bounded", "error", err)
+		} else {
+			schema = copied
+		}
+	}
+	if h.cacheControl != nil {
+		extended := *schema
+		extended.AddExtensions(newCacheControlExtension(h.cacheControl))
+		schema = &extended
+	}
+	return schema
+}
+
+// currentSchema returns the schema new requests execute against
+func (h *Handler) currentSchema() schemaVersion {
+	return h.schema.Load().(schemaVersion)
+}
+
+// SetSchema replaces the schema of a running handler, requests in flight
+// complete against the schema they started with
+func (h *Handler) SetSchema(schema *graphql.Schema) {
+	if schema == nil {
+		panic("undefined GraphQL schema")
+	}
+	prepared := h.prepareSchema(schema)
+	h.swapMu.Lock()
+	defer h.swapMu.Unlock()
+	current := h.currentSchema()
+	h.schema.Store(schemaVersion{schema: prepared, named: current.named, generation: current.generation + 1})
+}
+
+// CurrentSchema returns the schema requests currently execute against
+func (h *Handler) CurrentSchema() *graphql.Schema {
+	return h.currentSchema().schema
+}
@@ -0,0 +1,96 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+)
+
+func versionSchema(t *testing.T, version string) *graphql.Schema {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"version": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return version, nil
+				},
+			},
+		}}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &schema
+}
+
+func TestHandler_SetSchema(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:        versionSchema(t, "v1"),
+		ResponseCache: &handler.ResponseCacheConfig{},
+	})
+	version := func() interface{} {
+		req, _ := http.NewRequest("GET", "/graphql?query={version}", nil)
+		result, _ := executeTest(t, h, req)
+		return result.Data.(map[string]interface{})["version"]
+	}
+	if got := version(); got != "v1" {
+		t.Fatalf("unexpected version %v", got)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest("GET", "/graphql?query={version}", nil)
+			h.ServeHTTP(httptest.NewRecorder(), req)
+		}()
+	}
+	h.SetSchema(versionSchema(t, "v2"))
+	wg.Wait()
+	// the cached v1 response is not served for the new schema
+	if got := version(); got != "v2" {
+		t.Fatalf("unexpected version %v", got)
+	}
+	if h.CurrentSchema() == h.Schema {
+		t.Fatal("CurrentSchema still returns the initial schema")
+	}
+}
+
+func TestHandler_SchemaSelector(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema: versionSchema(t, "default"),
+		Schemas: map[string]*graphql.Schema{
+			"v1": versionSchema(t, "v1"),
+			"v2": versionSchema(t, "v2"),
+		},
+		SchemaSelectorFn: func(ctx context.Context, r *http.Request) string {
+			return strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/graphql"), "/")
+		},
+		ResponseCache: &handler.ResponseCacheConfig{},
+	})
+	for path, expected := range map[string]string{
+		"/graphql":    "default",
+		"/graphql/v1": "v1",
+		"/graphql/v2": "v2",
+	} {
+		req, _ := http.NewRequest("GET", path+"?query={version}", nil)
+		result, _ := executeTest(t, h, req)
+		if got := result.Data.(map[string]interface{})["version"]; got != expected {
+			t.Errorf("%s: expected %s, got %v", path, expected, got)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/graphql/v3?query={version}", nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status %d", resp.Code)
+	}
+}
@@ -0,0 +1,257 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+)
+
+// builtinTypes are the scalars every schema has, they are not printed
+var builtinTypes = map[string]bool{
+	"String":  true,
+	"Int":     true,
+	"Float":   true,
+	"Boolean": true,
+	"ID":      true,
+}
+
+// PrintSchema returns the SDL document describing schema
+func PrintSchema(schema *graphql.Schema) string {
+	var blocks []string
+	if def := printSchemaDefinition(schema); def != "" {
+		blocks = append(blocks, def)
+	}
+	specified := map[string]bool{}
+	for _, d := range graphql.SpecifiedDirectives {
+		specified[d.Name] = true
+	}
+	for _, d := range schema.Directives() {
+		if !specified[d.Name] {
+			blocks = append(blocks, printDirective(d))
+		}
+	}
+	typeMap := schema.TypeMap()
+	names := make([]string, 0, len(typeMap))
+	for name := range typeMap {
+		if !strings.HasPrefix(name, "__") && !builtinTypes[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		blocks = append(blocks, printType(typeMap[name]))
+	}
+	return strings.Join(blocks, "\n\n") + "\n"
+}
+
+// printSchemaDefinition returns the schema block, empty when the root types
+// have their default names
+func printSchemaDefinition(schema *graphql.Schema) string {
+	roots := []struct {
+		operation string
+		object    *graphql.Object
+	}{
+		{"query", schema.QueryType()},
+		{"mutation", schema.MutationType()},
+		{"subscription", schema.SubscriptionType()},
+	}
+	conventional := true
+	var lines []string
+	for _, root := range roots {
+		if root.object == nil {
+			continue
+		}
+		if root.object.Name() != strings.ToUpper(root.operation[:1])+root.operation[1:] {
+			conventional = false
+		}
+		lines = append(lines, fmt.Sprintf("  %s: %s", root.operation, root.object.Name()))
+	}
+	if conventional {
+		return ""
+	}
+	return "schema {\n" + strings.Join(lines, "\n") + "\n}"
+}
+
+// printDescription returns description as a block string above a
+// definition indented by indent
+func printDescription(description, indent string) string {
+	if description == "" {
+		return ""
+	}
+	description = strings.Replace(description, `"""`, `\"""`, -1)
+	if !strings.Contains(description, "\n") && len(description) < 70 {
+		return indent + `"""` + description + `"""` + "\n"
+	}
+	lines := strings.Split(description, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = indent + line
+		}
+	}
+	return indent + `"""` + "\n" + strings.Join(lines, "\n") + "\n" + indent + `"""` + "\n"
+}
+
+func printDeprecated(reason string) string {
+	if reason == "" {
+		return ""
+	}
+	if reason == graphql.DefaultDeprecationReason {
+		return " @deprecated"
+	}
+	quoted, _ := json.Marshal(reason)
+	return " @deprecated(reason: " + string(quoted) + ")"
+}
+
+func printArgs(args []*graphql.Argument) string {
+	if len(args) == 0 {
+		return ""
+	}
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = arg.Name() + ": " + arg.Type.String()
+		if arg.DefaultValue != nil {
+			parts[i] += " = " + printValue(arg.DefaultValue, arg.Type)
+		}
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// printValue returns the GraphQL literal of the input value v of type t
+func printValue(v interface{}, t graphql.Type) string {
+	if nonNull, ok := t.(*graphql.NonNull); ok {
+		t = nonNull.OfType
+	}
+	switch t := t.(type) {
+	case *graphql.List:
+		items, ok := v.([]interface{})
+		if !ok {
+			return printValue(v, t.OfType)
+		}
+		parts := make([]string, len(items))
+		for i, item := range items {
+			parts[i] = printValue(item, t.OfType)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case *graphql.InputObject:
+		fields, ok := v.(map[string]interface{})
+		if !ok {
+			break
+		}
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		parts := make([]string, len(names))
+		for i, name := range names {
+			var fieldType graphql.Type = graphql.String
+			if field, ok := t.Fields()[name]; ok {
+				fieldType = field.Type
+			}
+			parts[i] = name + ": " + printValue(fields[name], fieldType)
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	case *graphql.Enum:
+		if name, ok := t.Serialize(v).(string); ok {
+			return name
+		}
+	}
+	buff, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(buff)
+}
+
+func printDirective(d *graphql.Directive) string {
+	return printDescription(d.Description, "") +
+		"directive @" + d.Name + printArgs(d.Args) + " on " + strings.Join(d.Locations, " | ")
+}
+
+func printFields(fields graphql.FieldDefinitionMap) string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var lines []string
+	for _, name := range names {
+		f := fields[name]
+		lines = append(lines, printDescription(f.Description, "  ")+
+			"  "+name+printArgs(f.Args)+": "+f.Type.String()+printDeprecated(f.DeprecationReason))
+	}
+	return " {\n" + strings.Join(lines, "\n") + "\n}"
+}
+
+func printType(t graphql.Type) string {
+	out := printDescription(t.Description(), "")
+	switch t := t.(type) {
+	case *graphql.Scalar:
+		out += "scalar " + t.Name()
+	case *graphql.Object:
+		out += "type " + t.Name()
+		if interfaces := t.Interfaces(); len(interfaces) > 0 {
+			names := make([]string, len(interfaces))
+			for i, iface := range interfaces {
+				names[i] = iface.Name()
+			}
+			out += " implements " + strings.Join(names, " & ")
+		}
+		out += printFields(t.Fields())
+	case *graphql.Interface:
+		out += "interface " + t.Name() + printFields(t.Fields())
+	case *graphql.Union:
+		names := make([]string, len(t.Types()))
+		for i, possible := range t.Types() {
+			names[i] = possible.Name()
+		}
+		out += "union " + t.Name() + " = " + strings.Join(names, " | ")
+	case *graphql.Enum:
+		// the values come from a map, sort them for a stable document
+		values := append([]*graphql.EnumValueDefinition{}, t.Values()...)
+		sort.Slice(values, func(i, j int) bool { return values[i].Name < values[j].Name })
+		var lines []string
+		for _, value := range values {
+			lines = append(lines, printDescription(value.Description, "  ")+
+				"  "+value.Name+printDeprecated(value.DeprecationReason))
+		}
+		out += "enum " + t.Name() + " {\n" + strings.Join(lines, "\n") + "\n}"
+	case *graphql.InputObject:
+		fields := t.Fields()
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var lines []string
+		for _, name := range names {
+			f := fields[name]
+			line := printDescription(f.Description(), "  ") + "  " + name + ": " + f.Type.String()
+			if f.DefaultValue != nil {
+				line += " = " + printValue(f.DefaultValue, f.Type)
+			}
+			lines = append(lines, line)
+		}
+		out += "input " + t.Name() + " {\n" + strings.Join(lines, "\n") + "\n}"
+	}
+	return out
+}
+
+// serveSDL writes the SDL of the schema r selects when r requests the SDL path
+func (h *Handler) serveSDL(w http.ResponseWriter, r *http.Request) bool {
+	if h.sdlPath == "" || r.URL.Path != h.sdlPath || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return false
+	}
+	schema, _, err := h.selectSchema(r.Context(), r, h.currentSchema())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return true
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(PrintSchema(schema)))
+	return true
+}
@@ -0,0 +1,42 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_SDLPath(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:  &testutil.StarWarsSchema,
+		SDLPath: "/graphql/schema.graphql",
+	})
+	req, _ := http.NewRequest("GET", "/graphql/schema.graphql", nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if ct := resp.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	sdl := resp.Body.String()
+	for _, expected := range []string{
+		"enum Episode {\n" + `  """Released in 1980."""` + "\n  EMPIRE\n",
+		"type Droid implements Character {\n",
+		"  friends: [Character]\n",
+		"type Query {\n  droid(id: String!): Droid\n  hero(episode: Episode): Character\n",
+		"interface Character {\n",
+	} {
+		if !strings.Contains(sdl, expected) {
+			t.Errorf("SDL misses %q:\n%s", expected, sdl)
+		}
+	}
+	if strings.Contains(sdl, "__Schema") || strings.Contains(sdl, "scalar String") {
+		t.Errorf("SDL prints built-in types:\n%s", sdl)
+	}
+	if sdl != handler.PrintSchema(&testutil.StarWarsSchema) {
+		t.Error("served SDL differs from PrintSchema")
+	}
+}
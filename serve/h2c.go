@@ -0,0 +1,47 @@
+//go:build go1.24
+// +build go1.24
+
+package serve
+
+import (
+	"net/http"
+	"time"
+)
+
+// H2CConfig tunes HTTP/2 cleartext serving, zero values keep the net/http defaults
+type H2CConfig struct {
+	// MaxConcurrentStreams is the number of streams a client may have open
+	// on one connection
+	MaxConcurrentStreams int
+	// MaxReceiveBufferPerConnection is the connection flow control window
+	MaxReceiveBufferPerConnection int
+	// MaxReceiveBufferPerStream is the stream flow control window
+	MaxReceiveBufferPerStream int
+	// MaxReadFrameSize is the largest frame the server reads
+	MaxReadFrameSize int
+	// SendPingTimeout pings idle connections after this long without frames
+	SendPingTimeout time.Duration
+	// PingTimeout closes connections whose ping is not answered in time
+	PingTimeout time.Duration
+}
+
+// NewH2CServer returns an http.Server serving h over HTTP/1.1 and HTTP/2
+// with prior knowledge over cleartext connections, letting internal clients
+// multiplex many GraphQL calls over one connection
+func NewH2CServer(h http.Handler, cfg H2CConfig) (*http.Server, error) {
+	protocols := &http.Protocols{}
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+	return &http.Server{
+		Handler:   h,
+		Protocols: protocols,
+		HTTP2: &http.HTTP2Config{
+			MaxConcurrentStreams:          cfg.MaxConcurrentStreams,
+			MaxReceiveBufferPerConnection: cfg.MaxReceiveBufferPerConnection,
+			MaxReceiveBufferPerStream:     cfg.MaxReceiveBufferPerStream,
+			MaxReadFrameSize:              cfg.MaxReadFrameSize,
+			SendPingTimeout:               cfg.SendPingTimeout,
+			PingTimeout:                   cfg.PingTimeout,
+		},
+	}, nil
+}
@@ -0,0 +1,28 @@
+//go:build !go1.24
+// +build !go1.24
+
+package serve
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ErrH2CUnsupported is returned by NewH2CServer when built with Go older than 1.24
+var ErrH2CUnsupported = errors.New("HTTP/2 cleartext serving requires Go 1.24 or later")
+
+// H2CConfig tunes HTTP/2 cleartext serving, zero values keep the net/http defaults
+type H2CConfig struct {
+	MaxConcurrentStreams          int
+	MaxReceiveBufferPerConnection int
+	MaxReceiveBufferPerStream     int
+	MaxReadFrameSize              int
+	SendPingTimeout               time.Duration
+	PingTimeout                   time.Duration
+}
+
+// NewH2CServer requires Go 1.24, which added cleartext HTTP/2 to net/http
+func NewH2CServer(h http.Handler, cfg H2CConfig) (*http.Server, error) {
+	return nil, ErrH2CUnsupported
+}
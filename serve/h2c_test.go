@@ -0,0 +1,38 @@
+//go:build go1.24
+// +build go1.24
+
+package serve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/cxuhua/handler/serve"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestNewH2CServer(t *testing.T) {
+	h := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema})
+	srv, err := serve.NewH2CServer(h, serve.H2CConfig{MaxConcurrentStreams: 250})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewUnstartedServer(srv.Handler)
+	ts.Config = srv
+	ts.Start()
+	defer ts.Close()
+
+	protocols := &http.Protocols{}
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+	resp, err := client.Get(ts.URL + "/graphql?query={hero{name}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("expected HTTP/2 response, got %s", resp.Proto)
+	}
+}
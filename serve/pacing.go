@@ -0,0 +1,92 @@
+package serve
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ConnPacer paces the requests issued over each underlying connection, so
+// clients bursting requests over a single keep-alive or HTTP/2 connection
+// are throttled even when they rotate the identities rate limits key on.
+// Its ConnContext must be installed on the http.Server serving Handler.
+type ConnPacer struct {
+	// Rate is the sustained number of requests per second per connection
+	Rate float64
+	// Burst is the number of requests a connection may issue at once
+	Burst int
+	// MaxDelay is how long a request above the rate is held back before it
+	// is rejected with 429 Too Many Requests, zero rejects immediately
+	MaxDelay time.Duration
+}
+
+type pacerKey struct{}
+
+// bucket is the token bucket of one connection
+type bucket struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// reserve takes a token, returning how long to wait for it, or false when
+// the wait would exceed maxDelay
+func (b *bucket) reserve(rate float64, burst int, maxDelay time.Duration, now time.Time) (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	if wait > maxDelay {
+		return wait, false
+	}
+	b.tokens--
+	return wait, true
+}
+
+// ConnContext attaches the token bucket of c to the connection context
+func (p *ConnPacer) ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, pacerKey{}, &bucket{tokens: float64(p.burst()), last: time.Now()})
+}
+
+func (p *ConnPacer) burst() int {
+	if p.Burst < 1 {
+		return 1
+	}
+	return p.Burst
+}
+
+// Handler paces the requests h serves by their connection
+func (p *ConnPacer) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, ok := r.Context().Value(pacerKey{}).(*bucket)
+		if !ok || p.Rate <= 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+		wait, ok := b.reserve(p.Rate, p.burst(), p.MaxDelay, time.Now())
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		if wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-t.C:
+			case <-r.Context().Done():
+				t.Stop()
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,44 @@
+package serve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cxuhua/handler/serve"
+)
+
+func TestConnPacer(t *testing.T) {
+	pacer := &serve.ConnPacer{Rate: 0.001, Burst: 2}
+	ts := httptest.NewUnstartedServer(pacer.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	ts.Config.ConnContext = pacer.ConnContext
+	ts.Start()
+	defer ts.Close()
+
+	client := ts.Client()
+	codes := []int{}
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		codes = append(codes, resp.StatusCode)
+	}
+	if codes[0] != http.StatusNoContent || codes[1] != http.StatusNoContent || codes[2] != http.StatusTooManyRequests {
+		t.Fatalf("unexpected response codes %v", codes)
+	}
+
+	// a new connection gets its own budget
+	fresh := &http.Client{Transport: &http.Transport{}}
+	resp, err := fresh.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("unexpected response code %v on a new connection", resp.StatusCode)
+	}
+}
@@ -0,0 +1,28 @@
+package serve
+
+import (
+	"context"
+	"net"
+)
+
+// ListenReusePort opens n listeners bound to the same address with
+// SO_REUSEPORT, letting the kernel balance incoming connections across
+// them. A zero port is resolved by the first listener and shared by the rest.
+func ListenReusePort(network, addr string, n int) ([]net.Listener, error) {
+	lc := net.ListenConfig{Control: reusePortControl}
+	listeners := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		l, err := lc.Listen(context.Background(), network, addr)
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return nil, err
+		}
+		if i == 0 {
+			addr = l.Addr().String()
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
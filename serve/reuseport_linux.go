@@ -0,0 +1,4 @@
+package serve
+
+// soReusePort is SO_REUSEPORT, which the syscall package does not define on linux
+const soReusePort = 0xf
@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package serve
+
+import (
+	"errors"
+	"syscall"
+)
+
+// ErrReusePortUnsupported is returned by ListenReusePort on platforms without SO_REUSEPORT
+var ErrReusePortUnsupported = errors.New("SO_REUSEPORT is not supported on this platform")
+
+func reusePortControl(network, address string, c syscall.RawConn) error {
+	return ErrReusePortUnsupported
+}
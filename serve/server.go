@@ -0,0 +1,165 @@
+// Package serve provides serving helpers for running a GraphQL handler
+// across several listeners.
+package serve
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// ListenerStats is a snapshot of the counters of one listener
+type ListenerStats struct {
+	// Addr is the address the listener is bound to
+	Addr string
+	// Accepted is the number of connections accepted so far
+	Accepted int64
+	// Active is the number of currently open connections
+	Active int64
+	// Requests is the number of requests served so far
+	Requests int64
+	// InFlight is the number of requests currently being served
+	InFlight int64
+}
+
+// listenerStats holds the live counters of one listener
+type listenerStats struct {
+	accepted int64
+	active   int64
+	requests int64
+	inFlight int64
+}
+
+// countingListener counts accepted and open connections
+type countingListener struct {
+	net.Listener
+	stats *listenerStats
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt64(&l.stats.accepted, 1)
+	atomic.AddInt64(&l.stats.active, 1)
+	return &countingConn{Conn: c, stats: l.stats}, nil
+}
+
+// countingConn decrements the open connection count once closed
+type countingConn struct {
+	net.Conn
+	stats *listenerStats
+	once  sync.Once
+}
+
+func (c *countingConn) Close() error {
+	c.once.Do(func() {
+		atomic.AddInt64(&c.stats.active, -1)
+	})
+	return c.Conn.Close()
+}
+
+// Server runs a handler across several listeners, each with its own
+// http.Server so accept loops and connection goroutines are spread over them
+type Server struct {
+	// Handler serves the requests of every listener
+	Handler http.Handler
+	// NewServer returns the http.Server used for one listener, its Handler
+	// is set by Server, nil uses a zero http.Server
+	NewServer func() *http.Server
+	// Pacer paces the requests of every connection when set
+	Pacer *ConnPacer
+
+	mu        sync.Mutex
+	listeners []net.Listener
+	servers   []*http.Server
+	stats     []*listenerStats
+}
+
+// Serve serves the handler on listeners and blocks until all of them stop,
+// it returns the first error other than http.ErrServerClosed
+func (s *Server) Serve(listeners ...net.Listener) error {
+	errs := make(chan error, len(listeners))
+	s.mu.Lock()
+	for _, l := range listeners {
+		stats := &listenerStats{}
+		srv := &http.Server{}
+		if s.NewServer != nil {
+			srv = s.NewServer()
+		}
+		srv.Handler = countRequests(s.Handler, stats)
+		if s.Pacer != nil {
+			srv.Handler = s.Pacer.Handler(srv.Handler)
+			srv.ConnContext = chainConnContext(srv.ConnContext, s.Pacer.ConnContext)
+		}
+		s.listeners = append(s.listeners, l)
+		s.servers = append(s.servers, srv)
+		s.stats = append(s.stats, stats)
+		cl := &countingListener{Listener: l, stats: stats}
+		go func() {
+			errs <- srv.Serve(cl)
+		}()
+	}
+	s.mu.Unlock()
+	var first error
+	for range listeners {
+		if err := <-errs; err != nil && err != http.ErrServerClosed && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Shutdown gracefully shuts down the servers of all listeners
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	servers := append([]*http.Server(nil), s.servers...)
+	s.mu.Unlock()
+	var first error
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Stats returns the counters of every listener in the order they were served
+func (s *Server) Stats() []ListenerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make([]ListenerStats, len(s.stats))
+	for i, st := range s.stats {
+		stats[i] = ListenerStats{
+			Addr:     s.listeners[i].Addr().String(),
+			Accepted: atomic.LoadInt64(&st.accepted),
+			Active:   atomic.LoadInt64(&st.active),
+			Requests: atomic.LoadInt64(&st.requests),
+			InFlight: atomic.LoadInt64(&st.inFlight),
+		}
+	}
+	return stats
+}
+
+// countRequests counts the requests served by h
+func countRequests(h http.Handler, stats *listenerStats) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&stats.requests, 1)
+		atomic.AddInt64(&stats.inFlight, 1)
+		defer atomic.AddInt64(&stats.inFlight, -1)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// chainConnContext runs the connection context hooks first then next
+func chainConnContext(first, next func(context.Context, net.Conn) context.Context) func(context.Context, net.Conn) context.Context {
+	if first == nil {
+		return next
+	}
+	return func(ctx context.Context, c net.Conn) context.Context {
+		return next(first(ctx, c), c)
+	}
+}
@@ -0,0 +1,111 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package serve_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/cxuhua/handler/serve"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestServer_ReusePort(t *testing.T) {
+	listeners, err := serve.ListenReusePort("tcp", "127.0.0.1:0", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listeners[0].Addr().String() != listeners[1].Addr().String() {
+		t.Fatalf("listeners bound to different addresses %v and %v", listeners[0].Addr(), listeners[1].Addr())
+	}
+
+	srv := &serve.Server{
+		Handler: handler.New(&handler.Config{Schema: &testutil.StarWarsSchema}),
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Serve(listeners...)
+	}()
+
+	for i := 0; i < 4; i++ {
+		resp, err := http.Get("http://" + listeners[0].Addr().String() + "/graphql?query={hero{name}}")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+	}
+
+	var requests int64
+	for _, st := range srv.Stats() {
+		requests += st.Requests
+	}
+	if requests != 4 {
+		t.Fatalf("expected 4 requests across listeners, got %v", srv.Stats())
+	}
+
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServer_Unix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "serve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "graphql.sock")
+
+	l, err := serve.ListenUnix(path, 0660)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0660 {
+		t.Fatalf("unexpected socket file %v %v", fi, err)
+	}
+
+	srv := &serve.Server{
+		Handler: handler.New(&handler.Config{Schema: &testutil.StarWarsSchema}),
+	}
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	defer srv.Shutdown(context.Background())
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://unix/graphql?query={hero{name}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected server response %v", resp.StatusCode)
+	}
+}
+
+func TestSystemdListeners_NotActivated(t *testing.T) {
+	os.Setenv("LISTEN_PID", "1")
+	os.Setenv("LISTEN_FDS", "1")
+	listeners, err := serve.SystemdListeners()
+	if err != nil || len(listeners) != 0 {
+		t.Fatalf("expected no listeners, got %v %v", listeners, err)
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		t.Fatalf("expected LISTEN_FDS to be unset")
+	}
+}
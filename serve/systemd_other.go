@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package serve
+
+import "net"
+
+// SystemdListeners returns no listeners, socket activation is unix only
+func SystemdListeners() ([]net.Listener, error) {
+	return nil, nil
+}
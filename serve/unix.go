@@ -0,0 +1,30 @@
+package serve
+
+import (
+	"net"
+	"os"
+)
+
+// ListenUnix listens on the unix domain socket at path and applies mode to
+// the socket file. A socket file left over by a previous process that no
+// longer accepts connections is removed first.
+func ListenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if c, err := net.Dial("unix", path); err == nil {
+			_ = c.Close()
+		} else if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			_ = l.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
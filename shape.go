@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// DefaultMaxShapeOperations is the number of operations a ShapeSampler
+// keeps when MaxOperations is not set
+const DefaultMaxShapeOperations = 1000
+
+// ShapeSampler samples the data of responses and infers a JSON Schema of the
+// shape each operation actually returns, serve it on an admin route to feed
+// contract tests or OpenAPI generation
+type ShapeSampler struct {
+	// Every samples one response in Every per operation, values below 2
+	// sample every response
+	Every int
+	// MaxOperations bounds the operations sampled, the least recently
+	// executed are forgotten beyond it, DefaultMaxShapeOperations when zero
+	MaxOperations int
+
+	mu         sync.Mutex
+	order      *list.List
+	operations map[string]*list.Element
+}
+
+type sampledOperation struct {
+	key   string
+	query string
+	// executed is guarded by the mutex of the sampler
+	executed int
+
+	mu      sync.Mutex
+	samples int
+	shape   *shapeNode
+}
+
+// shapeNode merges the JSON values seen at one position of a response
+type shapeNode struct {
+	types map[string]bool
+	// objects is the number of objects seen, present counts the objects
+	// each property was seen in
+	objects    int
+	present    map[string]int
+	properties map[string]*shapeNode
+	items      *shapeNode
+}
+
+// NewShapeSampler returns a sampler recording one response in every
+func NewShapeSampler(every int) *ShapeSampler {
+	return &ShapeSampler{Every: every}
+}
+
+// operationKey names the operation of opts, by name when it has one
+func operationKey(opts *RequestOptions) string {
+	if opts.OperationName != "" {
+		return opts.OperationName
+	}
+	sum := sha256.Sum256([]byte(opts.Query))
+	return hex.EncodeToString(sum[:8])
+}
+
+// observe records data as a response of the operation of opts
+func (s *ShapeSampler) observe(opts *RequestOptions, data interface{}) {
+	s.mu.Lock()
+	op := s.operation(operationKey(opts), opts.Query)
+	op.executed++
+	sample := s.Every <= 1 || (op.executed-1)%s.Every == 0
+	s.mu.Unlock()
+	if !sample {
+		return
+	}
+	// round trip to get the JSON types of custom scalars
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return
+	}
+	op.mu.Lock()
+	op.samples++
+	op.shape.add(value)
+	op.mu.Unlock()
+}
+
+// operation returns the sampled operation of key, forgetting the least
+// recently executed one beyond MaxOperations. s.mu is held
+func (s *ShapeSampler) operation(key, query string) *sampledOperation {
+	if s.operations == nil {
+		s.order = list.New()
+		s.operations = map[string]*list.Element{}
+	}
+	if el, ok := s.operations[key]; ok {
+		s.order.MoveToFront(el)
+		return el.Value.(*sampledOperation)
+	}
+	op := &sampledOperation{key: key, query: query, shape: &shapeNode{}}
+	s.operations[key] = s.order.PushFront(op)
+	max := s.MaxOperations
+	if max <= 0 {
+		max = DefaultMaxShapeOperations
+	}
+	for s.order.Len() > max {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.operations, oldest.Value.(*sampledOperation).key)
+	}
+	return op
+}
+
+func (n *shapeNode) add(value interface{}) {
+	if n.types == nil {
+		n.types = map[string]bool{}
+	}
+	switch v := value.(type) {
+	case nil:
+		n.types["null"] = true
+	case bool:
+		n.types["boolean"] = true
+	case float64:
+		if v == float64(int64(v)) {
+			n.types["integer"] = true
+		} else {
+			n.types["number"] = true
+		}
+	case string:
+		n.types["string"] = true
+	case []interface{}:
+		n.types["array"] = true
+		if n.items == nil {
+			n.items = &shapeNode{}
+		}
+		for _, item := range v {
+			n.items.add(item)
+		}
+	case map[string]interface{}:
+		n.types["object"] = true
+		if n.properties == nil {
+			n.properties = map[string]*shapeNode{}
+			n.present = map[string]int{}
+		}
+		n.objects++
+		for key, item := range v {
+			if n.properties[key] == nil {
+				n.properties[key] = &shapeNode{}
+			}
+			n.present[key]++
+			n.properties[key].add(item)
+		}
+	}
+}
+
+// schema returns the JSON Schema of the values merged into n
+func (n *shapeNode) schema() map[string]interface{} {
+	out := map[string]interface{}{}
+	types := make([]string, 0, len(n.types))
+	for t := range n.types {
+		types = append(types, t)
+	}
+	if n.types["integer"] && n.types["number"] {
+		// every integer is a number
+		types = types[:0]
+		for t := range n.types {
+			if t != "integer" {
+				types = append(types, t)
+			}
+		}
+	}
+	sort.Strings(types)
+	switch len(types) {
+	case 0:
+	case 1:
+		out["type"] = types[0]
+	default:
+		out["type"] = types
+	}
+	if n.properties != nil {
+		properties := map[string]interface{}{}
+		required := []string{}
+		for key, property := range n.properties {
+			properties[key] = property.schema()
+			if n.present[key] == n.objects {
+				required = append(required, key)
+			}
+		}
+		sort.Strings(required)
+		out["properties"] = properties
+		out["required"] = required
+	}
+	if n.items != nil {
+		out["items"] = n.items.schema()
+	}
+	return out
+}
+
+// Schemas returns the inferred JSON Schema of the data of every sampled
+// operation, keyed by operation name or query hash
+func (s *ShapeSampler) Schemas() map[string]interface{} {
+	s.mu.Lock()
+	operations := make([]*sampledOperation, 0, len(s.operations))
+	for _, el := range s.operations {
+		operations = append(operations, el.Value.(*sampledOperation))
+	}
+	s.mu.Unlock()
+	out := map[string]interface{}{}
+	for _, op := range operations {
+		op.mu.Lock()
+		if op.samples > 0 {
+			schema := op.shape.schema()
+			schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+			out[op.key] = map[string]interface{}{
+				"query":   op.query,
+				"samples": op.samples,
+				"schema":  schema,
+			}
+		}
+		op.mu.Unlock()
+	}
+	return out
+}
+
+// ServeHTTP writes the inferred schemas as JSON
+func (s *ShapeSampler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	buff, _ := json.MarshalIndent(map[string]interface{}{"operations": s.Schemas()}, "", " ")
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, _ = w.Write(buff)
+}
@@ -0,0 +1,68 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestShapeSampler(t *testing.T) {
+	sampler := handler.NewShapeSampler(1)
+	h := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema, ShapeSampler: sampler})
+	query := `query Hero($episode: Episode) {hero(episode: $episode){name ... on Droid {primaryFunction}}}`
+	for _, variables := range []string{`{}`, `{"episode":"EMPIRE"}`} {
+		params := url.Values{"query": {query}, "operationName": {"Hero"}, "variables": {variables}}
+		req, _ := http.NewRequest("GET", "/graphql?"+params.Encode(), nil)
+		executeTest(t, h, req)
+	}
+
+	resp := httptest.NewRecorder()
+	sampler.ServeHTTP(resp, httptest.NewRequest("GET", "/admin/shapes", nil))
+	var out struct {
+		Operations map[string]struct {
+			Samples int
+			Schema  map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	hero := out.Operations["Hero"]
+	if hero.Samples != 2 {
+		t.Fatalf("expected 2 samples, got %d", hero.Samples)
+	}
+	// Luke is no droid, primaryFunction is optional
+	expected := map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"name"},
+		"properties": map[string]interface{}{
+			"name":            map[string]interface{}{"type": "string"},
+			"primaryFunction": map[string]interface{}{"type": "string"},
+		},
+	}
+	got := hero.Schema["properties"].(map[string]interface{})["hero"]
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("wrong schema, diff: %v", testutil.Diff(expected, got))
+	}
+}
+
+func TestShapeSampler_MaxOperations(t *testing.T) {
+	sampler := &handler.ShapeSampler{MaxOperations: 2}
+	h := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema, ShapeSampler: sampler})
+	for _, name := range []string{"A", "B", "A", "C"} {
+		params := url.Values{"query": {"query " + name + " {hero{name}}"}, "operationName": {name}}
+		req, _ := http.NewRequest("GET", "/graphql?"+params.Encode(), nil)
+		executeTest(t, h, req)
+	}
+	// B was executed least recently
+	schemas := sampler.Schemas()
+	if _, ok := schemas["B"]; ok || schemas["A"] == nil || schemas["C"] == nil {
+		t.Fatalf("unexpected operations %v", schemas)
+	}
+}
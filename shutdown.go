@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// ErrShuttingDown rejects the operations a shutting down handler receives,
+// the handler answers it with 503 Service Unavailable
+var ErrShuttingDown = errors.New("the server is shutting down")
+
+// shutdownRetryAfter is the Retry-After, in seconds, of the requests
+// Shutdown rejects
+const shutdownRetryAfter = 5
+
+// drain counts the operations in flight
+type drain struct {
+	mu       sync.Mutex
+	closing  bool
+	inflight int
+	// idle is closed when the last operation of a closing handler ends
+	idle chan struct{}
+}
+
+// begin registers an operation, false once Shutdown was called
+func (d *drain) begin() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closing {
+		return false
+	}
+	d.inflight++
+	return true
+}
+
+func (d *drain) end() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.inflight--
+	if d.closing && d.inflight == 0 {
+		close(d.idle)
+	}
+}
+
+func (d *drain) shuttingDown() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.closing
+}
+
+// Shutdown stops the handler accepting operations, they are answered with
+// 503 Service Unavailable and a Retry-After header, and waits for the
+// operations in flight, incremental deliveries included. It returns the
+// error of ctx when it ends first, the operations keep running. The state
+// of the WarmRestart is saved once they finished
+func (h *Handler) Shutdown(ctx context.Context) error {
+	d := &h.drain
+	d.mu.Lock()
+	if !d.closing {
+		d.closing = true
+		d.idle = make(chan struct{})
+		if d.inflight == 0 {
+			close(d.idle)
+		}
+	}
+	idle := d.idle
+	d.mu.Unlock()
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if h.warmRestart != nil {
+		return h.saveState(ctx)
+	}
+	return nil
+}
+
+// rejectShutdown answers a request Shutdown does not let through
+func (h *Handler) rejectShutdown(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Retry-After", strconv.Itoa(shutdownRetryAfter))
+	h.writeResult(ctx, w, r, http.StatusServiceUnavailable, &graphql.Result{
+		Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(ErrShuttingDown)},
+	})
+}
@@ -0,0 +1,64 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_Shutdown(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"slow": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					close(started)
+					<-release
+					return "done", nil
+				},
+			},
+		}}),
+	})
+	h := handler.New(&handler.Config{Schema: &schema, ReadyPath: "/ready"})
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		req, _ := http.NewRequest("GET", "/graphql?query={slow}", nil)
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		done <- resp
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := h.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected the slow query to outlast the deadline, got %v", err)
+	}
+	req, _ := http.NewRequest("GET", "/graphql?query={slow}", nil)
+	result, resp := executeTest(t, h, req)
+	if resp.Code != http.StatusServiceUnavailable || resp.Header().Get("Retry-After") == "" || len(result.Errors) != 1 {
+		t.Fatalf("unexpected response %d %v %+v", resp.Code, resp.Header(), result)
+	}
+	req, _ = http.NewRequest("GET", "/ready", nil)
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected the handler not to be ready, got %d", resp.Code)
+	}
+
+	shutdown := make(chan error)
+	go func() { shutdown <- h.Shutdown(context.Background()) }()
+	close(release)
+	if resp := <-done; resp.Code != http.StatusOK {
+		t.Fatalf("expected the in-flight query to complete, got %d %s", resp.Code, resp.Body)
+	}
+	if err := <-shutdown; err != nil {
+		t.Fatalf("unexpected shutdown error %v", err)
+	}
+}
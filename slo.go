@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SLO is the objective of an operation: Objective of its executions must
+// succeed within Latency
+type SLO struct {
+	// Latency is the slowest a good execution may be, zero counts every
+	// execution without errors as good
+	Latency time.Duration
+	// Objective is the ratio of good executions, e.g. 0.999
+	Objective float64
+}
+
+// SLOAlert reports an operation burning its error budget too fast
+type SLOAlert struct {
+	Operation string
+	SLO       SLO
+	// BurnRate is the ratio of bad executions in the window over the
+	// ratio the objective allows
+	BurnRate float64
+	Good     int
+	Total    int
+	Window   time.Duration
+}
+
+// SLOTracker tracks the compliance of operations with their SLOs over a
+// sliding window and calls AlertFn when the burn rate crosses
+// BurnRateThreshold, at most once per window and operation
+type SLOTracker struct {
+	// Objectives are keyed by operation name
+	Objectives map[string]SLO
+	// Default applies to the operations missing from Objectives, nil
+	// leaves them untracked
+	Default *SLO
+	// MaxDefaultOperations bounds the operations Default tracks one by one,
+	// the executions of the others are tracked together as
+	// SLOOtherOperations, DefaultMaxSLOOperations when zero
+	MaxDefaultOperations int
+	// Window is the span the burn rate is computed over, defaults to
+	// DefaultSLOWindow
+	Window time.Duration
+	// BurnRateThreshold defaults to DefaultBurnRateThreshold
+	BurnRateThreshold float64
+	// MinExecutions is the number of executions in the window below which
+	// no alert is raised
+	MinExecutions int
+	AlertFn       func(alert SLOAlert)
+
+	mu         sync.Mutex
+	operations map[string]*sloWindow
+	defaulted  int
+}
+
+const (
+	// DefaultSLOWindow is the default span of SLOTracker.Window
+	DefaultSLOWindow = 5 * time.Minute
+	// DefaultBurnRateThreshold spends a 30 day budget in about two days
+	DefaultBurnRateThreshold = 14.4
+	// DefaultMaxSLOOperations is the default of
+	// SLOTracker.MaxDefaultOperations
+	DefaultMaxSLOOperations = 100
+)
+
+// SLOOtherOperations is the operation the executions beyond
+// SLOTracker.MaxDefaultOperations are reported as, operation names come
+// from clients
+const SLOOtherOperations = "(other)"
+
+// sloBuckets is the number of buckets a window is split into
+const sloBuckets = 10
+
+type sloBucket struct {
+	start       time.Time
+	good, total int
+}
+
+// sloWindow counts the executions of one operation
+type sloWindow struct {
+	slo       SLO
+	buckets   [sloBuckets]sloBucket
+	lastAlert time.Time
+}
+
+// NewSLOTracker returns a tracker of objectives alerting through alertFn
+func NewSLOTracker(objectives map[string]SLO, alertFn func(alert SLOAlert)) *SLOTracker {
+	return &SLOTracker{Objectives: objectives, AlertFn: alertFn}
+}
+
+func (t *SLOTracker) window() time.Duration {
+	if t.Window <= 0 {
+		return DefaultSLOWindow
+	}
+	return t.Window
+}
+
+// counts returns the executions of w within the window ending at now
+func (w *sloWindow) counts(now time.Time, window time.Duration) (good, total int) {
+	for _, b := range w.buckets {
+		if now.Sub(b.start) < window {
+			good += b.good
+			total += b.total
+		}
+	}
+	return good, total
+}
+
+// observe records an execution of operation, good when it met its latency
+// without errors
+func (t *SLOTracker) observe(operation string, latency time.Duration, failed bool) {
+	now := time.Now()
+	window := t.window()
+	t.mu.Lock()
+	if t.operations == nil {
+		t.operations = map[string]*sloWindow{}
+	}
+	w, ok := t.operations[operation]
+	if !ok {
+		slo, found := t.Objectives[operation]
+		if !found {
+			if t.Default == nil {
+				t.mu.Unlock()
+				return
+			}
+			slo = *t.Default
+			max := t.MaxDefaultOperations
+			if max <= 0 {
+				max = DefaultMaxSLOOperations
+			}
+			if t.defaulted >= max {
+				operation = SLOOtherOperations
+			} else {
+				t.defaulted++
+			}
+		}
+		if w, ok = t.operations[operation]; !ok {
+			w = &sloWindow{slo: slo}
+			t.operations[operation] = w
+		}
+	}
+	span := window / sloBuckets
+	start := now.Truncate(span)
+	b := &w.buckets[int(start.UnixNano()/int64(span))%sloBuckets]
+	if !b.start.Equal(start) {
+		*b = sloBucket{start: start}
+	}
+	b.total++
+	if !failed && (w.slo.Latency <= 0 || latency <= w.slo.Latency) {
+		b.good++
+	}
+
+	var alert *SLOAlert
+	good, total := w.counts(now, window)
+	threshold := t.BurnRateThreshold
+	if threshold <= 0 {
+		threshold = DefaultBurnRateThreshold
+	}
+	if budget := 1 - w.slo.Objective; budget > 0 && total >= t.MinExecutions && now.Sub(w.lastAlert) >= window {
+		if rate := float64(total-good) / float64(total) / budget; rate >= threshold {
+			w.lastAlert = now
+			alert = &SLOAlert{Operation: operation, SLO: w.slo, BurnRate: rate, Good: good, Total: total, Window: window}
+		}
+	}
+	t.mu.Unlock()
+	if alert != nil && t.AlertFn != nil {
+		t.AlertFn(*alert)
+	}
+}
+
+// SLOStatus is the compliance of an operation over the current window
+type SLOStatus struct {
+	SLO      SLO     `json:"slo"`
+	Good     int     `json:"good"`
+	Total    int     `json:"total"`
+	BurnRate float64 `json:"burnRate"`
+}
+
+// Status returns the compliance of every tracked operation
+func (t *SLOTracker) Status() map[string]SLOStatus {
+	now := time.Now()
+	window := t.window()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := map[string]SLOStatus{}
+	for operation, w := range t.operations {
+		good, total := w.counts(now, window)
+		status := SLOStatus{SLO: w.slo, Good: good, Total: total}
+		if budget := 1 - w.slo.Objective; budget > 0 && total > 0 {
+			status.BurnRate = float64(total-good) / float64(total) / budget
+		}
+		out[operation] = status
+	}
+	return out
+}
+
+// ServeHTTP writes Status as JSON
+func (t *SLOTracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	buff, _ := json.MarshalIndent(t.Status(), "", " ")
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, _ = w.Write(buff)
+}
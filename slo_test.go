@@ -0,0 +1,62 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestSLOTracker_Alerts(t *testing.T) {
+	var alerts []handler.SLOAlert
+	tracker := handler.NewSLOTracker(map[string]handler.SLO{
+		"Hero": {Latency: time.Second, Objective: 0.9},
+	}, func(alert handler.SLOAlert) {
+		alerts = append(alerts, alert)
+	})
+	tracker.MinExecutions = 4
+	tracker.BurnRateThreshold = 2
+	h := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema, SLO: tracker})
+	do := func(query string) {
+		req, _ := http.NewRequest("GET", "/graphql?operationName=Hero&query="+query, nil)
+		executeTest(t, h, req)
+	}
+
+	do("query+Hero{hero{name}}")
+	do("query+Hero{hero{name}}")
+	do("query+Hero{hero{unknown}}")
+	if len(alerts) != 0 {
+		t.Fatalf("alerted below MinExecutions: %v", alerts)
+	}
+	// 2 bad out of 4 burn the 10% budget five times too fast
+	do("query+Hero{hero{unknown}}")
+	do("query+Hero{hero{unknown}}")
+	if len(alerts) != 1 {
+		t.Fatalf("expected one alert, got %v", alerts)
+	}
+	if a := alerts[0]; a.Operation != "Hero" || a.Total != 4 || a.Good != 2 || a.BurnRate < 4.9 {
+		t.Fatalf("unexpected alert %+v", a)
+	}
+	// untracked operations are ignored
+	req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}", nil)
+	executeTest(t, h, req)
+	if status := tracker.Status(); len(status) != 1 || status["Hero"].Total != 5 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+}
+
+func TestSLOTracker_Default(t *testing.T) {
+	tracker := &handler.SLOTracker{Default: &handler.SLO{Objective: 0.9}, MaxDefaultOperations: 2}
+	h := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema, SLO: tracker})
+	for _, name := range []string{"A", "B", "C", "D", "A"} {
+		req, _ := http.NewRequest("GET", "/graphql?operationName="+name+"&query=query+"+name+"{hero{name}}", nil)
+		executeTest(t, h, req)
+	}
+	// client chosen names beyond the first two share one window
+	status := tracker.Status()
+	if len(status) != 3 || status["A"].Total != 2 || status[handler.SLOOtherOperations].Total != 2 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+}
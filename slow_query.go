@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"context"
+	"time"
+	"unicode/utf8"
+)
+
+// SlowQueryLength is the number of bytes of the query SlowQueryInfo carries
+const SlowQueryLength = 2048
+
+// SlowQueryInfo describes an operation exceeding Config.SlowQueryThreshold
+type SlowQueryInfo struct {
+	OperationName string
+	Duration      time.Duration
+	// Query is the document truncated to SlowQueryLength bytes
+	Query     string
+	Truncated bool
+	Failed    bool
+	// Client is the client application that sent the operation
+	Client ClientInfo
+}
+
+// SlowQueryFn is called after the operations exceeding the threshold
+type SlowQueryFn func(ctx context.Context, info SlowQueryInfo)
+
+// reportSlowQuery calls the SlowQueryFn when opts took longer than the threshold
+func (h *Handler) reportSlowQuery(ctx context.Context, opts *RequestOptions, duration time.Duration, failed bool) {
+	if (h.slowQueryFn == nil && !h.logSlowQueries) || duration < h.slowQueryThreshold {
+		return
+	}
+	info := SlowQueryInfo{OperationName: opts.OperationName, Duration: duration, Query: opts.Query, Failed: failed, Client: RequestClient(ctx)}
+	if len(info.Query) > SlowQueryLength {
+		cut := SlowQueryLength
+		// do not split a rune
+		for cut > 0 && !utf8.RuneStart(info.Query[cut]) {
+			cut--
+		}
+		info.Query, info.Truncated = info.Query[:cut], true
+	}
+	if h.logSlowQueries {
+		h.logger.WarnContext(ctx, "slow graphql operation", "operation", info.OperationName, "duration", duration, "failed", failed)
+	}
+	if h.slowQueryFn != nil {
+		h.slowQueryFn(ctx, info)
+	}
+}
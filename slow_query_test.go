@@ -0,0 +1,53 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_SlowQueryFn(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"fast": &graphql.Field{Type: graphql.Boolean, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return true, nil
+			}},
+			"slow": &graphql.Field{Type: graphql.Boolean, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				time.Sleep(20 * time.Millisecond)
+				return true, nil
+			}},
+		}}),
+	})
+	var reports []handler.SlowQueryInfo
+	h := handler.New(&handler.Config{
+		Schema:             &schema,
+		SlowQueryThreshold: 10 * time.Millisecond,
+		SlowQueryFn: func(ctx context.Context, info handler.SlowQueryInfo) {
+			reports = append(reports, info)
+		},
+	})
+	req, _ := http.NewRequest("GET", "/graphql?query={fast}", nil)
+	executeTest(t, h, req)
+	if len(reports) != 0 {
+		t.Fatalf("unexpected reports %+v", reports)
+	}
+
+	// pad the document past the reported length
+	query := "query Slow { slow }" + strings.Repeat(" ", handler.SlowQueryLength)
+	req, _ = http.NewRequest("GET", "/graphql?query="+url.QueryEscape(query)+"&operationName=Slow", nil)
+	executeTest(t, h, req)
+	if len(reports) != 1 {
+		t.Fatalf("expected one report, got %+v", reports)
+	}
+	report := reports[0]
+	if report.OperationName != "Slow" || report.Duration < 20*time.Millisecond || !report.Truncated ||
+		len(report.Query) != handler.SlowQueryLength || !strings.HasPrefix(report.Query, "query Slow") {
+		t.Fatalf("unexpected report %+v", report)
+	}
+}
@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// UploadFile is the value Upload arguments resolve to
+type UploadFile struct {
+	Filename    string
+	ContentType string
+	Size        int64
+	// Header is the multipart file, nil when an UploadStore saved it
+	Header *multipart.FileHeader
+	// Ref is where the UploadStore saved the file
+	Ref *UploadRef
+	// Derived are the files the UploadProcessors derived from the upload by
+	// name, e.g. "thumbnail"
+	Derived map[string]*UploadFile
+
+	// open opens the files of resumable uploads
+	open func() (io.ReadCloser, error)
+}
+
+// Open opens the uploaded content, files saved by an UploadStore are read
+// from the store through Ref instead
+func (f *UploadFile) Open() (io.ReadCloser, error) {
+	if f.open != nil {
+		return f.open()
+	}
+	if f.Header == nil {
+		return nil, fmt.Errorf("upload %q was saved to %s", f.Filename, f.Ref.Location)
+	}
+	return f.Header.Open()
+}
+
+// Upload is the scalar of multipart request files, declare an argument as
+// `file: Upload!` and its resolver receives a *UploadFile
+var Upload = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "Upload",
+	Description: "A file uploaded in a multipart request",
+	Serialize: func(value interface{}) interface{} {
+		if f, ok := value.(*UploadFile); ok {
+			return f.Filename
+		}
+		return nil
+	},
+	ParseValue: func(value interface{}) interface{} {
+		if f, ok := value.(*UploadFile); ok {
+			return f
+		}
+		return nil
+	},
+	ParseLiteral: func(valueAST ast.Value) interface{} {
+		// files are only ever sent as variables
+		return nil
+	},
+})
+
+// MapUploads replaces the variables of opts declared with the Upload type
+// by the *UploadFile of the files mapped to them, a list when several are.
+// Files mapped below a variable, e.g. to "variables.files.0" or
+// "variables.input.file", replace the value at their path
+func MapUploads(opts *RequestOptions) {
+	mapUploads(context.Background(), opts)
+}
+
+// servesUploads reports whether the Upload scalar of schema is Upload, the
+// variables of a schema declaring its own Upload scalar are left as parsed
+func servesUploads(schema *graphql.Schema) bool {
+	return schema.Type(Upload.Name()) == graphql.Type(Upload)
+}
+
+// mapUploads maps the files of opts, or the UploadRefs ctx carries once an
+// UploadStore saved them
+func mapUploads(ctx context.Context, opts *RequestOptions) {
+	refs, _ := ctx.Value(uploadsKey{}).(map[string][]UploadRef)
+	if len(opts.File) == 0 && len(refs) == 0 {
+		return
+	}
+	_, operation := parseOperation(opts)
+	if operation == nil {
+		return
+	}
+	// the files by their path below the variables
+	uploads := map[string][]interface{}{}
+	for path, headers := range opts.File {
+		for _, header := range headers {
+			uploads[path] = append(uploads[path], &UploadFile{
+				Filename:    header.Filename,
+				ContentType: header.Header.Get("Content-Type"),
+				Size:        header.Size,
+				Header:      header,
+			})
+		}
+	}
+	for path := range refs {
+		for i := range refs[path] {
+			ref := refs[path][i]
+			uploads[path] = append(uploads[path], &UploadFile{Filename: ref.Filename, ContentType: ref.ContentType, Size: ref.Size, Ref: &ref})
+		}
+	}
+	// list items are set in order
+	paths := make([]string, 0, len(uploads))
+	for path := range uploads {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool { return lessPath(paths[i], paths[j]) })
+	for _, def := range operation.VariableDefinitions {
+		if def.Variable == nil || def.Variable.Name == nil {
+			continue
+		}
+		name := def.Variable.Name.Value
+		for _, path := range paths {
+			files := uploads[path]
+			var value interface{} = files
+			if len(files) == 1 {
+				value = files[0]
+			}
+			switch {
+			case path == name:
+				if !isUploadType(def.Type) {
+					continue
+				}
+				if isListVariable(def.Type) {
+					value = files
+				}
+			case strings.HasPrefix(path, name+"."):
+				value = setPath(opts.Variables[name], strings.Split(path[len(name)+1:], "."), value)
+			default:
+				continue
+			}
+			if opts.Variables == nil {
+				opts.Variables = map[string]interface{}{}
+			}
+			opts.Variables[name] = value
+		}
+	}
+}
+
+// lessPath orders paths by their segments, list indexes by their value
+func lessPath(a, b string) bool {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] == bs[i] {
+			continue
+		}
+		ai, aErr := strconv.Atoi(as[i])
+		bi, bErr := strconv.Atoi(bs[i])
+		if aErr == nil && bErr == nil {
+			return ai < bi
+		}
+		return as[i] < bs[i]
+	}
+	return len(as) < len(bs)
+}
+
+// setPath returns value with the element at path replaced by file, the
+// objects of the path are created as needed. Lists are only ever extended
+// by one item
+func setPath(value interface{}, path []string, file interface{}) interface{} {
+	if len(path) == 0 {
+		return file
+	}
+	if index, err := strconv.Atoi(path[0]); err == nil && index >= 0 {
+		list, _ := value.([]interface{})
+		switch {
+		case index < len(list):
+			list[index] = setPath(list[index], path[1:], file)
+		case index == len(list):
+			list = append(list, setPath(nil, path[1:], file))
+		}
+		return list
+	}
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		object = map[string]interface{}{}
+	}
+	object[path[0]] = setPath(object[path[0]], path[1:], file)
+	return object
+}
+
+func isUploadType(t ast.Type) bool {
+	switch t := t.(type) {
+	case *ast.NonNull:
+		return isUploadType(t.Type)
+	case *ast.List:
+		return isUploadType(t.Type)
+	case *ast.Named:
+		return t.Name != nil && t.Name.Value == Upload.Name()
+	}
+	return false
+}
+
+func isListVariable(t ast.Type) bool {
+	if nonNull, ok := t.(*ast.NonNull); ok {
+		t = nonNull.Type
+	}
+	_, ok := t.(*ast.List)
+	return ok
+}
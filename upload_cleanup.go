@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+)
+
+// UploadCleanupFn is called once the temporary files of the multipart
+// request r are removed, err is the first removal failure. ctx carries the
+// UploadRefs of the request
+type UploadCleanupFn func(ctx context.Context, r *http.Request, err error)
+
+// cleanupUploads removes the temporary files of the multipart form of r,
+// the form is only removed once
+func (h *Handler) cleanupUploads(ctx context.Context, r *http.Request) {
+	form := r.MultipartForm
+	if form == nil {
+		return
+	}
+	err := form.RemoveAll()
+	r.MultipartForm = nil
+	if h.onUploadCleanup != nil {
+		h.onUploadCleanup(ctx, r, err)
+	}
+}
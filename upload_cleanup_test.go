@@ -0,0 +1,67 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_UploadCleanup(t *testing.T) {
+	for _, first := range []bool{false, true} {
+		var events []string
+		var tmpfile string
+		h := handler.New(&handler.Config{
+			Schema: &testutil.StarWarsSchema,
+			FinishFn: func(ctx context.Context, w http.ResponseWriter, r *http.Request, buf []byte) {
+				events = append(events, "finish")
+				if r.MultipartForm == nil {
+					return
+				}
+				// files larger than MaxUploadMemorySize are kept on disk
+				f, err := r.MultipartForm.File["a"][0].Open()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if file, ok := f.(*os.File); ok {
+					tmpfile = file.Name()
+				}
+				f.Close()
+			},
+			OnUploadCleanup: func(ctx context.Context, r *http.Request, err error) {
+				if err != nil {
+					t.Errorf("unexpected cleanup error %v", err)
+				}
+				events = append(events, "cleanup")
+			},
+			CleanupUploadsBeforeFinish: first,
+		})
+		resp := httptest.NewRecorder()
+		content := strings.Repeat("x", int(handler.MaxUploadMemorySize)+1)
+		h.ServeHTTP(resp, uploadRequest(t, map[string]string{"a": content}))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("unexpected response %d %s", resp.Code, resp.Body)
+		}
+		expected := []string{"finish", "cleanup"}
+		if first {
+			expected = []string{"cleanup", "finish"}
+		}
+		if !reflect.DeepEqual(events, expected) {
+			t.Fatalf("expected %q, got %q", expected, events)
+		}
+		if !first {
+			if tmpfile == "" {
+				t.Fatal("expected the upload in a temporary file")
+			}
+			if _, err := os.Stat(tmpfile); !os.IsNotExist(err) {
+				t.Fatalf("expected %s to be removed, got %v", tmpfile, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// UploadLimits bound the files of multipart requests, zero values are unlimited
+type UploadLimits struct {
+	// MaxFileSize is the largest size of a single file in bytes
+	MaxFileSize int64
+	// MaxFiles is the number of files a request may carry
+	MaxFiles int
+	// MaxFileFields is the number of form fields a request may carry files in
+	MaxFileFields int
+}
+
+func (l UploadLimits) enabled() bool {
+	return l.MaxFileSize > 0 || l.MaxFiles > 0 || l.MaxFileFields > 0
+}
+
+// UploadViolation is a file part exceeding one of the UploadLimits or
+// failing its UploadRule
+type UploadViolation struct {
+	Field    string `json:"field"`
+	Filename string `json:"filename"`
+	// Limit is "maxFileSize", "maxFiles", "maxFileFields", "extension",
+	// "contentType" or "sniffedContentType"
+	Limit string `json:"limit"`
+	// Max is the exceeded bound of the UploadLimits
+	Max int64 `json:"max,omitempty"`
+	// Value is the rejected extension or content type
+	Value string `json:"value,omitempty"`
+}
+
+func (v UploadViolation) exceedsLimit() bool {
+	return v.Value == ""
+}
+
+// UploadLimitError lists the parts of a request exceeding its UploadLimits
+// or failing its UploadRules. The handler answers it with 413 Request
+// Entity Too Large, or 415 Unsupported Media Type when only rules failed
+type UploadLimitError struct {
+	Violations []UploadViolation
+}
+
+func (e *UploadLimitError) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		if v.exceedsLimit() {
+			parts[i] = fmt.Sprintf("field %q file %q exceeds %s %d", v.Field, v.Filename, v.Limit, v.Max)
+		} else {
+			parts[i] = fmt.Sprintf("field %q file %q has a disallowed %s %q", v.Field, v.Filename, v.Limit, v.Value)
+		}
+	}
+	return "upload rejected: " + strings.Join(parts, ", ")
+}
+
+func (e *UploadLimitError) status() int {
+	for _, v := range e.Violations {
+		if v.exceedsLimit() {
+			return http.StatusRequestEntityTooLarge
+		}
+	}
+	return http.StatusUnsupportedMediaType
+}
+
+// Extensions reports the violations in the GraphQL error
+func (e *UploadLimitError) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"code":       "UPLOAD_LIMIT_EXCEEDED",
+		"violations": e.Violations,
+	}
+}
+
+// readLimitedMultipartForm parses the multipart body of r like
+// ParseMultipartForm, the parts are checked against limits while they
+// stream in and the form is only kept when none exceeds them
+func readLimitedMultipartForm(r *http.Request, limits uploadPolicy) (*multipart.Form, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	type parsed struct {
+		form *multipart.Form
+		err  error
+	}
+	done := make(chan parsed, 1)
+	go func() {
+		form, err := multipart.NewReader(pr, mw.Boundary()).ReadForm(MaxUploadMemorySize)
+		// unblock the writer when the form could not be read
+		pr.CloseWithError(err)
+		done <- parsed{form, err}
+	}()
+	violations, err := walkLimitedParts(mr, limits, func(part *multipart.Part, content io.Reader) (int64, error) {
+		dst, err := mw.CreatePart(part.Header)
+		if err != nil {
+			return 0, err
+		}
+		return io.Copy(dst, content)
+	})
+	if err == nil {
+		err = mw.Close()
+	}
+	pw.CloseWithError(err)
+	result := <-done
+	if err == nil {
+		err = result.err
+	}
+	if err == nil && len(violations) > 0 {
+		err = &UploadLimitError{Violations: violations}
+	}
+	if err != nil {
+		if result.form != nil {
+			_ = result.form.RemoveAll()
+		}
+		return nil, err
+	}
+	r.MultipartForm = result.form
+	return result.form, nil
+}
+
+// walkLimitedParts passes the fields and files of mr to copyPart, checking
+// the files against limits. The parts following the first violation are only
+// checked, the content of a file is cut one byte beyond MaxFileSize
+func walkLimitedParts(mr *multipart.Reader, limits uploadPolicy, copyPart func(part *multipart.Part, content io.Reader) (int64, error)) ([]UploadViolation, error) {
+	var violations []UploadViolation
+	var variables map[string][]string
+	files := 0
+	fields := map[string]bool{}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return violations, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		name, filename := part.FormName(), part.FileName()
+		if filename == "" {
+			var content io.Reader = part
+			if name == "map" {
+				// the spec sends the map before the files
+				buff := &bytes.Buffer{}
+				if _, err := io.Copy(buff, part); err != nil {
+					return nil, err
+				}
+				variables = mappedVariables(buff.Bytes())
+				content = buff
+			}
+			if len(violations) == 0 {
+				if _, err := copyPart(part, content); err != nil {
+					return nil, err
+				}
+			}
+			if _, err := io.Copy(ioutil.Discard, part); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		content := bufio.NewReader(part)
+		violation := UploadViolation{Field: name, Filename: filename}
+		violation = limits.ruleOf(name, variables[name]).check(violation, part.Header.Get("Content-Type"), content)
+		switch {
+		case violation.Limit != "":
+			// the rule rejected the file, its content is skipped
+		case limits.MaxFiles > 0 && files >= limits.MaxFiles:
+			violation.Limit, violation.Max = "maxFiles", int64(limits.MaxFiles)
+		case limits.MaxFileFields > 0 && !fields[name] && len(fields) >= limits.MaxFileFields:
+			violation.Limit, violation.Max = "maxFileFields", int64(limits.MaxFileFields)
+		default:
+			files++
+			fields[name] = true
+			src := io.Reader(content)
+			if limits.MaxFileSize > 0 {
+				src = io.LimitReader(content, limits.MaxFileSize+1)
+			}
+			var n int64
+			if len(violations) == 0 {
+				n, err = copyPart(part, src)
+			} else {
+				n, err = io.Copy(ioutil.Discard, src)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if limits.MaxFileSize > 0 && n > limits.MaxFileSize {
+				violation.Limit, violation.Max = "maxFileSize", limits.MaxFileSize
+			}
+		}
+		if violation.Limit != "" {
+			violations = append(violations, violation)
+		}
+		if _, err := io.Copy(ioutil.Discard, part); err != nil {
+			return nil, err
+		}
+	}
+}
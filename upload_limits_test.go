@@ -0,0 +1,129 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func uploadRequest(t *testing.T, files map[string]string) *http.Request {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	_ = mw.WriteField("query", "{hero{name}}")
+	for _, field := range []string{"a", "b", "c"} {
+		content, ok := files[field]
+		if !ok {
+			continue
+		}
+		fw, err := mw.CreateFormFile(field, field+".txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = fw.Write([]byte(content))
+	}
+	_ = mw.Close()
+	req, _ := http.NewRequest("POST", "/graphql", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestHandler_UploadLimits(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:        &testutil.StarWarsSchema,
+		MaxFileSize:   4,
+		MaxFiles:      2,
+		MaxFileFields: 2,
+	})
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, uploadRequest(t, map[string]string{"a": "1234", "b": "12"}))
+	if resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), "R2-D2") {
+		t.Fatalf("unexpected response %d %s", resp.Code, resp.Body.String())
+	}
+
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, uploadRequest(t, map[string]string{"a": "12345", "b": "1", "c": "1"}))
+	if resp.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d", resp.Code)
+	}
+	var result struct {
+		Errors []struct {
+			Extensions struct {
+				Code       string                    `json:"code"`
+				Violations []handler.UploadViolation `json:"violations"`
+			} `json:"extensions"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &result); err != nil || len(result.Errors) != 1 {
+		t.Fatalf("unexpected body %s", resp.Body.String())
+	}
+	violations := result.Errors[0].Extensions.Violations
+	expected := []handler.UploadViolation{
+		{Field: "a", Filename: "a.txt", Limit: "maxFileSize", Max: 4},
+		{Field: "c", Filename: "c.txt", Limit: "maxFiles", Max: 2},
+	}
+	if result.Errors[0].Extensions.Code != "UPLOAD_LIMIT_EXCEEDED" || len(violations) != len(expected) {
+		t.Fatalf("unexpected violations %+v", result.Errors[0].Extensions)
+	}
+	for i := range expected {
+		if violations[i] != expected[i] {
+			t.Fatalf("expected %+v, got %+v", expected[i], violations[i])
+		}
+	}
+}
+
+func TestHandler_UploadLimits_FileFields(t *testing.T) {
+	h := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema, MaxFileFields: 1})
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, uploadRequest(t, map[string]string{"a": "1", "b": "1"}))
+	if resp.Code != http.StatusRequestEntityTooLarge || !strings.Contains(resp.Body.String(), `"maxFileFields"`) {
+		t.Fatalf("unexpected response %d %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestHandler_UploadRules(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	h := handler.New(&handler.Config{
+		Schema:     &testutil.StarWarsSchema,
+		UploadRule: &handler.UploadRule{Extensions: []string{".txt"}},
+		UploadFieldRules: map[string]*handler.UploadRule{
+			"b": {ContentTypes: []string{"image/*"}, Sniff: true},
+		},
+	})
+	do := func(files map[string]string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, uploadRequest(t, files))
+		return resp
+	}
+	if resp := do(map[string]string{"a": "text"}); resp.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d %s", resp.Code, resp.Body.String())
+	}
+	// CreateFormFile declares application/octet-stream
+	resp := do(map[string]string{"b": png})
+	if resp.Code != http.StatusUnsupportedMediaType || !strings.Contains(resp.Body.String(), `"limit":"contentType"`) {
+		t.Fatalf("unexpected response %d %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestHandler_UploadRules_Sniff(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:     &testutil.StarWarsSchema,
+		UploadRule: &handler.UploadRule{ContentTypes: []string{"application/octet-stream"}, Sniff: true},
+	})
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, uploadRequest(t, map[string]string{"a": "<html><body>"}))
+	if resp.Code != http.StatusUnsupportedMediaType || !strings.Contains(resp.Body.String(), `"value":"text/html; charset=utf-8"`) {
+		t.Fatalf("unexpected response %d %s", resp.Code, resp.Body.String())
+	}
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, uploadRequest(t, map[string]string{"a": "\x00\x01\x02"}))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d %s", resp.Code, resp.Body.String())
+	}
+}
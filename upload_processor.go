@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"strings"
+)
+
+// UploadProcessorFn processes an uploaded file before the resolvers see it,
+// e.g. to strip its EXIF data or render a thumbnail, and returns the files
+// derived from it by name. Files saved by an UploadStore are read from their
+// Ref
+type UploadProcessorFn func(ctx context.Context, file *UploadFile) (map[string]*UploadFile, error)
+
+// NewUploadFile returns an in memory file, e.g. a file derived by an
+// UploadProcessorFn
+func NewUploadFile(filename, contentType string, content []byte) *UploadFile {
+	return &UploadFile{
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        int64(len(content)),
+		open: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(content)), nil
+		},
+	}
+}
+
+// uploadProcessor returns the processor of the media type contentType, or
+// of all the subtypes of its type, "image/*"
+func (h *Handler) uploadProcessor(contentType string) UploadProcessorFn {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+	if processor, ok := h.uploadProcessors[mediaType]; ok {
+		return processor
+	}
+	if i := strings.IndexByte(mediaType, '/'); i > 0 {
+		return h.uploadProcessors[mediaType[:i]+"/*"]
+	}
+	return nil
+}
+
+// processUploads runs the processors of the files mapped to the variables
+// of opts, the derived files are set on the files they derive from
+func (h *Handler) processUploads(ctx context.Context, opts *RequestOptions) error {
+	if len(h.uploadProcessors) == 0 {
+		return nil
+	}
+	for _, value := range opts.Variables {
+		files, ok := value.([]interface{})
+		if !ok {
+			files = []interface{}{value}
+		}
+		for _, file := range files {
+			file, ok := file.(*UploadFile)
+			if !ok || file.Derived != nil {
+				continue
+			}
+			processor := h.uploadProcessor(file.ContentType)
+			if processor == nil {
+				continue
+			}
+			derived, err := processor(ctx, file)
+			if err != nil {
+				return fmt.Errorf("processing upload %q: %w", file.Filename, err)
+			}
+			if derived == nil {
+				derived = map[string]*UploadFile{}
+			}
+			file.Derived = derived
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_UploadProcessors(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"ok": &graphql.Field{Type: graphql.Boolean},
+		}}),
+		Mutation: graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: graphql.Fields{
+			"upload": &graphql.Field{
+				Type: graphql.NewList(graphql.String),
+				Args: graphql.FieldConfigArgument{
+					"files": &graphql.ArgumentConfig{Type: graphql.NewList(handler.Upload)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					var uploaded []interface{}
+					for _, file := range p.Args["files"].([]interface{}) {
+						file := file.(*handler.UploadFile)
+						if thumbnail := file.Derived["thumbnail"]; thumbnail != nil {
+							file = thumbnail
+						}
+						r, err := file.Open()
+						if err != nil {
+							return nil, err
+						}
+						content, _ := ioutil.ReadAll(r)
+						r.Close()
+						uploaded = append(uploaded, file.Filename+":"+string(content))
+					}
+					return uploaded, nil
+				},
+			},
+		}}),
+	})
+	upload := func(h *handler.Handler, contentTypes ...string) (*graphql.Result, int) {
+		body := &bytes.Buffer{}
+		mw := multipart.NewWriter(body)
+		// the files of a list variable are sent in a single field
+		_ = mw.WriteField("operations", `{"query": "mutation($files: [Upload]) { upload(files: $files) }", "variables": {"files": null}}`)
+		_ = mw.WriteField("map", `{"0": ["variables.files"]}`)
+		for i, contentType := range contentTypes {
+			header := textproto.MIMEHeader{}
+			header.Set("Content-Disposition", `form-data; name="0"; filename="`+string(rune('a'+i))+`"`)
+			header.Set("Content-Type", contentType)
+			fw, _ := mw.CreatePart(header)
+			_, _ = fw.Write([]byte("content"))
+		}
+		_ = mw.Close()
+		req, _ := http.NewRequest(http.MethodPost, "/graphql", body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		result, resp := executeTest(t, h, req)
+		return result, resp.Code
+	}
+
+	h := handler.NewHandler(&schema, handler.WithUploadProcessor("image/*",
+		func(ctx context.Context, file *handler.UploadFile) (map[string]*handler.UploadFile, error) {
+			if file.ContentType == "image/gif" {
+				return nil, errors.New("unsupported image")
+			}
+			return map[string]*handler.UploadFile{
+				"thumbnail": handler.NewUploadFile("thumb-"+file.Filename, file.ContentType, []byte("small")),
+			}, nil
+		}))
+	result, status := upload(h, "image/png; name=a", "text/plain")
+	if status != http.StatusOK || result.HasErrors() {
+		t.Fatalf("unexpected response %d %+v", status, result.Errors)
+	}
+	if expected := map[string]interface{}{"upload": []interface{}{"thumb-a:small", "b:content"}}; !reflect.DeepEqual(result.Data, expected) {
+		t.Fatalf("unexpected data %v", result.Data)
+	}
+	result, status = upload(h, "image/gif")
+	if status != http.StatusUnprocessableEntity || len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, "unsupported image") {
+		t.Fatalf("unexpected response %d %+v", status, result.Errors)
+	}
+}
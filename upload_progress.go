@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// uploadProgressStep is the number of bytes between UploadProgressFn calls
+const uploadProgressStep = 256 << 10
+
+// UploadProgressFn reports the bytes of a multipart request body read so
+// far, contentLength is -1 when the client did not send it. It is called
+// every 256 KiB and when the body ends, an error aborts the upload and the
+// request is answered with 400 Bad Request. With an UploadStallTimeout it is
+// called again with the same read count while the body stalls
+type UploadProgressFn func(ctx context.Context, read, contentLength int64) error
+
+// UploadStalledError aborts a multipart upload that read nothing for
+// Timeout, the request is answered with 408 Request Timeout
+type UploadStalledError struct {
+	Timeout time.Duration
+}
+
+func (e *UploadStalledError) Error() string {
+	return fmt.Sprintf("upload stalled for %v", e.Timeout)
+}
+
+func isUploadStalled(err error) bool {
+	_, ok := err.(*UploadStalledError)
+	return ok
+}
+
+// progressReader reports the progress of reading a request body
+type progressReader struct {
+	ctx    context.Context
+	body   io.ReadCloser
+	fn     UploadProgressFn
+	length int64
+	// deadline unblocks the reads of a cancelled upload, nil when the
+	// response writer cannot
+	deadline func(time.Time) error
+	stop     chan struct{}
+	stopOnce sync.Once
+	// fnMu serializes the calls of fn
+	fnMu sync.Mutex
+
+	mu       sync.Mutex
+	read     int64
+	reported int64
+	err      error
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	if err := p.error(); err != nil {
+		return 0, err
+	}
+	n, err := p.body.Read(b)
+	p.mu.Lock()
+	p.read += int64(n)
+	read := p.read
+	end := err == io.EOF || read == p.length
+	report := p.fn != nil && (read-p.reported >= uploadProgressStep || (end && read > p.reported))
+	if report {
+		p.reported = read
+	}
+	p.mu.Unlock()
+	if end || err != nil {
+		p.close()
+	}
+	if report {
+		if fnErr := p.call(read); fnErr != nil {
+			p.cancel(fnErr)
+			return n, fnErr
+		}
+	}
+	// a cancelled upload fails the read it unblocked
+	if cancelled := p.error(); cancelled != nil {
+		return n, cancelled
+	}
+	return n, err
+}
+
+func (p *progressReader) Close() error {
+	p.close()
+	return p.body.Close()
+}
+
+func (p *progressReader) call(read int64) error {
+	p.fnMu.Lock()
+	defer p.fnMu.Unlock()
+	return p.fn(p.ctx, read, p.length)
+}
+
+// error returns the error that cancelled the upload
+func (p *progressReader) error() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// cancel aborts the upload with err, a read blocked on the client is
+// unblocked by a read deadline when the response writer supports them, and
+// otherwise by closing the body
+func (p *progressReader) cancel(err error) {
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = err
+	}
+	p.mu.Unlock()
+	p.close()
+	if p.deadline != nil {
+		_ = p.deadline(time.Now())
+	} else {
+		// the close of a server body waits for the blocked read
+		go p.body.Close()
+	}
+}
+
+// close stops watching for stalls
+func (p *progressReader) close() {
+	p.stopOnce.Do(func() { close(p.stop) })
+}
+
+// watch checks every timeout whether the body was read, the stalled
+// uploads are reported to fn or cancelled
+func (p *progressReader) watch(timeout time.Duration) {
+	ticker := time.NewTicker(timeout)
+	defer ticker.Stop()
+	last := int64(-1)
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		p.mu.Lock()
+		read := p.read
+		p.mu.Unlock()
+		if read != last {
+			last = read
+			continue
+		}
+		var err error = &UploadStalledError{Timeout: timeout}
+		if p.fn != nil {
+			err = p.call(read)
+		}
+		if err != nil {
+			p.cancel(err)
+			return
+		}
+	}
+}
+
+// trackUploadProgress reports the progress of reading the body of r when it
+// is a multipart request, the returned reader holds the error aborting the
+// upload. It is stopped once the body is read or closed
+func (h *Handler) trackUploadProgress(ctx context.Context, w http.ResponseWriter, r *http.Request) *progressReader {
+	if r.Body == nil || r.Method != http.MethodPost {
+		return nil
+	}
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != ContentTypeMultipartFormData {
+		return nil
+	}
+	p := &progressReader{ctx: ctx, body: r.Body, fn: h.uploadProgressFn, length: r.ContentLength, stop: make(chan struct{})}
+	// http.ResponseController is not available to older Go versions
+	if d, ok := w.(interface{ SetReadDeadline(time.Time) error }); ok {
+		p.deadline = d.SetReadDeadline
+	}
+	if h.uploadStallTimeout > 0 {
+		go p.watch(h.uploadStallTimeout)
+	}
+	r.Body = p
+	return p
+}
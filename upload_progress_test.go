@@ -0,0 +1,108 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_UploadProgress(t *testing.T) {
+	var reads []int64
+	h := handler.New(&handler.Config{
+		Schema: &testutil.StarWarsSchema,
+		UploadProgressFn: func(ctx context.Context, read, contentLength int64) error {
+			if contentLength <= 0 {
+				t.Errorf("unexpected content length %d", contentLength)
+			}
+			reads = append(reads, read)
+			if read > 1<<20 {
+				return errors.New("upload too slow")
+			}
+			return nil
+		},
+	})
+	req := uploadRequest(t, map[string]string{"a": strings.Repeat("x", 600<<10)})
+	length := req.ContentLength
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d %s", resp.Code, resp.Body)
+	}
+	if len(reads) < 3 || reads[len(reads)-1] != length {
+		t.Fatalf("unexpected progress %v of %d bytes", reads, length)
+	}
+
+	reads = nil
+	result, resp := executeTest(t, h, uploadRequest(t, map[string]string{"a": strings.Repeat("x", 2<<20)}))
+	if resp.Code != http.StatusBadRequest || len(result.Errors) != 1 || result.Errors[0].Message != "upload too slow" {
+		t.Fatalf("unexpected response %d %+v", resp.Code, result)
+	}
+}
+
+// blockedBody sends head and then blocks until it is closed
+type blockedBody struct {
+	head   io.Reader
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (b *blockedBody) Read(p []byte) (int, error) {
+	if n, err := b.head.Read(p); err != io.EOF {
+		return n, err
+	}
+	<-b.closed
+	return 0, errors.New("body closed")
+}
+
+func (b *blockedBody) Close() error {
+	b.once.Do(func() { close(b.closed) })
+	return nil
+}
+
+func TestHandler_UploadStallTimeout(t *testing.T) {
+	stalledRequest := func() *http.Request {
+		req := uploadRequest(t, map[string]string{"a": "content"})
+		head, _ := ioutil.ReadAll(req.Body)
+		// the client stalls before the end of the body
+		req.Body = &blockedBody{head: bytes.NewReader(head[:len(head)/2]), closed: make(chan struct{})}
+		return req
+	}
+
+	h := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema, UploadStallTimeout: 10 * time.Millisecond})
+	result, resp := executeTest(t, h, stalledRequest())
+	if resp.Code != http.StatusRequestTimeout || len(result.Errors) != 1 || result.Errors[0].Message != "upload stalled for 10ms" {
+		t.Fatalf("unexpected response %d %+v", resp.Code, result)
+	}
+
+	// UploadProgressFn is told about the stall and cancels the upload
+	var stalls int32
+	h = handler.New(&handler.Config{
+		Schema:             &testutil.StarWarsSchema,
+		UploadStallTimeout: 10 * time.Millisecond,
+		UploadProgressFn: func(ctx context.Context, read, contentLength int64) error {
+			if atomic.AddInt32(&stalls, 1) < 3 {
+				return nil
+			}
+			return errors.New("client went quiet")
+		},
+	})
+	result, resp = executeTest(t, h, stalledRequest())
+	if resp.Code != http.StatusBadRequest || len(result.Errors) != 1 || result.Errors[0].Message != "client went quiet" {
+		t.Fatalf("unexpected response %d %+v", resp.Code, result)
+	}
+	if stalls != 3 {
+		t.Fatalf("expected 3 stall reports, got %d", stalls)
+	}
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// UploadRule restricts the files of multipart requests, empty lists allow
+// anything
+type UploadRule struct {
+	// ContentTypes are the allowed declared content types, e.g. "image/png"
+	// or "image/*"
+	ContentTypes []string
+	// Sniff also checks the content type sniffed from the first bytes of
+	// the file against ContentTypes
+	Sniff bool
+	// Extensions are the allowed filename extensions, e.g. ".png"
+	Extensions []string
+}
+
+// uploadPolicy is what request parsing enforces
+type uploadPolicy struct {
+	UploadLimits
+	rule *UploadRule
+	// fieldRules are keyed by variable names or form field names
+	fieldRules map[string]*UploadRule
+	// codecs decode bodies that are not JSON
+	codecs []Codec
+	// pool takes the options from the request options pool
+	pool bool
+	// store receives the files of multipart bodies as they stream in
+	store UploadStore
+}
+
+func (p uploadPolicy) enabled() bool {
+	return p.UploadLimits.enabled() || p.rule != nil || len(p.fieldRules) > 0
+}
+
+// ruleOf returns the rule of a file part, variables are the variable names
+// the "map" field of the operations maps the part to
+func (p uploadPolicy) ruleOf(field string, variables []string) *UploadRule {
+	for _, variable := range variables {
+		if rule, ok := p.fieldRules[variable]; ok {
+			return rule
+		}
+	}
+	if rule, ok := p.fieldRules[field]; ok {
+		return rule
+	}
+	return p.rule
+}
+
+// mappedVariables decodes the "map" field of the operations into the
+// variable names of every file field
+func mappedVariables(data []byte) map[string][]string {
+	var paths map[string][]string
+	if err := json.Unmarshal(data, &paths); err != nil {
+		return nil
+	}
+	variables := map[string][]string{}
+	for field, fieldPaths := range paths {
+		for _, path := range fieldPaths {
+			if ps := strings.Split(path, "."); len(ps) >= 2 && ps[0] == "variables" {
+				variables[field] = append(variables[field], ps[1])
+			}
+		}
+	}
+	return variables
+}
+
+// check returns the violation of the file a part carries, empty when the
+// file passes. Sniffing peeks at content without consuming it
+func (rule *UploadRule) check(violation UploadViolation, contentType string, content *bufio.Reader) UploadViolation {
+	if rule == nil {
+		return violation
+	}
+	if len(rule.Extensions) > 0 {
+		ext := strings.ToLower(filepath.Ext(violation.Filename))
+		allowed := false
+		for _, e := range rule.Extensions {
+			allowed = allowed || strings.ToLower(e) == ext
+		}
+		if !allowed {
+			violation.Limit, violation.Value = "extension", ext
+			return violation
+		}
+	}
+	if len(rule.ContentTypes) == 0 {
+		return violation
+	}
+	if !rule.allows(contentType) {
+		violation.Limit, violation.Value = "contentType", contentType
+		return violation
+	}
+	if rule.Sniff {
+		head, _ := content.Peek(512)
+		if sniffed := http.DetectContentType(head); !rule.allows(sniffed) {
+			violation.Limit, violation.Value = "sniffedContentType", sniffed
+		}
+	}
+	return violation
+}
+
+// allows reports whether contentType matches one of the allowed types
+func (rule *UploadRule) allows(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range rule.ContentTypes {
+		allowed = strings.ToLower(allowed)
+		if allowed == mediaType || allowed == "*/*" ||
+			(strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(allowed, "*"))) {
+			return true
+		}
+	}
+	return false
+}
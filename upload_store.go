@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// UploadRef references an uploaded file saved by an UploadStore
+type UploadRef struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	// Location is where the store saved the file, e.g. a path or an object key
+	Location string `json:"location"`
+}
+
+// UploadStore saves the files of multipart requests before they execute,
+// resolvers get their UploadRefs from UploadRefs. The files are streamed to
+// Save while the request body is read, with the context of the request
+type UploadStore interface {
+	// Save copies content, the file described by file, to the store
+	Save(ctx context.Context, file *UploadFile, content io.Reader) (UploadRef, error)
+	// Delete removes a saved file, the request saving it failed
+	Delete(ctx context.Context, ref UploadRef) error
+}
+
+type uploadsKey struct{}
+
+// UploadRefs returns the files a request uploaded in field, saved by the
+// configured UploadStore. Fields of the multipart operations are named by
+// the path below the variables they are mapped to, e.g. "file" or
+// "files.0"
+func UploadRefs(ctx context.Context, field string) []UploadRef {
+	refs, _ := ctx.Value(uploadsKey{}).(map[string][]UploadRef)
+	return refs[field]
+}
+
+// uploadStoreError is the failure of an UploadStore, the request is
+// answered with 500 Internal Server Error
+type uploadStoreError struct {
+	err error
+}
+
+func (e *uploadStoreError) Error() string {
+	return e.err.Error()
+}
+
+// readStoredMultipartForm parses the multipart body of r, its files are
+// streamed to the store of limits instead of being buffered. The saved
+// files are deleted again when the body turns out to be invalid
+func readStoredMultipartForm(r *http.Request, limits uploadPolicy) (*RequestOptions, error) {
+	ctx := r.Context()
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	values := url.Values{}
+	refs := map[string][]UploadRef{}
+	var saved []UploadRef
+	remaining := int64(MaxUploadMemorySize)
+	violations, err := walkLimitedParts(mr, limits, func(part *multipart.Part, content io.Reader) (int64, error) {
+		if part.FileName() == "" {
+			value := &strings.Builder{}
+			n, err := io.Copy(value, io.LimitReader(content, remaining+1))
+			if remaining -= n; err == nil && remaining < 0 {
+				err = multipart.ErrMessageTooLarge
+			}
+			values.Add(part.FormName(), value.String())
+			return n, err
+		}
+		counted := &countingReader{r: content}
+		ref, err := limits.store.Save(ctx, &UploadFile{Filename: part.FileName(), ContentType: part.Header.Get("Content-Type")}, counted)
+		if err != nil {
+			return counted.n, &uploadStoreError{err}
+		}
+		saved = append(saved, ref)
+		refs[part.FormName()] = append(refs[part.FormName()], ref)
+		return counted.n, nil
+	})
+	if err == nil && len(violations) > 0 {
+		err = &UploadLimitError{Violations: violations}
+	}
+	var opts *RequestOptions
+	if err == nil {
+		opts, err = getFromMultipartForm(&multipart.Form{Value: values})
+	}
+	if err != nil || opts == nil {
+		for _, ref := range saved {
+			_ = limits.store.Delete(ctx, ref)
+		}
+		return opts, err
+	}
+	opts.refs = mappedRefs(values, refs)
+	for path := range opts.refs {
+		// variables mapped as a whole reference their files like
+		// RequestOptions.File does
+		if _, ok := opts.Variables[path]; ok && values.Get("operations") != "" {
+			opts.Variables[path] = path
+		}
+	}
+	return opts, nil
+}
+
+// mappedRefs keys the refs of the file fields of an operations request by
+// their paths below the variables, the refs of other requests keep their
+// field names
+func mappedRefs(values url.Values, refs map[string][]UploadRef) map[string][]UploadRef {
+	if values.Get("operations") == "" {
+		return refs
+	}
+	paths, err := multipartPaths(values.Get("map"))
+	if err != nil {
+		return nil
+	}
+	mapped := make(map[string][]UploadRef, len(refs))
+	for field, fieldRefs := range refs {
+		for _, path := range paths[field] {
+			mapped[path] = append(mapped[path], fieldRefs...)
+		}
+	}
+	return mapped
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// saveUploads saves the files of opts to store and releases the parsed
+// form, the returned context carries the UploadRefs. It saves the forms of
+// parsers other than the handler's, whose files are already buffered
+func saveUploads(ctx context.Context, store UploadStore, form *multipart.Form, opts *RequestOptions) (context.Context, error) {
+	refs := make(map[string][]UploadRef, len(opts.File))
+	var saved []UploadRef
+	for field, files := range opts.File {
+		for _, file := range files {
+			ref, err := saveUpload(ctx, store, file)
+			if err != nil {
+				for _, ref := range saved {
+					_ = store.Delete(ctx, ref)
+				}
+				return ctx, err
+			}
+			saved = append(saved, ref)
+			refs[field] = append(refs[field], ref)
+		}
+	}
+	opts.File = nil
+	if form != nil {
+		_ = form.RemoveAll()
+	}
+	return context.WithValue(ctx, uploadsKey{}, refs), nil
+}
+
+func saveUpload(ctx context.Context, store UploadStore, file *multipart.FileHeader) (UploadRef, error) {
+	src, err := file.Open()
+	if err != nil {
+		return UploadRef{}, err
+	}
+	defer src.Close()
+	return store.Save(ctx, &UploadFile{Filename: file.Filename, ContentType: file.Header.Get("Content-Type"), Size: file.Size, Header: file}, src)
+}
+
+// DiskUploadStore saves uploaded files below Dir under random names
+type DiskUploadStore struct {
+	Dir string
+}
+
+// NewDiskUploadStore returns a store saving uploads below dir
+func NewDiskUploadStore(dir string) *DiskUploadStore {
+	return &DiskUploadStore{Dir: dir}
+}
+
+// Save copies content to a new file below s.Dir
+func (s *DiskUploadStore) Save(ctx context.Context, file *UploadFile, content io.Reader) (UploadRef, error) {
+	name := make([]byte, 16)
+	if _, err := rand.Read(name); err != nil {
+		return UploadRef{}, err
+	}
+	path := filepath.Join(s.Dir, hex.EncodeToString(name)+filepath.Ext(file.Filename))
+	dst, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return UploadRef{}, err
+	}
+	size, err := io.Copy(dst, content)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return UploadRef{}, err
+	}
+	return UploadRef{
+		Filename:    file.Filename,
+		ContentType: file.ContentType,
+		Size:        size,
+		Location:    path,
+	}, nil
+}
+
+// Delete removes the file of ref, files outside of s.Dir are left alone
+func (s *DiskUploadStore) Delete(ctx context.Context, ref UploadRef) error {
+	if filepath.Dir(ref.Location) != filepath.Clean(s.Dir) {
+		return fmt.Errorf("upload %s is not in %s", ref.Location, s.Dir)
+	}
+	return os.Remove(ref.Location)
+}
@@ -0,0 +1,144 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_UploadStore(t *testing.T) {
+	var refs []handler.UploadRef
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"hero": &graphql.Field{
+				Type: graphql.NewObject(graphql.ObjectConfig{Name: "Hero", Fields: graphql.Fields{
+					"name": &graphql.Field{Type: graphql.String},
+				}}),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					refs = handler.UploadRefs(p.Context, "a")
+					return map[string]interface{}{"name": "R2-D2"}, nil
+				},
+			},
+		}}),
+	})
+	h := handler.New(&handler.Config{
+		Schema:      &schema,
+		UploadStore: handler.NewDiskUploadStore(t.TempDir()),
+	})
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, uploadRequest(t, map[string]string{"a": "content"}))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d %s", resp.Code, resp.Body.String())
+	}
+	if len(refs) != 1 || refs[0].Filename != "a.txt" || refs[0].Size != 7 || !strings.HasSuffix(refs[0].Location, ".txt") {
+		t.Fatalf("unexpected refs %+v", refs)
+	}
+	content, err := ioutil.ReadFile(refs[0].Location)
+	if err != nil || string(content) != "content" {
+		t.Fatalf("unexpected saved file %q %v", content, err)
+	}
+}
+
+// memoryStore keeps the saved files, it fails on the files named "fail"
+type memoryStore struct {
+	mu    sync.Mutex
+	files map[string]string
+	saved chan struct{}
+}
+
+func (s *memoryStore) Save(ctx context.Context, file *handler.UploadFile, content io.Reader) (handler.UploadRef, error) {
+	if file.Filename == "fail.txt" {
+		return handler.UploadRef{}, errors.New("bucket unavailable")
+	}
+	buff, err := ioutil.ReadAll(content)
+	if err != nil {
+		return handler.UploadRef{}, err
+	}
+	s.mu.Lock()
+	s.files[file.Filename] = string(buff)
+	s.mu.Unlock()
+	if s.saved != nil {
+		s.saved <- struct{}{}
+	}
+	return handler.UploadRef{Filename: file.Filename, Size: int64(len(buff)), Location: file.Filename}, nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, ref handler.UploadRef) error {
+	s.mu.Lock()
+	delete(s.files, ref.Location)
+	s.mu.Unlock()
+	return nil
+}
+
+func TestHandler_UploadStore_Error(t *testing.T) {
+	store := &memoryStore{files: map[string]string{}}
+	h := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema, UploadStore: store, MaxFileSize: 4})
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	_ = mw.WriteField("query", "{hero{name}}")
+	fw, _ := mw.CreateFormFile("a", "a.txt")
+	_, _ = fw.Write([]byte("a"))
+	fw, _ = mw.CreateFormFile("b", "fail.txt")
+	_, _ = fw.Write([]byte("b"))
+	_ = mw.Close()
+	req, _ := http.NewRequest("POST", "/graphql", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	result, resp := executeTest(t, h, req)
+	if resp.Code != http.StatusInternalServerError || len(result.Errors) != 1 || result.Errors[0].Message != "bucket unavailable" {
+		t.Fatalf("unexpected response %d %+v", resp.Code, result)
+	}
+	// the files saved before the failure are deleted
+	if len(store.files) != 0 {
+		t.Fatalf("expected the saved files to be deleted, got %v", store.files)
+	}
+
+	// so are the files of rejected requests
+	result, resp = executeTest(t, h, uploadRequest(t, map[string]string{"a": "a", "b": "too large"}))
+	if resp.Code != http.StatusRequestEntityTooLarge || len(store.files) != 0 {
+		t.Fatalf("unexpected response %d %+v, stored %v", resp.Code, result, store.files)
+	}
+}
+
+func TestHandler_UploadStore_Streaming(t *testing.T) {
+	store := &memoryStore{files: map[string]string{}, saved: make(chan struct{}, 2)}
+	h := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema, UploadStore: store})
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	req, _ := http.NewRequest("POST", "/graphql", pr)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	_ = mw.WriteField("query", "{hero{name}}")
+	fw, _ := mw.CreateFormFile("a", "a.txt")
+	_, _ = fw.Write([]byte("first"))
+	// the next part ends the first, which is saved before the body ends
+	fw, _ = mw.CreateFormFile("b", "b.txt")
+	select {
+	case <-store.saved:
+	case <-time.After(time.Second):
+		t.Fatal("expected the first file to be saved while the body streams")
+	}
+	_, _ = fw.Write([]byte("second"))
+	_ = mw.Close()
+	_ = pw.Close()
+	<-done
+	if store.files["a.txt"] != "first" || store.files["b.txt"] != "second" {
+		t.Fatalf("unexpected files %v", store.files)
+	}
+}
@@ -0,0 +1,167 @@
+package handler_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+func TestUploadScalar(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"ok": &graphql.Field{Type: graphql.Boolean},
+		}}),
+		Mutation: graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: graphql.Fields{
+			"upload": &graphql.Field{
+				Type: graphql.String,
+				Args: graphql.FieldConfigArgument{
+					"file": &graphql.ArgumentConfig{Type: graphql.NewNonNull(handler.Upload)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					file := p.Args["file"].(*handler.UploadFile)
+					r, err := file.Open()
+					if err != nil {
+						return nil, err
+					}
+					defer r.Close()
+					content, err := ioutil.ReadAll(r)
+					return file.Filename + ":" + string(content), err
+				},
+			},
+		}}),
+	})
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	_ = mw.WriteField("operations", `{"query": "mutation($file: Upload!) { upload(file: $file) }", "variables": {"file": null}}`)
+	_ = mw.WriteField("map", `{"0": ["variables.file"]}`)
+	fw, _ := mw.CreateFormFile("0", "a.txt")
+	_, _ = fw.Write([]byte("content"))
+	_ = mw.Close()
+	req, _ := http.NewRequest(http.MethodPost, "/graphql", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	h := handler.New(&handler.Config{Schema: &schema})
+	result, resp := executeTest(t, h, req)
+	if resp.Code != http.StatusOK || result.HasErrors() {
+		t.Fatalf("unexpected response %d %+v", resp.Code, result.Errors)
+	}
+	if expected := map[string]interface{}{"upload": "a.txt:content"}; !reflect.DeepEqual(result.Data, expected) {
+		t.Fatalf("unexpected data %v", result.Data)
+	}
+}
+
+// operationsRequest posts the files of a multipart operations request, the
+// field of the i-th file is named i and the map gives its paths
+func operationsRequest(operations, paths string, contents ...string) *http.Request {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	_ = mw.WriteField("operations", operations)
+	_ = mw.WriteField("map", paths)
+	for i, content := range contents {
+		fw, _ := mw.CreateFormFile(strconv.Itoa(i), string(rune('a'+i))+".txt")
+		_, _ = fw.Write([]byte(content))
+	}
+	_ = mw.Close()
+	req, _ := http.NewRequest(http.MethodPost, "/graphql", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestUploadScalar_Paths(t *testing.T) {
+	read := func(file interface{}) string {
+		r, err := file.(*handler.UploadFile).Open()
+		if err != nil {
+			return err.Error()
+		}
+		defer r.Close()
+		content, _ := ioutil.ReadAll(r)
+		return string(content)
+	}
+	input := graphql.NewInputObject(graphql.InputObjectConfig{Name: "AttachInput", Fields: graphql.InputObjectConfigFieldMap{
+		"name": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"file": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(handler.Upload)},
+	}})
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"ok": &graphql.Field{Type: graphql.Boolean},
+		}}),
+		Mutation: graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: graphql.Fields{
+			"upload": &graphql.Field{
+				Type: graphql.NewList(graphql.String),
+				Args: graphql.FieldConfigArgument{
+					"files": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(handler.Upload)))},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					var contents []interface{}
+					for _, file := range p.Args["files"].([]interface{}) {
+						contents = append(contents, read(file))
+					}
+					return contents, nil
+				},
+			},
+			"attach": &graphql.Field{
+				Type: graphql.String,
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(input)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					input := p.Args["input"].(map[string]interface{})
+					return input["name"].(string) + ":" + read(input["file"]), nil
+				},
+			},
+		}}),
+	})
+	h := handler.New(&handler.Config{Schema: &schema})
+
+	req := operationsRequest(`{"query": "mutation($files: [Upload!]!) { upload(files: $files) }", "variables": {"files": [null, null]}}`,
+		`{"0": ["variables.files.0"], "1": ["variables.files.1"]}`, "first", "second")
+	result, resp := executeTest(t, h, req)
+	if expected := map[string]interface{}{"upload": []interface{}{"first", "second"}}; resp.Code != http.StatusOK || !reflect.DeepEqual(result.Data, expected) {
+		t.Fatalf("unexpected response %d %+v", resp.Code, result)
+	}
+	req = operationsRequest(`{"query": "mutation($input: AttachInput!) { attach(input: $input) }", "variables": {"input": {"name": "doc", "file": null}}}`,
+		`{"0": ["variables.input.file"]}`, "content")
+	result, resp = executeTest(t, h, req)
+	if expected := map[string]interface{}{"attach": "doc:content"}; resp.Code != http.StatusOK || !reflect.DeepEqual(result.Data, expected) {
+		t.Fatalf("unexpected response %d %+v", resp.Code, result)
+	}
+}
+
+func TestUploadScalar_OwnScalar(t *testing.T) {
+	// a schema with its own Upload scalar receives the multipart keys
+	upload := graphql.NewScalar(graphql.ScalarConfig{
+		Name:         "Upload",
+		Serialize:    func(value interface{}) interface{} { return value },
+		ParseValue:   func(value interface{}) interface{} { return value },
+		ParseLiteral: func(valueAST ast.Value) interface{} { return nil },
+	})
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"ok": &graphql.Field{Type: graphql.Boolean},
+		}}),
+		Mutation: graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: graphql.Fields{
+			"upload": &graphql.Field{
+				Type: graphql.String,
+				Args: graphql.FieldConfigArgument{"file": &graphql.ArgumentConfig{Type: upload}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Args["file"], nil
+				},
+			},
+		}}),
+	})
+	h := handler.New(&handler.Config{Schema: &schema})
+	req := operationsRequest(`{"query": "mutation($file: Upload) { upload(file: $file) }", "variables": {"file": null}}`,
+		`{"0": ["variables.file"]}`, "content")
+	result, _ := executeTest(t, h, req)
+	if expected := map[string]interface{}{"upload": "file"}; !reflect.DeepEqual(result.Data, expected) {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
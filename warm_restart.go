@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultWarmRestartKey is the entry WarmRestartConfig keeps the state in
+const DefaultWarmRestartKey = "graphql-handler-state"
+
+// DefaultWarmRestartTTL is how long a saved state can be restored
+const DefaultWarmRestartTTL = 5 * time.Minute
+
+// WarmRestartConfig hands the transient state of a handler over to the next
+// process of a rolling restart: Shutdown saves it to Store once the
+// operations in flight finished and New restores it. The state is the
+// client buckets of the IPRateLimiter and of the policy tiers, and the
+// responses of the in-memory response and introspection caches. Responses
+// are only restored for the same schemas. Registered operations are not
+// part of it, the process registers them at startup
+type WarmRestartConfig struct {
+	// Store keeps the state between the processes, e.g. rediscache
+	Store CacheStore
+	// Key is the entry of the state, DefaultWarmRestartKey when empty.
+	// Replicas sharing a Store each need their own
+	Key string
+	// TTL is how long the saved state can be restored,
+	// DefaultWarmRestartTTL when zero
+	TTL time.Duration
+}
+
+func (c *WarmRestartConfig) key() string {
+	if c.Key == "" {
+		return DefaultWarmRestartKey
+	}
+	return c.Key
+}
+
+// handlerState is the state WarmRestartConfig hands over
+type handlerState struct {
+	// Schemas fingerprints the schemas the responses were cached for
+	Schemas   string                            `json:"schemas"`
+	Limiters  map[string]map[string]bucketState `json:"limiters,omitempty"`
+	Responses map[string][]lruState             `json:"responses,omitempty"`
+}
+
+// stateLimiters returns the limiters with a state by name
+func (h *Handler) stateLimiters() map[string]*IPRateLimiter {
+	limiters := map[string]*IPRateLimiter{}
+	if limiter, ok := h.rateLimiter.(*IPRateLimiter); ok {
+		limiters["rate"] = limiter
+	}
+	for name, limiter := range h.policies.limiters() {
+		limiters["tier:"+name] = limiter
+	}
+	return limiters
+}
+
+// stateCaches returns the in-memory caches by name
+func (h *Handler) stateCaches() map[string]*LRUCache {
+	caches := map[string]*LRUCache{}
+	if h.responseCache != nil {
+		if store, ok := h.responseCache.Store.(*LRUCache); ok {
+			caches["responses"] = store
+		}
+	}
+	if h.introspectionCache != nil {
+		if store, ok := h.introspectionCache.Store.(*LRUCache); ok {
+			caches["introspection"] = store
+		}
+	}
+	return caches
+}
+
+// schemasFingerprint identifies the current schemas, cached responses are
+// keyed by the schema generation the next process starts over
+func (h *Handler) schemasFingerprint() string {
+	version := h.currentSchema()
+	sum := sha256.New()
+	fmt.Fprintf(sum, "%d\x00", version.generation)
+	if version.schema != nil {
+		fmt.Fprintf(sum, "%s\x00", PrintSchema(version.schema))
+	}
+	names := make([]string, 0, len(version.named))
+	for name := range version.named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(sum, "%s\x00%s\x00", name, PrintSchema(version.named[name]))
+	}
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// saveState saves the state of h to the store of the warm restart
+func (h *Handler) saveState(ctx context.Context) error {
+	state := handlerState{
+		Schemas:   h.schemasFingerprint(),
+		Limiters:  map[string]map[string]bucketState{},
+		Responses: map[string][]lruState{},
+	}
+	for name, limiter := range h.stateLimiters() {
+		state.Limiters[name] = limiter.snapshot()
+	}
+	for name, cache := range h.stateCaches() {
+		state.Responses[name] = cache.snapshot()
+	}
+	buff, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	ttl := h.warmRestart.TTL
+	if ttl <= 0 {
+		ttl = DefaultWarmRestartTTL
+	}
+	h.warmRestart.Store.Set(ctx, h.warmRestart.key(), buff, ttl)
+	return nil
+}
+
+// restoreState restores the state a previous process saved, a missing
+// state is no error
+func (h *Handler) restoreState(ctx context.Context) error {
+	buff, ok := h.warmRestart.Store.Get(ctx, h.warmRestart.key())
+	if !ok {
+		return nil
+	}
+	var state handlerState
+	if err := json.Unmarshal(buff, &state); err != nil {
+		return fmt.Errorf("invalid warm restart state: %v", err)
+	}
+	for name, limiter := range h.stateLimiters() {
+		limiter.restore(state.Limiters[name])
+	}
+	if state.Schemas != h.schemasFingerprint() {
+		// the responses of other schemas are never served
+		return nil
+	}
+	for name, cache := range h.stateCaches() {
+		cache.restore(state.Responses[name])
+	}
+	return nil
+}
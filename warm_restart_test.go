@@ -0,0 +1,70 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_WarmRestart(t *testing.T) {
+	state := handler.NewLRUCache(10)
+	calls := 0
+	schema := countingSchema(t, &calls)
+	start := func(schema *graphql.Schema) (*handler.Handler, *handler.LRUCache) {
+		responses := handler.NewLRUCache(10)
+		return handler.New(&handler.Config{
+			Schema:        schema,
+			ResponseCache: &handler.ResponseCacheConfig{Store: responses},
+			RateLimiter:   handler.NewIPRateLimiter(0.001, 1),
+			WarmRestart:   &handler.WarmRestartConfig{Store: state},
+		}), responses
+	}
+	do := func(h *handler.Handler, client string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/graphql?query={count}", nil)
+		req.RemoteAddr = client + ":1234"
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+
+	h, _ := start(schema)
+	if resp := do(h, "10.0.0.1"); resp.Code != http.StatusOK || calls != 1 {
+		t.Fatalf("unexpected response %d after %d calls", resp.Code, calls)
+	}
+	if err := h.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the next process keeps the bucket of the client and the response
+	h, responses := start(schema)
+	if responses.Len() != 1 {
+		t.Fatalf("restored %d responses", responses.Len())
+	}
+	if resp := do(h, "10.0.0.1"); resp.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the restored bucket to reject the client, got %d", resp.Code)
+	}
+	if resp := do(h, "10.0.0.2"); resp.Code != http.StatusOK || calls != 1 {
+		t.Fatalf("expected the restored response, got %d after %d calls", resp.Code, calls)
+	}
+	if err := h.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the responses of another schema are dropped
+	other, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"count": &graphql.Field{Type: graphql.String},
+		}}),
+	})
+	h, responses = start(&other)
+	if responses.Len() != 0 {
+		t.Fatalf("restored %d responses of another schema", responses.Len())
+	}
+	if resp := do(h, "10.0.0.1"); resp.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the restored bucket to reject the client, got %d", resp.Code)
+	}
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// Watchdog cancels executions running longer than Ceiling and reports them
+// with a dump of all goroutines, to find the resolvers they are stuck in
+type Watchdog struct {
+	// Ceiling is the longest an execution may run
+	Ceiling time.Duration
+	// DumpInterval is the shortest time between two goroutine dumps,
+	// reports in between carry none, defaults to a minute
+	DumpInterval time.Duration
+	ReportFn     func(report WatchdogReport)
+
+	mu       sync.Mutex
+	lastDump time.Time
+}
+
+// WatchdogReport describes an execution the watchdog cancelled
+type WatchdogReport struct {
+	Operation string
+	Query     string
+	Elapsed   time.Duration
+	// Goroutines is the stack dump of all goroutines, nil when rate limited
+	Goroutines []byte
+}
+
+// dump returns the stacks of all goroutines unless one was taken within
+// DumpInterval
+func (wd *Watchdog) dump() []byte {
+	interval := wd.DumpInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	wd.mu.Lock()
+	if !wd.lastDump.IsZero() && time.Since(wd.lastDump) < interval {
+		wd.mu.Unlock()
+		return nil
+	}
+	wd.lastDump = time.Now()
+	wd.mu.Unlock()
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= 64<<20 {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// execute runs params through do under the watchdog, an execution exceeding the
+// ceiling is cancelled and answered with an error right away
+func (wd *Watchdog) execute(ctx context.Context, params graphql.Params, opts *RequestOptions, do func(graphql.Params) *graphql.Result) *graphql.Result {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	params.Context = ctx
+	started := time.Now()
+	done := make(chan *graphql.Result, 1)
+	go func() {
+		// a panic would crash the server, nothing up this goroutine's
+		// stack recovers it
+		defer func() {
+			if r := recover(); r != nil {
+				done <- &graphql.Result{Errors: gqlerrors.FormatErrors(fmt.Errorf("execution panicked: %v", r))}
+			}
+		}()
+		done <- do(params)
+	}()
+	timer := time.NewTimer(wd.Ceiling)
+	defer timer.Stop()
+	select {
+	case result := <-done:
+		return result
+	case <-timer.C:
+	}
+	// dump before cancelling, the stuck resolvers are still on their stacks
+	report := WatchdogReport{
+		Operation:  operationKey(opts),
+		Query:      opts.Query,
+		Goroutines: wd.dump(),
+	}
+	cancel()
+	report.Elapsed = time.Since(started)
+	if wd.ReportFn != nil {
+		wd.ReportFn(report)
+	}
+	return &graphql.Result{Errors: []gqlerrors.FormattedError{
+		gqlerrors.NewFormattedError(fmt.Sprintf("execution exceeded %v and was cancelled", wd.Ceiling)),
+	}}
+}
@@ -0,0 +1,66 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cxuhua/handler"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func stuckResolver(p graphql.ResolveParams) (interface{}, error) {
+	<-p.Context.Done()
+	return nil, p.Context.Err()
+}
+
+func TestHandler_Watchdog(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+			"stuck": &graphql.Field{Type: graphql.String, Resolve: stuckResolver},
+		}}),
+	})
+	reports := make(chan handler.WatchdogReport, 2)
+	h := handler.New(&handler.Config{
+		Schema: &schema,
+		Watchdog: &handler.Watchdog{
+			Ceiling:  20 * time.Millisecond,
+			ReportFn: func(r handler.WatchdogReport) { reports <- r },
+		},
+	})
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "/graphql?query={stuck}", nil)
+		result, _ := executeTest(t, h, req)
+		if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, "exceeded") {
+			t.Fatalf("unexpected result %v", result)
+		}
+	}
+	first, second := <-reports, <-reports
+	if first.Query != "{stuck}" || first.Elapsed < 20*time.Millisecond {
+		t.Fatalf("unexpected report %+v", first)
+	}
+	if !strings.Contains(string(first.Goroutines), "stuckResolver") {
+		t.Fatalf("goroutine dump misses the stuck resolver:\n%s", first.Goroutines)
+	}
+	if second.Goroutines != nil {
+		t.Fatal("goroutine dumps were not rate limited")
+	}
+}
+
+func TestHandler_Watchdog_Panic(t *testing.T) {
+	h := handler.New(&handler.Config{
+		Schema:   &testutil.StarWarsSchema,
+		Watchdog: &handler.Watchdog{Ceiling: time.Second},
+		Executor: handler.ExecutorFunc(func(ctx context.Context, params graphql.Params) *graphql.Result {
+			panic("executor failed")
+		}),
+	})
+	req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}", nil)
+	result, _ := executeTest(t, h, req)
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, "executor failed") {
+		t.Fatalf("unexpected result %v", result)
+	}
+}
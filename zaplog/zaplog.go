@@ -0,0 +1,42 @@
+// Package zaplog reports the events of the handler to a zap logger, see
+// handler.Config.Logger
+package zaplog
+
+import (
+	"context"
+
+	"github.com/cxuhua/handler"
+	"go.uber.org/zap"
+)
+
+// Logger is the handler.Logger writing to a zap logger
+type Logger struct {
+	sugar *zap.SugaredLogger
+}
+
+var _ handler.Logger = Logger{}
+
+// New returns the handler.Logger writing to l
+func New(l *zap.Logger) Logger {
+	return Logger{sugar: l.Sugar()}
+}
+
+// DebugContext implements handler.Logger
+func (l Logger) DebugContext(_ context.Context, msg string, args ...interface{}) {
+	l.sugar.Debugw(msg, args...)
+}
+
+// InfoContext implements handler.Logger
+func (l Logger) InfoContext(_ context.Context, msg string, args ...interface{}) {
+	l.sugar.Infow(msg, args...)
+}
+
+// WarnContext implements handler.Logger
+func (l Logger) WarnContext(_ context.Context, msg string, args ...interface{}) {
+	l.sugar.Warnw(msg, args...)
+}
+
+// ErrorContext implements handler.Logger
+func (l Logger) ErrorContext(_ context.Context, msg string, args ...interface{}) {
+	l.sugar.Errorw(msg, args...)
+}
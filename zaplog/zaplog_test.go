@@ -0,0 +1,31 @@
+package zaplog_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/cxuhua/handler/zaplog"
+	"github.com/graphql-go/graphql/testutil"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	h := handler.New(&handler.Config{
+		Schema: &testutil.StarWarsSchema,
+		Logger: zaplog.New(zap.New(core)),
+	})
+	req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}&variables=[1]", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	entries := logs.All()
+	if len(entries) != 1 || entries[0].Level != zapcore.WarnLevel || entries[0].Message != "graphql request rejected" {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+	if fields := entries[0].ContextMap(); fields["status"] != int64(400) || fields["path"] != "/graphql" {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+}
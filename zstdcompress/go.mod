@@ -0,0 +1,12 @@
+module github.com/cxuhua/handler/zstdcompress
+
+go 1.18
+
+replace github.com/cxuhua/handler => ../
+
+require (
+	github.com/cxuhua/handler v0.0.0-00010101000000-000000000000
+	github.com/klauspost/compress v1.17.0
+)
+
+require github.com/graphql-go/graphql v0.7.9
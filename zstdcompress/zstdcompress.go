@@ -0,0 +1,69 @@
+// Package zstdcompress compresses the responses of a handler.Handler with
+// zstd, using the pre-trained dictionary of the operation when it has one.
+// Small responses of hot operations share most of their bytes with the
+// dictionary, clients decode them with the same dictionaries, which the
+// frames name by their ID:
+//
+//	compressor, err := zstdcompress.New(map[string][]byte{
+//		operations.MustRegister("Feed", feedQuery): feedDict,
+//	})
+//	h := handler.New(&handler.Config{Schema: &schema, Compressor: compressor})
+package zstdcompress
+
+import (
+	"fmt"
+
+	"github.com/cxuhua/handler"
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultMinSize is the size below which Compressors send bodies
+// uncompressed
+const DefaultMinSize = 64
+
+// Compressor is a handler.Compressor encoding zstd
+type Compressor struct {
+	// MinSize is the size below which bodies are sent uncompressed
+	MinSize int
+
+	plain *zstd.Encoder
+	dicts map[string]*zstd.Encoder
+}
+
+var _ handler.Compressor = (*Compressor)(nil)
+
+// New returns a Compressor compressing the responses to the operations
+// dicts has a zstd dictionary for with it, by the hash of the operation
+// handler.OperationRegistry.Register returns
+func New(dicts map[string][]byte) (*Compressor, error) {
+	plain, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, err
+	}
+	c := &Compressor{MinSize: DefaultMinSize, plain: plain, dicts: make(map[string]*zstd.Encoder, len(dicts))}
+	for operation, dict := range dicts {
+		encoder, err := zstd.NewWriter(nil, zstd.WithEncoderDict(dict))
+		if err != nil {
+			return nil, fmt.Errorf("dictionary of operation %s: %v", operation, err)
+		}
+		c.dicts[operation] = encoder
+	}
+	return c, nil
+}
+
+// Encoding implements handler.Compressor
+func (c *Compressor) Encoding() string {
+	return "zstd"
+}
+
+// Compress implements handler.Compressor
+func (c *Compressor) Compress(operation string, body []byte) ([]byte, error) {
+	if len(body) < c.MinSize {
+		return nil, nil
+	}
+	encoder, ok := c.dicts[operation]
+	if !ok {
+		encoder = c.plain
+	}
+	return encoder.EncodeAll(body, make([]byte, 0, len(body)/2)), nil
+}
@@ -0,0 +1,74 @@
+package zstdcompress_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cxuhua/handler"
+	"github.com/cxuhua/handler/zstdcompress"
+	"github.com/graphql-go/graphql/testutil"
+	"github.com/klauspost/compress/dict"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestCompressor(t *testing.T) {
+	// the samples a dictionary is trained on share the shape of the responses
+	var samples [][]byte
+	names := []string{"Luke Skywalker", "Darth Vader", "Han Solo", "Leia Organa", "Wilhuff Tarkin"}
+	planets := []string{"Tatooine", "Alderaan", "Corellia", "Eriadu"}
+	for i := 0; i < 256; i++ {
+		samples = append(samples, []byte(`{"data":{"human":{"id":"`+strconv.Itoa(1000+i*37)+`","name":"`+names[i%len(names)]+
+			`","homePlanet":"`+planets[i%len(planets)]+`","friends":[{"name":"`+names[(i+1)%len(names)]+`"},{"name":"`+names[(i*7)%len(names)]+`"}]}}}`))
+	}
+	humanDict, err := dict.BuildZstdDict(samples, dict.Options{MaxDictSize: 4096, HashBytes: 6, ZstdDictID: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	operations := handler.NewOperationRegistry(false)
+	hash := operations.MustRegister("Human", `query Human { human(id: "1000") { id name homePlanet friends { name } } }`)
+	compressor, err := zstdcompress.New(map[string][]byte{hash: humanDict})
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressor.MinSize = 0
+	h := handler.New(&handler.Config{Schema: &testutil.StarWarsSchema, Operations: operations, Compressor: compressor})
+	do := func(query url.Values) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/graphql?"+query.Encode(), nil)
+		req.Header.Set("Accept-Encoding", "gzip, zstd")
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		if resp.Header().Get("Content-Encoding") != "zstd" {
+			t.Fatalf("unexpected headers %v", resp.Header())
+		}
+		return resp
+	}
+
+	withDict := do(url.Values{"operationName": {"Human"}})
+	decoder, _ := zstd.NewReader(nil, zstd.WithDecoderDicts(humanDict))
+	body, err := decoder.DecodeAll(withDict.Body.Bytes(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), `"homePlanet":"Tatooine"`) {
+		t.Fatalf("unexpected body %s", body)
+	}
+	// other operations are compressed without a dictionary
+	plain := do(url.Values{"query": {`{ human(id: "1000") { id name homePlanet friends { name } } }`}})
+	decoder, _ = zstd.NewReader(nil)
+	if other, err := decoder.DecodeAll(plain.Body.Bytes(), nil); err != nil || string(other) != string(body) {
+		t.Fatalf("unexpected body %s: %v", other, err)
+	}
+	if withDict.Body.Len() >= plain.Body.Len() {
+		t.Fatalf("expected the dictionary to shrink the response, %d >= %d bytes", withDict.Body.Len(), plain.Body.Len())
+	}
+}
+
+func TestNew_InvalidDictionary(t *testing.T) {
+	if _, err := zstdcompress.New(map[string][]byte{"hash": []byte("not a dictionary")}); err == nil {
+		t.Fatal("expected an invalid dictionary to be rejected")
+	}
+}